@@ -316,7 +316,8 @@ func NewAtlasApp(
 
 	app.ValidationKeeper = validationkeeper.NewKeeper(
 		appCodec, keys[validationtypes.StoreKey], keys[validationtypes.MemStoreKey],
-		app.TrainingKeeper, app.ShardingKeeper,
+		app.TrainingKeeper, app.ShardingKeeper, app.ComputeKeeper, app.HealthKeeper,
+		app.BankKeeper, app.RewardKeeper,
 	)
 
 	app.mm = module.NewManager(
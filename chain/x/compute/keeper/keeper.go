@@ -4,6 +4,7 @@ import (
 	"github.com/cosmos/cosmos-sdk/codec"
 	storetypes "github.com/cosmos/cosmos-sdk/store/types"
 	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
 	bankkeeper "github.com/cosmos/cosmos-sdk/x/bank/keeper"
 
 	"github.com/atlas/chain/x/compute/types"
@@ -43,6 +44,28 @@ func (k Keeper) GetNode(ctx sdk.Context, id string) (types.Node, bool) {
 	return node, true
 }
 
+// GetNodePubKey returns the public key a node registered
+func (k Keeper) GetNodePubKey(ctx sdk.Context, id string) (string, error) {
+	node, found := k.GetNode(ctx, id)
+	if !found {
+		return "", sdkerrors.Wrapf(types.ErrNodeNotFound, "node %s not found", id)
+	}
+	if node.PubKey == "" {
+		return "", sdkerrors.Wrapf(types.ErrInvalidPubKey, "node %s has not registered a public key", id)
+	}
+	return node.PubKey, nil
+}
+
+// VerifyNodeSignature checks that a node signed message in domain with its
+// registered key
+func (k Keeper) VerifyNodeSignature(ctx sdk.Context, id string, domain string, message []byte, signature string) error {
+	pubKey, err := k.GetNodePubKey(ctx, id)
+	if err != nil {
+		return err
+	}
+	return types.VerifyNodeSignature(pubKey, domain, message, signature)
+}
+
 func (k Keeper) SetNode(ctx sdk.Context, node types.Node) {
 	store := ctx.KVStore(k.storeKey)
 	bz := k.cdc.MustMarshal(&node)
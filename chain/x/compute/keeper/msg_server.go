@@ -3,6 +3,7 @@ package keeper
 import (
 	"context"
 	"fmt"
+	"time"
 
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
@@ -46,6 +47,20 @@ func (ms MsgServer) RegisterNode(ctx context.Context, msg *types.MsgRegisterNode
 	node.Resources["memory_gb"] = fmt.Sprintf("%d", msg.MemoryGb)
 	node.Resources["storage_gb"] = fmt.Sprintf("%d", msg.StorageGb)
 
+	if msg.Location != nil {
+		if err := msg.Location.Validate(); err != nil {
+			return nil, sdkerrors.Wrap(types.ErrInvalidLocation, err.Error())
+		}
+		node.Location = msg.Location
+	}
+
+	if msg.PubKey != "" {
+		if err := types.ValidatePubKey(msg.PubKey); err != nil {
+			return nil, sdkerrors.Wrap(types.ErrInvalidPubKey, err.Error())
+		}
+		node.PubKey = msg.PubKey
+	}
+
 	ms.Keeper.SetNode(sdkCtx, node)
 
 	sdkCtx.EventManager().EmitEvent(
@@ -71,10 +86,38 @@ func (ms MsgServer) UpdateHeartbeat(ctx context.Context, msg *types.MsgUpdateHea
 		return nil, sdkerrors.Wrapf(types.ErrNodeNotFound, "node %s not found", msg.NodeId)
 	}
 
+	// Only the node itself can report that it is alive, and only once per
+	// signed heartbeat
+	message := types.HeartbeatMessage(msg.NodeId, msg.Timestamp, msg.Utilization)
+	if err := ms.Keeper.VerifyNodeSignature(sdkCtx, msg.NodeId, types.DomainHeartbeat, message, msg.Signature); err != nil {
+		return nil, err
+	}
+	skew := sdkCtx.BlockTime().Sub(time.Unix(msg.Timestamp, 0))
+	if skew > types.MaxHeartbeatSkew || skew < -types.MaxHeartbeatSkew {
+		return nil, sdkerrors.Wrapf(types.ErrStaleHeartbeat, "heartbeat signed %s from block time", skew)
+	}
+	if msg.Timestamp <= node.HeartbeatSignedAt {
+		return nil, sdkerrors.Wrapf(types.ErrStaleHeartbeat, "heartbeat is not newer than the last one")
+	}
+	node.HeartbeatSignedAt = msg.Timestamp
+
+	if msg.Utilization != nil {
+		if err := msg.Utilization.Validate(); err != nil {
+			return nil, sdkerrors.Wrap(types.ErrInvalidUtilization, err.Error())
+		}
+		node.Utilization = msg.Utilization
+	}
+
 	node.LastHeartbeat = sdkCtx.BlockTime()
 	node.Status = "online"
 	ms.Keeper.SetNode(sdkCtx, node)
 
+	attributes := []sdk.Attribute{sdk.NewAttribute(types.AttributeKeyNodeID, node.ID)}
+	if node.Utilization != nil {
+		attributes = append(attributes, sdk.NewAttribute(types.AttributeKeyLoad, fmt.Sprintf("%.1f", node.Utilization.Load())))
+	}
+	sdkCtx.EventManager().EmitEvent(sdk.NewEvent(types.EventTypeHeartbeatUpdated, attributes...))
+
 	return &types.MsgUpdateHeartbeatResponse{}, nil
 }
 
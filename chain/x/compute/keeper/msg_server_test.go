@@ -2,6 +2,8 @@ package keeper
 
 import (
 	"context"
+	"crypto/ed25519"
+	"encoding/base64"
 	"testing"
 	"time"
 
@@ -71,16 +73,63 @@ func TestRegisterNode(t *testing.T) {
 	require.Equal(t, msg.NodeId, node.ID)
 	require.Equal(t, msg.Address, node.Address)
 	require.Equal(t, "online", node.Status)
+	require.Nil(t, node.Location)
 
 	_, err = ms.RegisterNode(sdk.WrapSDKContext(ctx), msg)
 	require.Error(t, err)
 
+	msg.NodeId = "node-2"
+	msg.Location = &types.NodeLocation{CountryCode: "DE", Region: "Hesse", City: "Frankfurt", Latitude: 50.1109, Longitude: 8.6821}
+	_, err = ms.RegisterNode(sdk.WrapSDKContext(ctx), msg)
+	require.NoError(t, err)
+	node, _ = ms.Keeper.GetNode(ctx, "node-2")
+	require.Equal(t, msg.Location, node.Location)
+
+	msg.NodeId = "node-3"
+	msg.Location = &types.NodeLocation{CountryCode: "Germany"}
+	_, err = ms.RegisterNode(sdk.WrapSDKContext(ctx), msg)
+	require.ErrorIs(t, err, types.ErrInvalidLocation)
+	_, found = ms.Keeper.GetNode(ctx, "node-3")
+	require.False(t, found)
+
+	_, err = ms.Keeper.GetNodePubKey(ctx, "node-1")
+	require.ErrorIs(t, err, types.ErrInvalidPubKey)
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	msg.NodeId = "node-4"
+	msg.Location = nil
+	msg.PubKey = base64.StdEncoding.EncodeToString(pub)
+	_, err = ms.RegisterNode(sdk.WrapSDKContext(ctx), msg)
+	require.NoError(t, err)
+	pubKey, err := ms.Keeper.GetNodePubKey(ctx, "node-4")
+	require.NoError(t, err)
+	require.Equal(t, msg.PubKey, pubKey)
+
+	message := []byte("node-4:task-1:hash")
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, append([]byte(types.DomainProof+"\x00"), message...)))
+	require.NoError(t, ms.Keeper.VerifyNodeSignature(ctx, "node-4", types.DomainProof, message, signature))
+	require.ErrorIs(t, ms.Keeper.VerifyNodeSignature(ctx, "node-4", types.DomainHeartbeat, message, signature), types.ErrInvalidSignature)
+
+	msg.NodeId = "node-5"
+	msg.PubKey = base64.StdEncoding.EncodeToString([]byte("short"))
+	_, err = ms.RegisterNode(sdk.WrapSDKContext(ctx), msg)
+	require.ErrorIs(t, err, types.ErrInvalidPubKey)
+
 	_, err = ms.RegisterNode(context.Background(), nil)
 	require.Error(t, err)
 }
 
+// signHeartbeat signs msg as its node would with priv
+func signHeartbeat(priv ed25519.PrivateKey, msg *types.MsgUpdateHeartbeat) {
+	signed := append([]byte(types.DomainHeartbeat+"\x00"), types.HeartbeatMessage(msg.NodeId, msg.Timestamp, msg.Utilization)...)
+	msg.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(priv, signed))
+}
+
 func TestUpdateHeartbeat(t *testing.T) {
 	ms, ctx := setupMsgServer(t)
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
 
 	node := types.Node{
 		ID:            "node-1",
@@ -92,14 +141,17 @@ func TestUpdateHeartbeat(t *testing.T) {
 		LastHeartbeat: ctx.BlockTime().Add(-100 * time.Second),
 		RegisteredAt:  ctx.BlockTime(),
 		ActiveTasks:   []string{},
+		PubKey:        base64.StdEncoding.EncodeToString(pub),
 	}
 
 	ms.Keeper.SetNode(ctx, node)
 
 	msg := &types.MsgUpdateHeartbeat{
-		Creator: "cosmos1abc123",
-		NodeId:  "node-1",
+		Creator:   "cosmos1abc123",
+		NodeId:    "node-1",
+		Timestamp: ctx.BlockTime().Add(-10 * time.Second).Unix(),
 	}
+	signHeartbeat(priv, msg)
 
 	resp, err := ms.UpdateHeartbeat(sdk.WrapSDKContext(ctx), msg)
 	require.NoError(t, err)
@@ -108,6 +160,51 @@ func TestUpdateHeartbeat(t *testing.T) {
 	updatedNode, found := ms.Keeper.GetNode(ctx, "node-1")
 	require.True(t, found)
 	require.Equal(t, "online", updatedNode.Status)
+	require.Nil(t, updatedNode.Utilization)
+
+	// Replaying a heartbeat is rejected
+	_, err = ms.UpdateHeartbeat(sdk.WrapSDKContext(ctx), msg)
+	require.ErrorIs(t, err, types.ErrStaleHeartbeat)
+
+	msg.Timestamp++
+	msg.Utilization = &types.NodeUtilization{CpuPercent: 42.5, MemoryPercent: 60, HasGpu: true, GpuPercent: 80, ActiveTasks: 2, WindowSeconds: 300}
+	signHeartbeat(priv, msg)
+	_, err = ms.UpdateHeartbeat(sdk.WrapSDKContext(ctx), msg)
+	require.NoError(t, err)
+	updatedNode, _ = ms.Keeper.GetNode(ctx, "node-1")
+	require.Equal(t, msg.Utilization, updatedNode.Utilization)
+	require.Equal(t, 80.0, updatedNode.Utilization.Load())
+
+	// The signature covers the utilization
+	msg.Timestamp++
+	signHeartbeat(priv, msg)
+	msg.Utilization = &types.NodeUtilization{CpuPercent: 1}
+	_, err = ms.UpdateHeartbeat(sdk.WrapSDKContext(ctx), msg)
+	require.ErrorIs(t, err, types.ErrInvalidSignature)
+
+	msg.Utilization = &types.NodeUtilization{CpuPercent: 120}
+	signHeartbeat(priv, msg)
+	_, err = ms.UpdateHeartbeat(sdk.WrapSDKContext(ctx), msg)
+	require.ErrorIs(t, err, types.ErrInvalidUtilization)
+	msg.Utilization = nil
+
+	// So do timestamps too far from the block time
+	for _, offset := range []time.Duration{-types.MaxHeartbeatSkew - time.Second, types.MaxHeartbeatSkew + time.Second} {
+		msg.Timestamp = ctx.BlockTime().Add(offset).Unix()
+		signHeartbeat(priv, msg)
+		_, err = ms.UpdateHeartbeat(sdk.WrapSDKContext(ctx), msg)
+		require.ErrorIs(t, err, types.ErrStaleHeartbeat)
+	}
+
+	// Nodes without a registered key cannot send heartbeats
+	node.ID = "node-2"
+	node.PubKey = ""
+	ms.Keeper.SetNode(ctx, node)
+	msg.NodeId = "node-2"
+	msg.Timestamp = ctx.BlockTime().Unix()
+	signHeartbeat(priv, msg)
+	_, err = ms.UpdateHeartbeat(sdk.WrapSDKContext(ctx), msg)
+	require.ErrorIs(t, err, types.ErrInvalidPubKey)
 
 	msg.NodeId = "nonexistent"
 	_, err = ms.UpdateHeartbeat(sdk.WrapSDKContext(ctx), msg)
@@ -117,3 +214,9 @@ func TestUpdateHeartbeat(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestHeartbeatMessage(t *testing.T) {
+	require.Equal(t, "node-1\n1700000000", string(types.HeartbeatMessage("node-1", 1700000000, nil)))
+	utilization := &types.NodeUtilization{CpuPercent: 42.5, MemoryPercent: 60, HasGpu: true, GpuPercent: 80, ActiveTasks: 2, WindowSeconds: 300}
+	require.Equal(t, "node-1\n1700000000\n42.5 60 0 80 true 2 300", string(types.HeartbeatMessage("node-1", 1700000000, utilization)))
+}
+
@@ -18,10 +18,33 @@ func (k Keeper) UpdateReputation(ctx sdk.Context, nodeID string, uptimePercent f
 	if uptimePercent < 50.0 {
 		node.Reputation *= 0.5
 	}
+	node.Reputation = applyPenalty(node.Reputation, node.Penalty)
 	
 	k.SetNode(ctx, node)
 }
 
+// PenalizeNode lowers a node's reputation by penalty. Penalties accumulate
+// and keep applying when the reputation is recomputed from uptime.
+func (k Keeper) PenalizeNode(ctx sdk.Context, nodeID string, penalty float64) {
+	node, found := k.GetNode(ctx, nodeID)
+	if !found || penalty <= 0 {
+		return
+	}
+
+	node.Penalty += penalty
+	node.Reputation = applyPenalty(node.Reputation, penalty)
+
+	k.SetNode(ctx, node)
+}
+
+func applyPenalty(reputation float64, penalty float64) float64 {
+	reputation -= penalty
+	if reputation < 0 {
+		return 0
+	}
+	return reputation
+}
+
 func (k Keeper) GetNodeReputation(ctx sdk.Context, nodeID string) float64 {
 	node, found := k.GetNode(ctx, nodeID)
 	if !found {
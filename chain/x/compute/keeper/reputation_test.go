@@ -77,6 +77,38 @@ func TestUpdateReputation(t *testing.T) {
 	k.UpdateReputation(ctx, "nonexistent", 99.0)
 }
 
+func TestPenalizeNode(t *testing.T) {
+	k, ctx := setupReputationKeeper(t)
+
+	node := types.Node{
+		ID:            "node-1",
+		Address:       "cosmos1abc123",
+		Status:        "online",
+		Resources:     make(map[string]string),
+		Reputation:    90.0,
+		UptimePercent: 90.0,
+		LastHeartbeat: time.Now(),
+		RegisteredAt:  time.Now(),
+		ActiveTasks:   []string{},
+	}
+
+	k.SetNode(ctx, node)
+
+	k.PenalizeNode(ctx, "node-1", 20.0)
+	require.Equal(t, 70.0, k.GetNodeReputation(ctx, "node-1"))
+
+	// The penalty outlives the next uptime update
+	k.UpdateReputation(ctx, "node-1", 95.0)
+	require.Equal(t, 75.0, k.GetNodeReputation(ctx, "node-1"))
+
+	k.PenalizeNode(ctx, "node-1", 100.0)
+	require.Equal(t, 0.0, k.GetNodeReputation(ctx, "node-1"))
+	k.UpdateReputation(ctx, "node-1", 95.0)
+	require.Equal(t, 0.0, k.GetNodeReputation(ctx, "node-1"))
+
+	k.PenalizeNode(ctx, "nonexistent", 20.0)
+}
+
 func TestGetNodeReputation(t *testing.T) {
 	k, ctx := setupReputationKeeper(t)
 
@@ -8,6 +8,11 @@ var (
 	ErrNodeNotFound = sdkerrors.Register(ModuleName, 1, "node not found")
 	ErrNodeExists   = sdkerrors.Register(ModuleName, 2, "node already exists")
 	ErrInvalidNode  = sdkerrors.Register(ModuleName, 3, "invalid node")
+	ErrInvalidUtilization = sdkerrors.Register(ModuleName, 4, "invalid utilization")
+	ErrInvalidLocation    = sdkerrors.Register(ModuleName, 5, "invalid location")
+	ErrInvalidPubKey      = sdkerrors.Register(ModuleName, 6, "invalid public key")
+	ErrInvalidSignature   = sdkerrors.Register(ModuleName, 7, "invalid signature")
+	ErrStaleHeartbeat     = sdkerrors.Register(ModuleName, 8, "stale heartbeat")
 )
 
 const (
@@ -16,4 +21,5 @@ const (
 	
 	AttributeKeyNodeID  = "node_id"
 	AttributeKeyAddress = "address"
+	AttributeKeyLoad    = "load"
 )
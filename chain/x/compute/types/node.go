@@ -2,6 +2,8 @@ package types
 
 import (
 	"fmt"
+	"math"
+	"strings"
 	"time"
 )
 
@@ -15,6 +17,14 @@ type Node struct {
 	LastHeartbeat   time.Time         `json:"last_heartbeat"`
 	RegisteredAt    time.Time         `json:"registered_at"`
 	ActiveTasks     []string          `json:"active_tasks"`
+	Utilization     *NodeUtilization  `json:"utilization,omitempty"` // Last reported in a heartbeat
+	Location        *NodeLocation     `json:"location,omitempty"`
+	PubKey          string            `json:"pub_key,omitempty"` // Base64 ed25519 key the node signs its messages with
+	Penalty         float64           `json:"penalty,omitempty"` // Deducted from reputation for results found to be wrong
+
+	// HeartbeatSignedAt is the signed Unix time of the last accepted
+	// heartbeat; older ones are replays
+	HeartbeatSignedAt int64 `json:"heartbeat_signed_at,omitempty"`
 }
 
 func (n Node) Validate() error {
@@ -27,3 +37,59 @@ func (n Node) Validate() error {
 	return nil
 }
 
+
+// Validate checks that the percentages are in range
+func (u NodeUtilization) Validate() error {
+	for name, value := range map[string]float64{
+		"cpu":    u.CpuPercent,
+		"memory": u.MemoryPercent,
+		"disk":   u.DiskPercent,
+		"gpu":    u.GpuPercent,
+	} {
+		if value < 0 || value > 100 {
+			return fmt.Errorf("%s utilization %.1f is not between 0 and 100", name, value)
+		}
+	}
+	return nil
+}
+
+// Load returns how busy the node is from 0 to 100: the busiest of its CPU,
+// memory and, if it has one, GPU
+func (u NodeUtilization) Load() float64 {
+	load := u.CpuPercent
+	if u.MemoryPercent > load {
+		load = u.MemoryPercent
+	}
+	if u.HasGpu && u.GpuPercent > load {
+		load = u.GpuPercent
+	}
+	return load
+}
+
+// Validate checks the country code and coordinates
+func (l NodeLocation) Validate() error {
+	if l.CountryCode != "" && (len(l.CountryCode) != 2 || strings.ToUpper(l.CountryCode) != l.CountryCode) {
+		return fmt.Errorf("country code %q is not a two-letter ISO code", l.CountryCode)
+	}
+	if l.Latitude < -90 || l.Latitude > 90 || l.Longitude < -180 || l.Longitude > 180 {
+		return fmt.Errorf("coordinates %.4f, %.4f are out of range", l.Latitude, l.Longitude)
+	}
+	return nil
+}
+
+// HasCoordinates reports whether the location has coordinates. 0, 0 is
+// taken to mean unknown.
+func (l NodeLocation) HasCoordinates() bool {
+	return l.Latitude != 0 || l.Longitude != 0
+}
+
+// DistanceKm is the great-circle distance between two locations with
+// coordinates
+func (l NodeLocation) DistanceKm(other NodeLocation) float64 {
+	const earthRadiusKm = 6371
+	lat1, lat2 := l.Latitude*math.Pi/180, other.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (other.Longitude - l.Longitude) * math.Pi / 180
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
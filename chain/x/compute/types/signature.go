@@ -0,0 +1,75 @@
+package types
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+// Domains of the messages nodes sign. A signature covers the domain, a NUL
+// byte and the message, as made by the node's storage/identity package.
+const (
+	DomainHeartbeat  = "atlas/heartbeat/v1"
+	DomainCheckpoint = "atlas/checkpoint/v1"
+	DomainProof      = "atlas/proof/v1"
+	DomainGradients  = "atlas/gradients/v1"
+	DomainSegment    = "atlas/segment/v1"
+)
+
+// MaxHeartbeatSkew is how far a heartbeat's signed timestamp may be from the
+// block time
+const MaxHeartbeatSkew = 5 * time.Minute
+
+// HeartbeatMessage is what nodes sign with DomainHeartbeat: the node ID,
+// the Unix timestamp and, if reported, the utilization fields, one per line
+// and space-separated respectively
+func HeartbeatMessage(nodeID string, timestamp int64, utilization *NodeUtilization) []byte {
+	lines := []string{nodeID, strconv.FormatInt(timestamp, 10)}
+	if u := utilization; u != nil {
+		lines = append(lines, strings.Join([]string{
+			strconv.FormatFloat(u.CpuPercent, 'g', -1, 64),
+			strconv.FormatFloat(u.MemoryPercent, 'g', -1, 64),
+			strconv.FormatFloat(u.DiskPercent, 'g', -1, 64),
+			strconv.FormatFloat(u.GpuPercent, 'g', -1, 64),
+			strconv.FormatBool(u.HasGpu),
+			strconv.FormatUint(uint64(u.ActiveTasks), 10),
+			strconv.FormatUint(uint64(u.WindowSeconds), 10),
+		}, " "))
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// ValidatePubKey checks that pubKey is a base64-encoded ed25519 public key
+func ValidatePubKey(pubKey string) error {
+	key, err := base64.StdEncoding.DecodeString(pubKey)
+	if err != nil {
+		return fmt.Errorf("public key is not base64: %w", err)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("public key is %d bytes, not an ed25519 key", len(key))
+	}
+	return nil
+}
+
+// VerifyNodeSignature checks a node's base64-encoded signature of message
+// in domain against its public key
+func VerifyNodeSignature(pubKey string, domain string, message []byte, signature string) error {
+	if err := ValidatePubKey(pubKey); err != nil {
+		return sdkerrors.Wrap(ErrInvalidPubKey, err.Error())
+	}
+	key, _ := base64.StdEncoding.DecodeString(pubKey)
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return ErrInvalidSignature
+	}
+	signed := append(append([]byte(domain), 0), message...)
+	if !ed25519.Verify(ed25519.PublicKey(key), signed, sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -15,15 +15,31 @@ import (
 )
 
 type MsgRegisterNode struct {
-	Creator   string `protobuf:"bytes,1,opt,name=creator,proto3" json:"creator,omitempty"`
-	NodeId    string `protobuf:"bytes,2,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
-	Address   string `protobuf:"bytes,3,opt,name=address,proto3" json:"address,omitempty"`
-	CpuCores  int32  `protobuf:"varint,4,opt,name=cpu_cores,json=cpuCores,proto3" json:"cpu_cores,omitempty"`
-	GpuCount  int32  `protobuf:"varint,5,opt,name=gpu_count,json=gpuCount,proto3" json:"gpu_count,omitempty"`
-	MemoryGb  int32  `protobuf:"varint,6,opt,name=memory_gb,json=memoryGb,proto3" json:"memory_gb,omitempty"`
-	StorageGb int32  `protobuf:"varint,7,opt,name=storage_gb,json=storageGb,proto3" json:"storage_gb,omitempty"`
+	Creator   string        `protobuf:"bytes,1,opt,name=creator,proto3" json:"creator,omitempty"`
+	NodeId    string        `protobuf:"bytes,2,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
+	Address   string        `protobuf:"bytes,3,opt,name=address,proto3" json:"address,omitempty"`
+	CpuCores  int32         `protobuf:"varint,4,opt,name=cpu_cores,json=cpuCores,proto3" json:"cpu_cores,omitempty"`
+	GpuCount  int32         `protobuf:"varint,5,opt,name=gpu_count,json=gpuCount,proto3" json:"gpu_count,omitempty"`
+	MemoryGb  int32         `protobuf:"varint,6,opt,name=memory_gb,json=memoryGb,proto3" json:"memory_gb,omitempty"`
+	StorageGb int32         `protobuf:"varint,7,opt,name=storage_gb,json=storageGb,proto3" json:"storage_gb,omitempty"`
+	Location  *NodeLocation `protobuf:"bytes,8,opt,name=location,proto3" json:"location,omitempty"`
+	PubKey    string        `protobuf:"bytes,9,opt,name=pub_key,json=pubKey,proto3" json:"pub_key,omitempty"`
 }
 
+// NodeLocation is where a node is, as declared by its operator or looked
+// up from its IP address
+type NodeLocation struct {
+	CountryCode string  `protobuf:"bytes,1,opt,name=country_code,json=countryCode,proto3" json:"country_code,omitempty"`
+	Region      string  `protobuf:"bytes,2,opt,name=region,proto3" json:"region,omitempty"`
+	City        string  `protobuf:"bytes,3,opt,name=city,proto3" json:"city,omitempty"`
+	Latitude    float64 `protobuf:"fixed64,4,opt,name=latitude,proto3" json:"latitude,omitempty"`
+	Longitude   float64 `protobuf:"fixed64,5,opt,name=longitude,proto3" json:"longitude,omitempty"`
+}
+
+func (m *NodeLocation) Reset()         { *m = NodeLocation{} }
+func (m *NodeLocation) String() string { return proto.CompactTextString(m) }
+func (*NodeLocation) ProtoMessage()    {}
+
 func (m *MsgRegisterNode) Reset()         { *m = MsgRegisterNode{} }
 func (m *MsgRegisterNode) String() string { return proto.CompactTextString(m) }
 func (*MsgRegisterNode) ProtoMessage()    {}
@@ -36,14 +52,33 @@ func (m *MsgRegisterNodeResponse) String() string { return proto.CompactTextStri
 func (*MsgRegisterNodeResponse) ProtoMessage()    {}
 
 type MsgUpdateHeartbeat struct {
-	Creator string `protobuf:"bytes,1,opt,name=creator,proto3" json:"creator,omitempty"`
-	NodeId  string `protobuf:"bytes,2,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
+	Creator     string           `protobuf:"bytes,1,opt,name=creator,proto3" json:"creator,omitempty"`
+	NodeId      string           `protobuf:"bytes,2,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
+	Utilization *NodeUtilization `protobuf:"bytes,3,opt,name=utilization,proto3" json:"utilization,omitempty"`
+	Timestamp   int64            `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Signature   string           `protobuf:"bytes,5,opt,name=signature,proto3" json:"signature,omitempty"`
 }
 
 func (m *MsgUpdateHeartbeat) Reset()         { *m = MsgUpdateHeartbeat{} }
 func (m *MsgUpdateHeartbeat) String() string { return proto.CompactTextString(m) }
 func (*MsgUpdateHeartbeat) ProtoMessage()    {}
 
+// NodeUtilization summarizes a node's load over its sampling window.
+// Percentages range from 0 to 100.
+type NodeUtilization struct {
+	CpuPercent    float64 `protobuf:"fixed64,1,opt,name=cpu_percent,json=cpuPercent,proto3" json:"cpu_percent,omitempty"`
+	MemoryPercent float64 `protobuf:"fixed64,2,opt,name=memory_percent,json=memoryPercent,proto3" json:"memory_percent,omitempty"`
+	DiskPercent   float64 `protobuf:"fixed64,3,opt,name=disk_percent,json=diskPercent,proto3" json:"disk_percent,omitempty"`
+	GpuPercent    float64 `protobuf:"fixed64,4,opt,name=gpu_percent,json=gpuPercent,proto3" json:"gpu_percent,omitempty"`
+	HasGpu        bool    `protobuf:"varint,5,opt,name=has_gpu,json=hasGpu,proto3" json:"has_gpu,omitempty"`
+	ActiveTasks   uint32  `protobuf:"varint,6,opt,name=active_tasks,json=activeTasks,proto3" json:"active_tasks,omitempty"`
+	WindowSeconds uint32  `protobuf:"varint,7,opt,name=window_seconds,json=windowSeconds,proto3" json:"window_seconds,omitempty"`
+}
+
+func (m *NodeUtilization) Reset()         { *m = NodeUtilization{} }
+func (m *NodeUtilization) String() string { return proto.CompactTextString(m) }
+func (*NodeUtilization) ProtoMessage()    {}
+
 type MsgUpdateHeartbeatResponse struct {
 }
 
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
 	"github.com/atlas/chain/x/compute/types"
 	computekeeper "github.com/atlas/chain/x/compute/keeper"
 )
@@ -28,12 +29,20 @@ func (k Keeper) CheckNodeHealth(ctx sdk.Context, nodeID string) (bool, error) {
 	return true, nil
 }
 
-func (k Keeper) UpdateHeartbeat(ctx sdk.Context, nodeID string) error {
+// UpdateHeartbeat records a heartbeat from the node and, if it reported
+// one, its utilization summary
+func (k Keeper) UpdateHeartbeat(ctx sdk.Context, nodeID string, utilization *types.NodeUtilization) error {
 	node, found := k.computeKeeper.GetNode(ctx, nodeID)
 	if !found {
 		return fmt.Errorf("node not found")
 	}
 
+	if utilization != nil {
+		if err := utilization.Validate(); err != nil {
+			return sdkerrors.Wrap(types.ErrInvalidUtilization, err.Error())
+		}
+		node.Utilization = utilization
+	}
 	node.LastHeartbeat = ctx.BlockTime()
 	node.Status = "online"
 	k.computeKeeper.SetNode(ctx, node)
@@ -41,6 +50,16 @@ func (k Keeper) UpdateHeartbeat(ctx sdk.Context, nodeID string) error {
 	return nil
 }
 
+// GetNodeUtilization returns the utilization the node last reported in a
+// heartbeat, or nil if it never did
+func (k Keeper) GetNodeUtilization(ctx sdk.Context, nodeID string) (*types.NodeUtilization, error) {
+	node, found := k.computeKeeper.GetNode(ctx, nodeID)
+	if !found {
+		return nil, fmt.Errorf("node not found")
+	}
+	return node.Utilization, nil
+}
+
 func (k Keeper) GetOfflineNodes(ctx sdk.Context) []types.Node {
 	allNodes := k.computeKeeper.GetAllNodes(ctx)
 	var offlineNodes []types.Node
@@ -95,14 +95,29 @@ func TestUpdateHeartbeat(t *testing.T) {
 	}
 	k.computeKeeper.SetNode(ctx, node)
 
-	err := k.UpdateHeartbeat(ctx, "node-1")
+	err := k.UpdateHeartbeat(ctx, "node-1", nil)
 	require.NoError(t, err)
 
 	updatedNode, found := k.computeKeeper.GetNode(ctx, "node-1")
 	require.True(t, found)
 	require.Equal(t, "online", updatedNode.Status)
+	utilization, err := k.GetNodeUtilization(ctx, "node-1")
+	require.NoError(t, err)
+	require.Nil(t, utilization)
+
+	// The heartbeat record carries the node's utilization summary
+	reported := &computetypes.NodeUtilization{CpuPercent: 42.5, MemoryPercent: 60, ActiveTasks: 2, WindowSeconds: 300}
+	require.NoError(t, k.UpdateHeartbeat(ctx, "node-1", reported))
+	utilization, err = k.GetNodeUtilization(ctx, "node-1")
+	require.NoError(t, err)
+	require.Equal(t, reported, utilization)
+
+	err = k.UpdateHeartbeat(ctx, "node-1", &computetypes.NodeUtilization{MemoryPercent: 101})
+	require.ErrorIs(t, err, computetypes.ErrInvalidUtilization)
+	utilization, _ = k.GetNodeUtilization(ctx, "node-1")
+	require.Equal(t, reported, utilization)
 
-	err = k.UpdateHeartbeat(ctx, "nonexistent")
+	err = k.UpdateHeartbeat(ctx, "nonexistent", nil)
 	require.Error(t, err)
 }
 
@@ -35,6 +35,14 @@ func NewKeeper(
 }
 
 func (k Keeper) SelectNodeForInference(ctx sdk.Context, modelID string, strategy string) (string, error) {
+	return k.SelectNodeNear(ctx, modelID, strategy, nil)
+}
+
+// SelectNodeNear selects a node like SelectNodeForInference, among the
+// online nodes closest to near: those in the same region, else the same
+// country, else all. With the "nearest" strategy it picks the node with
+// the shortest distance to near's coordinates instead.
+func (k Keeper) SelectNodeNear(ctx sdk.Context, modelID string, strategy string, near *computetypes.NodeLocation) (string, error) {
 	nodes := k.computeKeeper.GetAllNodes(ctx)
 	
 	if len(nodes) == 0 {
@@ -52,6 +60,13 @@ func (k Keeper) SelectNodeForInference(ctx sdk.Context, modelID string, strategy
 		return "", fmt.Errorf("no online nodes available")
 	}
 	
+	if near != nil {
+		if strategy == "nearest" {
+			return selectNearest(onlineNodes, *near)
+		}
+		onlineNodes = closestNodes(onlineNodes, *near)
+	}
+	
 	switch strategy {
 	case "round_robin":
 		return k.selectRoundRobin(ctx, onlineNodes)
@@ -81,14 +96,20 @@ func (k Keeper) selectRoundRobin(ctx sdk.Context, nodes []computetypes.Node) (st
 	return selected.ID, nil
 }
 
+// selectLeastLoaded picks the node with the fewest active tasks, breaking
+// ties by the load last reported in its heartbeat. Nodes that have not
+// reported utilization count as fully loaded in a tie.
 func (k Keeper) selectLeastLoaded(ctx sdk.Context, nodes []computetypes.Node) (string, error) {
 	leastLoaded := nodes[0]
 	minTasks := len(nodes[0].ActiveTasks)
+	minLoad := reportedLoad(nodes[0])
 	
 	for _, node := range nodes[1:] {
 		taskCount := len(node.ActiveTasks)
-		if taskCount < minTasks {
+		load := reportedLoad(node)
+		if taskCount < minTasks || (taskCount == minTasks && load < minLoad) {
 			minTasks = taskCount
+			minLoad = load
 			leastLoaded = node
 		}
 	}
@@ -96,6 +117,13 @@ func (k Keeper) selectLeastLoaded(ctx sdk.Context, nodes []computetypes.Node) (s
 	return leastLoaded.ID, nil
 }
 
+func reportedLoad(node computetypes.Node) float64 {
+	if node.Utilization == nil {
+		return 100
+	}
+	return node.Utilization.Load()
+}
+
 func (k Keeper) selectBestReputation(ctx sdk.Context, nodes []computetypes.Node) (string, error) {
 	bestNode := nodes[0]
 	bestReputation := nodes[0].Reputation
@@ -110,6 +138,55 @@ func (k Keeper) selectBestReputation(ctx sdk.Context, nodes []computetypes.Node)
 	return bestNode.ID, nil
 }
 
+// closestNodes keeps the nodes in near's region if there are any, else
+// those in its country, else all of them
+func closestNodes(nodes []computetypes.Node, near computetypes.NodeLocation) []computetypes.Node {
+	if near.CountryCode == "" {
+		return nodes
+	}
+	var country, region []computetypes.Node
+	for _, node := range nodes {
+		if node.Location == nil || node.Location.CountryCode != near.CountryCode {
+			continue
+		}
+		country = append(country, node)
+		if near.Region != "" && node.Location.Region == near.Region {
+			region = append(region, node)
+		}
+	}
+	if len(region) > 0 {
+		return region
+	}
+	if len(country) > 0 {
+		return country
+	}
+	return nodes
+}
+
+// selectNearest picks the node closest to near's coordinates. Without
+// coordinates on either side it falls back to the region and country.
+func selectNearest(nodes []computetypes.Node, near computetypes.NodeLocation) (string, error) {
+	if !near.HasCoordinates() {
+		return closestNodes(nodes, near)[0].ID, nil
+	}
+	nearest := ""
+	minDistance := 0.0
+	for _, node := range nodes {
+		if node.Location == nil || !node.Location.HasCoordinates() {
+			continue
+		}
+		distance := near.DistanceKm(*node.Location)
+		if nearest == "" || distance < minDistance {
+			nearest = node.ID
+			minDistance = distance
+		}
+	}
+	if nearest == "" {
+		return closestNodes(nodes, near)[0].ID, nil
+	}
+	return nearest, nil
+}
+
 func (k Keeper) RecordInferenceRequest(ctx sdk.Context, nodeID string, modelID string, latencyMs int64) {
 	store := ctx.KVStore(k.storeKey)
 	key := []byte(fmt.Sprintf("inference:%s:%s", nodeID, modelID))
@@ -69,8 +69,13 @@ func (k Keeper) GetTask(ctx sdk.Context, id string) (types.Task, bool) {
 	return task, true
 }
 
+// SetTask stores task. New tasks and changes of a task's status or node
+// are queued as task updates for modules acting on them.
 func (k Keeper) SetTask(ctx sdk.Context, task types.Task) {
 	store := ctx.KVStore(k.storeKey)
+	if old, found := k.GetTask(ctx, task.ID); !found || old.Status != task.Status || old.NodeID != task.NodeID {
+		store.Set([]byte("task_update:"+task.ID), []byte{1})
+	}
 	bz := k.cdc.MustMarshal(&task)
 	store.Set([]byte("task:"+task.ID), bz)
 }
@@ -89,3 +94,22 @@ func (k Keeper) IterateTasks(ctx sdk.Context, handler func(task types.Task) (sto
 	}
 }
 
+
+// IterateTaskUpdates calls handler with the IDs of the tasks updated since
+// their update was last deleted
+func (k Keeper) IterateTaskUpdates(ctx sdk.Context, handler func(taskID string) (stop bool)) {
+	store := ctx.KVStore(k.storeKey)
+	iterator := sdk.KVStorePrefixIterator(store, []byte("task_update:"))
+	defer iterator.Close()
+
+	for ; iterator.Valid(); iterator.Next() {
+		if handler(string(iterator.Key()[len("task_update:"):])) {
+			break
+		}
+	}
+}
+
+func (k Keeper) DeleteTaskUpdate(ctx sdk.Context, taskID string) {
+	store := ctx.KVStore(k.storeKey)
+	store.Delete([]byte("task_update:" + taskID))
+}
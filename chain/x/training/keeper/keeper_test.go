@@ -200,3 +200,31 @@ func TestIterateTasks(t *testing.T) {
 	require.Equal(t, 1, count)
 }
 
+func TestTaskUpdates(t *testing.T) {
+	k, ctx := setupKeeper(t)
+
+	updates := func() []string {
+		var ids []string
+		k.IterateTaskUpdates(ctx, func(taskID string) (stop bool) {
+			ids = append(ids, taskID)
+			return false
+		})
+		return ids
+	}
+
+	task := types.Task{ID: "task-1", JobID: "job-1", Status: types.TaskStatusPending}
+	k.SetTask(ctx, task)
+	require.Equal(t, []string{"task-1"}, updates())
+	k.DeleteTaskUpdate(ctx, "task-1")
+
+	// Only changes of the status or node are updates
+	task.Progress = 0.5
+	k.SetTask(ctx, task)
+	require.Empty(t, updates())
+
+	task.NodeID = "node-1"
+	task.Status = types.TaskStatusAssigned
+	k.SetTask(ctx, task)
+	require.Equal(t, []string{"task-1"}, updates())
+}
+
@@ -109,6 +109,15 @@ func (ms MsgServer) UpdateTaskStatus(ctx context.Context, msg *types.MsgUpdateTa
 		return nil, sdkerrors.Wrapf(types.ErrTaskNotFound, "task %s not found", msg.TaskId)
 	}
 
+	// Completing a task takes a proof of computation signed by its node
+	completed := types.TaskStatus(msg.Status) == types.TaskStatusCompleted
+	if completed {
+		if err := ms.Keeper.VerifyProof(sdkCtx, task, msg.Proof); err != nil {
+			return nil, err
+		}
+		task.Proof = msg.Proof
+	}
+
 	task.Status = types.TaskStatus(msg.Status)
 	task.UpdatedAt = sdkCtx.BlockTime()
 	if msg.Progress >= 0 {
@@ -120,13 +129,18 @@ func (ms MsgServer) UpdateTaskStatus(ctx context.Context, msg *types.MsgUpdateTa
 
 	ms.Keeper.SetTask(sdkCtx, task)
 
-	sdkCtx.EventManager().EmitEvent(
-		sdk.NewEvent(
-			types.EventTypeTaskStatusUpdated,
-			sdk.NewAttribute(types.AttributeKeyTaskID, msg.TaskId),
-			sdk.NewAttribute(types.AttributeKeyStatus, msg.Status),
-		),
+	event := sdk.NewEvent(
+		types.EventTypeTaskStatusUpdated,
+		sdk.NewAttribute(types.AttributeKeyTaskID, msg.TaskId),
+		sdk.NewAttribute(types.AttributeKeyStatus, msg.Status),
 	)
+	if completed {
+		event = event.AppendAttributes(
+			sdk.NewAttribute(types.AttributeKeyOutputHash, msg.Proof.OutputHash),
+			sdk.NewAttribute(types.AttributeKeyStateRoot, msg.Proof.StateRoot),
+		)
+	}
+	sdkCtx.EventManager().EmitEvent(event)
 
 	return &types.MsgUpdateTaskStatusResponse{}, nil
 }
@@ -2,6 +2,10 @@ package keeper
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"testing"
 	"time"
 
@@ -171,3 +175,68 @@ func TestUpdateTaskStatus(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestUpdateTaskStatus_CompletionProof(t *testing.T) {
+	ms, ctx := setupMsgServer(t)
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	ms.Keeper.computeKeeper.SetNode(ctx, computetypes.Node{ID: "node-1", Address: "cosmos1abc123", PubKey: base64.StdEncoding.EncodeToString(pub)})
+	ms.Keeper.SetJob(ctx, types.Job{ID: "job-1", DatasetCID: "QmDataset"})
+	ms.Keeper.SetTask(ctx, types.Task{ID: "task-1", JobID: "job-1", NodeID: "node-1", Status: types.TaskStatusInProgress})
+
+	digest := func(data string) string {
+		sum := sha256.Sum256([]byte(data))
+		return hex.EncodeToString(sum[:])
+	}
+	sign := func(proof *types.ComputationProof) *types.ComputationProof {
+		message := append([]byte(computetypes.DomainProof+"\x00"), proof.Digest()...)
+		proof.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(priv, message))
+		return proof
+	}
+	newProof := func() *types.ComputationProof {
+		return &types.ComputationProof{
+			TaskId:       "task-1",
+			NodeId:       "node-1",
+			Timestamp:    1700000000,
+			InputCids:    []string{"QmModel", "QmDataset"},
+			OutputHash:   digest("output"),
+			StateRoot:    digest("states"),
+			SegmentCount: 10,
+			ProofCid:     "QmProof",
+		}
+	}
+	complete := func(proof *types.ComputationProof) error {
+		_, err := ms.UpdateTaskStatus(sdk.WrapSDKContext(ctx), &types.MsgUpdateTaskStatus{
+			Creator:  "cosmos1abc123",
+			TaskId:   "task-1",
+			Status:   string(types.TaskStatusCompleted),
+			Progress: 1,
+			Proof:    proof,
+		})
+		return err
+	}
+
+	require.ErrorIs(t, complete(nil), types.ErrInvalidProof)
+
+	tampered := sign(newProof())
+	tampered.OutputHash = digest("other output")
+	require.ErrorIs(t, complete(tampered), types.ErrInvalidProof)
+
+	otherNode := newProof()
+	otherNode.NodeId = "node-2"
+	require.ErrorIs(t, complete(sign(otherNode)), types.ErrInvalidProof)
+
+	otherData := newProof()
+	otherData.InputCids = []string{"QmModel", "QmOtherDataset"}
+	require.ErrorIs(t, complete(sign(otherData)), types.ErrInvalidProof)
+
+	task, _ := ms.Keeper.GetTask(ctx, "task-1")
+	require.Equal(t, types.TaskStatusInProgress, task.Status)
+
+	proof := sign(newProof())
+	require.NoError(t, complete(proof))
+	task, _ = ms.Keeper.GetTask(ctx, "task-1")
+	require.Equal(t, types.TaskStatusCompleted, task.Status)
+	require.Equal(t, proof, task.Proof)
+}
+
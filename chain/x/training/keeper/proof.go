@@ -0,0 +1,43 @@
+package keeper
+
+import (
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+
+	computetypes "github.com/atlas/chain/x/compute/types"
+	"github.com/atlas/chain/x/training/types"
+)
+
+// VerifyProof checks the proof of computation a task is completed with: it
+// must be for the task and the node it was assigned to, consume the job's
+// dataset and be signed with the key the node registered in x/compute
+func (k Keeper) VerifyProof(ctx sdk.Context, task types.Task, proof *types.ComputationProof) error {
+	if proof == nil {
+		return sdkerrors.Wrapf(types.ErrInvalidProof, "task %s cannot complete without a proof of computation", task.ID)
+	}
+	if err := proof.ValidateBasic(); err != nil {
+		return sdkerrors.Wrap(types.ErrInvalidProof, err.Error())
+	}
+	if proof.TaskId != task.ID {
+		return sdkerrors.Wrapf(types.ErrInvalidProof, "proof is for task %s, not %s", proof.TaskId, task.ID)
+	}
+	if task.NodeID != "" && proof.NodeId != task.NodeID {
+		return sdkerrors.Wrapf(types.ErrInvalidProof, "task %s is assigned to %s, not %s", task.ID, task.NodeID, proof.NodeId)
+	}
+	if job, found := k.GetJob(ctx, task.JobID); found && job.DatasetCID != "" && !contains(proof.InputCids, job.DatasetCID) {
+		return sdkerrors.Wrapf(types.ErrInvalidProof, "proof does not consume dataset %s", job.DatasetCID)
+	}
+	if err := k.computeKeeper.VerifyNodeSignature(ctx, proof.NodeId, computetypes.DomainProof, []byte(proof.Digest()), proof.Signature); err != nil {
+		return sdkerrors.Wrap(types.ErrInvalidProof, err.Error())
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
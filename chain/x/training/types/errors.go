@@ -9,6 +9,7 @@ var (
 	ErrTaskNotFound = sdkerrors.Register(ModuleName, 2, "task not found")
 	ErrInvalidJob   = sdkerrors.Register(ModuleName, 3, "invalid job")
 	ErrInvalidTask  = sdkerrors.Register(ModuleName, 4, "invalid task")
+	ErrInvalidProof = sdkerrors.Register(ModuleName, 5, "invalid proof of computation")
 )
 
 const (
@@ -22,5 +23,7 @@ const (
 	AttributeKeyShardID  = "shard_id"
 	AttributeKeyStatus   = "status"
 	AttributeKeyDatasetCID = "dataset_cid"
+	AttributeKeyOutputHash = "output_hash"
+	AttributeKeyStateRoot  = "state_root"
 )
 
@@ -0,0 +1,46 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Digest is what the node signs. It must match the digest of the node's
+// proof package: the task, node, timestamp, inputs, output, state root and
+// segment count, one per line.
+func (p ComputationProof) Digest() string {
+	data := strings.Join([]string{
+		p.TaskId,
+		p.NodeId,
+		strconv.FormatInt(p.Timestamp, 10),
+		strings.Join(p.InputCids, ","),
+		p.OutputHash,
+		p.StateRoot,
+		strconv.Itoa(int(p.SegmentCount)),
+	}, "\n")
+	hash := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(hash[:])
+}
+
+// ValidateBasic checks that the proof is complete and its hashes are
+// sha256 digests
+func (p ComputationProof) ValidateBasic() error {
+	if p.TaskId == "" || p.NodeId == "" {
+		return fmt.Errorf("proof must name its task and node")
+	}
+	for name, value := range map[string]string{"output hash": p.OutputHash, "state root": p.StateRoot} {
+		if decoded, err := hex.DecodeString(value); err != nil || len(decoded) != sha256.Size {
+			return fmt.Errorf("%s %q is not a sha256 digest", name, value)
+		}
+	}
+	if p.SegmentCount < 0 {
+		return fmt.Errorf("segment count must not be negative")
+	}
+	if p.Signature == "" {
+		return fmt.Errorf("proof is not signed")
+	}
+	return nil
+}
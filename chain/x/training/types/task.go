@@ -27,6 +27,7 @@ type Task struct {
 	UpdatedAt    time.Time  `json:"updated_at"`
 	Progress     float64    `json:"progress"`
 	CheckpointCID string    `json:"checkpoint_cid"`
+	Proof        *ComputationProof `json:"proof,omitempty"` // Accepted when the task completed
 }
 
 type Job struct {
@@ -53,12 +53,32 @@ func (m *MsgCreateTaskResponse) String() string { return proto.CompactTextString
 func (*MsgCreateTaskResponse) ProtoMessage()    {}
 
 type MsgUpdateTaskStatus struct {
-	Creator       string  `protobuf:"bytes,1,opt,name=creator,proto3" json:"creator,omitempty"`
-	TaskId        string  `protobuf:"bytes,2,opt,name=task_id,json=taskId,proto3" json:"task_id,omitempty"`
-	Status        string  `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
-	Progress      float64 `protobuf:"fixed64,4,opt,name=progress,proto3" json:"progress,omitempty"`
-	CheckpointCid string  `protobuf:"bytes,5,opt,name=checkpoint_cid,json=checkpointCid,proto3" json:"checkpoint_cid,omitempty"`
-}
+	Creator       string            `protobuf:"bytes,1,opt,name=creator,proto3" json:"creator,omitempty"`
+	TaskId        string            `protobuf:"bytes,2,opt,name=task_id,json=taskId,proto3" json:"task_id,omitempty"`
+	Status        string            `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	Progress      float64           `protobuf:"fixed64,4,opt,name=progress,proto3" json:"progress,omitempty"`
+	CheckpointCid string            `protobuf:"bytes,5,opt,name=checkpoint_cid,json=checkpointCid,proto3" json:"checkpoint_cid,omitempty"`
+	Proof         *ComputationProof `protobuf:"bytes,6,opt,name=proof,proto3" json:"proof,omitempty"`
+}
+
+// ComputationProof is the signed part of a node's proof of computation:
+// what the task consumed and produced and the Merkle root of its training
+// states. The full proof, with the states, is published at ProofCid.
+type ComputationProof struct {
+	TaskId       string   `protobuf:"bytes,1,opt,name=task_id,json=taskId,proto3" json:"task_id,omitempty"`
+	NodeId       string   `protobuf:"bytes,2,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
+	Timestamp    int64    `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	InputCids    []string `protobuf:"bytes,4,rep,name=input_cids,json=inputCids,proto3" json:"input_cids,omitempty"`
+	OutputHash   string   `protobuf:"bytes,5,opt,name=output_hash,json=outputHash,proto3" json:"output_hash,omitempty"`
+	StateRoot    string   `protobuf:"bytes,6,opt,name=state_root,json=stateRoot,proto3" json:"state_root,omitempty"`
+	SegmentCount int32    `protobuf:"varint,7,opt,name=segment_count,json=segmentCount,proto3" json:"segment_count,omitempty"`
+	ProofCid     string   `protobuf:"bytes,8,opt,name=proof_cid,json=proofCid,proto3" json:"proof_cid,omitempty"`
+	Signature    string   `protobuf:"bytes,9,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *ComputationProof) Reset()         { *m = ComputationProof{} }
+func (m *ComputationProof) String() string { return proto.CompactTextString(m) }
+func (*ComputationProof) ProtoMessage()    {}
 
 func (m *MsgUpdateTaskStatus) Reset()         { *m = MsgUpdateTaskStatus{} }
 func (m *MsgUpdateTaskStatus) String() string { return proto.CompactTextString(m) }
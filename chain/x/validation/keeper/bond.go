@@ -0,0 +1,132 @@
+package keeper
+
+import (
+	"fmt"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+
+	"github.com/atlas/chain/x/validation/types"
+)
+
+// GetBond returns the bond of address, empty if it has none
+func (k Keeper) GetBond(ctx sdk.Context, address string) types.Bond {
+	store := ctx.KVStore(k.storeKey)
+	bz := store.Get([]byte(types.BondKeyPrefix + address))
+	if bz == nil {
+		denom := k.GetParams(ctx).ChallengeBond.Denom
+		return types.Bond{Address: address, Amount: sdk.NewInt64Coin(denom, 0), Locked: sdk.NewInt64Coin(denom, 0)}
+	}
+
+	var bond types.Bond
+	k.cdc.MustUnmarshal(bz, &bond)
+	return bond
+}
+
+func (k Keeper) SetBond(ctx sdk.Context, bond types.Bond) {
+	store := ctx.KVStore(k.storeKey)
+	bz := k.cdc.MustMarshal(&bond)
+	store.Set([]byte(types.BondKeyPrefix+bond.Address), bz)
+}
+
+// Bond moves amount from address's account into its bond
+func (k Keeper) Bond(ctx sdk.Context, address string, amount sdk.Coin) error {
+	bond := k.GetBond(ctx, address)
+	if amount.Denom != bond.Amount.Denom || !amount.IsPositive() {
+		return sdkerrors.Wrapf(types.ErrInvalidBond, "bonds are positive amounts of %s", bond.Amount.Denom)
+	}
+	account, err := sdk.AccAddressFromBech32(address)
+	if err != nil {
+		return sdkerrors.Wrapf(types.ErrInvalidBond, "invalid address: %s", err)
+	}
+	if err := k.bankKeeper.SendCoinsFromAccountToModule(ctx, account, types.ModuleName, sdk.NewCoins(amount)); err != nil {
+		return fmt.Errorf("failed to bond: %w", err)
+	}
+
+	bond.Amount = bond.Amount.Add(amount)
+	k.SetBond(ctx, bond)
+
+	ctx.EventManager().EmitEvent(
+		sdk.NewEvent(
+			types.EventTypeBonded,
+			sdk.NewAttribute(types.AttributeKeyAddress, address),
+			sdk.NewAttribute(types.AttributeKeyAmount, amount.String()),
+		),
+	)
+	return nil
+}
+
+// Unbond returns amount of address's bond to its account. Locked coins
+// stay bonded until the challenges and results they back are settled.
+func (k Keeper) Unbond(ctx sdk.Context, address string, amount sdk.Coin) error {
+	bond := k.GetBond(ctx, address)
+	if amount.Denom != bond.Amount.Denom || !amount.IsPositive() {
+		return sdkerrors.Wrapf(types.ErrInvalidBond, "bonds are positive amounts of %s", bond.Amount.Denom)
+	}
+	if bond.Free().IsLT(amount) {
+		return sdkerrors.Wrapf(types.ErrInsufficientBond, "%s of the bond is free", bond.Free())
+	}
+	account, err := sdk.AccAddressFromBech32(address)
+	if err != nil {
+		return sdkerrors.Wrapf(types.ErrInvalidBond, "invalid address: %s", err)
+	}
+	if err := k.bankKeeper.SendCoinsFromModuleToAccount(ctx, types.ModuleName, account, sdk.NewCoins(amount)); err != nil {
+		return fmt.Errorf("failed to unbond: %w", err)
+	}
+
+	bond.Amount = bond.Amount.Sub(amount)
+	k.SetBond(ctx, bond)
+
+	ctx.EventManager().EmitEvent(
+		sdk.NewEvent(
+			types.EventTypeUnbonded,
+			sdk.NewAttribute(types.AttributeKeyAddress, address),
+			sdk.NewAttribute(types.AttributeKeyAmount, amount.String()),
+		),
+	)
+	return nil
+}
+
+func (k Keeper) lockBond(ctx sdk.Context, address string, amount sdk.Coin) error {
+	bond := k.GetBond(ctx, address)
+	if bond.Free().IsLT(amount) {
+		return sdkerrors.Wrapf(types.ErrInsufficientBond, "%s needs %s free in its bond, has %s", address, amount, bond.Free())
+	}
+	bond.Locked = bond.Locked.Add(amount)
+	k.SetBond(ctx, bond)
+	return nil
+}
+
+func (k Keeper) unlockBond(ctx sdk.Context, address string, amount sdk.Coin) {
+	if amount.IsZero() {
+		return
+	}
+	bond := k.GetBond(ctx, address)
+	bond.Locked = bond.Locked.Sub(amount)
+	k.SetBond(ctx, bond)
+}
+
+// forfeitBond moves locked coins of loser's bond to winner's. The coins
+// stay in the module account; the winner can unbond them.
+func (k Keeper) forfeitBond(ctx sdk.Context, loser string, winner string, amount sdk.Coin) {
+	if amount.IsZero() {
+		return
+	}
+	bond := k.GetBond(ctx, loser)
+	bond.Locked = bond.Locked.Sub(amount)
+	bond.Amount = bond.Amount.Sub(amount)
+	k.SetBond(ctx, bond)
+
+	bond = k.GetBond(ctx, winner)
+	bond.Amount = bond.Amount.Add(amount)
+	k.SetBond(ctx, bond)
+
+	ctx.EventManager().EmitEvent(
+		sdk.NewEvent(
+			types.EventTypeBondSlashed,
+			sdk.NewAttribute(types.AttributeKeyAddress, loser),
+			sdk.NewAttribute(types.AttributeKeyWinner, winner),
+			sdk.NewAttribute(types.AttributeKeyAmount, amount.String()),
+		),
+	)
+}
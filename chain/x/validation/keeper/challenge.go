@@ -0,0 +1,330 @@
+package keeper
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+
+	computetypes "github.com/atlas/chain/x/compute/types"
+	trainingtypes "github.com/atlas/chain/x/training/types"
+	"github.com/atlas/chain/x/validation/types"
+)
+
+func (k Keeper) GetLockedResult(ctx sdk.Context, taskID string) (types.LockedResult, bool) {
+	store := ctx.KVStore(k.storeKey)
+	bz := store.Get([]byte(types.ResultKeyPrefix + taskID))
+	if bz == nil {
+		return types.LockedResult{}, false
+	}
+
+	var result types.LockedResult
+	k.cdc.MustUnmarshal(bz, &result)
+	return result, true
+}
+
+func (k Keeper) SetLockedResult(ctx sdk.Context, result types.LockedResult) {
+	store := ctx.KVStore(k.storeKey)
+	bz := k.cdc.MustMarshal(&result)
+	store.Set([]byte(types.ResultKeyPrefix+result.TaskID), bz)
+}
+
+func (k Keeper) IterateLockedResults(ctx sdk.Context, handler func(result types.LockedResult) (stop bool)) {
+	store := ctx.KVStore(k.storeKey)
+	iterator := sdk.KVStorePrefixIterator(store, []byte(types.ResultKeyPrefix))
+	defer iterator.Close()
+
+	for ; iterator.Valid(); iterator.Next() {
+		var result types.LockedResult
+		k.cdc.MustUnmarshal(iterator.Value(), &result)
+		if handler(result) {
+			break
+		}
+	}
+}
+
+func (k Keeper) GetChallenge(ctx sdk.Context, id string) (types.Challenge, bool) {
+	store := ctx.KVStore(k.storeKey)
+	bz := store.Get([]byte(types.ChallengeKeyPrefix + id))
+	if bz == nil {
+		return types.Challenge{}, false
+	}
+
+	var challenge types.Challenge
+	k.cdc.MustUnmarshal(bz, &challenge)
+	return challenge, true
+}
+
+func (k Keeper) SetChallenge(ctx sdk.Context, challenge types.Challenge) {
+	store := ctx.KVStore(k.storeKey)
+	bz := k.cdc.MustMarshal(&challenge)
+	store.Set([]byte(types.ChallengeKeyPrefix+challenge.ID), bz)
+}
+
+func (k Keeper) IterateChallenges(ctx sdk.Context, handler func(challenge types.Challenge) (stop bool)) {
+	store := ctx.KVStore(k.storeKey)
+	iterator := sdk.KVStorePrefixIterator(store, []byte(types.ChallengeKeyPrefix))
+	defer iterator.Close()
+
+	for ; iterator.Valid(); iterator.Next() {
+		var challenge types.Challenge
+		k.cdc.MustUnmarshal(iterator.Value(), &challenge)
+		if handler(challenge) {
+			break
+		}
+	}
+}
+
+// LockResult opens the challenge window of a completed task. As much of the
+// node operator's bond as a challenger has to put up backs the result. If
+// that much is not free, the result is locked unbacked: it risks nothing,
+// so its reward is withheld.
+func (k Keeper) LockResult(ctx sdk.Context, task trainingtypes.Task, params types.Params) types.LockedResult {
+	var operator string
+	if node, found := k.computeKeeper.GetNode(ctx, task.NodeID); found {
+		operator = node.Address
+	}
+	stake := params.ChallengeBond
+	if err := k.lockBond(ctx, operator, stake); err != nil {
+		stake = sdk.NewInt64Coin(stake.Denom, 0)
+	}
+
+	result := types.LockedResult{
+		TaskID:       task.ID,
+		NodeID:       task.NodeID,
+		Operator:     operator,
+		OutputHash:   task.Proof.OutputHash,
+		StateRoot:    task.Proof.StateRoot,
+		SegmentCount: int(task.Proof.SegmentCount),
+		Bond:         stake,
+		Status:       types.ResultStatusLocked,
+		LockedAt:     ctx.BlockTime(),
+		WindowEnd:    ctx.BlockTime().Add(params.ChallengeWindow),
+	}
+	k.SetLockedResult(ctx, result)
+	k.enqueue(ctx, types.ResultQueuePrefix, result.WindowEnd, result.TaskID)
+
+	ctx.EventManager().EmitEvent(
+		sdk.NewEvent(
+			types.EventTypeResultLocked,
+			sdk.NewAttribute(types.AttributeKeyTaskID, task.ID),
+			sdk.NewAttribute(types.AttributeKeyNodeID, task.NodeID),
+			sdk.NewAttribute(types.AttributeKeyOutputHash, result.OutputHash),
+			sdk.NewAttribute(types.AttributeKeyWindowEnd, result.WindowEnd.String()),
+			sdk.NewAttribute(types.AttributeKeyAmount, stake.String()),
+		),
+	)
+	return result
+}
+
+// Challenge disputes segment index of a locked result on behalf of the
+// bonded challenger. The segment and the one before it, whose checkpoint the
+// replay starts from, must be proven part of the committed state root.
+// Referees are picked like replicas, from operators other than the node's
+// and the challenger's.
+func (k Keeper) Challenge(ctx sdk.Context, challenger string, taskID string, index int, segment types.Segment, path []string, previous *types.Segment, previousPath []string) (types.Challenge, error) {
+	params := k.GetParams(ctx)
+
+	result, found := k.GetLockedResult(ctx, taskID)
+	if !found {
+		return types.Challenge{}, sdkerrors.Wrapf(types.ErrResultNotFound, "task %s has no locked result", taskID)
+	}
+	switch {
+	case result.Status == types.ResultStatusChallenged:
+		return types.Challenge{}, sdkerrors.Wrapf(types.ErrChallengeOpen, "task %s is being challenged in %s", taskID, result.Challenge)
+	case result.Status != types.ResultStatusLocked || !ctx.BlockTime().Before(result.WindowEnd):
+		return types.Challenge{}, sdkerrors.Wrapf(types.ErrWindowClosed, "result of task %s is %s", taskID, result.Status)
+	}
+
+	if !types.VerifySegment(result.StateRoot, segment, index, result.SegmentCount, path) {
+		return types.Challenge{}, sdkerrors.Wrapf(types.ErrInvalidChallenge, "segment %d is not one the node committed to", index)
+	}
+	if index == 0 {
+		previous = nil
+	} else if previous == nil || !types.VerifySegment(result.StateRoot, *previous, index-1, result.SegmentCount, previousPath) {
+		return types.Challenge{}, sdkerrors.Wrapf(types.ErrInvalidChallenge, "segment %d the replay starts from is not one the node committed to", index-1)
+	}
+
+	if err := k.lockBond(ctx, challenger, params.ChallengeBond); err != nil {
+		return types.Challenge{}, err
+	}
+
+	challenge := types.Challenge{
+		ID:         fmt.Sprintf("%s-%d", taskID, len(result.Challenges)+1),
+		TaskID:     taskID,
+		Challenger: challenger,
+		Bond:       params.ChallengeBond,
+		Index:      index,
+		Segment:    segment,
+		Previous:   previous,
+		Status:     types.ChallengeStatusOpen,
+		CreatedAt:  ctx.BlockTime(),
+		Deadline:   ctx.BlockTime().Add(params.ChallengeTimeout),
+	}
+
+	// A segment whose starting checkpoint the node did not publish can not
+	// be replayed, so the node can not defend it
+	if previous == nil || previous.Checkpoint != "" {
+		hash := sha256.New()
+		hash.Write(ctx.HeaderHash())
+		hash.Write([]byte(challenge.ID))
+		challenge.Referees = k.selectReplicas(ctx, result.NodeID, hash.Sum(nil), params.Referees, challenger)
+		if len(challenge.Referees) < params.Referees {
+			k.unlockBond(ctx, challenger, params.ChallengeBond)
+			return types.Challenge{}, sdkerrors.Wrapf(types.ErrNoReferees, "found %d of %d", len(challenge.Referees), params.Referees)
+		}
+	}
+
+	result.Status = types.ResultStatusChallenged
+	result.Challenge = challenge.ID
+	result.Challenges = append(result.Challenges, challenge.ID)
+	k.SetLockedResult(ctx, result)
+
+	ctx.EventManager().EmitEvent(
+		sdk.NewEvent(
+			types.EventTypeChallenged,
+			sdk.NewAttribute(types.AttributeKeyTaskID, taskID),
+			sdk.NewAttribute(types.AttributeKeyChallenge, challenge.ID),
+			sdk.NewAttribute(types.AttributeKeySegment, fmt.Sprint(index)),
+			sdk.NewAttribute(types.AttributeKeyNodes, strings.Join(challenge.Referees, ",")),
+		),
+	)
+
+	k.enqueue(ctx, types.ChallengeQueuePrefix, challenge.Deadline, challenge.ID)
+	if challenge.Referees == nil {
+		k.settleChallenge(ctx, &challenge, params)
+	}
+	k.SetChallenge(ctx, challenge)
+	return challenge, nil
+}
+
+// SubmitSegmentResult records the state a referee reached replaying the
+// disputed segment. The challenge is settled as soon as every referee has
+// submitted.
+func (k Keeper) SubmitSegmentResult(ctx sdk.Context, challengeID string, nodeID string, stateHash string, signature string) error {
+	challenge, found := k.GetChallenge(ctx, challengeID)
+	if !found {
+		return sdkerrors.Wrapf(types.ErrChallengeNotFound, "challenge %s not found", challengeID)
+	}
+	if challenge.Status != types.ChallengeStatusOpen {
+		return sdkerrors.Wrapf(types.ErrInvalidChallenge, "challenge %s is %s", challengeID, challenge.Status)
+	}
+	if !challenge.IsReferee(nodeID) {
+		return sdkerrors.Wrapf(types.ErrNotAssigned, "node %s is not a referee of challenge %s", nodeID, challengeID)
+	}
+	if _, submitted := challenge.Result(nodeID); submitted {
+		return sdkerrors.Wrapf(types.ErrDuplicateResult, "node %s already replayed challenge %s", nodeID, challengeID)
+	}
+	if stateHash == "" {
+		return sdkerrors.Wrap(types.ErrInvalidChallenge, "state hash cannot be empty")
+	}
+	if err := k.computeKeeper.VerifyNodeSignature(ctx, nodeID, computetypes.DomainSegment, types.SegmentResultMessage(challengeID, stateHash), signature); err != nil {
+		return err
+	}
+
+	challenge.Results = append(challenge.Results, types.SegmentResult{NodeID: nodeID, StateHash: stateHash})
+
+	ctx.EventManager().EmitEvent(
+		sdk.NewEvent(
+			types.EventTypeSegmentReplayed,
+			sdk.NewAttribute(types.AttributeKeyChallenge, challengeID),
+			sdk.NewAttribute(types.AttributeKeyNodeID, nodeID),
+			sdk.NewAttribute(types.AttributeKeyStateHash, stateHash),
+		),
+	)
+
+	if len(challenge.Results) < len(challenge.Referees) {
+		k.SetChallenge(ctx, challenge)
+		return nil
+	}
+	params := k.GetParams(ctx)
+	k.settleChallenge(ctx, &challenge, params)
+	k.SetChallenge(ctx, challenge)
+	k.finishTask(ctx, challenge.TaskID, params)
+	return nil
+}
+
+// settleChallenge decides a challenge by the state most referees replayed.
+// If it differs from the node's claim, the node's bond is paid to the
+// challenger, the node loses reputation and its task fails. If it matches,
+// the challenger's bond is paid to the node's operator. Without a majority
+// both bonds are returned. Referees that are outvoted or submit nothing
+// lose reputation.
+func (k Keeper) settleChallenge(ctx sdk.Context, challenge *types.Challenge, params types.Params) {
+	k.dequeue(ctx, types.ChallengeQueuePrefix, challenge.Deadline, challenge.ID)
+	result, _ := k.GetLockedResult(ctx, challenge.TaskID)
+	stateHash, ok := challenge.Majority()
+
+	for _, nodeID := range challenge.Referees {
+		if replayed, submitted := challenge.Result(nodeID); !submitted || (ok && replayed.StateHash != stateHash) {
+			k.computeKeeper.PenalizeNode(ctx, nodeID, params.ReputationPenalty)
+		}
+	}
+
+	result.Status = types.ResultStatusLocked
+	switch {
+	case challenge.Referees != nil && !ok:
+		challenge.Status = types.ChallengeStatusInconclusive
+		k.unlockBond(ctx, challenge.Challenger, challenge.Bond)
+	case ok && stateHash == challenge.Segment.StateHash:
+		challenge.Status = types.ChallengeStatusDismissed
+		k.forfeitBond(ctx, challenge.Challenger, result.Operator, challenge.Bond)
+	default:
+		challenge.Status = types.ChallengeStatusUpheld
+		k.unlockBond(ctx, challenge.Challenger, challenge.Bond)
+		k.forfeitBond(ctx, result.Operator, challenge.Challenger, result.Bond)
+		result.Bond = sdk.NewInt64Coin(result.Bond.Denom, 0)
+		result.Status = types.ResultStatusRejected
+		k.computeKeeper.PenalizeNode(ctx, result.NodeID, params.ReputationPenalty)
+		k.failTask(ctx, challenge.TaskID)
+	}
+	challenge.StateHash = stateHash
+	result.Challenge = ""
+	k.SetLockedResult(ctx, result)
+
+	ctx.EventManager().EmitEvent(
+		sdk.NewEvent(
+			types.EventTypeChallengeSettled,
+			sdk.NewAttribute(types.AttributeKeyChallenge, challenge.ID),
+			sdk.NewAttribute(types.AttributeKeyTaskID, challenge.TaskID),
+			sdk.NewAttribute(types.AttributeKeyStatus, string(challenge.Status)),
+			sdk.NewAttribute(types.AttributeKeyStateHash, stateHash),
+		),
+	)
+}
+
+// finalizeResult pays the task's reward once its challenge window closed
+// without the result being overturned, and releases the node's bond. An
+// unbacked result is finalized without a reward.
+func (k Keeper) finalizeResult(ctx sdk.Context, result *types.LockedResult, params types.Params) {
+	k.unlockBond(ctx, result.Operator, result.Bond)
+
+	task, found := k.trainingKeeper.GetTask(ctx, result.TaskID)
+	if !found || task.Status != trainingtypes.TaskStatusCompleted {
+		// Failed since, e.g. outvoted in a redundant execution
+		result.Status = types.ResultStatusRejected
+		return
+	}
+	result.Status = types.ResultStatusFinalized
+
+	reward := sdk.NewInt64Coin(params.TaskReward.Denom, 0)
+	if result.Bond.IsPositive() {
+		reward = k.rewardKeeper.CalculateReward(ctx, result.NodeID, 1.0, params.TaskReward)
+	}
+	if reward.IsPositive() {
+		if err := k.rewardKeeper.DistributeReward(ctx, result.Operator, reward, "task"); err != nil {
+			ctx.Logger().Error("failed to pay task reward", "task", result.TaskID, "node", result.NodeID, "error", err)
+		}
+	}
+
+	ctx.EventManager().EmitEvent(
+		sdk.NewEvent(
+			types.EventTypeResultFinalized,
+			sdk.NewAttribute(types.AttributeKeyTaskID, result.TaskID),
+			sdk.NewAttribute(types.AttributeKeyNodeID, result.NodeID),
+			sdk.NewAttribute(types.AttributeKeyAmount, reward.String()),
+		),
+	)
+}
@@ -0,0 +1,300 @@
+package keeper
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/stretchr/testify/require"
+
+	computetypes "github.com/atlas/chain/x/compute/types"
+	trainingtypes "github.com/atlas/chain/x/training/types"
+	"github.com/atlas/chain/x/validation/types"
+)
+
+const verifier = "cosmos1verifier"
+
+// testTree is the Merkle tree of four segments as the node's proof package
+// builds it
+type testTree struct {
+	segments []types.Segment
+	leaves   [][]byte
+}
+
+func newTestTree() testTree {
+	var tree testTree
+	for i := 1; i <= 4; i++ {
+		state := sha256.Sum256([]byte(fmt.Sprintf("state %d", i)))
+		tree.segments = append(tree.segments, types.Segment{Epoch: int64(i), Checkpoint: fmt.Sprintf("QmCheckpoint%d", i), StateHash: hex.EncodeToString(state[:])})
+	}
+	return tree.hash()
+}
+
+// hash recomputes the leaves from the segments
+func (t testTree) hash() testTree {
+	t.leaves = nil
+	for _, segment := range t.segments {
+		leaf := sha256.Sum256(append([]byte{0x00}, fmt.Sprintf("%d:%d:%s:%s", segment.Epoch, segment.Step, segment.Checkpoint, segment.StateHash)...))
+		t.leaves = append(t.leaves, leaf[:])
+	}
+	return t
+}
+
+func testNodeHash(left, right []byte) []byte {
+	sum := sha256.Sum256(append(append([]byte{0x01}, left...), right...))
+	return sum[:]
+}
+
+func (t testTree) root() string {
+	return hex.EncodeToString(testNodeHash(testNodeHash(t.leaves[0], t.leaves[1]), testNodeHash(t.leaves[2], t.leaves[3])))
+}
+
+func (t testTree) path(index int) []string {
+	sibling := t.leaves[index^1]
+	other := testNodeHash(t.leaves[2], t.leaves[3])
+	if index >= 2 {
+		other = testNodeHash(t.leaves[0], t.leaves[1])
+	}
+	return []string{hex.EncodeToString(sibling), hex.EncodeToString(other)}
+}
+
+// setupChallenge completes task-1 on node-1 with a proof of the test tree
+// and locks its result. node-2 and node-3 can referee; node-4 shares
+// node-1's operator.
+func setupChallenge(t *testing.T) (*Keeper, sdk.Context, testNodes, testTree) {
+	k, ctx, nodes := setupRedundancy(t, nil)
+
+	params := k.GetParams(ctx)
+	params.RedundancyFraction = 0
+	params.Referees = 2
+	require.NoError(t, k.SetParams(ctx, params))
+
+	denom := params.ChallengeBond.Denom
+	k.SetBond(ctx, types.Bond{Address: "cosmos1operator1", Amount: sdk.NewInt64Coin(denom, 50000000), Locked: sdk.NewInt64Coin(denom, 0)})
+	k.SetBond(ctx, types.Bond{Address: verifier, Amount: sdk.NewInt64Coin(denom, 50000000), Locked: sdk.NewInt64Coin(denom, 0)})
+
+	tree := newTestTree()
+	proof := nodes.proof("node-1", "output")
+	proof.StateRoot = tree.root()
+	proof.SegmentCount = 4
+	task, _ := k.trainingKeeper.GetTask(ctx, "task-1")
+	task.Status = trainingtypes.TaskStatusCompleted
+	task.Proof = nodes.sign(proof)
+	k.trainingKeeper.SetTask(ctx, task)
+
+	k.EndBlocker(ctx)
+	return k, ctx, nodes, tree
+}
+
+func (n testNodes) replay(challengeID string, stateHash string, nodeID string) string {
+	message := append([]byte(computetypes.DomainSegment+"\x00"), types.SegmentResultMessage(challengeID, stateHash)...)
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(n[nodeID], message))
+}
+
+func TestLockAndFinalizeResult(t *testing.T) {
+	k, ctx, _, tree := setupChallenge(t)
+	params := k.GetParams(ctx)
+
+	result, found := k.GetLockedResult(ctx, "task-1")
+	require.True(t, found)
+	require.Equal(t, types.ResultStatusLocked, result.Status)
+	require.Equal(t, tree.root(), result.StateRoot)
+	require.Equal(t, params.ChallengeBond, result.Bond)
+	require.Equal(t, params.ChallengeBond, k.GetBond(ctx, "cosmos1operator1").Locked)
+
+	// Locked coins can not be unbonded
+	require.ErrorIs(t, k.Unbond(ctx, "cosmos1operator1", sdk.NewInt64Coin(params.ChallengeBond.Denom, 50000000)), types.ErrInsufficientBond)
+
+	ctx = ctx.WithBlockTime(result.WindowEnd.Add(-1))
+	k.EndBlocker(ctx)
+	result, _ = k.GetLockedResult(ctx, "task-1")
+	require.Equal(t, types.ResultStatusLocked, result.Status)
+
+	ctx = ctx.WithBlockTime(result.WindowEnd)
+	_, err := k.Challenge(ctx, verifier, "task-1", 0, tree.segments[0], tree.path(0), nil, nil)
+	require.ErrorIs(t, err, types.ErrWindowClosed)
+	k.EndBlocker(ctx)
+	require.Equal(t, "node-1", eventAttribute(ctx, types.EventTypeResultFinalized, types.AttributeKeyNodeID))
+	require.True(t, k.GetBond(ctx, "cosmos1operator1").Locked.IsZero())
+
+	// The finalized result is pruned, and completing the task again does
+	// not lock another one
+	_, found = k.GetLockedResult(ctx, "task-1")
+	require.False(t, found)
+	task, _ := k.trainingKeeper.GetTask(ctx, "task-1")
+	task.Status = trainingtypes.TaskStatusInProgress
+	k.trainingKeeper.SetTask(ctx, task)
+	task.Status = trainingtypes.TaskStatusCompleted
+	k.trainingKeeper.SetTask(ctx, task)
+	k.EndBlocker(ctx)
+	_, found = k.GetLockedResult(ctx, "task-1")
+	require.False(t, found)
+}
+
+func TestLockUnbackedResult(t *testing.T) {
+	k, ctx, nodes, tree := setupChallenge(t)
+	params := k.GetParams(ctx)
+
+	// Half of the challenge bond free does not back a result
+	k.SetBond(ctx, types.Bond{Address: "cosmos1operator2", Amount: sdk.NewInt64Coin(params.ChallengeBond.Denom, 5000000), Locked: sdk.NewInt64Coin(params.ChallengeBond.Denom, 0)})
+	proof := nodes.proof("node-2", "output")
+	proof.TaskId = "task-2"
+	proof.StateRoot = tree.root()
+	proof.SegmentCount = 4
+	k.trainingKeeper.SetTask(ctx, trainingtypes.Task{ID: "task-2", JobID: "job-1", NodeID: "node-2", Status: trainingtypes.TaskStatusCompleted, Proof: nodes.sign(proof)})
+	k.EndBlocker(ctx)
+
+	result, found := k.GetLockedResult(ctx, "task-2")
+	require.True(t, found)
+	require.True(t, result.Bond.IsZero())
+	require.True(t, k.GetBond(ctx, "cosmos1operator2").Locked.IsZero())
+
+	// Finalized after task-1, it earns nothing
+	ctx = ctx.WithBlockTime(result.WindowEnd)
+	k.EndBlocker(ctx)
+	require.Equal(t, sdk.NewInt64Coin(params.TaskReward.Denom, 0).String(), eventAttribute(ctx, types.EventTypeResultFinalized, types.AttributeKeyAmount))
+}
+
+func TestChallengeDismissed(t *testing.T) {
+	k, ctx, nodes, tree := setupChallenge(t)
+	params := k.GetParams(ctx)
+
+	// The segment and the checkpoint before it must be the committed ones
+	_, err := k.Challenge(ctx, verifier, "task-1", 2, tree.segments[2], tree.path(1), &tree.segments[1], tree.path(1))
+	require.ErrorIs(t, err, types.ErrInvalidChallenge)
+	_, err = k.Challenge(ctx, verifier, "task-1", 2, tree.segments[2], tree.path(2), nil, nil)
+	require.ErrorIs(t, err, types.ErrInvalidChallenge)
+	_, err = k.Challenge(ctx, "cosmos1unbonded", "task-1", 2, tree.segments[2], tree.path(2), &tree.segments[1], tree.path(1))
+	require.ErrorIs(t, err, types.ErrInsufficientBond)
+
+	challenge, err := k.Challenge(ctx, verifier, "task-1", 2, tree.segments[2], tree.path(2), &tree.segments[1], tree.path(1))
+	require.NoError(t, err)
+	require.Equal(t, "task-1-1", challenge.ID)
+	require.ElementsMatch(t, []string{"node-2", "node-3"}, challenge.Referees)
+	_, err = k.Challenge(ctx, verifier, "task-1", 1, tree.segments[1], tree.path(1), &tree.segments[0], tree.path(0))
+	require.ErrorIs(t, err, types.ErrChallengeOpen)
+
+	stateHash := tree.segments[2].StateHash
+	require.ErrorIs(t, k.SubmitSegmentResult(ctx, challenge.ID, "node-4", stateHash, nodes.replay(challenge.ID, stateHash, "node-4")), types.ErrNotAssigned)
+	require.ErrorIs(t, k.SubmitSegmentResult(ctx, challenge.ID, "node-2", stateHash, nodes.replay(challenge.ID, stateHash, "node-3")), computetypes.ErrInvalidSignature)
+	require.NoError(t, k.SubmitSegmentResult(ctx, challenge.ID, "node-2", stateHash, nodes.replay(challenge.ID, stateHash, "node-2")))
+	require.NoError(t, k.SubmitSegmentResult(ctx, challenge.ID, "node-3", stateHash, nodes.replay(challenge.ID, stateHash, "node-3")))
+
+	// The node's claim held up, so the challenger pays the node's operator
+	challenge, _ = k.GetChallenge(ctx, challenge.ID)
+	require.Equal(t, types.ChallengeStatusDismissed, challenge.Status)
+	require.Equal(t, sdk.NewInt64Coin(params.ChallengeBond.Denom, 40000000), k.GetBond(ctx, verifier).Amount)
+	require.True(t, k.GetBond(ctx, verifier).Locked.IsZero())
+	require.Equal(t, sdk.NewInt64Coin(params.ChallengeBond.Denom, 60000000), k.GetBond(ctx, "cosmos1operator1").Amount)
+
+	result, _ := k.GetLockedResult(ctx, "task-1")
+	require.Equal(t, types.ResultStatusLocked, result.Status)
+	require.Empty(t, result.Challenge)
+	task, _ := k.trainingKeeper.GetTask(ctx, "task-1")
+	require.Equal(t, trainingtypes.TaskStatusCompleted, task.Status)
+}
+
+func TestChallengeUpheld(t *testing.T) {
+	k, ctx, nodes, tree := setupChallenge(t)
+	params := k.GetParams(ctx)
+
+	challenge, err := k.Challenge(ctx, verifier, "task-1", 0, tree.segments[0], tree.path(0), nil, nil)
+	require.NoError(t, err)
+	replayed := hex.EncodeToString(make([]byte, sha256.Size))
+	for _, referee := range challenge.Referees {
+		require.NoError(t, k.SubmitSegmentResult(ctx, challenge.ID, referee, replayed, nodes.replay(challenge.ID, replayed, referee)))
+	}
+
+	// The referees replayed another state, so the node pays the challenger
+	challenge, _ = k.GetChallenge(ctx, challenge.ID)
+	require.Equal(t, types.ChallengeStatusUpheld, challenge.Status)
+	require.Equal(t, sdk.NewInt64Coin(params.ChallengeBond.Denom, 60000000), k.GetBond(ctx, verifier).Amount)
+	require.Equal(t, sdk.NewInt64Coin(params.ChallengeBond.Denom, 40000000), k.GetBond(ctx, "cosmos1operator1").Amount)
+	require.True(t, k.GetBond(ctx, "cosmos1operator1").Locked.IsZero())
+	require.Less(t, k.computeKeeper.GetNodeReputation(ctx, "node-1"), 100.0)
+
+	task, _ := k.trainingKeeper.GetTask(ctx, "task-1")
+	require.Equal(t, trainingtypes.TaskStatusFailed, task.Status)
+
+	// A rejected result is never finalized, only pruned with its
+	// challenges once the window closes
+	result, _ := k.GetLockedResult(ctx, "task-1")
+	require.Equal(t, types.ResultStatusRejected, result.Status)
+	ctx = ctx.WithBlockTime(result.WindowEnd)
+	k.EndBlocker(ctx)
+	require.Empty(t, eventAttribute(ctx, types.EventTypeResultFinalized, types.AttributeKeyTaskID))
+	_, found := k.GetLockedResult(ctx, "task-1")
+	require.False(t, found)
+	_, found = k.GetChallenge(ctx, challenge.ID)
+	require.False(t, found)
+}
+
+func TestFinalizeAfterChallenge(t *testing.T) {
+	k, ctx, nodes, tree := setupChallenge(t)
+
+	challenge, err := k.Challenge(ctx, verifier, "task-1", 1, tree.segments[1], tree.path(1), &tree.segments[0], tree.path(0))
+	require.NoError(t, err)
+	stateHash := tree.segments[1].StateHash
+	require.NoError(t, k.SubmitSegmentResult(ctx, challenge.ID, challenge.Referees[0], stateHash, nodes.replay(challenge.ID, stateHash, challenge.Referees[0])))
+
+	// The window closes while the result is challenged
+	result, _ := k.GetLockedResult(ctx, "task-1")
+	ctx = ctx.WithBlockTime(result.WindowEnd)
+	k.EndBlocker(ctx)
+	result, found := k.GetLockedResult(ctx, "task-1")
+	require.True(t, found)
+	require.Equal(t, types.ResultStatusChallenged, result.Status)
+
+	// Dismissing the challenge finalizes it
+	require.NoError(t, k.SubmitSegmentResult(ctx, challenge.ID, challenge.Referees[1], stateHash, nodes.replay(challenge.ID, stateHash, challenge.Referees[1])))
+	require.Equal(t, "task-1", eventAttribute(ctx, types.EventTypeResultFinalized, types.AttributeKeyTaskID))
+	_, found = k.GetLockedResult(ctx, "task-1")
+	require.False(t, found)
+}
+
+func TestChallengeUnreplayableSegment(t *testing.T) {
+	k, ctx, nodes, _ := setupChallenge(t)
+
+	// A node that did not publish the checkpoint of segment 1 can not have
+	// segment 2 replayed
+	tree := newTestTree()
+	tree.segments[1].Checkpoint = ""
+	tree = tree.hash()
+	proof := nodes.proof("node-1", "output")
+	proof.StateRoot = tree.root()
+	proof.SegmentCount = 4
+	task, _ := k.trainingKeeper.GetTask(ctx, "task-1")
+	task.ID = "task-2"
+	proof.TaskId = "task-2"
+	task.Proof = nodes.sign(proof)
+	k.trainingKeeper.SetTask(ctx, task)
+	k.EndBlocker(ctx)
+
+	challenge, err := k.Challenge(ctx, verifier, "task-2", 2, tree.segments[2], tree.path(2), &tree.segments[1], tree.path(1))
+	require.NoError(t, err)
+	require.Empty(t, challenge.Referees)
+	require.Equal(t, types.ChallengeStatusUpheld, challenge.Status)
+}
+
+func TestChallengeTimeout(t *testing.T) {
+	k, ctx, nodes, tree := setupChallenge(t)
+
+	challenge, err := k.Challenge(ctx, verifier, "task-1", 3, tree.segments[3], tree.path(3), &tree.segments[2], tree.path(2))
+	require.NoError(t, err)
+	silent := challenge.Referees[1]
+	stateHash := tree.segments[3].StateHash
+	require.NoError(t, k.SubmitSegmentResult(ctx, challenge.ID, challenge.Referees[0], stateHash, nodes.replay(challenge.ID, stateHash, challenge.Referees[0])))
+
+	// One of two referees is no majority: both bonds are returned
+	ctx = ctx.WithBlockTime(challenge.Deadline)
+	k.EndBlocker(ctx)
+	challenge, _ = k.GetChallenge(ctx, challenge.ID)
+	require.Equal(t, types.ChallengeStatusInconclusive, challenge.Status)
+	require.True(t, k.GetBond(ctx, verifier).Locked.IsZero())
+	require.Less(t, k.computeKeeper.GetNodeReputation(ctx, silent), 100.0)
+	require.Equal(t, 100.0, k.computeKeeper.GetNodeReputation(ctx, challenge.Referees[0]))
+}
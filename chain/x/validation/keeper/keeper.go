@@ -3,10 +3,12 @@ package keeper
 import (
 	"github.com/cosmos/cosmos-sdk/codec"
 	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	bankkeeper "github.com/cosmos/cosmos-sdk/x/bank/keeper"
 	trainingkeeper "github.com/atlas/chain/x/training/keeper"
 	shardingkeeper "github.com/atlas/chain/x/sharding/keeper"
 	computekeeper "github.com/atlas/chain/x/compute/keeper"
 	healthkeeper "github.com/atlas/chain/x/health/keeper"
+	rewardkeeper "github.com/atlas/chain/x/reward/keeper"
 )
 
 type Keeper struct {
@@ -17,6 +19,8 @@ type Keeper struct {
 	shardingKeeper shardingkeeper.Keeper
 	computeKeeper computekeeper.Keeper
 	healthKeeper healthkeeper.Keeper
+	bankKeeper bankkeeper.Keeper
+	rewardKeeper rewardkeeper.Keeper
 }
 
 func NewKeeper(
@@ -26,6 +30,8 @@ func NewKeeper(
 	shardingKeeper shardingkeeper.Keeper,
 	computeKeeper computekeeper.Keeper,
 	healthKeeper healthkeeper.Keeper,
+	bankKeeper bankkeeper.Keeper,
+	rewardKeeper rewardkeeper.Keeper,
 ) *Keeper {
 	return &Keeper{
 		cdc: cdc, storeKey: storeKey, memKey: memKey,
@@ -33,6 +39,8 @@ func NewKeeper(
 		shardingKeeper: shardingKeeper,
 		computeKeeper: computeKeeper,
 		healthKeeper: healthKeeper,
+		bankKeeper: bankKeeper,
+		rewardKeeper: rewardKeeper,
 	}
 }
 
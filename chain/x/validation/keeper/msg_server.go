@@ -0,0 +1,84 @@
+package keeper
+
+import (
+	"context"
+	"fmt"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/atlas/chain/x/validation/types"
+)
+
+type MsgServer struct {
+	Keeper
+}
+
+func NewMsgServer(keeper Keeper) MsgServer {
+	return MsgServer{Keeper: keeper}
+}
+
+func (ms MsgServer) SubmitResult(ctx context.Context, msg *types.MsgSubmitResult) (*types.MsgSubmitResultResponse, error) {
+	if msg == nil {
+		return nil, fmt.Errorf("invalid message")
+	}
+
+	sdkCtx := sdk.UnwrapSDKContext(ctx)
+	if err := ms.Keeper.SubmitResult(sdkCtx, msg.TaskId, msg.Proof, msg.Metrics); err != nil {
+		return nil, err
+	}
+
+	return &types.MsgSubmitResultResponse{}, nil
+}
+
+func (ms MsgServer) Bond(ctx context.Context, msg *types.MsgBond) (*types.MsgBondResponse, error) {
+	if msg == nil {
+		return nil, fmt.Errorf("invalid message")
+	}
+
+	sdkCtx := sdk.UnwrapSDKContext(ctx)
+	if err := ms.Keeper.Bond(sdkCtx, msg.Creator, msg.Amount); err != nil {
+		return nil, err
+	}
+
+	return &types.MsgBondResponse{}, nil
+}
+
+func (ms MsgServer) Unbond(ctx context.Context, msg *types.MsgUnbond) (*types.MsgUnbondResponse, error) {
+	if msg == nil {
+		return nil, fmt.Errorf("invalid message")
+	}
+
+	sdkCtx := sdk.UnwrapSDKContext(ctx)
+	if err := ms.Keeper.Unbond(sdkCtx, msg.Creator, msg.Amount); err != nil {
+		return nil, err
+	}
+
+	return &types.MsgUnbondResponse{}, nil
+}
+
+func (ms MsgServer) Challenge(ctx context.Context, msg *types.MsgChallenge) (*types.MsgChallengeResponse, error) {
+	if msg == nil || msg.Segment == nil {
+		return nil, fmt.Errorf("invalid message")
+	}
+
+	sdkCtx := sdk.UnwrapSDKContext(ctx)
+	challenge, err := ms.Keeper.Challenge(sdkCtx, msg.Creator, msg.TaskId, int(msg.Index), *msg.Segment, msg.Path, msg.Previous, msg.PreviousPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.MsgChallengeResponse{ChallengeId: challenge.ID, Referees: challenge.Referees}, nil
+}
+
+func (ms MsgServer) SubmitSegmentResult(ctx context.Context, msg *types.MsgSubmitSegmentResult) (*types.MsgSubmitSegmentResultResponse, error) {
+	if msg == nil {
+		return nil, fmt.Errorf("invalid message")
+	}
+
+	sdkCtx := sdk.UnwrapSDKContext(ctx)
+	if err := ms.Keeper.SubmitSegmentResult(sdkCtx, msg.ChallengeId, msg.NodeId, msg.StateHash, msg.Signature); err != nil {
+		return nil, err
+	}
+
+	return &types.MsgSubmitSegmentResultResponse{}, nil
+}
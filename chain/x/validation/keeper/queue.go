@@ -0,0 +1,41 @@
+package keeper
+
+import (
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+var queueTimeLen = len(sdk.FormatTimeBytes(time.Time{}))
+
+func queueKey(prefix string, due time.Time, id string) []byte {
+	return append(append([]byte(prefix), sdk.FormatTimeBytes(due)...), id...)
+}
+
+func (k Keeper) enqueue(ctx sdk.Context, prefix string, due time.Time, id string) {
+	store := ctx.KVStore(k.storeKey)
+	store.Set(queueKey(prefix, due, id), []byte{1})
+}
+
+func (k Keeper) dequeue(ctx sdk.Context, prefix string, due time.Time, id string) {
+	store := ctx.KVStore(k.storeKey)
+	store.Delete(queueKey(prefix, due, id))
+}
+
+// queued returns the IDs in the queue under prefix in the order they are
+// due. If due is true, only those due by the block time are returned.
+func (k Keeper) queued(ctx sdk.Context, prefix string, due bool) []string {
+	store := ctx.KVStore(k.storeKey)
+	end := sdk.PrefixEndBytes([]byte(prefix))
+	if due {
+		end = sdk.PrefixEndBytes(queueKey(prefix, ctx.BlockTime(), ""))
+	}
+	iterator := store.Iterator([]byte(prefix), end)
+	defer iterator.Close()
+
+	var ids []string
+	for ; iterator.Valid(); iterator.Next() {
+		ids = append(ids, string(iterator.Key()[len(prefix)+queueTimeLen:]))
+	}
+	return ids
+}
@@ -0,0 +1,460 @@
+package keeper
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+
+	trainingtypes "github.com/atlas/chain/x/training/types"
+	"github.com/atlas/chain/x/validation/types"
+)
+
+func (k Keeper) GetExecution(ctx sdk.Context, taskID string) (types.RedundantExecution, bool) {
+	store := ctx.KVStore(k.storeKey)
+	bz := store.Get([]byte(types.ExecutionKeyPrefix + taskID))
+	if bz == nil {
+		return types.RedundantExecution{}, false
+	}
+
+	var execution types.RedundantExecution
+	k.cdc.MustUnmarshal(bz, &execution)
+	return execution, true
+}
+
+func (k Keeper) SetExecution(ctx sdk.Context, execution types.RedundantExecution) {
+	store := ctx.KVStore(k.storeKey)
+	bz := k.cdc.MustMarshal(&execution)
+	store.Set([]byte(types.ExecutionKeyPrefix+execution.TaskID), bz)
+}
+
+func (k Keeper) IterateExecutions(ctx sdk.Context, handler func(execution types.RedundantExecution) (stop bool)) {
+	store := ctx.KVStore(k.storeKey)
+	iterator := sdk.KVStorePrefixIterator(store, []byte(types.ExecutionKeyPrefix))
+	defer iterator.Close()
+
+	for ; iterator.Valid(); iterator.Next() {
+		var execution types.RedundantExecution
+		k.cdc.MustUnmarshal(iterator.Value(), &execution)
+		if handler(execution) {
+			break
+		}
+	}
+}
+
+// GetPendingExecutionsByNode returns the executions nodeID still has to
+// submit a result for
+func (k Keeper) GetPendingExecutionsByNode(ctx sdk.Context, nodeID string) []types.RedundantExecution {
+	var executions []types.RedundantExecution
+	for _, taskID := range k.queued(ctx, types.ExecutionQueuePrefix, false) {
+		execution, found := k.GetExecution(ctx, taskID)
+		if _, submitted := execution.Result(nodeID); found && execution.IsAssigned(nodeID) && !submitted {
+			executions = append(executions, execution)
+		}
+	}
+	return executions
+}
+
+// EndBlocker acts on the tasks whose status or node changed, then settles
+// the executions and challenges that are overdue and finalizes the results
+// whose challenge window closed. It only reads the task updates and the
+// queued items that are due, so its work does not grow with the number of
+// tasks.
+func (k Keeper) EndBlocker(ctx sdk.Context) {
+	params := k.GetParams(ctx)
+
+	var updated []string
+	k.trainingKeeper.IterateTaskUpdates(ctx, func(taskID string) bool {
+		updated = append(updated, taskID)
+		return false
+	})
+	for _, taskID := range updated {
+		k.trainingKeeper.DeleteTaskUpdate(ctx, taskID)
+		if task, found := k.trainingKeeper.GetTask(ctx, taskID); found {
+			k.taskUpdated(ctx, task, params)
+		}
+	}
+
+	for _, taskID := range k.queued(ctx, types.ExecutionQueuePrefix, true) {
+		execution, found := k.GetExecution(ctx, taskID)
+		if !found {
+			continue
+		}
+		k.trySettle(ctx, &execution, params)
+		k.SetExecution(ctx, execution)
+		k.finishTask(ctx, taskID, params)
+	}
+
+	for _, id := range k.queued(ctx, types.ChallengeQueuePrefix, true) {
+		challenge, found := k.GetChallenge(ctx, id)
+		if !found {
+			continue
+		}
+		k.settleChallenge(ctx, &challenge, params)
+		k.SetChallenge(ctx, challenge)
+		k.finishTask(ctx, challenge.TaskID, params)
+	}
+
+	// Results still waiting for a challenge or their task's redundant
+	// execution are finished when those settle
+	for _, taskID := range k.queued(ctx, types.ResultQueuePrefix, true) {
+		if result, found := k.GetLockedResult(ctx, taskID); found {
+			k.dequeue(ctx, types.ResultQueuePrefix, result.WindowEnd, taskID)
+		}
+		k.finishTask(ctx, taskID, params)
+	}
+}
+
+// taskUpdated assigns replicas to a newly assigned task and locks the
+// result of a newly completed one. Completing or failing can also settle
+// or finish what waited for the task.
+func (k Keeper) taskUpdated(ctx sdk.Context, task trainingtypes.Task, params types.Params) {
+	store := ctx.KVStore(k.storeKey)
+	if store.Has([]byte(types.FinishedKeyPrefix + task.ID)) {
+		return
+	}
+
+	assigned := task.NodeID != "" && task.Status != trainingtypes.TaskStatusPending && task.Status != trainingtypes.TaskStatusFailed
+	if assigned && !store.Has([]byte(types.CheckedKeyPrefix+task.ID)) && !store.Has([]byte(types.ExecutionKeyPrefix+task.ID)) {
+		k.ScheduleRedundantExecution(ctx, task, params)
+	}
+
+	switch task.Status {
+	case trainingtypes.TaskStatusCompleted:
+		if task.Proof != nil && !store.Has([]byte(types.ResultKeyPrefix+task.ID)) {
+			k.LockResult(ctx, task, params)
+		}
+		// The task's proof may be the result its execution waited for
+		if execution, found := k.GetExecution(ctx, task.ID); found && execution.Status == types.ExecutionStatusPending && k.trySettle(ctx, &execution, params) {
+			k.SetExecution(ctx, execution)
+			k.finishTask(ctx, task.ID, params)
+		}
+	case trainingtypes.TaskStatusFailed:
+		k.finishTask(ctx, task.ID, params)
+	}
+}
+
+// finishTask finalizes the task's locked result once its challenge window
+// closed and neither a challenge of it nor its redundant execution is
+// open. It then prunes the execution, the result and its challenges; their
+// outcomes remain in the events emitted when they were settled. A task
+// without a locked result is pruned once it failed.
+func (k Keeper) finishTask(ctx sdk.Context, taskID string, params types.Params) {
+	if execution, found := k.GetExecution(ctx, taskID); found && execution.Status == types.ExecutionStatusPending {
+		return
+	}
+
+	store := ctx.KVStore(k.storeKey)
+	result, found := k.GetLockedResult(ctx, taskID)
+	if found {
+		if result.Status == types.ResultStatusChallenged || ctx.BlockTime().Before(result.WindowEnd) {
+			return
+		}
+		if result.Status == types.ResultStatusLocked {
+			k.finalizeResult(ctx, &result, params)
+		}
+		k.dequeue(ctx, types.ResultQueuePrefix, result.WindowEnd, taskID)
+		for _, id := range result.Challenges {
+			store.Delete([]byte(types.ChallengeKeyPrefix + id))
+		}
+		store.Delete([]byte(types.ResultKeyPrefix + taskID))
+		// A finished task's result is never locked, and paid, again
+		store.Set([]byte(types.FinishedKeyPrefix+taskID), []byte{1})
+	} else if task, found := k.trainingKeeper.GetTask(ctx, taskID); found && task.Status != trainingtypes.TaskStatusFailed {
+		return
+	}
+	store.Delete([]byte(types.ExecutionKeyPrefix + taskID))
+}
+
+// ScheduleRedundantExecution decides whether task is run redundantly and,
+// if so, assigns it to params.Replicas more nodes. The choice is seeded
+// with the block hash so nodes can not know in advance which of their
+// tasks will be checked. It returns nil if the task is not selected.
+func (k Keeper) ScheduleRedundantExecution(ctx sdk.Context, task trainingtypes.Task, params types.Params) *types.RedundantExecution {
+	store := ctx.KVStore(k.storeKey)
+	store.Set([]byte(types.CheckedKeyPrefix+task.ID), []byte{1})
+
+	hash := sha256.New()
+	hash.Write(ctx.HeaderHash())
+	hash.Write([]byte(task.ID))
+	seed := hash.Sum(nil)
+
+	draw := float64(binary.BigEndian.Uint64(seed[:8])) / float64(math.MaxUint64)
+	if params.RedundancyFraction <= 0 || (params.RedundancyFraction < 1 && draw >= params.RedundancyFraction) {
+		return nil
+	}
+
+	replicas := k.selectReplicas(ctx, task.NodeID, seed, params.Replicas)
+	if len(replicas) < params.Replicas {
+		return nil
+	}
+
+	compare := types.CompareOutputHash
+	if job, found := k.trainingKeeper.GetJob(ctx, task.JobID); found && nondeterministic(job) {
+		compare = types.CompareMetrics
+	}
+
+	execution := types.RedundantExecution{
+		TaskID:    task.ID,
+		JobID:     task.JobID,
+		Nodes:     append([]string{task.NodeID}, replicas...),
+		Compare:   compare,
+		Tolerance: params.MetricTolerance,
+		Status:    types.ExecutionStatusPending,
+		CreatedAt: ctx.BlockTime(),
+		Deadline:  ctx.BlockTime().Add(params.ResultTimeout),
+	}
+	k.SetExecution(ctx, execution)
+	k.enqueue(ctx, types.ExecutionQueuePrefix, execution.Deadline, execution.TaskID)
+
+	ctx.EventManager().EmitEvent(
+		sdk.NewEvent(
+			types.EventTypeReplicasAssigned,
+			sdk.NewAttribute(types.AttributeKeyTaskID, task.ID),
+			sdk.NewAttribute(types.AttributeKeyNodes, strings.Join(replicas, ",")),
+		),
+	)
+
+	return &execution
+}
+
+// selectReplicas picks up to n healthy nodes other than the task's, run by
+// different operators than it, each other and exclude, in an order seeded
+// by seed
+func (k Keeper) selectReplicas(ctx sdk.Context, nodeID string, seed []byte, n int, exclude ...string) []string {
+	operators := make(map[string]bool)
+	if node, found := k.computeKeeper.GetNode(ctx, nodeID); found {
+		operators[node.Address] = true
+	}
+	for _, address := range exclude {
+		operators[address] = true
+	}
+
+	type candidate struct {
+		id, address string
+		score       []byte
+	}
+	var candidates []candidate
+	for _, node := range k.computeKeeper.GetAllNodes(ctx) {
+		// Replicas sign their results, so they need a registered key
+		if node.ID == nodeID || node.Status != "online" || node.PubKey == "" {
+			continue
+		}
+		if healthy, err := k.healthKeeper.CheckNodeHealth(ctx, node.ID); err != nil || !healthy {
+			continue
+		}
+		score := sha256.Sum256(append(append([]byte{}, seed...), node.ID...))
+		candidates = append(candidates, candidate{id: node.ID, address: node.Address, score: score[:]})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return bytes.Compare(candidates[i].score, candidates[j].score) < 0
+	})
+
+	var replicas []string
+	for _, c := range candidates {
+		if len(replicas) == n {
+			break
+		}
+		if operators[c.address] {
+			continue
+		}
+		operators[c.address] = true
+		replicas = append(replicas, c.id)
+	}
+	return replicas
+}
+
+// SubmitResult records the result a node assigned to the task committed to
+// in its proof. The execution is settled as soon as every node has
+// submitted.
+func (k Keeper) SubmitResult(ctx sdk.Context, taskID string, proof *trainingtypes.ComputationProof, metrics map[string]float64) error {
+	execution, found := k.GetExecution(ctx, taskID)
+	if !found {
+		return sdkerrors.Wrapf(types.ErrExecutionNotFound, "task %s is not run redundantly", taskID)
+	}
+	if execution.Status != types.ExecutionStatusPending {
+		return sdkerrors.Wrapf(types.ErrExecutionSettled, "task %s", taskID)
+	}
+	if proof == nil {
+		return sdkerrors.Wrap(trainingtypes.ErrInvalidProof, "a result must come with a proof of computation")
+	}
+	if !execution.IsAssigned(proof.NodeId) {
+		return sdkerrors.Wrapf(types.ErrNotAssigned, "node %s is not assigned to task %s", proof.NodeId, taskID)
+	}
+	if _, submitted := execution.Result(proof.NodeId); submitted {
+		return sdkerrors.Wrapf(types.ErrDuplicateResult, "node %s already submitted a result for task %s", proof.NodeId, taskID)
+	}
+
+	task, found := k.trainingKeeper.GetTask(ctx, taskID)
+	if !found {
+		return sdkerrors.Wrapf(trainingtypes.ErrTaskNotFound, "task %s not found", taskID)
+	}
+	// Replicas prove their run like the task's own node would
+	task.NodeID = proof.NodeId
+	if err := k.trainingKeeper.VerifyProof(ctx, task, proof); err != nil {
+		return err
+	}
+
+	execution.Results = append(execution.Results, types.ExecutionResult{
+		NodeID:      proof.NodeId,
+		OutputHash:  proof.OutputHash,
+		StateRoot:   proof.StateRoot,
+		Metrics:     metrics,
+		SubmittedAt: ctx.BlockTime(),
+	})
+
+	ctx.EventManager().EmitEvent(
+		sdk.NewEvent(
+			types.EventTypeResultSubmitted,
+			sdk.NewAttribute(types.AttributeKeyTaskID, taskID),
+			sdk.NewAttribute(types.AttributeKeyNodeID, proof.NodeId),
+			sdk.NewAttribute(types.AttributeKeyOutputHash, proof.OutputHash),
+		),
+	)
+
+	params := k.GetParams(ctx)
+	settled := k.trySettle(ctx, &execution, params)
+	k.SetExecution(ctx, execution)
+	if settled {
+		k.finishTask(ctx, taskID, params)
+	}
+	return nil
+}
+
+// trySettle settles the execution once all nodes submitted or its deadline
+// passed. If the task's node submitted nothing itself, the proof it
+// completed the task with stands in; for metric comparisons only at the
+// deadline, as that proof carries no metrics.
+func (k Keeper) trySettle(ctx sdk.Context, execution *types.RedundantExecution, params types.Params) bool {
+	overdue := !ctx.BlockTime().Before(execution.Deadline)
+
+	original := execution.Nodes[0]
+	if _, submitted := execution.Result(original); !submitted && (overdue || execution.Compare == types.CompareOutputHash) {
+		if task, found := k.trainingKeeper.GetTask(ctx, execution.TaskID); found && task.Proof != nil && task.Proof.NodeId == original {
+			execution.Results = append(execution.Results, types.ExecutionResult{
+				NodeID:      original,
+				OutputHash:  task.Proof.OutputHash,
+				StateRoot:   task.Proof.StateRoot,
+				SubmittedAt: task.UpdatedAt,
+			})
+		}
+	}
+
+	if len(execution.Results) < len(execution.Nodes) && !overdue {
+		return false
+	}
+	k.settle(ctx, execution, params)
+	return true
+}
+
+// settle compares the submitted results. Nodes outvoted by the majority
+// lose reputation and are slashed; nodes that submitted nothing only lose
+// reputation. If the task's own node is outvoted, its task fails.
+func (k Keeper) settle(ctx sdk.Context, execution *types.RedundantExecution, params types.Params) {
+	k.dequeue(ctx, types.ExecutionQueuePrefix, execution.Deadline, execution.TaskID)
+	for _, nodeID := range execution.Nodes {
+		if _, submitted := execution.Result(nodeID); !submitted {
+			execution.Missing = append(execution.Missing, nodeID)
+			k.computeKeeper.PenalizeNode(ctx, nodeID, params.ReputationPenalty)
+		}
+	}
+
+	reference, agreeing, ok := execution.Majority()
+	if !ok {
+		execution.Status = types.ExecutionStatusInconclusive
+	} else {
+		execution.Status = types.ExecutionStatusSettled
+		execution.OutputHash = reference.OutputHash
+
+		majority := make(map[string]bool)
+		for _, nodeID := range agreeing {
+			majority[nodeID] = true
+		}
+		for _, result := range execution.Results {
+			if majority[result.NodeID] {
+				continue
+			}
+			execution.Dissenters = append(execution.Dissenters, result.NodeID)
+			k.computeKeeper.PenalizeNode(ctx, result.NodeID, params.ReputationPenalty)
+			if err := k.slash(ctx, result.NodeID, params.SlashAmount); err != nil {
+				ctx.Logger().Error("failed to slash node", "node", result.NodeID, "task", execution.TaskID, "error", err)
+			}
+			if result.NodeID == execution.Nodes[0] {
+				k.failTask(ctx, execution.TaskID)
+			}
+		}
+	}
+
+	ctx.EventManager().EmitEvent(
+		sdk.NewEvent(
+			types.EventTypeExecutionSettled,
+			sdk.NewAttribute(types.AttributeKeyTaskID, execution.TaskID),
+			sdk.NewAttribute(types.AttributeKeyStatus, string(execution.Status)),
+			sdk.NewAttribute(types.AttributeKeyOutputHash, execution.OutputHash),
+			sdk.NewAttribute(types.AttributeKeyDissenters, strings.Join(execution.Dissenters, ",")),
+		),
+	)
+}
+
+// slash moves up to amount from the node's account to the module
+func (k Keeper) slash(ctx sdk.Context, nodeID string, amount sdk.Coin) error {
+	if amount.IsZero() {
+		return nil
+	}
+	node, found := k.computeKeeper.GetNode(ctx, nodeID)
+	if !found {
+		return fmt.Errorf("node %s not found", nodeID)
+	}
+	address, err := sdk.AccAddressFromBech32(node.Address)
+	if err != nil {
+		return fmt.Errorf("invalid node address: %w", err)
+	}
+
+	balance := k.bankKeeper.GetBalance(ctx, address, amount.Denom)
+	if balance.IsLT(amount) {
+		amount = balance
+	}
+	if amount.IsZero() {
+		return nil
+	}
+	if err := k.bankKeeper.SendCoinsFromAccountToModule(ctx, address, types.ModuleName, sdk.NewCoins(amount)); err != nil {
+		return fmt.Errorf("failed to slash node: %w", err)
+	}
+
+	ctx.EventManager().EmitEvent(
+		sdk.NewEvent(
+			types.EventTypeNodeSlashed,
+			sdk.NewAttribute(types.AttributeKeyNodeID, nodeID),
+			sdk.NewAttribute(types.AttributeKeyAmount, amount.String()),
+		),
+	)
+	return nil
+}
+
+func (k Keeper) failTask(ctx sdk.Context, taskID string) {
+	task, found := k.trainingKeeper.GetTask(ctx, taskID)
+	if !found {
+		return
+	}
+	task.Status = trainingtypes.TaskStatusFailed
+	task.UpdatedAt = ctx.BlockTime()
+	k.trainingKeeper.SetTask(ctx, task)
+}
+
+// nondeterministic reports whether the job says its training does not
+// reproduce bit for bit, e.g. because it uses non-deterministic GPU kernels
+func nondeterministic(job trainingtypes.Job) bool {
+	switch value := job.Config["nondeterministic"].(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	}
+	return false
+}
@@ -0,0 +1,215 @@
+package keeper
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/stretchr/testify/require"
+
+	computetypes "github.com/atlas/chain/x/compute/types"
+	trainingtypes "github.com/atlas/chain/x/training/types"
+	"github.com/atlas/chain/x/validation/types"
+)
+
+type testNodes map[string]ed25519.PrivateKey
+
+// setupRedundancy registers node-1 to node-4 and a task of node-1 that is
+// always run redundantly. node-4 is run by the same operator as node-1.
+func setupRedundancy(t *testing.T, jobConfig map[string]interface{}) (*Keeper, sdk.Context, testNodes) {
+	k, ctx := setupKeeper(t)
+
+	params := types.DefaultParams()
+	params.RedundancyFraction = 1
+	require.NoError(t, k.SetParams(ctx, params))
+
+	nodes := make(testNodes)
+	for i := 1; i <= 4; i++ {
+		pub, priv, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+		id := fmt.Sprintf("node-%d", i)
+		address := fmt.Sprintf("cosmos1operator%d", i)
+		if i == 4 {
+			address = "cosmos1operator1"
+		}
+		k.computeKeeper.SetNode(ctx, computetypes.Node{
+			ID:            id,
+			Address:       address,
+			Status:        "online",
+			Reputation:    100.0,
+			LastHeartbeat: ctx.BlockTime(),
+			PubKey:        base64.StdEncoding.EncodeToString(pub),
+		})
+		nodes[id] = priv
+	}
+
+	k.trainingKeeper.SetJob(ctx, trainingtypes.Job{ID: "job-1", DatasetCID: "QmDataset", Config: jobConfig})
+	k.trainingKeeper.SetTask(ctx, trainingtypes.Task{ID: "task-1", JobID: "job-1", NodeID: "node-1", Status: trainingtypes.TaskStatusInProgress})
+
+	return k, ctx, nodes
+}
+
+func (n testNodes) proof(nodeID string, output string) *trainingtypes.ComputationProof {
+	digest := func(data string) string {
+		sum := sha256.Sum256([]byte(data))
+		return hex.EncodeToString(sum[:])
+	}
+	proof := &trainingtypes.ComputationProof{
+		TaskId:       "task-1",
+		NodeId:       nodeID,
+		Timestamp:    1700000000,
+		InputCids:    []string{"QmModel", "QmDataset"},
+		OutputHash:   digest(output),
+		StateRoot:    digest(output + " states"),
+		SegmentCount: 10,
+	}
+	return n.sign(proof)
+}
+
+func (n testNodes) sign(proof *trainingtypes.ComputationProof) *trainingtypes.ComputationProof {
+	message := append([]byte(computetypes.DomainProof+"\x00"), proof.Digest()...)
+	proof.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(n[proof.NodeId], message))
+	return proof
+}
+
+func TestScheduleRedundantExecution(t *testing.T) {
+	k, ctx, _ := setupRedundancy(t, nil)
+
+	k.EndBlocker(ctx)
+	execution, found := k.GetExecution(ctx, "task-1")
+	require.True(t, found)
+	require.Equal(t, types.ExecutionStatusPending, execution.Status)
+	require.Equal(t, types.CompareOutputHash, execution.Compare)
+	// node-4 is not independent of node-1
+	require.Equal(t, "node-1", execution.Nodes[0])
+	require.ElementsMatch(t, []string{"node-1", "node-2", "node-3"}, execution.Nodes)
+	require.Len(t, k.GetPendingExecutionsByNode(ctx, "node-2"), 1)
+	require.Empty(t, k.GetPendingExecutionsByNode(ctx, "node-4"))
+
+	// Tasks are only considered once
+	k.EndBlocker(ctx)
+	again, _ := k.GetExecution(ctx, "task-1")
+	require.Equal(t, execution, again)
+
+	params := k.GetParams(ctx)
+	params.RedundancyFraction = 0
+	require.NoError(t, k.SetParams(ctx, params))
+	k.trainingKeeper.SetTask(ctx, trainingtypes.Task{ID: "task-2", JobID: "job-1", NodeID: "node-1", Status: trainingtypes.TaskStatusAssigned})
+	k.EndBlocker(ctx)
+	_, found = k.GetExecution(ctx, "task-2")
+	require.False(t, found)
+
+	params.Replicas = 1
+	require.ErrorIs(t, k.SetParams(ctx, params), types.ErrInvalidParams)
+}
+
+func TestSettleByMajority(t *testing.T) {
+	k, ctx, nodes := setupRedundancy(t, nil)
+	k.EndBlocker(ctx)
+
+	// The task's node completed the task; its proof counts as its result
+	task, _ := k.trainingKeeper.GetTask(ctx, "task-1")
+	task.Status = trainingtypes.TaskStatusCompleted
+	task.Proof = nodes.proof("node-1", "output")
+	k.trainingKeeper.SetTask(ctx, task)
+
+	require.ErrorIs(t, k.SubmitResult(ctx, "task-1", nodes.proof("node-4", "output"), nil), types.ErrNotAssigned)
+	require.ErrorIs(t, k.SubmitResult(ctx, "task-2", nodes.proof("node-2", "output"), nil), types.ErrExecutionNotFound)
+	forged := nodes.proof("node-2", "output")
+	forged.OutputHash = nodes.proof("node-2", "other output").OutputHash
+	require.ErrorIs(t, k.SubmitResult(ctx, "task-1", forged, nil), trainingtypes.ErrInvalidProof)
+
+	require.NoError(t, k.SubmitResult(ctx, "task-1", nodes.proof("node-2", "output"), nil))
+	require.ErrorIs(t, k.SubmitResult(ctx, "task-1", nodes.proof("node-2", "output"), nil), types.ErrDuplicateResult)
+	require.NoError(t, k.SubmitResult(ctx, "task-1", nodes.proof("node-3", "lazy output"), nil))
+
+	execution, _ := k.GetExecution(ctx, "task-1")
+	require.Equal(t, types.ExecutionStatusSettled, execution.Status)
+	require.Equal(t, task.Proof.OutputHash, execution.OutputHash)
+	require.Equal(t, []string{"node-3"}, execution.Dissenters)
+	require.Less(t, k.computeKeeper.GetNodeReputation(ctx, "node-3"), 100.0)
+	require.Equal(t, 100.0, k.computeKeeper.GetNodeReputation(ctx, "node-1"))
+	require.Equal(t, 100.0, k.computeKeeper.GetNodeReputation(ctx, "node-2"))
+
+	task, _ = k.trainingKeeper.GetTask(ctx, "task-1")
+	require.Equal(t, trainingtypes.TaskStatusCompleted, task.Status)
+	require.ErrorIs(t, k.SubmitResult(ctx, "task-1", nodes.proof("node-1", "output"), nil), types.ErrExecutionSettled)
+}
+
+func TestSettleOutvotedTaskNode(t *testing.T) {
+	k, ctx, nodes := setupRedundancy(t, nil)
+	k.EndBlocker(ctx)
+
+	require.NoError(t, k.SubmitResult(ctx, "task-1", nodes.proof("node-1", "made-up output"), nil))
+	require.NoError(t, k.SubmitResult(ctx, "task-1", nodes.proof("node-2", "output"), nil))
+	require.NoError(t, k.SubmitResult(ctx, "task-1", nodes.proof("node-3", "output"), nil))
+
+	require.Equal(t, "node-1", eventAttribute(ctx, types.EventTypeExecutionSettled, types.AttributeKeyDissenters))
+	require.Less(t, k.computeKeeper.GetNodeReputation(ctx, "node-1"), 100.0)
+	task, _ := k.trainingKeeper.GetTask(ctx, "task-1")
+	require.Equal(t, trainingtypes.TaskStatusFailed, task.Status)
+
+	// Nothing more is to be done for the failed task, so its execution is
+	// pruned
+	_, found := k.GetExecution(ctx, "task-1")
+	require.False(t, found)
+	require.Empty(t, k.GetPendingExecutionsByNode(ctx, "node-2"))
+}
+
+func TestSettleMetricsWithinTolerance(t *testing.T) {
+	k, ctx, nodes := setupRedundancy(t, map[string]interface{}{"nondeterministic": true})
+	k.EndBlocker(ctx)
+
+	execution, _ := k.GetExecution(ctx, "task-1")
+	require.Equal(t, types.CompareMetrics, execution.Compare)
+
+	// Outputs differ between runs; the metrics are within 1% or not
+	require.NoError(t, k.SubmitResult(ctx, "task-1", nodes.proof("node-1", "run 1"), map[string]float64{"loss": 0.500, "accuracy": 0.91}))
+	require.NoError(t, k.SubmitResult(ctx, "task-1", nodes.proof("node-2", "run 2"), map[string]float64{"loss": 0.503, "accuracy": 0.912}))
+	require.NoError(t, k.SubmitResult(ctx, "task-1", nodes.proof("node-3", "run 3"), map[string]float64{"loss": 0.9, "accuracy": 0.91}))
+
+	execution, _ = k.GetExecution(ctx, "task-1")
+	require.Equal(t, types.ExecutionStatusSettled, execution.Status)
+	require.Equal(t, []string{"node-3"}, execution.Dissenters)
+}
+
+func TestSettleAtDeadline(t *testing.T) {
+	k, ctx, nodes := setupRedundancy(t, nil)
+	k.EndBlocker(ctx)
+
+	require.NoError(t, k.SubmitResult(ctx, "task-1", nodes.proof("node-2", "output"), nil))
+	k.EndBlocker(ctx)
+	execution, _ := k.GetExecution(ctx, "task-1")
+	require.Equal(t, types.ExecutionStatusPending, execution.Status)
+
+	// One result out of three settles nothing, but the silent nodes pay
+	ctx = ctx.WithBlockTime(execution.Deadline)
+	k.EndBlocker(ctx)
+	execution, _ = k.GetExecution(ctx, "task-1")
+	require.Equal(t, types.ExecutionStatusInconclusive, execution.Status)
+	require.Equal(t, []string{"node-1", "node-3"}, execution.Missing)
+	require.Empty(t, execution.Dissenters)
+	require.Less(t, k.computeKeeper.GetNodeReputation(ctx, "node-3"), 100.0)
+	require.Equal(t, 100.0, k.computeKeeper.GetNodeReputation(ctx, "node-2"))
+	require.Empty(t, k.GetPendingExecutionsByNode(ctx, "node-3"))
+}
+
+// eventAttribute returns the value of key in the last event of eventType
+func eventAttribute(ctx sdk.Context, eventType string, key string) string {
+	var value string
+	for _, event := range ctx.EventManager().Events() {
+		if event.Type != eventType {
+			continue
+		}
+		for _, attribute := range event.Attributes {
+			if attribute.Key == key {
+				value = attribute.Value
+			}
+		}
+	}
+	return value
+}
@@ -19,7 +19,9 @@ import (
 	computekeeper "github.com/atlas/chain/x/compute/keeper"
 	computetypes "github.com/atlas/chain/x/compute/types"
 	healthkeeper "github.com/atlas/chain/x/health/keeper"
+	rewardkeeper "github.com/atlas/chain/x/reward/keeper"
 	shardingkeeper "github.com/atlas/chain/x/sharding/keeper"
+	storagekeeper "github.com/atlas/chain/x/storage/keeper"
 	trainingkeeper "github.com/atlas/chain/x/training/keeper"
 	trainingtypes "github.com/atlas/chain/x/training/types"
 )
@@ -31,6 +33,8 @@ func setupKeeper(t *testing.T) (*Keeper, sdk.Context) {
 	trainingStoreKey := sdk.NewKVStoreKey("training")
 	shardingStoreKey := sdk.NewKVStoreKey("sharding")
 	healthStoreKey := sdk.NewKVStoreKey("health")
+	storageStoreKey := sdk.NewKVStoreKey("storage")
+	rewardStoreKey := sdk.NewKVStoreKey("reward")
 
 	db := dbm.NewMemDB()
 	stateStore := store.NewCommitMultiStore(db)
@@ -40,6 +44,8 @@ func setupKeeper(t *testing.T) (*Keeper, sdk.Context) {
 	stateStore.MountStoreWithDB(trainingStoreKey, storetypes.StoreTypeIAVL, db)
 	stateStore.MountStoreWithDB(shardingStoreKey, storetypes.StoreTypeIAVL, db)
 	stateStore.MountStoreWithDB(healthStoreKey, storetypes.StoreTypeIAVL, db)
+	stateStore.MountStoreWithDB(storageStoreKey, storetypes.StoreTypeIAVL, db)
+	stateStore.MountStoreWithDB(rewardStoreKey, storetypes.StoreTypeIAVL, db)
 	require.NoError(t, stateStore.LoadLatestVersion())
 
 	registry := codectypes.NewInterfaceRegistry()
@@ -56,9 +62,11 @@ func setupKeeper(t *testing.T) (*Keeper, sdk.Context) {
 	computeKeeper := computekeeper.NewKeeper(cdc, computeStoreKey, storetypes.NewMemoryStoreKey("mem_compute"), bankKeeper)
 	healthKeeper := healthkeeper.NewKeeper(cdc, healthStoreKey, storetypes.NewMemoryStoreKey("mem_health"), computeKeeper)
 	shardingKeeper := shardingkeeper.NewKeeper(cdc, shardingStoreKey, storetypes.NewMemoryStoreKey("mem_sharding"))
-	trainingKeeper := trainingkeeper.NewKeeper(cdc, trainingStoreKey, storetypes.NewMemoryStoreKey("mem_training"), computeKeeper, nil, bankKeeper)
+	storageKeeper := storagekeeper.NewKeeper(cdc, storageStoreKey, storetypes.NewMemoryStoreKey("mem_storage"), bankKeeper)
+	trainingKeeper := trainingkeeper.NewKeeper(cdc, trainingStoreKey, storetypes.NewMemoryStoreKey("mem_training"), computeKeeper, storageKeeper, bankKeeper)
+	rewardKeeper := rewardkeeper.NewKeeper(cdc, rewardStoreKey, storetypes.NewMemoryStoreKey("mem_reward"), bankKeeper, computeKeeper, storageKeeper)
 
-	k := NewKeeper(cdc, storeKey, memStoreKey, shardingKeeper, trainingKeeper, computeKeeper, healthKeeper)
+	k := NewKeeper(cdc, storeKey, memStoreKey, trainingKeeper, shardingKeeper, computeKeeper, healthKeeper, bankKeeper, rewardKeeper)
 
 	ctx := sdk.NewContext(stateStore, tmproto.Header{Time: time.Now()}, false, log.NewNopLogger())
 
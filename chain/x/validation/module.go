@@ -8,6 +8,7 @@ import (
 
 	"github.com/cosmos/cosmos-sdk/client"
 	"github.com/cosmos/cosmos-sdk/codec"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/types/module"
 
@@ -23,7 +24,9 @@ type AppModule struct {
 
 func (AppModuleBasic) Name() string { return types.ModuleName }
 func (AppModuleBasic) RegisterLegacyAminoCodec(*codec.LegacyAmino) {}
-func (AppModuleBasic) RegisterInterfaces(types.InterfaceRegistry) {}
+func (AppModuleBasic) RegisterInterfaces(reg codectypes.InterfaceRegistry) {
+	types.RegisterInterfaces(reg)
+}
 func (AppModuleBasic) DefaultGenesis(codec.JSONCodec) json.RawMessage {
 	return json.RawMessage("{}")
 }
@@ -37,14 +40,17 @@ func NewAppModule(cdc codec.Codec, k keeper.Keeper) AppModule {
 	return AppModule{AppModuleBasic{cdc}, k}
 }
 func (am AppModule) Name() string { return am.AppModuleBasic.Name() }
-func (am AppModule) RegisterServices(module.Configurator) {}
+func (am AppModule) RegisterServices(cfg module.Configurator) {
+	types.RegisterMsgServer(cfg.MsgServer(), keeper.NewMsgServer(am.keeper))
+}
 func (am AppModule) InitGenesis(sdk.Context, codec.JSONCodec, json.RawMessage) {}
 func (am AppModule) ExportGenesis(sdk.Context, codec.JSONCodec) json.RawMessage {
 	return json.RawMessage("{}")
 }
 func (AppModule) ConsensusVersion() uint64 { return 1 }
 func (AppModule) BeginBlock(sdk.Context, abci.RequestBeginBlock) {}
-func (AppModule) EndBlock(sdk.Context, abci.RequestEndBlock) []abci.ValidatorUpdate {
+func (am AppModule) EndBlock(ctx sdk.Context, _ abci.RequestEndBlock) []abci.ValidatorUpdate {
+	am.keeper.EndBlocker(ctx)
 	return []abci.ValidatorUpdate{}
 }
 
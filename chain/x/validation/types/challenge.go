@@ -0,0 +1,121 @@
+package types
+
+import (
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// Bond is what an account has put up to challenge results, or as a node to
+// back its own. Locked coins back open challenges and unfinalized results
+// and can not be unbonded.
+type Bond struct {
+	Address string   `json:"address"`
+	Amount  sdk.Coin `json:"amount"`
+	Locked  sdk.Coin `json:"locked"`
+}
+
+// Free returns the coins that are not locked
+func (b Bond) Free() sdk.Coin {
+	return b.Amount.Sub(b.Locked)
+}
+
+type ResultStatus string
+
+const (
+	// Open to challenges until the window closes
+	ResultStatusLocked     ResultStatus = "locked"
+	ResultStatusChallenged ResultStatus = "challenged"
+	ResultStatusFinalized  ResultStatus = "finalized"
+	ResultStatusRejected   ResultStatus = "rejected"
+)
+
+// LockedResult is the result of a completed task while it can still be
+// challenged. The task's reward is only paid once it is finalized.
+type LockedResult struct {
+	TaskID       string       `json:"task_id"`
+	NodeID       string       `json:"node_id"`
+	Operator     string       `json:"operator"` // Address whose bond backs the result
+	OutputHash   string       `json:"output_hash"`
+	StateRoot    string       `json:"state_root"`
+	SegmentCount int          `json:"segment_count"`
+	Bond         sdk.Coin     `json:"bond"` // Zero if the result is unbacked
+	Status       ResultStatus `json:"status"`
+	LockedAt     time.Time    `json:"locked_at"`
+	WindowEnd    time.Time    `json:"window_end"`
+	Challenge    string       `json:"challenge,omitempty"` // The open challenge
+	Challenges   []string     `json:"challenges,omitempty"`
+}
+
+type ChallengeStatus string
+
+const (
+	ChallengeStatusOpen ChallengeStatus = "open"
+	// The referees replayed a different state than the node claimed
+	ChallengeStatusUpheld ChallengeStatus = "upheld"
+	// The referees replayed the state the node claimed
+	ChallengeStatusDismissed    ChallengeStatus = "dismissed"
+	ChallengeStatusInconclusive ChallengeStatus = "inconclusive"
+)
+
+// Challenge disputes one segment of a locked result. Referees replay the
+// segment from the checkpoint of the previous one, or from the task's
+// inputs for the first segment.
+type Challenge struct {
+	ID         string          `json:"id"`
+	TaskID     string          `json:"task_id"`
+	Challenger string          `json:"challenger"`
+	Bond       sdk.Coin        `json:"bond"`
+	Index      int             `json:"index"`
+	Segment    Segment         `json:"segment"`
+	Previous   *Segment        `json:"previous,omitempty"`
+	Referees   []string        `json:"referees"`
+	Results    []SegmentResult `json:"results"`
+	Status     ChallengeStatus `json:"status"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Deadline   time.Time       `json:"deadline"`
+	StateHash  string          `json:"state_hash,omitempty"` // Replayed by the majority
+}
+
+// SegmentResult is the state a referee reached replaying the segment
+type SegmentResult struct {
+	NodeID    string `json:"node_id"`
+	StateHash string `json:"state_hash"`
+}
+
+// SegmentResultMessage is what referees sign with DomainSegment
+func SegmentResultMessage(challengeID string, stateHash string) []byte {
+	return []byte(challengeID + "\n" + stateHash)
+}
+
+// IsReferee reports whether nodeID is one of the challenge's referees
+func (c Challenge) IsReferee(nodeID string) bool {
+	for _, id := range c.Referees {
+		if id == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
+// Result returns the state hash nodeID submitted, if any
+func (c Challenge) Result(nodeID string) (SegmentResult, bool) {
+	for _, result := range c.Results {
+		if result.NodeID == nodeID {
+			return result, true
+		}
+	}
+	return SegmentResult{}, false
+}
+
+// Majority returns the state hash more than half of the referees replayed
+func (c Challenge) Majority() (string, bool) {
+	counts := make(map[string]int)
+	for _, result := range c.Results {
+		counts[result.StateHash]++
+		if counts[result.StateHash]*2 > len(c.Referees) {
+			return result.StateHash, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,50 @@
+package types
+
+import (
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+var (
+	ErrInvalidParams     = sdkerrors.Register(ModuleName, 1, "invalid params")
+	ErrExecutionNotFound = sdkerrors.Register(ModuleName, 2, "redundant execution not found")
+	ErrNotAssigned       = sdkerrors.Register(ModuleName, 3, "node is not assigned to the task")
+	ErrDuplicateResult   = sdkerrors.Register(ModuleName, 4, "result already submitted")
+	ErrExecutionSettled  = sdkerrors.Register(ModuleName, 5, "redundant execution already settled")
+	ErrInvalidBond       = sdkerrors.Register(ModuleName, 6, "invalid bond")
+	ErrInsufficientBond  = sdkerrors.Register(ModuleName, 7, "insufficient bond")
+	ErrResultNotFound    = sdkerrors.Register(ModuleName, 8, "locked result not found")
+	ErrWindowClosed      = sdkerrors.Register(ModuleName, 9, "challenge window closed")
+	ErrChallengeOpen     = sdkerrors.Register(ModuleName, 10, "result is already being challenged")
+	ErrInvalidChallenge  = sdkerrors.Register(ModuleName, 11, "invalid challenge")
+	ErrChallengeNotFound = sdkerrors.Register(ModuleName, 12, "challenge not found")
+	ErrNoReferees        = sdkerrors.Register(ModuleName, 13, "not enough referees")
+)
+
+const (
+	EventTypeReplicasAssigned = "replicas_assigned"
+	EventTypeResultSubmitted  = "result_submitted"
+	EventTypeExecutionSettled = "execution_settled"
+	EventTypeNodeSlashed      = "node_slashed"
+	EventTypeBonded           = "bonded"
+	EventTypeUnbonded         = "unbonded"
+	EventTypeResultLocked     = "result_locked"
+	EventTypeResultFinalized  = "result_finalized"
+	EventTypeChallenged       = "challenged"
+	EventTypeSegmentReplayed  = "segment_replayed"
+	EventTypeChallengeSettled = "challenge_settled"
+	EventTypeBondSlashed      = "bond_slashed"
+
+	AttributeKeyTaskID     = "task_id"
+	AttributeKeyNodeID     = "node_id"
+	AttributeKeyNodes      = "nodes"
+	AttributeKeyStatus     = "status"
+	AttributeKeyOutputHash = "output_hash"
+	AttributeKeyDissenters = "dissenters"
+	AttributeKeyAmount     = "amount"
+	AttributeKeyAddress    = "address"
+	AttributeKeyChallenge  = "challenge_id"
+	AttributeKeySegment    = "segment"
+	AttributeKeyStateHash  = "state_hash"
+	AttributeKeyWindowEnd  = "window_end"
+	AttributeKeyWinner     = "winner"
+)
@@ -7,3 +7,23 @@ const (
 	MemStoreKey = "mem_validation"
 )
 
+var (
+	ParamsKey = []byte("p_validation")
+
+	ExecutionKeyPrefix = "execution:"
+	// Tasks that were considered for redundant execution
+	CheckedKeyPrefix = "checked:"
+	// Tasks whose result was finalized or rejected and pruned
+	FinishedKeyPrefix = "finished:"
+
+	BondKeyPrefix      = "bond:"
+	ResultKeyPrefix    = "result:"
+	ChallengeKeyPrefix = "challenge:"
+
+	// Pending executions, open challenges and locked results by when they
+	// are due: the prefix, the sortable due time, then the task or
+	// challenge ID
+	ExecutionQueuePrefix = "queue:execution:"
+	ChallengeQueuePrefix = "queue:challenge:"
+	ResultQueuePrefix    = "queue:result:"
+)
@@ -0,0 +1,57 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Segments of a proof of computation form a Merkle tree as in RFC 6962,
+// built by the node's proof package. The chain only keeps the root, so
+// disputed segments come with their audit path.
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+func leafHash(s Segment) []byte {
+	data := fmt.Sprintf("%d:%d:%s:%s", s.Epoch, s.Step, s.Checkpoint, s.StateHash)
+	sum := sha256.Sum256(append([]byte{leafPrefix}, data...))
+	return sum[:]
+}
+
+func nodeHash(left, right []byte) []byte {
+	data := make([]byte, 0, 1+len(left)+len(right))
+	data = append(data, nodePrefix)
+	data = append(data, left...)
+	sum := sha256.Sum256(append(data, right...))
+	return sum[:]
+}
+
+// VerifySegment checks that segment is number index of count segments
+// under root, given its audit path
+func VerifySegment(root string, segment Segment, index int, count int, path []string) bool {
+	if index < 0 || index >= count {
+		return false
+	}
+	hash := leafHash(segment)
+	fn, sn := index, count-1
+	for _, hexSibling := range path {
+		sibling, err := hex.DecodeString(hexSibling)
+		if err != nil || sn == 0 {
+			return false
+		}
+		if fn%2 == 1 || fn == sn {
+			hash = nodeHash(sibling, hash)
+			for fn%2 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			hash = nodeHash(hash, sibling)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	return sn == 0 && hex.EncodeToString(hash) == root
+}
@@ -0,0 +1,90 @@
+package types
+
+import (
+	"fmt"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// Params configure redundant execution and optimistic verification
+type Params struct {
+	// Fraction of tasks that are also run by Replicas other nodes
+	RedundancyFraction float64 `json:"redundancy_fraction"`
+	Replicas           int     `json:"replicas"`
+	// Relative difference up to which the metrics of non-deterministic
+	// tasks count as the same result
+	MetricTolerance float64 `json:"metric_tolerance"`
+	// How long after replicas are assigned results are settled with
+	// whatever has been submitted
+	ResultTimeout time.Duration `json:"result_timeout"`
+	// Reputation deducted from nodes outvoted by the majority, and from
+	// nodes that submit no result at all
+	ReputationPenalty float64 `json:"reputation_penalty"`
+	// Taken from the account of nodes outvoted by the majority
+	SlashAmount sdk.Coin `json:"slash_amount"`
+
+	// How long completed results can be challenged before they are
+	// finalized and their task's reward is paid
+	ChallengeWindow time.Duration `json:"challenge_window"`
+	// Locked from a challenger's bond for each challenge, and from a node's
+	// for each result it backs. The loser's is paid to the winner. Results
+	// of nodes without this much free in their bond earn no reward.
+	ChallengeBond sdk.Coin `json:"challenge_bond"`
+	// Nodes replaying a disputed segment, and how long they have to
+	Referees         int           `json:"referees"`
+	ChallengeTimeout time.Duration `json:"challenge_timeout"`
+	// Paid for a finalized task, scaled by the node's reputation
+	TaskReward sdk.Coin `json:"task_reward"`
+}
+
+func DefaultParams() Params {
+	return Params{
+		RedundancyFraction: 0.05,
+		Replicas:           2,
+		MetricTolerance:    0.01,
+		ResultTimeout:      24 * time.Hour,
+		ReputationPenalty:  10.0,
+		SlashAmount:        sdk.NewInt64Coin("uatlas", 1000000),
+		ChallengeWindow:    24 * time.Hour,
+		ChallengeBond:      sdk.NewInt64Coin("uatlas", 10000000),
+		Referees:           3,
+		ChallengeTimeout:   6 * time.Hour,
+		TaskReward:         sdk.NewInt64Coin("uatlas", 1000000),
+	}
+}
+
+func (p Params) Validate() error {
+	if p.RedundancyFraction < 0 || p.RedundancyFraction > 1 {
+		return fmt.Errorf("redundancy fraction must be between 0 and 1")
+	}
+	// A majority needs at least two results besides the original's
+	if p.Replicas < 2 {
+		return fmt.Errorf("at least 2 replicas are needed to settle disagreements")
+	}
+	if p.MetricTolerance < 0 {
+		return fmt.Errorf("metric tolerance must not be negative")
+	}
+	if p.ResultTimeout <= 0 {
+		return fmt.Errorf("result timeout must be positive")
+	}
+	if p.ReputationPenalty < 0 {
+		return fmt.Errorf("reputation penalty must not be negative")
+	}
+	if err := p.SlashAmount.Validate(); err != nil {
+		return fmt.Errorf("invalid slash amount: %w", err)
+	}
+	if p.ChallengeWindow <= 0 || p.ChallengeTimeout <= 0 {
+		return fmt.Errorf("challenge window and timeout must be positive")
+	}
+	if err := p.ChallengeBond.Validate(); err != nil || !p.ChallengeBond.IsPositive() {
+		return fmt.Errorf("challenge bond must be positive")
+	}
+	if p.Referees < 1 {
+		return fmt.Errorf("at least 1 referee is needed to settle challenges")
+	}
+	if err := p.TaskReward.Validate(); err != nil {
+		return fmt.Errorf("invalid task reward: %w", err)
+	}
+	return nil
+}
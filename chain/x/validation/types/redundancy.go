@@ -0,0 +1,114 @@
+package types
+
+import (
+	"math"
+	"time"
+)
+
+// Ways results of a redundantly executed task are compared
+const (
+	// Deterministic tasks must produce byte-identical outputs
+	CompareOutputHash = "output_hash"
+	// Non-deterministic training only has to land on the same metrics
+	// within the metric tolerance
+	CompareMetrics = "metrics"
+)
+
+type ExecutionStatus string
+
+const (
+	ExecutionStatusPending      ExecutionStatus = "pending"
+	ExecutionStatusSettled      ExecutionStatus = "settled"
+	ExecutionStatusInconclusive ExecutionStatus = "inconclusive"
+)
+
+// RedundantExecution tracks a task that is run by its own node and by
+// replicas so that their results can be compared
+type RedundantExecution struct {
+	TaskID    string            `json:"task_id"`
+	JobID     string            `json:"job_id"`
+	Nodes     []string          `json:"nodes"` // The task's node first, then the replicas
+	Compare   string            `json:"compare"`
+	Tolerance float64           `json:"tolerance"`
+	Results   []ExecutionResult `json:"results"`
+	Status    ExecutionStatus   `json:"status"`
+	CreatedAt time.Time         `json:"created_at"`
+	Deadline  time.Time         `json:"deadline"`
+
+	// Set when the execution is settled
+	OutputHash string   `json:"output_hash,omitempty"` // Of the majority
+	Dissenters []string `json:"dissenters,omitempty"`
+	Missing    []string `json:"missing,omitempty"`
+}
+
+// ExecutionResult is what one node committed to for the task
+type ExecutionResult struct {
+	NodeID      string             `json:"node_id"`
+	OutputHash  string             `json:"output_hash"`
+	StateRoot   string             `json:"state_root"`
+	Metrics     map[string]float64 `json:"metrics,omitempty"`
+	SubmittedAt time.Time          `json:"submitted_at"`
+}
+
+// IsAssigned reports whether nodeID is one of the nodes running the task
+func (e RedundantExecution) IsAssigned(nodeID string) bool {
+	for _, id := range e.Nodes {
+		if id == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
+// Result returns the result nodeID submitted, if any
+func (e RedundantExecution) Result(nodeID string) (ExecutionResult, bool) {
+	for _, result := range e.Results {
+		if result.NodeID == nodeID {
+			return result, true
+		}
+	}
+	return ExecutionResult{}, false
+}
+
+// Agree reports whether two results count as the same. Metrics are
+// compared if both results report some of the same ones; otherwise the
+// outputs must match exactly.
+func (e RedundantExecution) Agree(a, b ExecutionResult) bool {
+	if e.Compare != CompareMetrics {
+		return a.OutputHash == b.OutputHash
+	}
+
+	shared := 0
+	for name, x := range a.Metrics {
+		y, ok := b.Metrics[name]
+		if !ok {
+			continue
+		}
+		shared++
+		if math.Abs(x-y) > e.Tolerance*math.Max(math.Abs(x), math.Abs(y)) {
+			return false
+		}
+	}
+	if shared == 0 {
+		return a.OutputHash == b.OutputHash
+	}
+	return true
+}
+
+// Majority finds the largest group of submitted results that agree with one
+// result. It is only a majority if it holds more than half of the assigned
+// nodes, so nodes that submit nothing can not tip the outcome.
+func (e RedundantExecution) Majority() (reference ExecutionResult, agreeing []string, ok bool) {
+	for _, candidate := range e.Results {
+		var group []string
+		for _, result := range e.Results {
+			if e.Agree(candidate, result) {
+				group = append(group, result.NodeID)
+			}
+		}
+		if len(group) > len(agreeing) {
+			reference, agreeing = candidate, group
+		}
+	}
+	return reference, agreeing, len(agreeing)*2 > len(e.Nodes)
+}
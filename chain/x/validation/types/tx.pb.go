@@ -0,0 +1,317 @@
+package types
+
+import (
+	context "context"
+	grpc1 "github.com/cosmos/gogoproto/grpc"
+	proto "github.com/cosmos/gogoproto/proto"
+	grpc "google.golang.org/grpc"
+
+	types "github.com/cosmos/cosmos-sdk/types"
+
+	trainingtypes "github.com/atlas/chain/x/training/types"
+)
+
+// MsgSubmitResult commits a node's result of a task it was assigned to
+// run redundantly. Proof is signed by the node like the proof it would
+// complete the task with; Metrics are compared for non-deterministic tasks.
+type MsgSubmitResult struct {
+	Creator string                          `protobuf:"bytes,1,opt,name=creator,proto3" json:"creator,omitempty"`
+	TaskId  string                          `protobuf:"bytes,2,opt,name=task_id,json=taskId,proto3" json:"task_id,omitempty"`
+	Proof   *trainingtypes.ComputationProof `protobuf:"bytes,3,opt,name=proof,proto3" json:"proof,omitempty"`
+	Metrics map[string]float64              `protobuf:"bytes,4,rep,name=metrics,proto3" json:"metrics,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"fixed64,2,opt,name=value,proto3"`
+}
+
+func (m *MsgSubmitResult) Reset()         { *m = MsgSubmitResult{} }
+func (m *MsgSubmitResult) String() string { return proto.CompactTextString(m) }
+func (*MsgSubmitResult) ProtoMessage()    {}
+
+type MsgSubmitResultResponse struct {
+}
+
+func (m *MsgSubmitResultResponse) Reset()         { *m = MsgSubmitResultResponse{} }
+func (m *MsgSubmitResultResponse) String() string { return proto.CompactTextString(m) }
+func (*MsgSubmitResultResponse) ProtoMessage()    {}
+
+// Segment is one segment of a proof of computation as the node's proof
+// package commits to it
+type Segment struct {
+	Epoch      int64  `protobuf:"varint,1,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	Step       int64  `protobuf:"varint,2,opt,name=step,proto3" json:"step,omitempty"`
+	Checkpoint string `protobuf:"bytes,3,opt,name=checkpoint,proto3" json:"checkpoint,omitempty"`
+	StateHash  string `protobuf:"bytes,4,opt,name=state_hash,json=stateHash,proto3" json:"state_hash,omitempty"`
+}
+
+func (m *Segment) Reset()         { *m = Segment{} }
+func (m *Segment) String() string { return proto.CompactTextString(m) }
+func (*Segment) ProtoMessage()    {}
+
+type MsgBond struct {
+	Creator string     `protobuf:"bytes,1,opt,name=creator,proto3" json:"creator,omitempty"`
+	Amount  types.Coin `protobuf:"bytes,2,opt,name=amount,proto3" json:"amount"`
+}
+
+func (m *MsgBond) Reset()         { *m = MsgBond{} }
+func (m *MsgBond) String() string { return proto.CompactTextString(m) }
+func (*MsgBond) ProtoMessage()    {}
+
+type MsgBondResponse struct {
+}
+
+func (m *MsgBondResponse) Reset()         { *m = MsgBondResponse{} }
+func (m *MsgBondResponse) String() string { return proto.CompactTextString(m) }
+func (*MsgBondResponse) ProtoMessage()    {}
+
+type MsgUnbond struct {
+	Creator string     `protobuf:"bytes,1,opt,name=creator,proto3" json:"creator,omitempty"`
+	Amount  types.Coin `protobuf:"bytes,2,opt,name=amount,proto3" json:"amount"`
+}
+
+func (m *MsgUnbond) Reset()         { *m = MsgUnbond{} }
+func (m *MsgUnbond) String() string { return proto.CompactTextString(m) }
+func (*MsgUnbond) ProtoMessage()    {}
+
+type MsgUnbondResponse struct {
+}
+
+func (m *MsgUnbondResponse) Reset()         { *m = MsgUnbondResponse{} }
+func (m *MsgUnbondResponse) String() string { return proto.CompactTextString(m) }
+func (*MsgUnbondResponse) ProtoMessage()    {}
+
+// MsgChallenge disputes segment Index of a locked result. Segment and its
+// Merkle path show it is the segment the node committed to; Previous, for
+// all but the first segment, names the checkpoint the replay starts from.
+type MsgChallenge struct {
+	Creator      string   `protobuf:"bytes,1,opt,name=creator,proto3" json:"creator,omitempty"`
+	TaskId       string   `protobuf:"bytes,2,opt,name=task_id,json=taskId,proto3" json:"task_id,omitempty"`
+	Index        int32    `protobuf:"varint,3,opt,name=index,proto3" json:"index,omitempty"`
+	Segment      *Segment `protobuf:"bytes,4,opt,name=segment,proto3" json:"segment,omitempty"`
+	Path         []string `protobuf:"bytes,5,rep,name=path,proto3" json:"path,omitempty"`
+	Previous     *Segment `protobuf:"bytes,6,opt,name=previous,proto3" json:"previous,omitempty"`
+	PreviousPath []string `protobuf:"bytes,7,rep,name=previous_path,json=previousPath,proto3" json:"previous_path,omitempty"`
+}
+
+func (m *MsgChallenge) Reset()         { *m = MsgChallenge{} }
+func (m *MsgChallenge) String() string { return proto.CompactTextString(m) }
+func (*MsgChallenge) ProtoMessage()    {}
+
+type MsgChallengeResponse struct {
+	ChallengeId string   `protobuf:"bytes,1,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
+	Referees    []string `protobuf:"bytes,2,rep,name=referees,proto3" json:"referees,omitempty"`
+}
+
+func (m *MsgChallengeResponse) Reset()         { *m = MsgChallengeResponse{} }
+func (m *MsgChallengeResponse) String() string { return proto.CompactTextString(m) }
+func (*MsgChallengeResponse) ProtoMessage()    {}
+
+// MsgSubmitSegmentResult reports the state a referee reached replaying a
+// disputed segment, signed by the referee's node key
+type MsgSubmitSegmentResult struct {
+	Creator     string `protobuf:"bytes,1,opt,name=creator,proto3" json:"creator,omitempty"`
+	ChallengeId string `protobuf:"bytes,2,opt,name=challenge_id,json=challengeId,proto3" json:"challenge_id,omitempty"`
+	NodeId      string `protobuf:"bytes,3,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
+	StateHash   string `protobuf:"bytes,4,opt,name=state_hash,json=stateHash,proto3" json:"state_hash,omitempty"`
+	Signature   string `protobuf:"bytes,5,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *MsgSubmitSegmentResult) Reset()         { *m = MsgSubmitSegmentResult{} }
+func (m *MsgSubmitSegmentResult) String() string { return proto.CompactTextString(m) }
+func (*MsgSubmitSegmentResult) ProtoMessage()    {}
+
+type MsgSubmitSegmentResultResponse struct {
+}
+
+func (m *MsgSubmitSegmentResultResponse) Reset()         { *m = MsgSubmitSegmentResultResponse{} }
+func (m *MsgSubmitSegmentResultResponse) String() string { return proto.CompactTextString(m) }
+func (*MsgSubmitSegmentResultResponse) ProtoMessage()    {}
+
+type MsgClient interface {
+	SubmitResult(ctx context.Context, in *MsgSubmitResult, opts ...grpc.CallOption) (*MsgSubmitResultResponse, error)
+	Bond(ctx context.Context, in *MsgBond, opts ...grpc.CallOption) (*MsgBondResponse, error)
+	Unbond(ctx context.Context, in *MsgUnbond, opts ...grpc.CallOption) (*MsgUnbondResponse, error)
+	Challenge(ctx context.Context, in *MsgChallenge, opts ...grpc.CallOption) (*MsgChallengeResponse, error)
+	SubmitSegmentResult(ctx context.Context, in *MsgSubmitSegmentResult, opts ...grpc.CallOption) (*MsgSubmitSegmentResultResponse, error)
+}
+
+type msgClient struct {
+	cc grpc1.ClientConn
+}
+
+func NewMsgClient(cc grpc1.ClientConn) MsgClient {
+	return &msgClient{cc}
+}
+
+func (c *msgClient) SubmitResult(ctx context.Context, in *MsgSubmitResult, opts ...grpc.CallOption) (*MsgSubmitResultResponse, error) {
+	out := new(MsgSubmitResultResponse)
+	err := c.cc.Invoke(ctx, "/atlas.validation.Msg/SubmitResult", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *msgClient) Bond(ctx context.Context, in *MsgBond, opts ...grpc.CallOption) (*MsgBondResponse, error) {
+	out := new(MsgBondResponse)
+	err := c.cc.Invoke(ctx, "/atlas.validation.Msg/Bond", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *msgClient) Unbond(ctx context.Context, in *MsgUnbond, opts ...grpc.CallOption) (*MsgUnbondResponse, error) {
+	out := new(MsgUnbondResponse)
+	err := c.cc.Invoke(ctx, "/atlas.validation.Msg/Unbond", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *msgClient) Challenge(ctx context.Context, in *MsgChallenge, opts ...grpc.CallOption) (*MsgChallengeResponse, error) {
+	out := new(MsgChallengeResponse)
+	err := c.cc.Invoke(ctx, "/atlas.validation.Msg/Challenge", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *msgClient) SubmitSegmentResult(ctx context.Context, in *MsgSubmitSegmentResult, opts ...grpc.CallOption) (*MsgSubmitSegmentResultResponse, error) {
+	out := new(MsgSubmitSegmentResultResponse)
+	err := c.cc.Invoke(ctx, "/atlas.validation.Msg/SubmitSegmentResult", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+type MsgServer interface {
+	SubmitResult(context.Context, *MsgSubmitResult) (*MsgSubmitResultResponse, error)
+	Bond(context.Context, *MsgBond) (*MsgBondResponse, error)
+	Unbond(context.Context, *MsgUnbond) (*MsgUnbondResponse, error)
+	Challenge(context.Context, *MsgChallenge) (*MsgChallengeResponse, error)
+	SubmitSegmentResult(context.Context, *MsgSubmitSegmentResult) (*MsgSubmitSegmentResultResponse, error)
+}
+
+func RegisterMsgServer(s grpc1.Server, srv MsgServer) {
+	s.RegisterService(&_Msg_serviceDesc, srv)
+}
+
+func _Msg_SubmitResult_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MsgSubmitResult)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MsgServer).SubmitResult(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/atlas.validation.Msg/SubmitResult",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MsgServer).SubmitResult(ctx, req.(*MsgSubmitResult))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Msg_Bond_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MsgBond)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MsgServer).Bond(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/atlas.validation.Msg/Bond",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MsgServer).Bond(ctx, req.(*MsgBond))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Msg_Unbond_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MsgUnbond)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MsgServer).Unbond(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/atlas.validation.Msg/Unbond",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MsgServer).Unbond(ctx, req.(*MsgUnbond))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Msg_Challenge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MsgChallenge)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MsgServer).Challenge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/atlas.validation.Msg/Challenge",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MsgServer).Challenge(ctx, req.(*MsgChallenge))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Msg_SubmitSegmentResult_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MsgSubmitSegmentResult)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MsgServer).SubmitSegmentResult(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/atlas.validation.Msg/SubmitSegmentResult",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MsgServer).SubmitSegmentResult(ctx, req.(*MsgSubmitSegmentResult))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Msg_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "atlas.validation.Msg",
+	HandlerType: (*MsgServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SubmitResult",
+			Handler:    _Msg_SubmitResult_Handler,
+		},
+		{
+			MethodName: "Bond",
+			Handler:    _Msg_Bond_Handler,
+		},
+		{
+			MethodName: "Unbond",
+			Handler:    _Msg_Unbond_Handler,
+		},
+		{
+			MethodName: "Challenge",
+			Handler:    _Msg_Challenge_Handler,
+		},
+		{
+			MethodName: "SubmitSegmentResult",
+			Handler:    _Msg_SubmitSegmentResult_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "atlas/validation/tx.proto",
+}
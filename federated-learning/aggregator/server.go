@@ -6,15 +6,23 @@ import (
 	"fmt"
 	"time"
 	"github.com/atlas/federated-learning/protocols"
+	"github.com/atlas/storage/identity"
+	"github.com/atlas/storage/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+const tracerName = "github.com/atlas/federated-learning/aggregator"
+
 type Aggregator struct {
 	clients      map[string]*ClientState
 	protocol     *protocols.FLProtocol
 	jobID        string
 	round        int
-	nodeID       string // This node's ID (for distributed aggregation)
-	isAggregator bool   // Whether this node is acting as aggregator
+	nodeID       string               // This node's ID (for distributed aggregation)
+	isAggregator bool                 // Whether this node is acting as aggregator
+	keys         identity.KeyResolver // Verifies gradient messages if set
 }
 
 type ClientState struct {
@@ -23,6 +31,8 @@ type ClientState struct {
 	Ready       bool
 	Timestamp   time.Time
 	Contribution float64 // Contribution weight for fairness
+
+	trace trace.SpanContext // Span that sent the gradients, if traced
 }
 
 func NewAggregator(jobID string, nodeID string, ipfsAPIURL string) *Aggregator {
@@ -36,6 +46,12 @@ func NewAggregator(jobID string, nodeID string, ipfsAPIURL string) *Aggregator {
 	}
 }
 
+// SetKeyResolver makes the aggregator drop gradient messages that are not
+// signed with their node's registered key
+func (a *Aggregator) SetKeyResolver(keys identity.KeyResolver) {
+	a.keys = keys
+}
+
 func (a *Aggregator) BecomeAggregator() error {
 	a.isAggregator = true
 	topic := fmt.Sprintf("/atlas/fl/aggregator/%s", a.jobID)
@@ -77,9 +93,14 @@ func (a *Aggregator) Start(ctx context.Context) error {
 				if err := json.Unmarshal(msg.Data, &gradientMsg); err != nil {
 					continue
 				}
+				if err := a.verify(&gradientMsg); err != nil {
+					fmt.Printf("Warning: dropping gradients: %v\n", err)
+					continue
+				}
 				
 				if gradientMsg.Round == a.round {
-					if err := a.ReceiveGradients(gradientMsg.NodeID, gradientMsg.Gradients); err != nil {
+					sender := trace.SpanContextFromContext(tracing.Extract(ctx, gradientMsg.TraceContext))
+					if err := a.receive(gradientMsg.NodeID, gradientMsg.Gradients, sender); err != nil {
 						continue
 					}
 				}
@@ -90,38 +111,67 @@ func (a *Aggregator) Start(ctx context.Context) error {
 	return nil
 }
 
+// verify checks a gradient message's signature if a key resolver is set
+func (a *Aggregator) verify(msg *protocols.GradientMessage) error {
+	if a.keys == nil {
+		return nil
+	}
+	publicKey, err := a.keys(msg.NodeID)
+	if err != nil {
+		return fmt.Errorf("no key for node %s: %w", msg.NodeID, err)
+	}
+	return msg.Verify(publicKey)
+}
+
 func (a *Aggregator) ReceiveGradients(nodeID string, gradients []float64) error {
+	return a.receive(nodeID, gradients, trace.SpanContext{})
+}
+
+// receive records a client's gradients and the span that sent them
+func (a *Aggregator) receive(nodeID string, gradients []float64, sender trace.SpanContext) error {
 	if state, ok := a.clients[nodeID]; ok {
 		state.Gradients = gradients
 		state.Ready = true
 		state.Timestamp = time.Now()
+		state.trace = sender
 	} else {
 		a.clients[nodeID] = &ClientState{
 			NodeID:    nodeID,
 			Gradients: gradients,
 			Ready:     true,
 			Timestamp: time.Now(),
+			trace:     sender,
 		}
 	}
 	return nil
 }
 
-func (a *Aggregator) Aggregate() ([]float64, error) {
+func (a *Aggregator) Aggregate() (aggregated []float64, err error) {
 	var gradientsList [][]float64
 	var weights []float64
+	// The aggregation fans in the clients' traces, so it links to them
+	// rather than belonging to one
+	var links []trace.Link
 	
 	for _, state := range a.clients {
 		if state.Ready {
 			gradientsList = append(gradientsList, state.Gradients)
 			weights = append(weights, 1.0)
+			if state.trace.IsValid() {
+				links = append(links, trace.Link{SpanContext: state.trace})
+			}
 		}
 	}
+
+	_, span := otel.Tracer(tracerName).Start(context.Background(), "fl.aggregate", trace.WithLinks(links...),
+		trace.WithAttributes(attribute.String("fl.job_id", a.jobID), attribute.Int("fl.round", a.round), attribute.Int("fl.clients", len(gradientsList))))
+	defer func() { tracing.End(span, err) }()
 	
 	if len(gradientsList) == 0 {
 		return nil, fmt.Errorf("no gradients to aggregate")
 	}
 	
-	aggregated, err := FederatedAveraging(gradientsList, weights)
+	aggregated, err = FederatedAveraging(gradientsList, weights)
 	if err != nil {
 		return nil, fmt.Errorf("aggregation failed: %w", err)
 	}
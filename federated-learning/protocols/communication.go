@@ -2,15 +2,24 @@ package protocols
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"math"
 	"time"
+	"github.com/atlas/storage/identity"
+	"github.com/atlas/storage/tracing"
 	"github.com/ipfs/go-ipfs-api"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+const tracerName = "github.com/atlas/federated-learning/protocols"
+
 type FLProtocol struct {
 	api    *api.Shell
 	nodeID string
+	signer *identity.Identity
 }
 
 func NewFLProtocol(apiURL string, nodeID string) *FLProtocol {
@@ -24,23 +33,61 @@ func (p *FLProtocol) GetAPI() *api.Shell {
 	return p.api
 }
 
+// SetIdentity sets the key gradient messages are signed with
+func (p *FLProtocol) SetIdentity(signer *identity.Identity) {
+	p.signer = signer
+}
+
 type GradientMessage struct {
 	NodeID    string    `json:"node_id"`
 	JobID     string    `json:"job_id"`
 	Round     int       `json:"round"`
 	Gradients []float64 `json:"gradients"`
 	Timestamp string    `json:"timestamp"`
+	// W3C trace context of the sender, so the aggregator can link its
+	// aggregation to the rounds that produced the gradients
+	TraceContext map[string]string `json:"trace_context,omitempty"`
+	// The sender's ed25519 signature of SignedBytes
+	Signature string `json:"signature,omitempty"`
+}
+
+// SignedBytes is what the sender signs: the node, job, round, timestamp
+// and a digest of the gradients
+func (m *GradientMessage) SignedBytes() []byte {
+	digest := sha256.New()
+	value := make([]byte, 8)
+	for _, gradient := range m.Gradients {
+		binary.BigEndian.PutUint64(value, math.Float64bits(gradient))
+		digest.Write(value)
+	}
+	return []byte(fmt.Sprintf("%s:%s:%d:%s:%x", m.NodeID, m.JobID, m.Round, m.Timestamp, digest.Sum(nil)))
 }
 
-func (p *FLProtocol) SendGradients(ctx context.Context, jobID string, round int, gradients []float64) error {
+// Verify checks the message's signature against publicKey, the key its
+// node registered on chain
+func (m *GradientMessage) Verify(publicKey string) error {
+	if err := identity.Verify(publicKey, identity.DomainGradients, m.SignedBytes(), m.Signature); err != nil {
+		return fmt.Errorf("gradients from %s: %w", m.NodeID, err)
+	}
+	return nil
+}
+
+func (p *FLProtocol) SendGradients(ctx context.Context, jobID string, round int, gradients []float64) (err error) {
 	topic := fmt.Sprintf("/atlas/fl/gradients/%s", jobID)
+	ctx, span := tracing.Start(ctx, tracerName, "fl.send_gradients",
+		attribute.String("fl.job_id", jobID), attribute.Int("fl.round", round), attribute.Int("fl.gradients", len(gradients)))
+	defer func() { tracing.End(span, err) }()
 	
 	msg := GradientMessage{
-		NodeID:    p.nodeID,
-		JobID:     jobID,
-		Round:     round,
-		Gradients: gradients,
-		Timestamp: fmt.Sprintf("%d", time.Now().Unix()),
+		NodeID:       p.nodeID,
+		JobID:        jobID,
+		Round:        round,
+		Gradients:    gradients,
+		Timestamp:    fmt.Sprintf("%d", time.Now().Unix()),
+		TraceContext: tracing.Inject(ctx),
+	}
+	if p.signer != nil {
+		msg.Signature = p.signer.Sign(identity.DomainGradients, msg.SignedBytes())
 	}
 
 	data, err := json.Marshal(msg)
@@ -0,0 +1,20 @@
+package protocols
+
+import (
+	"testing"
+
+	"github.com/atlas/storage/identity"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGradientMessageSignature(t *testing.T) {
+	id, err := identity.Generate()
+	require.NoError(t, err)
+
+	msg := &GradientMessage{NodeID: "node-1", JobID: "job-1", Round: 2, Gradients: []float64{0.5, -1.25}, Timestamp: "1700000000"}
+	msg.Signature = id.Sign(identity.DomainGradients, msg.SignedBytes())
+	require.NoError(t, msg.Verify(id.PublicKey()))
+
+	msg.Gradients[1] = -1.5
+	require.ErrorIs(t, msg.Verify(id.PublicKey()), identity.ErrInvalidSignature)
+}
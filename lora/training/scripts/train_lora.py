@@ -0,0 +1,48 @@
+#!/usr/bin/env python3
+# Atlas LoRA training script. All parameters are read from the JSON file
+# named by ATLAS_PARAMS_FILE (default: params.json in the working directory).
+import json
+import os
+import torch
+import torch.nn as nn
+from torch.utils.data import Dataset, DataLoader
+
+SCRIPT_VERSION = "lora-training/2"
+
+with open(os.environ.get("ATLAS_PARAMS_FILE", "params.json"), 'r') as f:
+    params = json.load(f)
+
+# Load adapter configuration
+with open(params['adapter_config_path'], 'r') as f:
+    adapter_config = json.load(f)
+
+weights = adapter_config['weights']
+
+# Load dataset
+# dataset = load_dataset(params['dataset_path'])
+# dataloader = DataLoader(dataset, batch_size=32, shuffle=True)
+
+# LoRA Training Loop (simplified)
+# In production, this would:
+# 1. Load base model
+# 2. Apply LoRA adapters to target modules
+# 3. Train only LoRA parameters (freeze base model)
+# 4. Update LoRA weights (B and A matrices)
+# 5. Save updated weights
+
+# Simulated training updates
+for module_name, module_weights in weights.items():
+    for i in range(len(module_weights)):
+        # Simulated gradient update
+        weights[module_name][i] += 0.001 * (torch.rand(1).item() - 0.5)
+
+# Save updated weights
+output_config = {
+    "weights": weights,
+    "script_version": SCRIPT_VERSION
+}
+
+with open('adapter_weights.json', 'w') as f:
+    json.dump(output_config, f, indent=2)
+
+print("LoRA training completed")
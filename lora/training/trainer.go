@@ -2,6 +2,7 @@ package training
 
 import (
 	"context"
+	_ "embed"
 	"encoding/json"
 	"fmt"
 	"math/rand"
@@ -11,11 +12,27 @@ import (
 	"time"
 	
 	"github.com/atlas/lora/adapters"
+	"github.com/atlas/storage/cache"
 )
 
+// The training script is embedded and written out unchanged; paths reach
+// it only through params.json. Bump the version in scripts/train_lora.py
+// whenever the script changes.
+//
+//go:embed scripts/train_lora.py
+var trainingScript []byte
+
+// trainingParams are the parameters read by scripts/train_lora.py
+type trainingParams struct {
+	DatasetPath       string `json:"dataset_path"`
+	AdapterConfigPath string `json:"adapter_config_path"`
+}
+
 type LoRATrainer struct {
-	adapter *adapters.LoRAAdapter
-	workDir string // Working directory for training scripts
+	adapter       *adapters.LoRAAdapter
+	workDir       string       // Working directory for training scripts
+	artifacts     *cache.Cache // Fetches datasets given by CID, if set
+	scriptVersion string       // Reported by the training script in the last run
 }
 
 func NewLoRATrainer(adapter *adapters.LoRAAdapter) *LoRATrainer {
@@ -29,7 +46,22 @@ func (t *LoRATrainer) SetWorkDir(workDir string) {
 	t.workDir = workDir
 }
 
+// SetArtifactCache lets Train take datasets by CID, fetched through the
+// node's artifact cache
+func (t *LoRATrainer) SetArtifactCache(artifacts *cache.Cache) {
+	t.artifacts = artifacts
+}
+
 func (t *LoRATrainer) Train(ctx context.Context, datasetPath string) error {
+	if t.artifacts != nil && cache.IsCID(datasetPath) {
+		handle, err := t.artifacts.Acquire(ctx, datasetPath)
+		if err != nil {
+			return fmt.Errorf("failed to fetch dataset: %w", err)
+		}
+		defer handle.Release()
+		datasetPath = handle.Path
+	}
+
 	trainDir := filepath.Join(t.workDir, fmt.Sprintf("lora_train_%d", time.Now().Unix()))
 	if err := os.MkdirAll(trainDir, 0755); err != nil {
 		return fmt.Errorf("failed to create training directory: %w", err)
@@ -109,6 +141,10 @@ func (t *LoRATrainer) loadWeightsFromFile(path string) error {
 		return fmt.Errorf("failed to parse weights JSON: %w", err)
 	}
 	
+	if version, ok := config["script_version"].(string); ok {
+		t.scriptVersion = version
+	}
+	
 	if weightsData, ok := config["weights"].(map[string]interface{}); ok {
 		weights := make(map[string][]float64)
 		for k, v := range weightsData {
@@ -129,49 +165,28 @@ func (t *LoRATrainer) loadWeightsFromFile(path string) error {
 	return fmt.Errorf("invalid weights format in file")
 }
 
+// createTrainingScript writes the embedded training script and the
+// params.json it reads its parameters from
 func (t *LoRATrainer) createTrainingScript(scriptPath string, datasetPath string, adapterConfigPath string) error {
-	script := fmt.Sprintf(`#!/usr/bin/env python3
-import json
-import torch
-import torch.nn as nn
-from torch.utils.data import Dataset, DataLoader
-
-# Load adapter configuration
-with open('%s', 'r') as f:
-    adapter_config = json.load(f)
-
-weights = adapter_config['weights']
-
-# Load dataset
-# dataset = load_dataset('%s')
-# dataloader = DataLoader(dataset, batch_size=32, shuffle=True)
-
-# LoRA Training Loop (simplified)
-# In production, this would:
-# 1. Load base model
-# 2. Apply LoRA adapters to target modules
-# 3. Train only LoRA parameters (freeze base model)
-# 4. Update LoRA weights (B and A matrices)
-# 5. Save updated weights
-
-# Simulated training updates
-for module_name, module_weights in weights.items():
-    for i in range(len(module_weights)):
-        # Simulated gradient update
-        weights[module_name][i] += 0.001 * (torch.rand(1).item() - 0.5)
-
-# Save updated weights
-output_config = {
-    "weights": weights
-}
+	params, err := json.MarshalIndent(trainingParams{
+		DatasetPath:       datasetPath,
+		AdapterConfigPath: adapterConfigPath,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode training parameters: %w", err)
+	}
 
-with open('adapter_weights.json', 'w') as f:
-    json.dump(output_config, f, indent=2)
+	if err := os.WriteFile(filepath.Join(filepath.Dir(scriptPath), "params.json"), params, 0644); err != nil {
+		return fmt.Errorf("failed to write training parameters: %w", err)
+	}
 
-print("LoRA training completed")
-`, adapterConfigPath, datasetPath)
+	return os.WriteFile(scriptPath, trainingScript, 0755)
+}
 
-	return os.WriteFile(scriptPath, []byte(script), 0755)
+// ScriptVersion returns the version reported by the training script in the
+// last training run
+func (t *LoRATrainer) ScriptVersion() string {
+	return t.scriptVersion
 }
 
 func (t *LoRATrainer) GetAdapterWeights() (map[string][]float64, error) {
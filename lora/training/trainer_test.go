@@ -2,6 +2,7 @@ package training
 
 import (
 	"context"
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"testing"
@@ -74,3 +75,38 @@ func TestSaveAndLoadAdapterConfig(t *testing.T) {
 	require.NoError(t, err)
 }
 
+
+func TestCreateTrainingScript(t *testing.T) {
+	adapter := adapters.NewLoRAAdapter(4, 8.0)
+	trainer := NewLoRATrainer(adapter)
+	
+	tempDir := t.TempDir()
+	scriptPath := filepath.Join(tempDir, "train_lora.py")
+	datasetPath := "data'); __import__('os').system('id'); ('"
+	
+	err := trainer.createTrainingScript(scriptPath, datasetPath, filepath.Join(tempDir, "adapter_config.json"))
+	require.NoError(t, err)
+	
+	// Paths only reach the script through params.json
+	script, err := os.ReadFile(scriptPath)
+	require.NoError(t, err)
+	require.Equal(t, trainingScript, script)
+	
+	data, err := os.ReadFile(filepath.Join(tempDir, "params.json"))
+	require.NoError(t, err)
+	var params trainingParams
+	require.NoError(t, json.Unmarshal(data, &params))
+	require.Equal(t, datasetPath, params.DatasetPath)
+}
+
+func TestLoadWeightsRecordsScriptVersion(t *testing.T) {
+	adapter := adapters.NewLoRAAdapter(4, 8.0)
+	trainer := NewLoRATrainer(adapter)
+	
+	path := filepath.Join(t.TempDir(), "adapter_weights.json")
+	err := os.WriteFile(path, []byte(`{"weights": {"q_proj": [1.5]}, "script_version": "lora-training/2"}`), 0644)
+	require.NoError(t, err)
+	
+	require.NoError(t, trainer.loadWeightsFromFile(path))
+	require.Equal(t, "lora-training/2", trainer.ScriptVersion())
+}
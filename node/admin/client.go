@@ -0,0 +1,212 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Client talks to the admin API of a running node
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// NewClient creates a client for an admin API address ("unix:<path>" or
+// "host:port") authenticating with token
+func NewClient(address string, token string) *Client {
+	transport := &http.Transport{}
+	baseURL := "http://" + address
+
+	if path, ok := strings.CutPrefix(address, "unix:"); ok {
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", path)
+		}
+		baseURL = "http://atlas-node"
+	}
+
+	return &Client{
+		baseURL:    baseURL,
+		token:      token,
+		httpClient: &http.Client{Transport: transport},
+	}
+}
+
+// ListTasks returns all tasks on the node
+func (c *Client) ListTasks(ctx context.Context) ([]TaskInfo, error) {
+	var tasks []TaskInfo
+	err := c.do(ctx, http.MethodGet, "/tasks", nil, &tasks)
+	return tasks, err
+}
+
+// GetTask returns a single task, including its output
+func (c *Client) GetTask(ctx context.Context, taskID string) (*TaskInfo, error) {
+	var task TaskInfo
+	if err := c.do(ctx, http.MethodGet, "/tasks/"+url.PathEscape(taskID), nil, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// SubmitTask queues a new task and returns it
+func (c *Client) SubmitTask(ctx context.Context, req TaskRequest) (*TaskInfo, error) {
+	var task TaskInfo
+	if err := c.do(ctx, http.MethodPost, "/tasks", req, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// PauseTask pauses a running task
+func (c *Client) PauseTask(ctx context.Context, taskID string) (*TaskInfo, error) {
+	return c.taskAction(ctx, taskID, "pause")
+}
+
+// ResumeTask resumes a paused task
+func (c *Client) ResumeTask(ctx context.Context, taskID string) (*TaskInfo, error) {
+	return c.taskAction(ctx, taskID, "resume")
+}
+
+// CancelTask cancels a task
+func (c *Client) CancelTask(ctx context.Context, taskID string) (*TaskInfo, error) {
+	return c.taskAction(ctx, taskID, "cancel")
+}
+
+// RemoveTask removes a completed, failed or cancelled task from the node
+func (c *Client) RemoveTask(ctx context.Context, taskID string) error {
+	resp, err := c.send(ctx, http.MethodDelete, "/tasks/"+url.PathEscape(taskID), nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (c *Client) taskAction(ctx context.Context, taskID string, action string) (*TaskInfo, error) {
+	var task TaskInfo
+	if err := c.do(ctx, http.MethodPost, "/tasks/"+url.PathEscape(taskID)+"/"+action, nil, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// Resources returns the node's resources and current allocations
+func (c *Client) Resources(ctx context.Context) (*ResourcesInfo, error) {
+	var info ResourcesInfo
+	if err := c.do(ctx, http.MethodGet, "/resources", nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Inference returns the node's model workers and batching stats
+func (c *Client) Inference(ctx context.Context) (*InferenceInfo, error) {
+	var info InferenceInfo
+	if err := c.do(ctx, http.MethodGet, "/inference", nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// DrainStatus reports whether the node is draining
+func (c *Client) DrainStatus(ctx context.Context) (*DrainInfo, error) {
+	return c.drain(ctx, http.MethodGet)
+}
+
+// Drain stops the node from admitting tasks
+func (c *Client) Drain(ctx context.Context) (*DrainInfo, error) {
+	return c.drain(ctx, http.MethodPost)
+}
+
+// Undrain lets the node admit tasks again
+func (c *Client) Undrain(ctx context.Context) (*DrainInfo, error) {
+	return c.drain(ctx, http.MethodDelete)
+}
+
+func (c *Client) drain(ctx context.Context, method string) (*DrainInfo, error) {
+	var info DrainInfo
+	if err := c.do(ctx, method, "/drain", nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Logs copies the last lines of a task's output to w. With follow it keeps
+// copying until the task stops running or ctx is cancelled.
+func (c *Client) Logs(ctx context.Context, taskID string, lines int, follow bool, w io.Writer) error {
+	query := url.Values{}
+	query.Set("lines", strconv.Itoa(lines))
+	if follow {
+		query.Set("follow", "true")
+	}
+
+	resp, err := c.send(ctx, http.MethodGet, "/tasks/"+url.PathEscape(taskID)+"/logs?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(w, resp.Body); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("failed to read logs: %w", err)
+	}
+	return nil
+}
+
+// do sends a JSON request and decodes the JSON response into out
+func (c *Client) do(ctx context.Context, method string, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	resp, err := c.send(ctx, method, path, reader)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// send performs a request and turns error responses into errors
+func (c *Client) send(ctx context.Context, method string, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+apiPrefix+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach node admin API: %w", err)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		var apiErr errorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
+			return nil, fmt.Errorf("admin API returned %s", resp.Status)
+		}
+		return nil, errors.New(apiErr.Error)
+	}
+	return resp, nil
+}
@@ -0,0 +1,542 @@
+package admin
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atlas/node/executor"
+	"github.com/atlas/node/proof"
+	"github.com/atlas/node/resource"
+	"github.com/atlas/storage/tracing"
+)
+
+const tracerName = "github.com/atlas/node/admin"
+
+// The admin API is HTTP/JSON, served on a unix socket or a loopback address.
+// Every request must carry "Authorization: Bearer <token>".
+//
+//	GET    /v1/tasks                     list tasks
+//	POST   /v1/tasks                     submit a task (TaskRequest)
+//	GET    /v1/tasks/{id}                inspect a task
+//	POST   /v1/tasks/{id}/pause          pause a running task
+//	POST   /v1/tasks/{id}/resume         resume a paused task
+//	POST   /v1/tasks/{id}/cancel         cancel a task
+//	GET    /v1/tasks/{id}/logs           task output (?lines=N&follow=true)
+//	GET    /v1/tasks/{id}/metrics        task progress and metrics events
+//	GET    /v1/resources                 node resources and allocations
+//	GET    /v1/drain                     drain status
+//	POST   /v1/drain                     stop admitting tasks
+//	DELETE /v1/drain                     admit tasks again
+const apiPrefix = "/v1"
+
+// ResourceReporter reports node resources and per-task allocations.
+// *resource.Manager implements it.
+type ResourceReporter interface {
+	GetResources() map[string]interface{}
+	GetAllocations() []resource.ResourceAllocation
+}
+
+// TaskRequest is the body of a task submission
+type TaskRequest struct {
+	ID           string                `json:"id"`
+	JobID        string                `json:"job_id,omitempty"`
+	ShardID      string                `json:"shard_id,omitempty"`
+	TaskType     string                `json:"task_type"`
+	ModelPath    string                `json:"model_path,omitempty"`
+	DatasetPath  string                `json:"dataset_path,omitempty"`
+	Input        json.RawMessage       `json:"input,omitempty"`
+	Requirements resource.Requirements `json:"requirements"`
+	Priority     int                   `json:"priority,omitempty"`
+	Metadata     map[string]string     `json:"metadata,omitempty"`
+}
+
+// TaskInfo is the API view of a task
+type TaskInfo struct {
+	ID            string                    `json:"id"`
+	JobID         string                    `json:"job_id,omitempty"`
+	ShardID       string                    `json:"shard_id,omitempty"`
+	TaskType      string                    `json:"task_type"`
+	Status        string                    `json:"status"`
+	Progress      float64                   `json:"progress"`
+	CheckpointCID string                    `json:"checkpoint_cid,omitempty"`
+	ModelPath     string                    `json:"model_path,omitempty"`
+	DatasetPath   string                    `json:"dataset_path,omitempty"`
+	Output        json.RawMessage           `json:"output,omitempty"`
+	CreatedAt     time.Time                 `json:"created_at"`
+	StartedAt     *time.Time                `json:"started_at,omitempty"`
+	CompletedAt   *time.Time                `json:"completed_at,omitempty"`
+	Error         string                    `json:"error,omitempty"`
+	Requirements  resource.Requirements     `json:"requirements"`
+	Priority      int                       `json:"priority,omitempty"`
+	Usage         *executor.ResourceUsage   `json:"usage,omitempty"`
+	Metadata      map[string]string         `json:"metadata,omitempty"`
+	Proof         *proof.ProofOfComputation `json:"proof,omitempty"`
+}
+
+// ResourcesInfo is the body of GET /v1/resources
+type ResourcesInfo struct {
+	Resources   map[string]interface{}        `json:"resources"`
+	Allocations []resource.ResourceAllocation `json:"allocations"`
+}
+
+// DrainInfo is the body of the drain endpoints
+type DrainInfo struct {
+	Draining     bool `json:"draining"`
+	RunningTasks int  `json:"running_tasks"`
+}
+
+// InferenceInfo is the body of GET /v1/inference
+type InferenceInfo struct {
+	Workers []executor.WorkerInfo `json:"workers"`
+	Batches []executor.BatchStats `json:"batches"`
+}
+
+// errorResponse is the body of every failed request
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Server serves the admin API for an executor
+type Server struct {
+	executor  *executor.Executor
+	resources ResourceReporter
+	token     string
+	mux       *http.ServeMux
+}
+
+// NewServer creates an admin server. resources may be nil if the node has
+// no resource manager.
+func NewServer(exec *executor.Executor, resources ResourceReporter, token string) *Server {
+	s := &Server{
+		executor:  exec,
+		resources: resources,
+		token:     token,
+		mux:       http.NewServeMux(),
+	}
+	s.mux.HandleFunc(apiPrefix+"/tasks", s.handleTasks)
+	s.mux.HandleFunc(apiPrefix+"/tasks/", s.handleTask)
+	s.mux.HandleFunc(apiPrefix+"/resources", s.handleResources)
+	s.mux.HandleFunc(apiPrefix+"/drain", s.handleDrain)
+	s.mux.HandleFunc(apiPrefix+"/inference", s.handleInference)
+	return s
+}
+
+// ServeHTTP checks the token and dispatches the request
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(header, "Bearer ")
+	if s.token == "" || !found || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+		writeError(w, http.StatusUnauthorized, errors.New("invalid or missing admin token"))
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// Serve serves the API on a listener opened with Listen until ctx is
+// cancelled
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	server := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("admin API failed: %w", err)
+	}
+	return nil
+}
+
+// Listen opens a listener for an admin API address: "unix:<path>" or a
+// loopback "host:port", so the API is never exposed on other interfaces.
+// Unix sockets are created with mode 0600, replacing a stale socket file
+// but not one a running node still listens on.
+func Listen(address string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(address, "unix:"); ok {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create socket directory: %w", err)
+		}
+		if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+				conn.Close()
+				return nil, fmt.Errorf("admin socket %s is in use by another node", path)
+			}
+			os.Remove(path)
+		}
+		listener, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+		}
+		if err := os.Chmod(path, 0600); err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("failed to restrict admin socket: %w", err)
+		}
+		return listener, nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid admin address %q: %w", address, err)
+	}
+	if host != "localhost" {
+		ip := net.ParseIP(host)
+		if ip == nil || !ip.IsLoopback() {
+			return nil, fmt.Errorf("admin address %q is not a loopback address", address)
+		}
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+	return listener, nil
+}
+
+// LoadOrCreateToken reads the admin token from path, generating and saving
+// a random one with mode 0600 if the file does not exist
+func LoadOrCreateToken(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return "", fmt.Errorf("admin token file %s is empty", path)
+		}
+		return token, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read admin token: %w", err)
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate admin token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create token directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("failed to write admin token: %w", err)
+	}
+	return token, nil
+}
+
+func (s *Server) handleTasks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		tasks := s.executor.ListTaskSnapshots()
+		infos := make([]TaskInfo, 0, len(tasks))
+		for _, task := range tasks {
+			info := newTaskInfo(task)
+			// Outputs can be large; they are only returned by inspect
+			info.Output = nil
+			infos = append(infos, info)
+		}
+		writeJSON(w, http.StatusOK, infos)
+
+	case http.MethodPost:
+		var req TaskRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid task request: %w", err))
+			return
+		}
+		if req.ID == "" {
+			id, err := newTaskID()
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+			req.ID = id
+		}
+		if err := executor.ValidateTaskID(req.ID); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		// The task's trace continues the caller's, if it sent one
+		ctx, span := tracing.Start(tracing.ExtractHeader(r.Context(), r.Header), tracerName, "admin.submit_task")
+		defer span.End()
+		if req.Metadata["traceparent"] == "" {
+			for key, value := range tracing.Inject(ctx) {
+				if req.Metadata == nil {
+					req.Metadata = make(map[string]string)
+				}
+				req.Metadata[key] = value
+			}
+		}
+
+		task := &executor.Task{
+			ID:           req.ID,
+			JobID:        req.JobID,
+			ShardID:      req.ShardID,
+			TaskType:     req.TaskType,
+			ModelPath:    req.ModelPath,
+			DatasetPath:  req.DatasetPath,
+			InputData:    []byte(req.Input),
+			Requirements: req.Requirements,
+			Priority:     req.Priority,
+			Metadata:     req.Metadata,
+		}
+		if err := s.executor.AddTask(task); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, executor.ErrDraining) {
+				status = http.StatusServiceUnavailable
+			}
+			writeError(w, status, err)
+			return
+		}
+		s.writeTask(w, http.StatusCreated, task.ID)
+
+	default:
+		writeMethodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+func (s *Server) handleTask(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, apiPrefix+"/tasks/")
+	taskID, action, _ := strings.Cut(path, "/")
+	if taskID == "" {
+		writeError(w, http.StatusNotFound, errors.New("task ID required"))
+		return
+	}
+	if _, err := s.executor.TaskSnapshot(taskID); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	switch action {
+	case "":
+		switch r.Method {
+		case http.MethodGet:
+			s.writeTask(w, http.StatusOK, taskID)
+		case http.MethodDelete:
+			if err := s.executor.RemoveTask(taskID); err != nil {
+				writeError(w, http.StatusConflict, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeMethodNotAllowed(w, http.MethodGet, http.MethodDelete)
+		}
+
+	case "pause", "resume", "cancel":
+		if r.Method != http.MethodPost {
+			writeMethodNotAllowed(w, http.MethodPost)
+			return
+		}
+		var err error
+		switch action {
+		case "pause":
+			err = s.executor.PauseTask(taskID)
+		case "resume":
+			err = s.executor.ResumeTask(taskID)
+		case "cancel":
+			err = s.executor.CancelTask(taskID)
+		}
+		if err != nil {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+		s.writeTask(w, http.StatusOK, taskID)
+
+	case "logs":
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w, http.MethodGet)
+			return
+		}
+		s.handleLogs(w, r, taskID)
+
+	case "metrics":
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w, http.MethodGet)
+			return
+		}
+		events, err := s.executor.TaskMetrics(taskID)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, events)
+
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown task action: %s", action))
+	}
+}
+
+// handleLogs writes the end of a task's log as plain text and, with
+// follow=true, keeps streaming output until the task stops running
+func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request, taskID string) {
+	lines := 100
+	if value := r.URL.Query().Get("lines"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid lines: %s", value))
+			return
+		}
+		lines = n
+	}
+	follow := r.URL.Query().Get("follow") == "true"
+
+	if !follow {
+		tail, err := s.executor.TailLogs(taskID, lines)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, line := range tail {
+			fmt.Fprintln(w, line)
+		}
+		return
+	}
+
+	if _, err := os.Stat(s.executor.LogPath(taskID)); err != nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no logs for task %s", taskID))
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	s.executor.FollowLogs(r.Context(), taskID, lines, &flushWriter{w: w})
+}
+
+func (s *Server) handleResources(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	info := ResourcesInfo{
+		Resources:   map[string]interface{}{},
+		Allocations: []resource.ResourceAllocation{},
+	}
+	if s.resources != nil {
+		info.Resources = s.resources.GetResources()
+		info.Allocations = s.resources.GetAllocations()
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (s *Server) handleDrain(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		s.executor.Drain()
+	case http.MethodDelete:
+		s.executor.Undrain()
+	default:
+		writeMethodNotAllowed(w, http.MethodGet, http.MethodPost, http.MethodDelete)
+		return
+	}
+
+	draining, running := s.executor.DrainStatus()
+	writeJSON(w, http.StatusOK, DrainInfo{Draining: draining, RunningTasks: running})
+}
+
+func (s *Server) handleInference(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, InferenceInfo{
+		Workers: s.executor.ModelWorkers(),
+		Batches: s.executor.BatchStats(),
+	})
+}
+
+func (s *Server) writeTask(w http.ResponseWriter, status int, taskID string) {
+	task, err := s.executor.TaskSnapshot(taskID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, status, newTaskInfo(task))
+}
+
+func newTaskInfo(task executor.Task) TaskInfo {
+	info := TaskInfo{
+		ID:            task.ID,
+		JobID:         task.JobID,
+		ShardID:       task.ShardID,
+		TaskType:      task.TaskType,
+		Status:        task.Status,
+		Progress:      task.Progress,
+		CheckpointCID: task.CheckpointCID,
+		ModelPath:     task.ModelPath,
+		DatasetPath:   task.DatasetPath,
+		CreatedAt:     task.CreatedAt,
+		StartedAt:     task.StartedAt,
+		CompletedAt:   task.CompletedAt,
+		Requirements:  task.Requirements,
+		Priority:      task.Priority,
+		Usage:         task.Usage,
+		Metadata:      task.Metadata,
+		Proof:         task.Proof,
+	}
+	if task.Error != nil {
+		info.Error = task.Error.Error()
+	}
+	if len(task.OutputData) > 0 {
+		if json.Valid(task.OutputData) {
+			info.Output = json.RawMessage(task.OutputData)
+		} else {
+			output, _ := json.Marshal(string(task.OutputData))
+			info.Output = output
+		}
+	}
+	return info
+}
+
+func newTaskID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate task ID: %w", err)
+	}
+	return "task-" + hex.EncodeToString(buf), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeMethodNotAllowed(w http.ResponseWriter, methods ...string) {
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+}
+
+// flushWriter flushes every write so followed logs reach the client as they
+// are produced
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if flusher, ok := f.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return n, err
+}
@@ -0,0 +1,207 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/atlas/node/executor"
+	"github.com/atlas/node/resource"
+	"github.com/stretchr/testify/require"
+)
+
+// startServer serves the admin API for e on a unix socket and returns a
+// client for it
+func startServer(t *testing.T, e *executor.Executor, resources ResourceReporter) *Client {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	address := "unix:" + filepath.Join(t.TempDir(), "admin.sock")
+	listener, err := Listen(address)
+	require.NoError(t, err)
+	go NewServer(e, resources, "secret").Serve(ctx, listener)
+
+	return NewClient(address, "secret")
+}
+
+func TestServer_RequiresToken(t *testing.T) {
+	server := NewServer(executor.NewExecutor(nil), nil, "secret")
+
+	for _, header := range []string{"", "Bearer wrong", "secret"} {
+		req := httptest.NewRequest(http.MethodGet, "/v1/tasks", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/tasks", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestServer_Tasks(t *testing.T) {
+	e := executor.NewExecutor(nil)
+	e.SetWorkDir(t.TempDir())
+	client := startServer(t, e, nil)
+	ctx := context.Background()
+
+	task, err := client.SubmitTask(ctx, TaskRequest{
+		ID:           "task-1",
+		TaskType:     "inference",
+		Input:        []byte(`{"prompt":"hi"}`),
+		Requirements: resource.Requirements{CPU: 2},
+		Metadata:     map[string]string{"runtime": "command"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "pending", task.Status)
+
+	generated, err := client.SubmitTask(ctx, TaskRequest{TaskType: "training"})
+	require.NoError(t, err)
+	require.NotEmpty(t, generated.ID)
+
+	_, err = client.SubmitTask(ctx, TaskRequest{ID: "task-1"})
+	require.ErrorContains(t, err, "already exists")
+
+	tasks, err := client.ListTasks(ctx)
+	require.NoError(t, err)
+	require.Len(t, tasks, 2)
+	require.Equal(t, "task-1", tasks[0].ID)
+
+	task, err = client.GetTask(ctx, "task-1")
+	require.NoError(t, err)
+	require.Equal(t, 2, task.Requirements.CPU)
+	require.Equal(t, "command", task.Metadata["runtime"])
+
+	stored, err := e.TaskSnapshot("task-1")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"prompt":"hi"}`, string(stored.InputData))
+
+	_, err = client.GetTask(ctx, "missing")
+	require.ErrorContains(t, err, "task not found")
+
+	// Pending tasks cannot be paused or resumed, but can be cancelled
+	_, err = client.PauseTask(ctx, "task-1")
+	require.ErrorContains(t, err, "not in progress")
+	_, err = client.ResumeTask(ctx, "task-1")
+	require.ErrorContains(t, err, "not paused")
+
+	require.ErrorContains(t, client.RemoveTask(ctx, "task-1"), "cancel it before removing it")
+	task, err = client.CancelTask(ctx, "task-1")
+	require.NoError(t, err)
+	require.Equal(t, "cancelled", task.Status)
+
+	require.NoError(t, client.RemoveTask(ctx, "task-1"))
+	_, err = client.GetTask(ctx, "task-1")
+	require.ErrorContains(t, err, "task not found")
+}
+
+func TestServer_RejectsUnsafeTaskIDs(t *testing.T) {
+	root := t.TempDir()
+	e := executor.NewExecutor(nil)
+	e.SetWorkDir(filepath.Join(root, "work"))
+	server := NewServer(e, nil, "secret")
+
+	for _, id := range []string{"../x", "..", "cache"} {
+		body := bytes.NewBufferString(`{"id":"` + id + `","task_type":"training"}`)
+		req := httptest.NewRequest(http.MethodPost, "/v1/tasks", body)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusBadRequest, rec.Code, id)
+		require.Contains(t, rec.Body.String(), "task ID", id)
+	}
+	require.Empty(t, e.ListTasks())
+	require.DirExists(t, root)
+}
+
+func TestServer_Drain(t *testing.T) {
+	e := executor.NewExecutor(nil)
+	client := startServer(t, e, nil)
+	ctx := context.Background()
+
+	info, err := client.Drain(ctx)
+	require.NoError(t, err)
+	require.True(t, info.Draining)
+
+	_, err = client.SubmitTask(ctx, TaskRequest{ID: "task-1"})
+	require.ErrorContains(t, err, "draining")
+
+	info, err = client.Undrain(ctx)
+	require.NoError(t, err)
+	require.False(t, info.Draining)
+
+	_, err = client.SubmitTask(ctx, TaskRequest{ID: "task-1"})
+	require.NoError(t, err)
+}
+
+func TestServer_ResourcesAndLogs(t *testing.T) {
+	manager := resource.NewManagerWithCapacity(8, 16, 0, 100)
+	require.NoError(t, manager.Allocate("task-1", resource.Requirements{CPU: 2, MemoryGB: 4}))
+
+	workDir := t.TempDir()
+	e := executor.NewExecutor(manager)
+	e.SetWorkDir(workDir)
+	require.NoError(t, e.AddTask(&executor.Task{ID: "task-1", Status: "completed"}))
+
+	path := executor.TaskLogPath(workDir, "task-1")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0644))
+
+	client := startServer(t, e, manager)
+	ctx := context.Background()
+
+	info, err := client.Resources(ctx)
+	require.NoError(t, err)
+	require.Len(t, info.Allocations, 1)
+	require.Equal(t, 2, info.Allocations[0].CPU)
+	require.EqualValues(t, 8, info.Resources["cpu_cores"])
+
+	inference, err := client.Inference(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, inference.Workers)
+	require.Empty(t, inference.Batches)
+
+	var out bytes.Buffer
+	require.NoError(t, client.Logs(ctx, "task-1", 2, false, &out))
+	require.Equal(t, "two\nthree\n", out.String())
+
+	// Following a finished task returns once its output has been sent
+	out.Reset()
+	require.NoError(t, client.Logs(ctx, "task-1", 10, true, &out))
+	require.Equal(t, "one\ntwo\nthree\n", out.String())
+}
+
+func TestListen_RejectsNonLoopback(t *testing.T) {
+	_, err := Listen("0.0.0.0:0")
+	require.ErrorContains(t, err, "not a loopback address")
+
+	listener, err := Listen("127.0.0.1:0")
+	require.NoError(t, err)
+	listener.Close()
+}
+
+func TestLoadOrCreateToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "admin.token")
+
+	token, err := LoadOrCreateToken(path)
+	require.NoError(t, err)
+	require.Len(t, token, 64)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	again, err := LoadOrCreateToken(path)
+	require.NoError(t, err)
+	require.Equal(t, token, again)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/atlas/node/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	configPath    string
+	configProfile string
+	nodeConfig    *config.Loaded
+)
+
+// configFlags maps flags to the settings they override. A key starting with
+// "!" is a boolean setting the flag negates.
+var configFlags = map[string]string{
+	"chain-rpc":            "chain_rpc",
+	"ipfs-api":             "ipfs.api",
+	"ipfs-fallback":        "ipfs.fallbacks",
+	"node-id":              "node_id",
+	"key-file":             "key_file",
+	"address":              "address",
+	"work-dir":             "work_dir",
+	"admin-addr":           "api.admin_addr",
+	"admin-token":          "api.admin_token",
+	"trace-exporter":       "tracing.exporter",
+	"trace-endpoint":       "tracing.endpoint",
+	"trace-insecure":       "tracing.insecure",
+	"trace-file":           "tracing.file",
+	"trace-sample-ratio":   "tracing.sample_ratio",
+	"resume-tasks":         "scheduling.resume_tasks",
+	"no-sandbox":           "!scheduling.sandbox",
+	"task-network":         "scheduling.task_network",
+	"task-command":         "scheduling.task_commands",
+	"task-types":           "scheduling.task_types",
+	"max-concurrent-tasks": "scheduling.max_concurrent_tasks",
+	"default-task-cpu":     "scheduling.default_cpu",
+	"default-task-memory":  "scheduling.default_memory_gb",
+	"serve-addr":           "api.serve_addr",
+	"serve-api-key":        "api.serve_api_key",
+	"serve-model":          "api.serve_models",
+	"max-models":           "inference.max_models",
+	"model-idle-timeout":   "inference.model_idle_timeout",
+	"max-batch-size":       "inference.max_batch_size",
+	"max-batch-wait":       "inference.max_batch_wait",
+	"cache-dir":            "cache.dir",
+	"cache-quota-gb":       "cache.quota_gb",
+	"metrics-addr":         "api.metrics_addr",
+	"probe-addr":           "network.probe_addr",
+	"peer":                 "network.peers",
+	"probe-sample":         "network.probe_sample",
+	"probe-interval":       "network.probe_interval",
+	"internet-speed-test":  "network.internet_speed_test",
+	"geoip-db":             "location.geoip_db",
+	"public-ip":            "location.public_ip",
+	"online-lookup":        "location.online_lookup",
+}
+
+// loadConfig loads the config file and reconciles it with the command's
+// flags: flags given on the command line override the file, and the others
+// take their values from it
+func loadConfig(cmd *cobra.Command) error {
+	loaded, err := config.Load(configPath, configProfile)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		key, ok := configFlags[flag.Name]
+		if !ok {
+			return
+		}
+		key, negate := strings.CutPrefix(key, "!")
+
+		if flag.Changed {
+			value := strings.Trim(flag.Value.String(), "[]")
+			if negate {
+				value = fmt.Sprint(value != "true")
+			}
+			if err := loaded.Set(key, value); err != nil {
+				errs = append(errs, fmt.Errorf("invalid --%s: %w", flag.Name, err))
+			}
+			return
+		}
+
+		value, err := loaded.Get(key)
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		if value == "" && (flag.Value.Type() == "stringToString" || flag.Value.Type() == "stringSlice") {
+			return
+		}
+		if negate {
+			value = fmt.Sprint(value != "true")
+		}
+		if err := flag.Value.Set(value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s in configuration: %w", key, err))
+		}
+	})
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	nodeConfig = loaded
+	return nil
+}
+
+// newConfigCmd creates the config command and its subcommands
+func newConfigCmd() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Create, show and validate the node configuration",
+	}
+
+	var force bool
+	initCmd := &cobra.Command{
+		Use:   "init",
+		Short: "Write a commented config file with the default settings",
+		// The file may not exist or parse yet
+		Annotations: map[string]string{"skip-config": "true"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := configPath
+			if path == "" {
+				path = config.DefaultPath()
+			}
+			if err := config.WriteTemplate(path, force); err != nil {
+				return err
+			}
+			fmt.Printf("Wrote %s\n", path)
+			return nil
+		},
+	}
+	initCmd.Flags().BoolVar(&force, "force", false, "Replace an existing config file")
+
+	showCmd := &cobra.Command{
+		Use:   "show",
+		Short: "Print the effective configuration with secrets redacted",
+		// Shows settings tracing would reject
+		Annotations: map[string]string{"skip-tracing": "true"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := nodeConfig.Redacted().YAML()
+			if err != nil {
+				return err
+			}
+			printConfigSource()
+			fmt.Print(string(data))
+			return nil
+		},
+	}
+
+	validateCmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check the configuration for errors",
+		// Validate reports the settings tracing would reject
+		Annotations: map[string]string{"skip-tracing": "true"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			printConfigSource()
+			if err := nodeConfig.Validate(); err != nil {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("invalid configuration:\n%w", err)
+			}
+			fmt.Println("Configuration is valid")
+			return nil
+		},
+	}
+
+	configCmd.AddCommand(initCmd, showCmd, validateCmd)
+	return configCmd
+}
+
+// printConfigSource prints which file and profile were loaded
+func printConfigSource() {
+	source := "built-in defaults (no config file)"
+	if nodeConfig.Path != "" {
+		source = nodeConfig.Path
+	}
+	if nodeConfig.Profile != "" {
+		source += fmt.Sprintf(", profile %s", nodeConfig.Profile)
+	}
+	fmt.Printf("# Configuration: %s\n", source)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/atlas/storage/identity"
+	"github.com/spf13/cobra"
+)
+
+// loadIdentity loads the node key, creating it on first use, and derives
+// the node ID from it if none is configured
+func loadIdentity() (*identity.Identity, error) {
+	path := keyFile
+	if path == "" {
+		path = identity.DefaultKeyPath()
+	}
+	id, err := identity.LoadOrCreate(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load node key %s: %w", path, err)
+	}
+	if nodeID == "" {
+		nodeID = id.NodeID()
+	}
+	return id, nil
+}
+
+// newIdentityCmd creates the identity command, which prints the node ID
+// and the public key to register on chain
+func newIdentityCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:         "identity",
+		Short:       "Show the node ID and public key, creating the key if needed",
+		Annotations: map[string]string{"skip-tracing": "true"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := loadIdentity()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Node ID: %s\n", nodeID)
+			fmt.Printf("Public key: %s\n", id.PublicKey())
+			return nil
+		},
+	}
+}
@@ -6,20 +6,68 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
+	"github.com/atlas/node/admin"
 	"github.com/atlas/node/executor"
 	"github.com/atlas/node/health"
+	"github.com/atlas/node/metrics"
+	"github.com/atlas/node/network"
 	"github.com/atlas/node/resource"
+	"github.com/atlas/node/serving"
+	"github.com/atlas/node/validator"
+	"github.com/atlas/storage/cache"
+	"github.com/atlas/storage/manager"
+	"github.com/atlas/storage/tracing"
 	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var (
-	chainRPCURL string
-	ipfsAPIURL  string
-	nodeID      string
-	nodeAddress string
+	chainRPCURL  string
+	ipfsAPIURL   string
+	ipfsFallback []string
+	nodeID       string
+	keyFile      string
+	nodeAddress  string
+	resumeTasks  bool
+	noSandbox    bool
+	taskNetwork  bool
+	taskCommands map[string]string
+	taskTypes    []string
+	maxTasks     int
+	defaultCPU   int
+	defaultMem   uint64
+	workDir      string
+	adminAddress string
+	adminToken   string
+	serveAddress string
+	serveAPIKey  string
+	serveModels  map[string]string
+	maxModels    int
+	modelIdle    time.Duration
+	maxBatch     int
+	maxBatchWait time.Duration
+	cacheDir     string
+	cacheQuota   float64
+	metricsAddr  string
+	probeAddr    string
+	peers        map[string]string
+	probeSample  int
+	probeEvery   time.Duration
+	internetTest bool
+	geoIPDB      string
+	publicIP     string
+	onlineLookup bool
+
+	traceExporter    string
+	traceEndpoint    string
+	traceInsecure    bool
+	traceFile        string
+	traceSampleRatio float64
+	stopTracing      func(context.Context) error
 )
 
 func main() {
@@ -30,24 +78,83 @@ func main() {
 	}
 
 	// Global flags
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Config file (default: ATLAS_NODE_CONFIG or ~/.atlas/node.yaml)")
+	rootCmd.PersistentFlags().StringVar(&configProfile, "profile", "", "Config profile to apply (default: ATLAS_NODE_PROFILE or the file's profile)")
 	rootCmd.PersistentFlags().StringVar(&chainRPCURL, "chain-rpc", "http://localhost:26657", "Chain RPC URL")
 	rootCmd.PersistentFlags().StringVar(&ipfsAPIURL, "ipfs-api", "/ip4/127.0.0.1/tcp/5001", "IPFS API URL")
-	rootCmd.PersistentFlags().StringVar(&nodeID, "node-id", "", "Node ID")
+	rootCmd.PersistentFlags().StringSliceVar(&ipfsFallback, "ipfs-fallback", nil, "IPFS API tried when the main one cannot serve a file (repeatable)")
+	rootCmd.PersistentFlags().StringVar(&nodeID, "node-id", "", "Node ID (default: derived from the node key)")
+	rootCmd.PersistentFlags().StringVar(&keyFile, "key-file", "", "Node ed25519 key, created if missing (default ~/.atlas/node.key)")
 	rootCmd.PersistentFlags().StringVar(&nodeAddress, "address", "", "Node wallet address")
+	rootCmd.PersistentFlags().StringVar(&workDir, "work-dir", "/tmp/atlas-tasks", "Directory for task files, logs and the task journal")
+	rootCmd.PersistentFlags().StringToStringVar(&peers, "peer", nil, "Probe server of another node as node-id=host:port (repeatable)")
+	rootCmd.PersistentFlags().IntVar(&probeSample, "probe-sample", network.DefaultProbeSample, "Peers measured per probe round")
+	rootCmd.PersistentFlags().BoolVar(&internetTest, "internet-speed-test", true, "Use public speed test services until a peer has been measured")
+	rootCmd.PersistentFlags().StringVar(&geoIPDB, "geoip-db", "", "MaxMind-format (mmdb) city database to look the node's location up in")
+	rootCmd.PersistentFlags().StringVar(&publicIP, "public-ip", "", "Public IP to look the location up for (default: ask ipify.org)")
+	rootCmd.PersistentFlags().BoolVar(&onlineLookup, "online-lookup", true, "Allow looking the public IP and location up online")
+	rootCmd.PersistentFlags().StringVar(&adminAddress, "admin-addr", "", "Admin API address: unix:<path> or a loopback host:port (default unix:<work-dir>/admin.sock)")
+	rootCmd.PersistentFlags().StringVar(&adminToken, "admin-token", "", "Admin API token (default: read from <work-dir>/admin.token)")
+	rootCmd.PersistentFlags().StringVar(&traceExporter, "trace-exporter", tracing.ExporterNone, "Export OpenTelemetry traces: none, otlp or file")
+	rootCmd.PersistentFlags().StringVar(&traceEndpoint, "trace-endpoint", "", "OTLP/HTTP endpoint as host:port (default: OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4318)")
+	rootCmd.PersistentFlags().BoolVar(&traceInsecure, "trace-insecure", false, "Send OTLP traces over plain HTTP")
+	rootCmd.PersistentFlags().StringVar(&traceFile, "trace-file", "", "Output of the file trace exporter (default <work-dir>/traces.jsonl)")
+	rootCmd.PersistentFlags().Float64Var(&traceSampleRatio, "trace-sample-ratio", 1, "Fraction of new traces to record")
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if cmd.Annotations["skip-config"] != "" {
+			return nil
+		}
+		if err := loadConfig(cmd); err != nil {
+			return err
+		}
+		if cmd.Annotations["skip-tracing"] != "" {
+			return nil
+		}
+		if traceFile == "" {
+			traceFile = filepath.Join(workDir, "traces.jsonl")
+		}
+		stop, err := tracing.Setup(context.Background(), tracing.Config{
+			ServiceName: "atlas-node",
+			Exporter:    traceExporter,
+			Endpoint:    traceEndpoint,
+			Insecure:    traceInsecure,
+			File:        traceFile,
+			SampleRatio: traceSampleRatio,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to set up tracing: %w", err)
+		}
+		stopTracing = stop
+		return nil
+	}
 
 	// Start command
 	startCmd := &cobra.Command{
 		Use:   "start",
 		Short: "Start the node",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := nodeConfig.Validate(); err != nil {
+				return fmt.Errorf("invalid configuration (see 'atlas-node config validate'):\n%w", err)
+			}
+			id, err := loadIdentity()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Node %s (public key %s)\n", nodeID, id.PublicKey())
+
 			ctx, cancel := context.WithCancel(context.Background())
 			defer cancel()
 
 			// Initialize components with auto-detection
-			resourceManager := resource.NewManager()
+			prober := newProber()
+			resourceManager, err := newResourceManager(prober)
+			if err != nil {
+				return err
+			}
 			
 			// Perform full resource detection
 			fmt.Println("Detecting system resources...")
+			probePeers(ctx, prober)
 			if err := resourceManager.DetectResources(ctx); err != nil {
 				fmt.Printf("Warning: Resource detection failed: %v\n", err)
 			}
@@ -58,13 +165,154 @@ func main() {
 			resourceJSON, _ := json.MarshalIndent(resources, "", "  ")
 			fmt.Println(string(resourceJSON))
 
+			sandbox := executor.DefaultSandboxConfig()
+			sandbox.Enabled = !noSandbox
+			sandbox.IsolateNetwork = !taskNetwork
+			commandRuntime := executor.NewCommandRuntime(taskCommands)
+			workerConfig := executor.DefaultWorkerPoolConfig()
+			workerConfig.MaxModels = maxModels
+			workerConfig.IdleTimeout = modelIdle
+			batchConfig := executor.BatchConfig{MaxBatchSize: maxBatch, MaxWait: maxBatchWait}
+			policy := executor.SchedulingPolicy{
+				TaskTypes:            taskTypes,
+				MaxConcurrentTasks:   maxTasks,
+				FallbackRequirements: resource.Requirements{CPU: defaultCPU, MemoryGB: defaultMem},
+			}
+
+			// Models and datasets fetched by CID are shared by every task
+			if cacheDir == "" {
+				cacheDir = filepath.Join(workDir, "cache")
+			}
+			artifacts, err := cache.Open(cacheDir, int64(cacheQuota*(1<<30)), manager.NewIPFSManager(ipfsAPIURL, ipfsFallback...))
+			if err != nil {
+				return fmt.Errorf("failed to open artifact cache: %w", err)
+			}
+
 			executor := executor.NewExecutor(resourceManager)
-			healthMonitor := health.NewMonitor()
+			executor.SetWorkDir(workDir)
+			executor.SetIPFSAPIURL(ipfsAPIURL, ipfsFallback...)
+			executor.SetSchedulingPolicy(policy)
+			executor.SetSandbox(sandbox)
+			executor.SetWorkerPoolConfig(workerConfig)
+			executor.SetBatchConfig(batchConfig)
+			executor.SetArtifactCache(artifacts)
+			executor.SetProver(nodeID, id)
+			executor.RegisterRuntime("command", commandRuntime)
+			if err := executor.OpenTaskStore(); err != nil {
+				return err
+			}
+			defer executor.Stop()
+
+			// Reload tasks from the previous run
+			interrupted, err := executor.RecoverTasks()
+			if err != nil {
+				return fmt.Errorf("task recovery failed: %w", err)
+			}
+			for _, task := range interrupted {
+				if resumeTasks {
+					if err := executor.ResumeTask(task.ID); err != nil {
+						fmt.Printf("Warning: failed to resume task %s: %v\n", task.ID, err)
+						continue
+					}
+					fmt.Printf("Resuming interrupted task %s (checkpoint: %s)\n", task.ID, task.CheckpointCID)
+				} else {
+					fmt.Printf("Task %s was interrupted and is paused (checkpoint: %s)\n", task.ID, task.CheckpointCID)
+				}
+			}
+			if len(interrupted) > 0 && !resumeTasks {
+				fmt.Println("Restart with --resume-tasks to resume interrupted tasks")
+			}
+
+			token := adminToken
+			if token == "" {
+				token, err = admin.LoadOrCreateToken(adminTokenPath())
+				if err != nil {
+					return err
+				}
+			}
+			adminServer := admin.NewServer(executor, resourceManager, token)
+			adminListener, err := admin.Listen(adminAddr())
+			if err != nil {
+				return err
+			}
+
+			models := serving.NewRegistry()
+			for id, path := range serveModels {
+				if err := models.Add(id, path); err != nil {
+					return err
+				}
+			}
+			inferenceServer := serving.NewServer(models, executor, serveAPIKey)
+
+			nodeMetrics := metrics.New(metrics.Sources{
+				Tasks:     executor,
+				Resources: resourceManager,
+				Cache:     artifacts,
+				IPFS:      manager.Transfers,
+			})
+			executor.SetObserver(nodeMetrics)
+
+			sampler := resource.NewSampler(resource.SamplerConfig{
+				WorkDir:     workDir,
+				ActiveTasks: executor.RunningTasks,
+			})
+
+			healthMonitor := health.NewMonitor(nodeID, id)
+			healthMonitor.OnHeartbeat(nodeMetrics.Heartbeat)
+			healthMonitor.SetUtilization(sampler.Summary)
+			chainClient := validator.NewHTTPBlockchainClient(chainRPCURL)
+			healthMonitor.SetChainSubmitter(chainClient.SubmitHeartbeat)
+			if prober != nil {
+				healthMonitor.SetPeers(func() []network.PeerMeasurement {
+					return prober.Matrix().Row(nodeID)
+				})
+			}
+
+			// Tasks the chain re-runs on this node to check other nodes' results
+			replicaWorker := validator.NewReplicaWorker(nodeID, chainClient, executor)
+			// Challenged segments this node replays as a referee
+			refereeWorker := validator.NewRefereeWorker(nodeID, id, chainClient, executor.Replay)
 
 			// Start services
 			fmt.Println("Starting node services...")
+			go sampler.Start(ctx)
 			go healthMonitor.Start(ctx)
 			go executor.Start(ctx)
+			go replicaWorker.Start(ctx)
+			go refereeWorker.Start(ctx)
+			if prober != nil {
+				go prober.Start(ctx)
+			}
+			if probeAddr != "" {
+				go func() {
+					if err := network.NewProbeServer().ListenAndServe(ctx, probeAddr); err != nil {
+						fmt.Printf("Warning: %v\n", err)
+					}
+				}()
+				fmt.Printf("Answering bandwidth probes on %s\n", probeAddr)
+			}
+			go func() {
+				if err := adminServer.Serve(ctx, adminListener); err != nil {
+					fmt.Printf("Warning: %v\n", err)
+				}
+			}()
+			fmt.Printf("Admin API listening on %s\n", adminAddr())
+			if serveAddress != "" {
+				go func() {
+					if err := inferenceServer.ListenAndServe(ctx, serveAddress); err != nil {
+						fmt.Printf("Warning: %v\n", err)
+					}
+				}()
+				fmt.Printf("OpenAI-compatible API listening on %s (%d models)\n", serveAddress, len(serveModels))
+			}
+			if metricsAddr != "" {
+				go func() {
+					if err := nodeMetrics.ListenAndServe(ctx, metricsAddr); err != nil {
+						fmt.Printf("Warning: %v\n", err)
+					}
+				}()
+				fmt.Printf("Prometheus metrics at http://%s/metrics\n", metricsAddr)
+			}
 
 			// Wait for interrupt
 			sigChan := make(chan os.Signal, 1)
@@ -76,13 +324,44 @@ func main() {
 		},
 	}
 
+	startCmd.Flags().BoolVar(&resumeTasks, "resume-tasks", false, "Resume tasks interrupted by the last shutdown from their last checkpoint")
+	startCmd.Flags().BoolVar(&noSandbox, "no-sandbox", false, "Run task processes without cgroup limits and namespaces")
+	startCmd.Flags().BoolVar(&taskNetwork, "task-network", false, "Let task processes use the host network")
+	startCmd.Flags().StringToStringVar(&taskCommands, "task-command", nil, "Register a binary for the command runtime as name=path (repeatable)")
+	startCmd.Flags().StringSliceVar(&taskTypes, "task-types", nil, "Only accept tasks of these types, e.g. training,inference (default: all)")
+	startCmd.Flags().IntVar(&maxTasks, "max-concurrent-tasks", 0, "Maximum number of tasks running at once (0: limited by resources only)")
+	startCmd.Flags().IntVar(&defaultCPU, "default-task-cpu", 1, "CPUs reserved for tasks that declare no requirements")
+	startCmd.Flags().Uint64Var(&defaultMem, "default-task-memory", 1, "Memory in GB reserved for tasks that declare no requirements")
+	startCmd.Flags().StringVar(&serveAddress, "serve-addr", "", "Serve the OpenAI-compatible inference API on this address, e.g. 127.0.0.1:8000")
+	startCmd.Flags().StringVar(&serveAPIKey, "serve-api-key", "", "API key clients must send as a bearer token")
+	startCmd.Flags().StringToStringVar(&serveModels, "serve-model", nil, "Serve a model as id=cid-or-path (repeatable)")
+	startCmd.Flags().IntVar(&maxModels, "max-models", executor.DefaultWorkerPoolConfig().MaxModels, "Maximum number of models kept loaded by model workers")
+	startCmd.Flags().DurationVar(&modelIdle, "model-idle-timeout", executor.DefaultWorkerPoolConfig().IdleTimeout, "Stop model workers unused for this long (0 keeps them loaded)")
+	startCmd.Flags().IntVar(&maxBatch, "max-batch-size", executor.DefaultBatchConfig().MaxBatchSize, "Maximum inference requests per batch (1 disables batching)")
+	startCmd.Flags().DurationVar(&maxBatchWait, "max-batch-wait", executor.DefaultBatchConfig().MaxWait, "How long an inference request waits for others to join its batch")
+	startCmd.Flags().StringVar(&cacheDir, "cache-dir", "", "Directory of the artifact cache (default <work-dir>/cache)")
+	startCmd.Flags().Float64Var(&cacheQuota, "cache-quota-gb", 0, "Disk quota of the artifact cache in GB (0 for no quota)")
+	startCmd.Flags().StringVar(&probeAddr, "probe-addr", "", "Answer bandwidth and latency probes from other nodes on this address, e.g. 0.0.0.0:7947")
+	startCmd.Flags().DurationVar(&probeEvery, "probe-interval", network.DefaultProbeInterval, "Time between probe rounds")
+	startCmd.Flags().StringVar(&metricsAddr, "metrics-addr", "", "Serve Prometheus metrics at /metrics on this address, e.g. 127.0.0.1:9464")
+
 	// Status command
 	statusCmd := &cobra.Command{
 		Use:   "status",
 		Short: "Show node status",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			resourceManager := resource.NewManager()
+			id, err := loadIdentity()
+			if err != nil {
+				return err
+			}
+			prober := newProber()
+			resourceManager, err := newResourceManager(prober)
+			if err != nil {
+				return err
+			}
 			
+			probePeers(context.Background(), prober)
+
 			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 			defer cancel()
 			
@@ -94,24 +373,55 @@ func main() {
 			
 			fmt.Println("Node Status:")
 			fmt.Println("============")
+			fmt.Printf("Node ID: %s\n", nodeID)
+			fmt.Printf("Public key: %s\n", id.PublicKey())
 			fmt.Printf("CPU Cores: %d\n", resourceManager.CPUCount)
 			fmt.Printf("Memory: %d GB\n", resourceManager.MemoryGB)
-			fmt.Printf("Storage: %d GB\n", resourceManager.StorageGB)
+			fmt.Printf("Storage: %d GB free in %s\n", resourceManager.StorageGB, workDir)
 			fmt.Printf("GPUs: %d\n", len(resourceManager.GPUs))
 			
+			if limits := resourceManager.Limits; limits.Version > 0 {
+				fmt.Printf("\nCgroup v%d limits:\n", limits.Version)
+				if limits.CPUQuota > 0 {
+					fmt.Printf("  CPU quota: %.2f CPUs\n", limits.CPUQuota)
+				}
+				if limits.CPUSet > 0 {
+					fmt.Printf("  CPU set: %d CPUs\n", limits.CPUSet)
+				}
+				if limits.MemoryBytes > 0 {
+					fmt.Printf("  Memory: %d MB\n", limits.MemoryBytes>>20)
+				}
+			}
+			
 			if resourceManager.NetworkSpeed != nil {
 				fmt.Printf("\nNetwork:\n")
 				fmt.Printf("  Download: %.2f Mbps\n", resourceManager.NetworkSpeed.DownloadSpeedMbps)
 				fmt.Printf("  Upload: %.2f Mbps\n", resourceManager.NetworkSpeed.UploadSpeedMbps)
 				fmt.Printf("  Latency: %.2f ms\n", resourceManager.NetworkSpeed.LatencyMs)
+				if resourceManager.NetworkSpeed.Source == network.SourcePeers {
+					fmt.Printf("  (median of %d peers)\n", resourceManager.NetworkSpeed.Peers)
+				}
+			}
+			
+			if prober != nil {
+				fmt.Printf("\nPeers:\n")
+				for _, peer := range prober.Matrix().Row(nodeID) {
+					if peer.Error != "" {
+						fmt.Printf("  %s (%s): %s\n", peer.Peer, peer.Address, peer.Error)
+						continue
+					}
+					fmt.Printf("  %s (%s): %.2f ms, %.2f Mbps down, %.2f Mbps up\n", peer.Peer, peer.Address, peer.LatencyMs, peer.DownloadMbps, peer.UploadMbps)
+				}
 			}
 			
 			if resourceManager.Geolocation != nil {
 				fmt.Printf("\nLocation:\n")
 				fmt.Printf("  IP: %s\n", resourceManager.Geolocation.IP)
-				fmt.Printf("  Country: %s\n", resourceManager.Geolocation.Country)
+				fmt.Printf("  Country: %s (%s)\n", resourceManager.Geolocation.Country, resourceManager.Geolocation.CountryCode)
 				fmt.Printf("  Region: %s\n", resourceManager.Geolocation.Region)
 				fmt.Printf("  City: %s\n", resourceManager.Geolocation.City)
+				fmt.Printf("  Coordinates: %.4f, %.4f\n", resourceManager.Geolocation.Lat, resourceManager.Geolocation.Lon)
+				fmt.Printf("  Source: %s\n", resourceManager.Geolocation.Source)
 			}
 			
 			return nil
@@ -123,15 +433,22 @@ func main() {
 		Use:   "register",
 		Short: "Register node on blockchain",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if nodeID == "" {
-				return fmt.Errorf("node-id is required")
-			}
 			if nodeAddress == "" {
 				return fmt.Errorf("address is required")
 			}
+			id, err := loadIdentity()
+			if err != nil {
+				return err
+			}
 
-			resourceManager := resource.NewManager()
+			prober := newProber()
+			resourceManager, err := newResourceManager(prober)
+			if err != nil {
+				return err
+			}
 			
+			probePeers(context.Background(), prober)
+
 			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 			defer cancel()
 			
@@ -171,7 +488,10 @@ func main() {
 				}
 			}
 
-			err := registerNodeOnBlockchain(chainRPCURL, nodeID, nodeAddress, cpuCores, gpuCount, int(memoryGB), int(storageGB))
+			_, span := tracing.Start(context.Background(), "github.com/atlas/node/cmd/node", "chain.register_node",
+				attribute.String("node.id", nodeID), attribute.String("chain.rpc", chainRPCURL))
+			err = registerNodeOnBlockchain(chainRPCURL, nodeID, id.PublicKey(), nodeAddress, cpuCores, gpuCount, int(memoryGB), int(storageGB), resourceManager.Geolocation)
+			tracing.End(span, err)
 			if err != nil {
 				return fmt.Errorf("blockchain registration failed: %w\nNote: Use 'atlasd tx compute register-node ...' as fallback", err)
 			}
@@ -181,29 +501,89 @@ func main() {
 		},
 	}
 
-	// Config command
-	configCmd := &cobra.Command{
-		Use:   "config",
-		Short: "Show or update node configuration",
-		RunE: func(cmd *cobra.Command, args []string) error {
-			fmt.Println("Node Configuration:")
-			fmt.Println("===================")
-			fmt.Printf("Chain RPC URL: %s\n", chainRPCURL)
-			fmt.Printf("IPFS API URL: %s\n", ipfsAPIURL)
-			fmt.Printf("Node ID: %s\n", nodeID)
-			fmt.Printf("Node Address: %s\n", nodeAddress)
-			return nil
-		},
+	rootCmd.AddCommand(startCmd, statusCmd, registerCmd, newConfigCmd(), newTasksCmd(), newIdentityCmd())
+
+	err := rootCmd.Execute()
+	if stopTracing != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := stopTracing(ctx); err != nil {
+			fmt.Printf("Warning: failed to flush traces: %v\n", err)
+		}
+		cancel()
+	}
+	if err != nil {
+		os.Exit(1)
 	}
+}
+
+// newResourceManager detects the resources the node can offer: what its
+// cgroup allows, the space free in the work directory, less the configured
+// reservations. Its network speed comes from prober if it is not nil, its
+// location from the configured location sources.
+func newResourceManager(prober *network.Prober) (*resource.Manager, error) {
+	reserved := nodeConfig.Resources
+	location := nodeConfig.Location
+	return resource.NewManagerWithOptions(resource.Options{
+		WorkDir: workDir,
+		Prober:  prober,
+		SkipInternetSpeedTest: !internetTest,
+		Location: network.LocationConfig{
+			Declared: network.Geolocation{
+				IP:          location.PublicIP,
+				Country:     location.Country,
+				CountryCode: location.CountryCode,
+				Region:      location.Region,
+				City:        location.City,
+				Lat:         location.Latitude,
+				Lon:         location.Longitude,
+			},
+			GeoIPDB: location.GeoIPDB,
+			Offline: !location.OnlineLookup,
+		},
+		Reserved: resource.Requirements{
+			CPU:      reserved.ReserveCPU,
+			MemoryGB: reserved.ReserveMemoryGB,
+			GPUs:     reserved.ReserveGPUs,
+			DiskGB:   reserved.ReserveDiskGB,
+		},
+	})
+}
 
-	rootCmd.AddCommand(startCmd, statusCmd, registerCmd, configCmd)
+// newProber creates a prober for the configured peers, or returns nil if
+// there are none
+func newProber() *network.Prober {
+	if len(peers) == 0 {
+		return nil
+	}
+	config := network.ProberConfig{
+		Self:       nodeID,
+		SampleSize: probeSample,
+		Interval:   probeEvery,
+	}
+	for id, address := range peers {
+		config.Peers = append(config.Peers, network.Peer{ID: id, Address: address})
+	}
+	return network.NewProber(config)
+}
 
-	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+// probePeers runs a first probe round so the network speed is measured
+// against peers rather than the internet
+func probePeers(ctx context.Context, prober *network.Prober) {
+	if prober == nil {
+		return
+	}
+	fmt.Println("Measuring bandwidth to peers...")
+	for _, measurement := range prober.Probe(ctx) {
+		if measurement.Error != "" {
+			fmt.Printf("Warning: failed to probe peer %s: %s\n", measurement.Peer, measurement.Error)
+		}
 	}
 }
 
-func registerNodeOnBlockchain(rpcURL string, nodeID string, address string, cpuCores int, gpuCount int, memoryGB int, storageGB int) error {
-	return fmt.Errorf("blockchain client not fully implemented: requires gRPC client with protobuf stubs generated from chain proto files. Use 'atlasd tx compute register-node --node-id %s --address %s --cpu-cores %d --gpu-count %d --memory-gb %d --storage-gb %d' as alternative", nodeID, address, cpuCores, gpuCount, memoryGB, storageGB)
+func registerNodeOnBlockchain(rpcURL string, nodeID string, pubKey string, address string, cpuCores int, gpuCount int, memoryGB int, storageGB int, location *network.Geolocation) error {
+	if location != nil {
+		return fmt.Errorf("blockchain client not fully implemented: requires gRPC client with protobuf stubs generated from chain proto files. Use 'atlasd tx compute register-node --node-id %s --pub-key %s --address %s --cpu-cores %d --gpu-count %d --memory-gb %d --storage-gb %d --country-code %s --region %q --city %q --latitude %.4f --longitude %.4f' as alternative", nodeID, pubKey, address, cpuCores, gpuCount, memoryGB, storageGB, location.CountryCode, location.Region, location.City, location.Lat, location.Lon)
+	}
+	return fmt.Errorf("blockchain client not fully implemented: requires gRPC client with protobuf stubs generated from chain proto files. Use 'atlasd tx compute register-node --node-id %s --pub-key %s --address %s --cpu-cores %d --gpu-count %d --memory-gb %d --storage-gb %d' as alternative", nodeID, pubKey, address, cpuCores, gpuCount, memoryGB, storageGB)
 }
 
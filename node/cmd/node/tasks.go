@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/atlas/node/admin"
+	"github.com/atlas/node/executor"
+	"github.com/spf13/cobra"
+)
+
+// adminAddr returns the admin API address, defaulting to a unix socket in
+// the work directory
+func adminAddr() string {
+	if adminAddress != "" {
+		return adminAddress
+	}
+	return "unix:" + filepath.Join(workDir, "admin.sock")
+}
+
+func adminTokenPath() string {
+	return filepath.Join(workDir, "admin.token")
+}
+
+// newAdminClient connects to the admin API of the node running with the
+// same work directory
+func newAdminClient() (*admin.Client, error) {
+	token := adminToken
+	if token == "" {
+		data, err := os.ReadFile(adminTokenPath())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read admin token (is the node running with --work-dir %s?): %w", workDir, err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	return admin.NewClient(adminAddr(), token), nil
+}
+
+func printJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// newTasksCmd builds the "tasks" commands, which talk to a running node
+// through its admin API
+func newTasksCmd() *cobra.Command {
+	tasksCmd := &cobra.Command{
+		Use:   "tasks",
+		Short: "Manage tasks on a running node",
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List tasks",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newAdminClient()
+			if err != nil {
+				return err
+			}
+			tasks, err := client.ListTasks(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tTYPE\tSTATUS\tPROGRESS\tCREATED")
+			for _, task := range tasks {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%.0f%%\t%s\n", task.ID, task.TaskType, task.Status, task.Progress*100, task.CreatedAt.Format(time.RFC3339))
+			}
+			return w.Flush()
+		},
+	}
+
+	inspectCmd := &cobra.Command{
+		Use:   "inspect <task-id>",
+		Short: "Show a task in detail",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newAdminClient()
+			if err != nil {
+				return err
+			}
+			task, err := client.GetTask(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			return printJSON(task)
+		},
+	}
+
+	submitCmd := &cobra.Command{
+		Use:   "submit <task.json>",
+		Short: "Submit a task described by a JSON file (- for stdin)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var data []byte
+			var err error
+			if args[0] == "-" {
+				data, err = io.ReadAll(os.Stdin)
+			} else {
+				data, err = os.ReadFile(args[0])
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read task: %w", err)
+			}
+
+			var req admin.TaskRequest
+			if err := json.Unmarshal(data, &req); err != nil {
+				return fmt.Errorf("failed to parse task: %w", err)
+			}
+
+			client, err := newAdminClient()
+			if err != nil {
+				return err
+			}
+			task, err := client.SubmitTask(cmd.Context(), req)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Submitted task %s\n", task.ID)
+			return nil
+		},
+	}
+
+	// pause, resume and cancel only differ in the client call
+	actions := []struct {
+		use   string
+		short string
+		done  string
+		call  func(*admin.Client, context.Context, string) (*admin.TaskInfo, error)
+	}{
+		{"pause", "Checkpoint and pause a running task", "paused", (*admin.Client).PauseTask},
+		{"resume", "Resume a paused task from its last checkpoint", "resumed", (*admin.Client).ResumeTask},
+		{"cancel", "Kill a task and remove its directory", "cancelled", (*admin.Client).CancelTask},
+	}
+	for _, action := range actions {
+		action := action
+		tasksCmd.AddCommand(&cobra.Command{
+			Use:   action.use + " <task-id>",
+			Short: action.short,
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				client, err := newAdminClient()
+				if err != nil {
+					return err
+				}
+				task, err := action.call(client, cmd.Context(), args[0])
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Task %s %s (status: %s)\n", task.ID, action.done, task.Status)
+				return nil
+			},
+		})
+	}
+
+	removeCmd := &cobra.Command{
+		Use:   "remove <task-id>",
+		Short: "Remove a completed, failed or cancelled task from the node",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newAdminClient()
+			if err != nil {
+				return err
+			}
+			if err := client.RemoveTask(cmd.Context(), args[0]); err != nil {
+				return err
+			}
+			fmt.Printf("Task %s removed\n", args[0])
+			return nil
+		},
+	}
+
+	var logLines int
+	var followLogs bool
+	logsCmd := &cobra.Command{
+		Use:   "logs <task-id>",
+		Short: "Show the output of a task",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newAdminClient()
+			if err != nil {
+				return err
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return client.Logs(ctx, args[0], logLines, followLogs, os.Stdout)
+		},
+	}
+	logsCmd.Flags().IntVarP(&logLines, "lines", "n", 100, "Number of lines to show from the end of the log")
+	logsCmd.Flags().BoolVarP(&followLogs, "follow", "f", false, "Keep streaming output until the task stops running")
+
+	allocationsCmd := &cobra.Command{
+		Use:   "allocations",
+		Short: "Show node resources and the resources reserved by running tasks",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newAdminClient()
+			if err != nil {
+				return err
+			}
+			info, err := client.Resources(cmd.Context())
+			if err != nil {
+				return err
+			}
+			return printJSON(info)
+		},
+	}
+
+	modelsCmd := &cobra.Command{
+		Use:   "models",
+		Short: "Show loaded models and how their inference requests are batched",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newAdminClient()
+			if err != nil {
+				return err
+			}
+			info, err := client.Inference(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			models := make(map[string]*modelRow)
+			var order []string
+			row := func(path string) *modelRow {
+				if models[path] == nil {
+					models[path] = &modelRow{}
+					order = append(order, path)
+				}
+				return models[path]
+			}
+			for i := range info.Workers {
+				row(info.Workers[i].ModelPath).worker = &info.Workers[i]
+			}
+			for i := range info.Batches {
+				row(info.Batches[i].ModelPath).batches = &info.Batches[i]
+			}
+			sort.Strings(order)
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "MODEL\tPID\tREQUESTS\tIN FLIGHT\tQUEUED\tBATCHES\tMEAN BATCH\tLARGEST")
+			for _, path := range order {
+				r := models[path]
+				pid, requests, inFlight := "-", "-", "-"
+				if r.worker != nil {
+					pid = fmt.Sprint(r.worker.PID)
+					requests = fmt.Sprint(r.worker.Requests)
+					inFlight = fmt.Sprint(r.worker.InFlight)
+				}
+				queued, batches, mean, largest := "0", "0", "-", "-"
+				if b := r.batches; b != nil {
+					queued = fmt.Sprint(b.QueueDepth)
+					batches = fmt.Sprint(b.Batches)
+					if b.Batches > 0 {
+						mean = fmt.Sprintf("%.1f", float64(b.Requests)/float64(b.Batches))
+						largest = fmt.Sprint(b.LargestBatch)
+					}
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", path, pid, requests, inFlight, queued, batches, mean, largest)
+			}
+			return w.Flush()
+		},
+	}
+
+	var undrain, waitDrain bool
+	drainCmd := &cobra.Command{
+		Use:   "drain",
+		Short: "Stop the node from admitting tasks so it can be taken down",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newAdminClient()
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			var info *admin.DrainInfo
+			if undrain {
+				info, err = client.Undrain(ctx)
+			} else {
+				info, err = client.Drain(ctx)
+			}
+			if err != nil {
+				return err
+			}
+
+			for waitDrain && !undrain && info.RunningTasks > 0 {
+				fmt.Printf("Waiting for %d running task(s)...\n", info.RunningTasks)
+				time.Sleep(5 * time.Second)
+				if info, err = client.DrainStatus(ctx); err != nil {
+					return err
+				}
+			}
+
+			fmt.Printf("Draining: %t, running tasks: %d\n", info.Draining, info.RunningTasks)
+			return nil
+		},
+	}
+	drainCmd.Flags().BoolVar(&undrain, "undo", false, "Admit tasks again")
+	drainCmd.Flags().BoolVar(&waitDrain, "wait", false, "Wait until no tasks are running")
+
+	tasksCmd.AddCommand(listCmd, inspectCmd, submitCmd, removeCmd, logsCmd, allocationsCmd, modelsCmd, drainCmd)
+	return tasksCmd
+}
+
+// modelRow joins a model's worker and batching stats for "tasks models"
+type modelRow struct {
+	worker  *executor.WorkerInfo
+	batches *executor.BatchStats
+}
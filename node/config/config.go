@@ -0,0 +1,469 @@
+// Package config loads atlas-node settings from a YAML file with named
+// profiles and environment variable overrides.
+//
+// Settings are applied in order, each overriding the last: built-in
+// defaults, the top level of the file, the selected profile, ATLAS_NODE_*
+// environment variables and finally command-line flags (applied by the
+// caller with Set). The variable for a setting is its key path in upper
+// case with dots replaced by underscores, e.g. cache.quota_gb is
+// ATLAS_NODE_CACHE_QUOTA_GB. Lists are comma-separated and maps are
+// written as key=value pairs separated by commas.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables that select the file and profile
+const (
+	EnvConfig  = "ATLAS_NODE_CONFIG"
+	EnvProfile = "ATLAS_NODE_PROFILE"
+	envPrefix  = "ATLAS_NODE_"
+)
+
+// Config holds the settings of a node
+type Config struct {
+	ChainRPC string `yaml:"chain_rpc"`
+	NodeID   string `yaml:"node_id"`  // Default derived from the node key
+	KeyFile  string `yaml:"key_file"` // Default ~/.atlas/node.key
+	Address  string `yaml:"address"`
+	WorkDir  string `yaml:"work_dir"`
+
+	IPFS       IPFSConfig       `yaml:"ipfs"`
+	Cache      CacheConfig      `yaml:"cache"`
+	Resources  ResourceConfig   `yaml:"resources"`
+	Scheduling SchedulingConfig `yaml:"scheduling"`
+	Inference  InferenceConfig  `yaml:"inference"`
+	Network    NetworkConfig    `yaml:"network"`
+	Location   LocationConfig   `yaml:"location"`
+	API        APIConfig        `yaml:"api"`
+	Tracing    TracingConfig    `yaml:"tracing"`
+}
+
+// IPFSConfig selects the IPFS nodes artifacts are fetched from
+type IPFSConfig struct {
+	API       string   `yaml:"api"`
+	Fallbacks []string `yaml:"fallbacks"` // Tried in order when the API node fails
+}
+
+// CacheConfig configures the artifact cache
+type CacheConfig struct {
+	Dir     string  `yaml:"dir"` // Default <work_dir>/cache
+	QuotaGB float64 `yaml:"quota_gb"`
+}
+
+// ResourceConfig holds back resources for the host. Reserved resources are
+// neither offered to the chain nor allocated to tasks.
+type ResourceConfig struct {
+	ReserveCPU      int    `yaml:"reserve_cpu"`
+	ReserveMemoryGB uint64 `yaml:"reserve_memory_gb"`
+	ReserveGPUs     int    `yaml:"reserve_gpus"`
+	ReserveDiskGB   uint64 `yaml:"reserve_disk_gb"`
+}
+
+// SchedulingConfig controls which tasks the node takes on and how they run
+type SchedulingConfig struct {
+	TaskTypes          []string          `yaml:"task_types"`           // Accepted task types; empty accepts all
+	MaxConcurrentTasks int               `yaml:"max_concurrent_tasks"` // 0 leaves the limit to resources
+	DefaultCPU         int               `yaml:"default_cpu"`          // Reserved for tasks that declare no requirements
+	DefaultMemoryGB    uint64            `yaml:"default_memory_gb"`
+	ResumeTasks        bool              `yaml:"resume_tasks"`
+	Sandbox            bool              `yaml:"sandbox"`
+	TaskNetwork        bool              `yaml:"task_network"`
+	TaskCommands       map[string]string `yaml:"task_commands"` // Binaries for the command runtime by name
+}
+
+// InferenceConfig configures model workers and batching
+type InferenceConfig struct {
+	MaxModels        int           `yaml:"max_models"`
+	ModelIdleTimeout time.Duration `yaml:"model_idle_timeout"`
+	MaxBatchSize     int           `yaml:"max_batch_size"`
+	MaxBatchWait     time.Duration `yaml:"max_batch_wait"`
+}
+
+// NetworkConfig configures bandwidth and latency probes between nodes
+type NetworkConfig struct {
+	ProbeAddr         string            `yaml:"probe_addr"` // Answer other nodes' probes here; empty disables
+	Peers             map[string]string `yaml:"peers"`      // Probe servers of other nodes by node ID
+	ProbeSample       int               `yaml:"probe_sample"`
+	ProbeInterval     time.Duration     `yaml:"probe_interval"`
+	InternetSpeedTest bool              `yaml:"internet_speed_test"` // Fall back to public speed test services
+}
+
+// LocationConfig sets or looks up where the node is. A declared country,
+// region, city or coordinates are used as is; otherwise the public IP is
+// looked up in geoip_db, or online.
+type LocationConfig struct {
+	CountryCode  string  `yaml:"country_code"` // ISO 3166-1 alpha-2, e.g. DE
+	Country      string  `yaml:"country"`
+	Region       string  `yaml:"region"`
+	City         string  `yaml:"city"`
+	Latitude     float64 `yaml:"latitude"`
+	Longitude    float64 `yaml:"longitude"`
+	PublicIP     string  `yaml:"public_ip"`     // Looked up instead of asking ipify.org
+	GeoIPDB      string  `yaml:"geoip_db"`      // MaxMind-format (mmdb) city database
+	OnlineLookup bool    `yaml:"online_lookup"` // Allow ipify.org and ip-api.com
+}
+
+// APIConfig configures the node's listeners
+type APIConfig struct {
+	AdminAddr   string            `yaml:"admin_addr"` // Default unix:<work_dir>/admin.sock
+	AdminToken  string            `yaml:"admin_token"`
+	ServeAddr   string            `yaml:"serve_addr"`
+	ServeAPIKey string            `yaml:"serve_api_key"`
+	ServeModels map[string]string `yaml:"serve_models"`
+	MetricsAddr string            `yaml:"metrics_addr"`
+}
+
+// TracingConfig configures OpenTelemetry export
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter"`
+	Endpoint    string  `yaml:"endpoint"`
+	Insecure    bool    `yaml:"insecure"`
+	File        string  `yaml:"file"` // Default <work_dir>/traces.jsonl
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// secretKeys are redacted by Redacted
+var secretKeys = []string{"api.admin_token", "api.serve_api_key"}
+
+// Default returns the built-in settings
+func Default() *Config {
+	return &Config{
+		ChainRPC: "http://localhost:26657",
+		WorkDir:  "/tmp/atlas-tasks",
+		IPFS:     IPFSConfig{API: "/ip4/127.0.0.1/tcp/5001"},
+		Scheduling: SchedulingConfig{
+			DefaultCPU:      1,
+			DefaultMemoryGB: 1,
+			Sandbox:         true,
+		},
+		Inference: InferenceConfig{
+			MaxModels:        2,
+			ModelIdleTimeout: 10 * time.Minute,
+			MaxBatchSize:     8,
+			MaxBatchWait:     10 * time.Millisecond,
+		},
+		Network: NetworkConfig{
+			ProbeSample:       3,
+			ProbeInterval:     10 * time.Minute,
+			InternetSpeedTest: true,
+		},
+		Location: LocationConfig{
+			OnlineLookup: true,
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			SampleRatio: 1,
+		},
+	}
+}
+
+// DefaultPath is the file used when neither a path nor ATLAS_NODE_CONFIG is
+// given: ~/.atlas/node.yaml
+func DefaultPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".atlas", "node.yaml")
+	}
+	return filepath.Join(home, ".atlas", "node.yaml")
+}
+
+// file is the layout of a config file: settings at the top level, and
+// profiles that override some of them
+type file struct {
+	Config   `yaml:",inline"`
+	Profile  string               `yaml:"profile"` // Used when none is selected
+	Profiles map[string]yaml.Node `yaml:"profiles"`
+}
+
+// Loaded is a configuration and where it came from
+type Loaded struct {
+	*Config
+	Path     string   // File read, or "" if there was none
+	Profile  string   // Profile applied, or ""
+	Profiles []string // Profiles defined in the file
+}
+
+// Load reads the configuration. path and profile may be empty to use
+// ATLAS_NODE_CONFIG and ATLAS_NODE_PROFILE, then DefaultPath and the file's
+// default profile. A missing file is an error only if it was named
+// explicitly.
+func Load(path string, profile string) (*Loaded, error) {
+	explicit := path != ""
+	if path == "" {
+		path = os.Getenv(EnvConfig)
+		explicit = path != ""
+	}
+	if path == "" {
+		path = DefaultPath()
+	}
+	if profile == "" {
+		profile = os.Getenv(EnvProfile)
+	}
+
+	loaded := &Loaded{Config: Default()}
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		loaded.Path = path
+		if err := loaded.parse(data, profile); err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", path, err)
+		}
+	case errors.Is(err, os.ErrNotExist) && !explicit:
+		if profile != "" {
+			return nil, fmt.Errorf("profile %q selected but there is no config file at %s", profile, path)
+		}
+	default:
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if err := loaded.applyEnv(os.Environ()); err != nil {
+		return nil, err
+	}
+	return loaded, nil
+}
+
+// parse applies the file's settings and the selected profile
+func (l *Loaded) parse(data []byte, profile string) error {
+	f := file{Config: *l.Config}
+	if err := decodeStrict(data, &f); err != nil {
+		return err
+	}
+	*l.Config = f.Config
+
+	for name := range f.Profiles {
+		l.Profiles = append(l.Profiles, name)
+	}
+	sort.Strings(l.Profiles)
+
+	if profile == "" {
+		profile = f.Profile
+	}
+	if profile == "" {
+		return nil
+	}
+	node, ok := f.Profiles[profile]
+	if !ok {
+		return fmt.Errorf("unknown profile %q (defined: %s)", profile, strings.Join(l.Profiles, ", "))
+	}
+	// Re-encode the profile so it is decoded as strictly as the file
+	raw, err := yaml.Marshal(&node)
+	if err != nil {
+		return fmt.Errorf("failed to read profile %q: %w", profile, err)
+	}
+	if err := decodeStrict(raw, l.Config); err != nil {
+		return fmt.Errorf("profile %q: %w", profile, err)
+	}
+	l.Profile = profile
+	return nil
+}
+
+// decodeStrict decodes YAML into out, rejecting unknown keys
+func decodeStrict(data []byte, out interface{}) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(out); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// applyEnv applies ATLAS_NODE_* variables from environ
+func (l *Loaded) applyEnv(environ []string) error {
+	for _, key := range Keys() {
+		name := EnvName(key)
+		for _, entry := range environ {
+			if value, ok := strings.CutPrefix(entry, name+"="); ok {
+				if err := l.Set(key, value); err != nil {
+					return fmt.Errorf("invalid %s: %w", name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// EnvName is the environment variable overriding key
+func EnvName(key string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// Keys lists the setting keys, e.g. "cache.quota_gb", in file order
+func Keys() []string {
+	var keys []string
+	var walk func(t reflect.Type, prefix string)
+	walk = func(t reflect.Type, prefix string) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			key := prefix + yamlName(field)
+			if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Duration(0)) {
+				walk(field.Type, key+".")
+				continue
+			}
+			keys = append(keys, key)
+		}
+	}
+	walk(reflect.TypeOf(Config{}), "")
+	return keys
+}
+
+// Set parses value into the setting key, in the format of environment
+// variables
+func (c *Config) Set(key string, value string) error {
+	field, err := c.field(key)
+	if err != nil {
+		return err
+	}
+	return setValue(field, value)
+}
+
+// Get formats the setting key like Set expects it
+func (c *Config) Get(key string) (string, error) {
+	field, err := c.field(key)
+	if err != nil {
+		return "", err
+	}
+	return formatValue(field), nil
+}
+
+func (c *Config) field(key string) (reflect.Value, error) {
+	v := reflect.ValueOf(c).Elem()
+	for _, part := range strings.Split(key, ".") {
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, fmt.Errorf("unknown setting %q", key)
+		}
+		found := false
+		for i := 0; i < v.NumField(); i++ {
+			if yamlName(v.Type().Field(i)) == part {
+				v = v.Field(i)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return reflect.Value{}, fmt.Errorf("unknown setting %q", key)
+		}
+	}
+	return v, nil
+}
+
+func yamlName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	return name
+}
+
+func setValue(v reflect.Value, value string) error {
+	value = strings.TrimSpace(value)
+	switch {
+	case v.Type() == reflect.TypeOf(time.Duration(0)):
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(value)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case v.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case v.Kind() == reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	case v.Kind() == reflect.Map:
+		pairs := make(map[string]string)
+		for _, pair := range strings.Split(value, ",") {
+			if pair = strings.TrimSpace(pair); pair == "" {
+				continue
+			}
+			key, val, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("%q is not key=value", pair)
+			}
+			pairs[strings.TrimSpace(key)] = strings.TrimSpace(val)
+		}
+		v.Set(reflect.ValueOf(pairs))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+func formatValue(v reflect.Value) string {
+	switch {
+	case v.Type() == reflect.TypeOf(time.Duration(0)):
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.Slice:
+		return strings.Join(v.Interface().([]string), ",")
+	case v.Kind() == reflect.Map:
+		m := v.Interface().(map[string]string)
+		pairs := make([]string, 0, len(m))
+		for key, value := range m {
+			pairs = append(pairs, key+"="+value)
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// Redacted returns a copy of the configuration with secrets masked
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	for _, key := range secretKeys {
+		if value, _ := redacted.Get(key); value != "" {
+			redacted.Set(key, "REDACTED")
+		}
+	}
+	return &redacted
+}
+
+// YAML encodes the configuration in the file format
+func (c *Config) YAML() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c); err != nil {
+		return nil, fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	return buf.Bytes(), nil
+}
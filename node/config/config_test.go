@@ -0,0 +1,142 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "node.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoad_Template(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "atlas", "node.yaml")
+	require.NoError(t, WriteTemplate(path, false))
+	require.Error(t, WriteTemplate(path, false))
+
+	loaded, err := Load(path, "")
+	require.NoError(t, err)
+	for _, key := range Keys() {
+		want, _ := Default().Get(key)
+		got, _ := loaded.Get(key)
+		require.Equal(t, want, got, key)
+	}
+	require.Equal(t, []string{"dev", "prod"}, loaded.Profiles)
+	require.NoError(t, loaded.Validate())
+}
+
+func TestLoad_ProfileAndEnv(t *testing.T) {
+	path := writeConfig(t, `
+work_dir: /srv/atlas
+cache:
+  quota_gb: 10
+scheduling:
+  task_commands:
+    train: /usr/bin/train
+profile: small
+profiles:
+  small:
+    cache:
+      quota_gb: 5
+    inference:
+      max_models: 1
+  gpu:
+    resources:
+      reserve_gpus: 1
+`)
+
+	loaded, err := Load(path, "")
+	require.NoError(t, err)
+	require.Equal(t, "small", loaded.Profile)
+	require.Equal(t, "/srv/atlas", loaded.WorkDir)
+	require.Equal(t, 5.0, loaded.Cache.QuotaGB)
+	require.Equal(t, 1, loaded.Inference.MaxModels)
+	require.Equal(t, 10*time.Minute, loaded.Inference.ModelIdleTimeout)
+
+	t.Setenv(EnvProfile, "gpu")
+	t.Setenv("ATLAS_NODE_CACHE_QUOTA_GB", "50")
+	t.Setenv("ATLAS_NODE_SCHEDULING_TASK_TYPES", "training, inference")
+	t.Setenv("ATLAS_NODE_INFERENCE_MAX_BATCH_WAIT", "5ms")
+	loaded, err = Load(path, "")
+	require.NoError(t, err)
+	require.Equal(t, "gpu", loaded.Profile)
+	require.Equal(t, 1, loaded.Resources.ReserveGPUs)
+	require.Equal(t, 50.0, loaded.Cache.QuotaGB)
+	require.Equal(t, []string{"training", "inference"}, loaded.Scheduling.TaskTypes)
+	require.Equal(t, 5*time.Millisecond, loaded.Inference.MaxBatchWait)
+	require.Equal(t, map[string]string{"train": "/usr/bin/train"}, loaded.Scheduling.TaskCommands)
+
+	_, err = Load(path, "missing")
+	require.ErrorContains(t, err, `unknown profile "missing"`)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	_, err := Load(writeConfig(t, "cache:\n  quota: 5\n"), "")
+	require.ErrorContains(t, err, "field quota not found")
+
+	_, err = Load(writeConfig(t, "profiles:\n  dev:\n    sandbox: false\n"), "dev")
+	require.ErrorContains(t, err, "field sandbox not found")
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.yaml"), "")
+	require.Error(t, err)
+
+	t.Setenv(EnvConfig, "")
+	t.Setenv("HOME", t.TempDir())
+	loaded, err := Load("", "")
+	require.NoError(t, err)
+	require.Empty(t, loaded.Path)
+
+	t.Setenv("ATLAS_NODE_INFERENCE_MAX_MODELS", "many")
+	_, err = Load("", "")
+	require.ErrorContains(t, err, "ATLAS_NODE_INFERENCE_MAX_MODELS")
+}
+
+func TestValidate(t *testing.T) {
+	c := Default()
+	c.ChainRPC = "localhost:26657"
+	c.WorkDir = "relative"
+	c.IPFS.Fallbacks = []string{"/ip4/10.0.0.2"}
+	c.Inference.MaxBatchSize = 0
+	c.API.AdminAddr = "0.0.0.0:9000"
+	c.API.ServeAddr = "127.0.0.1:8000"
+	c.API.MetricsAddr = "127.0.0.1:8000"
+	c.Tracing.Exporter = "jaeger"
+	c.Network.Peers = map[string]string{"node-2": "10.0.0.2"}
+	c.Network.ProbeAddr = "127.0.0.1:8000"
+	c.Location.CountryCode = "de"
+	c.Location.Latitude = 91
+
+	err := c.Validate()
+	require.Error(t, err)
+	for _, key := range []string{"chain_rpc", "work_dir", "ipfs.fallbacks", "inference.max_batch_size",
+		"api.admin_addr", "api.metrics_addr", "tracing.exporter", "network.peers", "network.probe_addr",
+		"location.country_code", "location.latitude"} {
+		require.ErrorContains(t, err, key+":")
+	}
+}
+
+func TestSetGetRedacted(t *testing.T) {
+	c := Default()
+	require.NoError(t, c.Set("api.serve_models", "llama=Qm123, tiny=/models/tiny"))
+	require.NoError(t, c.Set("api.admin_token", "secret"))
+	require.Error(t, c.Set("api.unknown", "x"))
+	require.Error(t, c.Set("inference.max_models", "x"))
+
+	value, err := c.Get("api.serve_models")
+	require.NoError(t, err)
+	require.Equal(t, "llama=Qm123,tiny=/models/tiny", value)
+
+	data, err := c.Redacted().YAML()
+	require.NoError(t, err)
+	require.Contains(t, string(data), "admin_token: REDACTED")
+	require.NotContains(t, string(data), "secret")
+	require.Contains(t, string(data), "model_idle_timeout: 10m0s")
+	require.Equal(t, "secret", c.API.AdminToken)
+}
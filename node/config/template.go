@@ -0,0 +1,137 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Template is the commented file written by `atlas-node config init`. Its
+// settings are the defaults.
+const Template = `# atlas-node configuration
+#
+# Settings are applied in order: built-in defaults, this file, the selected
+# profile, ATLAS_NODE_* environment variables (e.g. ATLAS_NODE_CACHE_QUOTA_GB)
+# and command-line flags.
+
+chain_rpc: http://localhost:26657
+# Derived from the public key if empty
+node_id: ""
+# The node's ed25519 key, created on first start (default ~/.atlas/node.key)
+key_file: ""
+address: ""
+work_dir: /tmp/atlas-tasks
+
+ipfs:
+  api: /ip4/127.0.0.1/tcp/5001
+  # Tried in order when the API node cannot serve a file
+  fallbacks: []
+
+cache:
+  # dir: /tmp/atlas-tasks/cache
+  quota_gb: 0 # 0 for no quota
+
+# Resources held back for the host. They are neither offered to the chain
+# nor allocated to tasks.
+resources:
+  reserve_cpu: 0
+  reserve_memory_gb: 0
+  reserve_gpus: 0
+  reserve_disk_gb: 0
+
+scheduling:
+  task_types: [] # e.g. [training, inference]; empty accepts all
+  max_concurrent_tasks: 0 # 0 leaves the limit to the node's resources
+  # Reserved for tasks that declare no resource requirements
+  default_cpu: 1
+  default_memory_gb: 1
+  resume_tasks: false
+  sandbox: true
+  task_network: false
+  task_commands: {} # name: /path/to/binary
+
+inference:
+  max_models: 2
+  model_idle_timeout: 10m
+  max_batch_size: 8
+  max_batch_wait: 10ms
+
+# Bandwidth and latency probes between nodes. Each round the node measures
+# a sample of its peers; the internet speed test is only used until a peer
+# has answered.
+network:
+  probe_addr: "" # e.g. 0.0.0.0:7947; empty does not answer probes
+  peers: {} # node id: host:port of its probe_addr
+  probe_sample: 3
+  probe_interval: 10m
+  internet_speed_test: true
+
+# Where the node is, for routing requests to nearby nodes. A declared
+# place or coordinates are used as is; otherwise the public IP is looked up
+# in geoip_db, or online with ipify.org and ip-api.com.
+location:
+  country_code: "" # e.g. DE
+  country: ""
+  region: ""
+  city: ""
+  latitude: 0
+  longitude: 0
+  public_ip: "" # looked up instead of asking ipify.org
+  geoip_db: "" # e.g. /usr/share/GeoIP/GeoLite2-City.mmdb
+  online_lookup: true
+
+api:
+  # admin_addr: unix:/tmp/atlas-tasks/admin.sock
+  # admin_token: ""
+  serve_addr: "" # e.g. 127.0.0.1:8000
+  serve_api_key: ""
+  serve_models: {} # model id: CID or path
+  metrics_addr: "" # e.g. 127.0.0.1:9464
+
+tracing:
+  exporter: none # none, otlp or file
+  endpoint: ""
+  insecure: false
+  # file: /tmp/atlas-tasks/traces.jsonl
+  sample_ratio: 1
+
+# Profile used when none is selected with --profile or ATLAS_NODE_PROFILE
+# profile: dev
+
+# Profiles override any of the settings above
+profiles:
+  dev:
+    chain_rpc: http://localhost:26657
+    scheduling:
+      sandbox: false
+    tracing:
+      exporter: file
+  prod:
+    cache:
+      quota_gb: 200
+    resources:
+      reserve_cpu: 1
+      reserve_memory_gb: 4
+    scheduling:
+      resume_tasks: true
+`
+
+// WriteTemplate writes Template to path, creating its directory. An
+// existing file is only replaced if force is set.
+func WriteTemplate(path string, force bool) error {
+	if !force {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s already exists (use --force to replace it)", path)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to check %s: %w", path, err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(Template), 0600); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// TraceExporters are the accepted tracing.exporter values
+var TraceExporters = []string{"none", "otlp", "file"}
+
+// Validate checks the configuration and returns every problem found, joined
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(key string, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if u, err := url.Parse(c.ChainRPC); err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "tcp") || u.Host == "" {
+		fail("chain_rpc", "%q is not an http, https or tcp URL", c.ChainRPC)
+	}
+
+	if !filepath.IsAbs(c.WorkDir) {
+		fail("work_dir", "%q must be an absolute path", c.WorkDir)
+	}
+	for _, path := range []struct{ key, dir string }{
+		{"key_file", c.KeyFile},
+		{"cache.dir", c.Cache.Dir},
+		{"tracing.file", c.Tracing.File},
+		{"location.geoip_db", c.Location.GeoIPDB},
+	} {
+		if path.dir != "" && !filepath.IsAbs(path.dir) {
+			fail(path.key, "%q must be an absolute path", path.dir)
+		}
+	}
+
+	if c.IPFS.API == "" {
+		fail("ipfs.api", "must be set")
+	} else if err := checkIPFSAddress(c.IPFS.API); err != nil {
+		fail("ipfs.api", "%v", err)
+	}
+	for _, fallback := range c.IPFS.Fallbacks {
+		if err := checkIPFSAddress(fallback); err != nil {
+			fail("ipfs.fallbacks", "%v", err)
+		}
+	}
+
+	if c.Cache.QuotaGB < 0 {
+		fail("cache.quota_gb", "must not be negative")
+	}
+	if c.Resources.ReserveCPU < 0 || c.Resources.ReserveGPUs < 0 {
+		fail("resources", "reservations must not be negative")
+	}
+
+	for _, taskType := range c.Scheduling.TaskTypes {
+		if strings.TrimSpace(taskType) == "" {
+			fail("scheduling.task_types", "task types must not be empty")
+		}
+	}
+	if c.Scheduling.MaxConcurrentTasks < 0 {
+		fail("scheduling.max_concurrent_tasks", "must not be negative")
+	}
+	if c.Scheduling.DefaultCPU < 1 {
+		fail("scheduling.default_cpu", "must be at least 1")
+	}
+	for name, path := range c.Scheduling.TaskCommands {
+		if name == "" || path == "" {
+			fail("scheduling.task_commands", "entries need a name and a path")
+		}
+	}
+
+	if c.Inference.MaxModels < 1 {
+		fail("inference.max_models", "must be at least 1")
+	}
+	if c.Inference.ModelIdleTimeout < 0 {
+		fail("inference.model_idle_timeout", "must not be negative")
+	}
+	if c.Inference.MaxBatchSize < 1 {
+		fail("inference.max_batch_size", "must be at least 1")
+	}
+	if c.Inference.MaxBatchWait < 0 {
+		fail("inference.max_batch_wait", "must not be negative")
+	}
+
+	for id, address := range c.Network.Peers {
+		if _, _, err := net.SplitHostPort(address); id == "" || err != nil {
+			fail("network.peers", "entries need a node ID and a host:port")
+		}
+	}
+	if c.Network.ProbeSample < 1 {
+		fail("network.probe_sample", "must be at least 1")
+	}
+	if c.Network.ProbeInterval <= 0 {
+		fail("network.probe_interval", "must be positive")
+	}
+
+	if code := c.Location.CountryCode; code != "" && (len(code) != 2 || strings.ToUpper(code) != code) {
+		fail("location.country_code", "%q is not a two-letter ISO country code like DE", code)
+	}
+	if c.Location.Latitude < -90 || c.Location.Latitude > 90 {
+		fail("location.latitude", "must be between -90 and 90")
+	}
+	if c.Location.Longitude < -180 || c.Location.Longitude > 180 {
+		fail("location.longitude", "must be between -180 and 180")
+	}
+	if ip := c.Location.PublicIP; ip != "" && net.ParseIP(ip) == nil {
+		fail("location.public_ip", "%q is not an IP address", ip)
+	}
+
+	if c.API.AdminAddr != "" {
+		if err := checkAdminAddress(c.API.AdminAddr); err != nil {
+			fail("api.admin_addr", "%v", err)
+		}
+	}
+	listeners := map[string]string{}
+	for _, listener := range []struct{ key, address string }{
+		{"api.admin_addr", c.API.AdminAddr},
+		{"api.serve_addr", c.API.ServeAddr},
+		{"api.metrics_addr", c.API.MetricsAddr},
+		{"network.probe_addr", c.Network.ProbeAddr},
+	} {
+		if listener.address == "" || strings.HasPrefix(listener.address, "unix:") {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(listener.address); err != nil {
+			fail(listener.key, "%q is not host:port", listener.address)
+			continue
+		}
+		if other, ok := listeners[listener.address]; ok {
+			fail(listener.key, "%s is already used by %s", listener.address, other)
+		}
+		listeners[listener.address] = listener.key
+	}
+	for id, model := range c.API.ServeModels {
+		if id == "" || model == "" {
+			fail("api.serve_models", "entries need a model id and a CID or path")
+		}
+	}
+	if len(c.API.ServeModels) > 0 && c.API.ServeAddr == "" {
+		fail("api.serve_models", "set but api.serve_addr is empty")
+	}
+
+	if !contains(TraceExporters, c.Tracing.Exporter) {
+		fail("tracing.exporter", "unknown exporter %q (known: %s)", c.Tracing.Exporter, strings.Join(TraceExporters, ", "))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		fail("tracing.sample_ratio", "must be between 0 and 1")
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkIPFSAddress accepts multiaddrs (/ip4/.../tcp/...), URLs and host:port
+func checkIPFSAddress(address string) error {
+	switch {
+	case strings.HasPrefix(address, "/"):
+		if len(strings.Split(strings.Trim(address, "/"), "/")) < 4 {
+			return fmt.Errorf("%q is not a multiaddr like /ip4/127.0.0.1/tcp/5001", address)
+		}
+	case strings.Contains(address, "://"):
+		if u, err := url.Parse(address); err != nil || u.Host == "" {
+			return fmt.Errorf("%q is not a valid URL", address)
+		}
+	default:
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return fmt.Errorf("%q is not a multiaddr, URL or host:port", address)
+		}
+	}
+	return nil
+}
+
+// checkAdminAddress applies the admin API's rule: a unix socket or a
+// loopback host:port
+func checkAdminAddress(address string) error {
+	if path, ok := strings.CutPrefix(address, "unix:"); ok {
+		if path == "" {
+			return fmt.Errorf("unix socket path is empty")
+		}
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%q is not unix:<path> or host:port", address)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("%q is not a loopback address", address)
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
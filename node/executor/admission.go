@@ -0,0 +1,155 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/atlas/node/resource"
+)
+
+// ResourceAllocator reserves node resources for running tasks.
+// *resource.Manager implements it.
+type ResourceAllocator interface {
+	Allocate(taskID string, req resource.Requirements) error
+	ReleaseResources(taskID string) error
+}
+
+// ErrDraining is returned for new work while the executor is draining
+var ErrDraining = errors.New("executor is draining")
+
+// Drain stops the executor from admitting tasks so the node can be taken
+// down. Running tasks are left to finish; queued and newly resumed tasks stay
+// pending until Undrain, and new tasks are rejected with ErrDraining.
+func (e *Executor) Drain() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.draining = true
+}
+
+// Undrain lets the executor admit tasks again after Drain
+func (e *Executor) Undrain() {
+	e.mu.Lock()
+	e.draining = false
+	e.mu.Unlock()
+	e.notify()
+}
+
+// DrainStatus reports whether the executor is draining and how many tasks
+// are still running
+func (e *Executor) DrainStatus() (bool, int) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.draining, len(e.handles)
+}
+
+// pendingQueue returns pending tasks in admission order: highest priority
+// first, oldest first within a priority.
+func (e *Executor) pendingQueue() []*Task {
+	e.mu.RLock()
+	queue := make([]*Task, 0)
+	for _, task := range e.tasks {
+		if task.Status == "pending" {
+			queue = append(queue, task)
+		}
+	}
+	e.mu.RUnlock()
+
+	sort.SliceStable(queue, func(i, j int) bool {
+		if queue[i].Priority != queue[j].Priority {
+			return queue[i].Priority > queue[j].Priority
+		}
+		return queue[i].CreatedAt.Before(queue[j].CreatedAt)
+	})
+
+	return queue
+}
+
+// admitPending starts as many queued tasks as the resource manager can
+// reserve resources for, up to the scheduling policy's concurrency limit.
+// Once a task has to wait, lower-priority tasks are not allowed to jump ahead
+// of it; tasks of the same priority may still fill the remaining capacity.
+func (e *Executor) admitPending(ctx context.Context) {
+	e.mu.RLock()
+	draining := e.draining
+	e.mu.RUnlock()
+	if draining {
+		return
+	}
+
+	blocked := false
+	blockedPriority := 0
+
+	for _, task := range e.pendingQueue() {
+		if blocked && task.Priority < blockedPriority {
+			break
+		}
+		if e.atConcurrencyLimit() {
+			break
+		}
+
+		if err := e.admit(task); err != nil {
+			if errors.Is(err, resource.ErrExceedsCapacity) {
+				e.rejectTask(task, err)
+				continue
+			}
+			if !blocked {
+				blocked = true
+				blockedPriority = task.Priority
+			}
+			continue
+		}
+
+		if !e.startTask(ctx, task) {
+			e.release(task.ID)
+		}
+	}
+}
+
+// admit reserves the task's declared resources, or the scheduling policy's
+// default for tasks that declare none. With no resource manager configured
+// every task is admitted.
+func (e *Executor) admit(task *Task) error {
+	if e.resourceManager == nil {
+		return nil
+	}
+	if err := e.resourceManager.Allocate(task.ID, e.requirementsFor(task)); err != nil {
+		return fmt.Errorf("cannot admit task %s: %w", task.ID, err)
+	}
+	return nil
+}
+
+func (e *Executor) release(taskID string) {
+	if e.resourceManager == nil {
+		return
+	}
+	if err := e.resourceManager.ReleaseResources(taskID); err != nil {
+		fmt.Printf("Warning: failed to release resources for task %s: %v\n", taskID, err)
+	}
+}
+
+// rejectTask fails a task whose requirements can never be met on this node
+func (e *Executor) rejectTask(task *Task, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if task.Status != "pending" {
+		return
+	}
+	task.Status = "failed"
+	task.Error = err
+	now := time.Now()
+	task.CompletedAt = &now
+	e.persistLocked(task)
+}
+
+// notify wakes the processing loop so queued tasks are admitted without
+// waiting for the next tick
+func (e *Executor) notify() {
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+}
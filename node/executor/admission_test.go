@@ -0,0 +1,273 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/atlas/node/resource"
+	"github.com/stretchr/testify/require"
+)
+
+// waitForTasks drives the admission loop until every task has finished
+func waitForTasks(t *testing.T, e *Executor, ctx context.Context) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		e.processTasks(ctx)
+
+		done := true
+		for _, task := range e.ListTasks() {
+			e.mu.RLock()
+			status := task.Status
+			e.mu.RUnlock()
+			if status == "pending" || status == "in_progress" {
+				done = false
+				break
+			}
+		}
+		if done {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("tasks did not finish in time")
+}
+
+func TestAdmission_NoOversubscription(t *testing.T) {
+	manager := resource.NewManagerWithCapacity(8, 16, 2, 100)
+	e := NewExecutor(manager)
+
+	var mu sync.Mutex
+	var usedCPU, peakCPU, usedGPUs, peakGPUs int
+	e.runTask = func(ctx context.Context, task *Task) {
+		mu.Lock()
+		usedCPU += task.Requirements.CPU
+		usedGPUs += task.Requirements.GPUs
+		if usedCPU > peakCPU {
+			peakCPU = usedCPU
+		}
+		if usedGPUs > peakGPUs {
+			peakGPUs = usedGPUs
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		usedCPU -= task.Requirements.CPU
+		usedGPUs -= task.Requirements.GPUs
+		mu.Unlock()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Submit from many goroutines while the admission loop is running
+	var wg sync.WaitGroup
+	var stop atomic.Bool
+	go func() {
+		for !stop.Load() {
+			e.processTasks(ctx)
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	for i := 0; i < 40; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			require.NoError(t, e.AddTask(&Task{
+				ID:           fmt.Sprintf("task-%d", i),
+				Requirements: resource.Requirements{CPU: 3, MemoryGB: 2, GPUs: i % 2},
+			}))
+		}(i)
+	}
+	wg.Wait()
+	stop.Store(true)
+
+	waitForTasks(t, e, ctx)
+
+	require.LessOrEqual(t, peakCPU, 8)
+	require.LessOrEqual(t, peakGPUs, 2)
+	for _, task := range e.ListTasks() {
+		require.Equal(t, "completed", task.Status)
+	}
+	require.Empty(t, manager.GetAllocations())
+}
+
+func TestAdmission_DefaultRequirements(t *testing.T) {
+	manager := resource.NewManagerWithCapacity(4, 16, 0, 100)
+	e := NewExecutor(manager)
+	e.SetSchedulingPolicy(SchedulingPolicy{
+		DefaultRequirements: map[string]resource.Requirements{"inference": {CPU: 2, MemoryGB: 1}},
+	})
+
+	var mu sync.Mutex
+	var running, peak int
+	peakByType := map[string]int{}
+	runningByType := map[string]int{}
+	e.runTask = func(ctx context.Context, task *Task) {
+		mu.Lock()
+		running++
+		runningByType[task.TaskType]++
+		if running > peak {
+			peak = running
+		}
+		if runningByType[task.TaskType] > peakByType[task.TaskType] {
+			peakByType[task.TaskType] = runningByType[task.TaskType]
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		runningByType[task.TaskType]--
+		mu.Unlock()
+	}
+
+	// Tasks without requirements reserve the default for their type
+	for i := 0; i < 12; i++ {
+		taskType := "training"
+		if i%2 == 1 {
+			taskType = "inference"
+		}
+		require.NoError(t, e.AddTask(&Task{ID: fmt.Sprintf("task-%d", i), TaskType: taskType}))
+	}
+
+	ctx := context.Background()
+	waitForTasks(t, e, ctx)
+
+	require.LessOrEqual(t, peak, 4)
+	require.LessOrEqual(t, peakByType["inference"], 2)
+	for _, task := range e.ListTasks() {
+		require.Equal(t, "completed", task.Status)
+		require.Equal(t, resource.Requirements{}, task.Requirements)
+	}
+	require.Empty(t, manager.GetAllocations())
+}
+
+func TestAdmission_PriorityOrder(t *testing.T) {
+	manager := resource.NewManagerWithCapacity(2, 16, 0, 100)
+	e := NewExecutor(manager)
+
+	var mu sync.Mutex
+	var order []string
+	release := make(chan struct{})
+	e.runTask = func(ctx context.Context, task *Task) {
+		mu.Lock()
+		order = append(order, task.ID)
+		mu.Unlock()
+		<-release
+	}
+
+	require.NoError(t, e.AddTask(&Task{ID: "low", Priority: 0, Requirements: resource.Requirements{CPU: 2}}))
+	require.NoError(t, e.AddTask(&Task{ID: "small-low", Priority: 0, Requirements: resource.Requirements{CPU: 1}}))
+	require.NoError(t, e.AddTask(&Task{ID: "high", Priority: 10, Requirements: resource.Requirements{CPU: 2}}))
+
+	ctx := context.Background()
+	e.processTasks(ctx)
+
+	high, err := e.GetTask("high")
+	require.NoError(t, err)
+	require.Equal(t, "in_progress", high.Status)
+
+	// Lower-priority tasks stay queued even though "small-low" would fit
+	// once capacity frees up, and never run alongside "high"
+	low, err := e.GetTask("low")
+	require.NoError(t, err)
+	require.Equal(t, "pending", low.Status)
+
+	close(release)
+	waitForTasks(t, e, ctx)
+
+	require.Equal(t, "high", order[0])
+	require.ElementsMatch(t, []string{"high", "low", "small-low"}, order)
+}
+
+func TestAdmission_RejectsTasksLargerThanNode(t *testing.T) {
+	e := NewExecutor(resource.NewManagerWithCapacity(2, 4, 0, 10))
+	e.runTask = func(ctx context.Context, task *Task) {}
+
+	require.NoError(t, e.AddTask(&Task{ID: "huge", Requirements: resource.Requirements{CPU: 64}}))
+	e.processTasks(context.Background())
+
+	task, err := e.GetTask("huge")
+	require.NoError(t, err)
+	require.Equal(t, "failed", task.Status)
+	require.ErrorIs(t, task.Error, resource.ErrExceedsCapacity)
+}
+
+func TestAdmission_ExecuteTaskRequiresResources(t *testing.T) {
+	manager := resource.NewManagerWithCapacity(2, 4, 0, 10)
+	require.NoError(t, manager.Allocate("other", resource.Requirements{CPU: 2}))
+
+	e := NewExecutor(manager)
+	e.runTask = func(ctx context.Context, task *Task) {}
+
+	require.NoError(t, e.AddTask(&Task{ID: "task-1", Requirements: resource.Requirements{CPU: 1}}))
+	require.Error(t, e.ExecuteTask("task-1"))
+
+	require.NoError(t, manager.ReleaseResources("other"))
+	require.NoError(t, e.ExecuteTask("task-1"))
+}
+
+func TestAdmission_Drain(t *testing.T) {
+	e := NewExecutor(nil)
+	var ran atomic.Int32
+	e.runTask = func(ctx context.Context, task *Task) {
+		ran.Add(1)
+	}
+
+	require.NoError(t, e.AddTask(&Task{ID: "queued"}))
+	e.Drain()
+
+	e.processTasks(context.Background())
+	task, err := e.TaskSnapshot("queued")
+	require.NoError(t, err)
+	require.Equal(t, "pending", task.Status)
+
+	require.ErrorIs(t, e.AddTask(&Task{ID: "new"}), ErrDraining)
+	require.ErrorIs(t, e.ExecuteTask("queued"), ErrDraining)
+
+	draining, running := e.DrainStatus()
+	require.True(t, draining)
+	require.Equal(t, 0, running)
+
+	e.Undrain()
+	waitForTasks(t, e, context.Background())
+	require.Equal(t, int32(1), ran.Load())
+}
+
+func TestAdmission_SchedulingPolicy(t *testing.T) {
+	e := NewExecutor(nil)
+	running := make(chan string, 4)
+	release := make(chan struct{})
+	e.runTask = func(ctx context.Context, task *Task) {
+		running <- task.ID
+		<-release
+	}
+	e.SetSchedulingPolicy(SchedulingPolicy{TaskTypes: []string{"training"}, MaxConcurrentTasks: 1})
+
+	require.ErrorIs(t, e.AddTask(&Task{ID: "infer", TaskType: "inference"}), ErrTaskTypeNotAccepted)
+	require.NoError(t, e.AddTask(&Task{ID: "first", TaskType: "training"}))
+	require.NoError(t, e.AddTask(&Task{ID: "second"}))
+
+	ctx := context.Background()
+	e.processTasks(ctx)
+	<-running
+	e.processTasks(ctx)
+
+	statuses := map[string]int{}
+	for _, task := range e.ListTaskSnapshots() {
+		statuses[task.Status]++
+	}
+	require.Equal(t, map[string]int{"in_progress": 1, "pending": 1}, statuses)
+
+	close(release)
+	waitForTasks(t, e, ctx)
+	require.Len(t, running, 1)
+}
@@ -0,0 +1,264 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BatchConfig bounds how inference requests are batched
+type BatchConfig struct {
+	MaxBatchSize int           // Requests per batch; 1 disables batching
+	MaxWait      time.Duration // How long a request waits for others to join its batch
+}
+
+// DefaultBatchConfig returns the batching defaults
+func DefaultBatchConfig() BatchConfig {
+	return BatchConfig{
+		MaxBatchSize: 8,
+		MaxWait:      10 * time.Millisecond,
+	}
+}
+
+// BatchStats describes the batching of one model's requests
+type BatchStats struct {
+	ModelPath    string        `json:"model_path"`
+	QueueDepth   int           `json:"queue_depth"`   // Requests waiting for their batch to be dispatched
+	Batches      int64         `json:"batches"`       // Batches dispatched to the model's worker
+	Requests     int64         `json:"requests"`      // Requests in those batches
+	LargestBatch int           `json:"largest_batch"` // Size of the largest batch
+	BatchSizes   map[int]int64 `json:"batch_sizes"`   // Number of batches of each size
+}
+
+// Batcher groups compatible inference requests for the same model and
+// runs each group on the model's worker as one batch. A batch is dispatched
+// once it is full or its first request has waited MaxWait.
+type Batcher struct {
+	pool   *WorkerPool
+	config BatchConfig
+
+	mu     sync.Mutex
+	queues map[string]*batchQueue // By model path and batch key
+	stats  map[string]*BatchStats // By model path
+}
+
+// batchQueue holds the requests of a batch that has not been dispatched
+type batchQueue struct {
+	modelPath string
+	operation string
+	items     []*batchItem
+	timer     *time.Timer
+}
+
+type batchItem struct {
+	ctx  context.Context
+	data interface{}
+	done chan batchOutcome
+}
+
+type batchOutcome struct {
+	resp *workerResponse
+	err  error
+}
+
+// NewBatcher creates a batcher in front of pool. Zero config values take
+// their defaults.
+func NewBatcher(pool *WorkerPool, config BatchConfig) *Batcher {
+	defaults := DefaultBatchConfig()
+	if config.MaxBatchSize <= 0 {
+		config.MaxBatchSize = defaults.MaxBatchSize
+	}
+	if config.MaxWait < 0 {
+		config.MaxWait = 0
+	}
+
+	return &Batcher{
+		pool:   pool,
+		config: config,
+		queues: make(map[string]*batchQueue),
+		stats:  make(map[string]*BatchStats),
+	}
+}
+
+// Infer queues a request and waits for the outcome of its batch
+func (b *Batcher) Infer(ctx context.Context, modelPath string, input InferenceInput) (*workerResponse, error) {
+	operation := input.Operation
+	if operation == "" {
+		operation = InferencePredict
+	}
+	item := &batchItem{
+		ctx:  ctx,
+		data: input.Data,
+		done: make(chan batchOutcome, 1),
+	}
+	key := modelPath + "\x00" + batchKey(operation, input.Data)
+
+	b.mu.Lock()
+	q := b.queues[key]
+	if q == nil {
+		q = &batchQueue{modelPath: modelPath, operation: operation}
+		b.queues[key] = q
+	}
+	q.items = append(q.items, item)
+	b.statsLocked(modelPath).QueueDepth++
+
+	if len(q.items) >= b.config.MaxBatchSize || b.config.MaxWait == 0 {
+		b.dispatchLocked(key, q)
+	} else if len(q.items) == 1 {
+		q.timer = time.AfterFunc(b.config.MaxWait, func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if b.queues[key] == q {
+				b.dispatchLocked(key, q)
+			}
+		})
+	}
+	b.mu.Unlock()
+
+	select {
+	case outcome := <-item.done:
+		return outcome.resp, outcome.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Stats returns the batching stats of every model that has had requests,
+// sorted by model path
+func (b *Batcher) Stats() []BatchStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := make([]BatchStats, 0, len(b.stats))
+	for _, s := range b.stats {
+		copied := *s
+		copied.BatchSizes = make(map[int]int64, len(s.BatchSizes))
+		for size, count := range s.BatchSizes {
+			copied.BatchSizes[size] = count
+		}
+		stats = append(stats, copied)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].ModelPath < stats[j].ModelPath
+	})
+	return stats
+}
+
+func (b *Batcher) statsLocked(modelPath string) *BatchStats {
+	s := b.stats[modelPath]
+	if s == nil {
+		s = &BatchStats{ModelPath: modelPath, BatchSizes: make(map[int]int64)}
+		b.stats[modelPath] = s
+	}
+	return s
+}
+
+// dispatchLocked takes a queue out of the batcher and runs it. The caller
+// must hold b.mu.
+func (b *Batcher) dispatchLocked(key string, q *batchQueue) {
+	delete(b.queues, key)
+	if q.timer != nil {
+		q.timer.Stop()
+	}
+	b.statsLocked(q.modelPath).QueueDepth -= len(q.items)
+	go b.run(q)
+}
+
+// run sends a batch to the worker and fans the results out. Requests whose
+// callers have gone away are dropped first; the batch itself is cancelled
+// only once every caller has gone away.
+func (b *Batcher) run(q *batchQueue) {
+	var items []*batchItem
+	for _, item := range q.items {
+		if item.ctx.Err() == nil {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	s := b.statsLocked(q.modelPath)
+	s.Batches++
+	s.Requests += int64(len(items))
+	s.BatchSizes[len(items)]++
+	if len(items) > s.LargestBatch {
+		s.LargestBatch = len(items)
+	}
+	b.mu.Unlock()
+
+	if len(items) == 1 {
+		resp, err := b.pool.Infer(items[0].ctx, q.modelPath, InferenceInput{Operation: q.operation, Data: items[0].data})
+		items[0].done <- batchOutcome{resp: resp, err: err}
+		return
+	}
+
+	ctx, cancel := batchContext(items)
+	defer cancel()
+
+	data := make([]interface{}, len(items))
+	for i, item := range items {
+		data[i] = item.data
+	}
+	resp, err := b.pool.InferBatch(ctx, q.modelPath, q.operation, data)
+	for i, item := range items {
+		if err != nil {
+			item.done <- batchOutcome{err: err}
+			continue
+		}
+		result := resp.Results[i]
+		if !result.OK {
+			item.done <- batchOutcome{err: errors.New(result.Error)}
+			continue
+		}
+		item.done <- batchOutcome{resp: &workerResponse{
+			OK:            true,
+			Result:        result.Result,
+			LatencyMs:     resp.LatencyMs,
+			ScriptVersion: resp.ScriptVersion,
+		}}
+	}
+}
+
+// batchContext returns a context that is cancelled once every item's
+// context is done, or when cancel is called
+func batchContext(items []*batchItem) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for _, item := range items {
+			select {
+			case <-item.ctx.Done():
+			case <-ctx.Done():
+				return
+			}
+		}
+		cancel()
+	}()
+	return ctx, cancel
+}
+
+// batchKey groups requests that can share a batch. Generation requests
+// must agree on their sampling parameters; the worker applies those of the
+// first request to the whole batch.
+func batchKey(operation string, data interface{}) string {
+	if operation != InferenceGenerate && operation != InferenceChat {
+		return operation
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return operation
+	}
+	var params struct {
+		MaxTokens   int      `json:"max_tokens"`
+		Temperature *float64 `json:"temperature"`
+		TopP        *float64 `json:"top_p"`
+	}
+	json.Unmarshal(encoded, &params)
+	key, _ := json.Marshal(params)
+	return operation + string(key)
+}
@@ -0,0 +1,131 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newBatchingExecutor(t *testing.T, config BatchConfig) *Executor {
+	t.Helper()
+	e := newWorkerExecutor(t, DefaultWorkerPoolConfig())
+	e.SetBatchConfig(config)
+	return e
+}
+
+func TestBatcher_GroupsRequests(t *testing.T) {
+	capture := fakeWorker(t, `{"text": "single", "finish_reason": "stop"}`)
+	e := newBatchingExecutor(t, BatchConfig{MaxBatchSize: 4, MaxWait: 5 * time.Second})
+	model := newModelDir(t)
+
+	var wg sync.WaitGroup
+	texts := make([]string, 4)
+	errs := make([]error, 4)
+	for i := range texts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			generation, err := e.Generate(context.Background(), model, GenerateRequest{Prompt: fmt.Sprintf("p%d", i)})
+			errs[i] = err
+			if err == nil {
+				texts[i] = generation.Text
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// The batch filled up without waiting and each caller got its own result
+	for i := range texts {
+		require.NoError(t, errs[i])
+		require.Equal(t, fmt.Sprintf("p%d", i), texts[i])
+	}
+	requests := capturedRequests(t, capture)
+	require.Len(t, requests, 1)
+	require.Equal(t, "batch", requests[0].Type)
+	require.Len(t, requests[0].Requests, 4)
+
+	stats := e.BatchStats()
+	require.Len(t, stats, 1)
+	require.Equal(t, model, stats[0].ModelPath)
+	require.Equal(t, 0, stats[0].QueueDepth)
+	require.Equal(t, int64(1), stats[0].Batches)
+	require.Equal(t, int64(4), stats[0].Requests)
+	require.Equal(t, 4, stats[0].LargestBatch)
+	require.Equal(t, map[int]int64{4: 1}, stats[0].BatchSizes)
+	require.Equal(t, int64(4), e.ModelWorkers()[0].Requests)
+}
+
+func TestBatcher_MaxWaitAndCompatibility(t *testing.T) {
+	fakeWorker(t, `{"text": "single", "finish_reason": "stop"}`)
+	e := newBatchingExecutor(t, BatchConfig{MaxBatchSize: 8, MaxWait: 500 * time.Millisecond})
+	model := newModelDir(t)
+
+	// Requests with different sampling parameters cannot share a batch
+	requests := []GenerateRequest{
+		{Prompt: "a", MaxTokens: 16},
+		{Prompt: "b", MaxTokens: 16},
+		{Prompt: "c", MaxTokens: 32},
+	}
+	var wg sync.WaitGroup
+	texts := make([]string, len(requests))
+	for i, req := range requests {
+		wg.Add(1)
+		go func(i int, req GenerateRequest) {
+			defer wg.Done()
+			generation, err := e.Generate(context.Background(), model, req)
+			if err == nil {
+				texts[i] = generation.Text
+			}
+		}(i, req)
+	}
+
+	require.Eventually(t, func() bool {
+		stats := e.BatchStats()
+		return len(stats) == 1 && stats[0].QueueDepth == 3
+	}, 2*time.Second, 5*time.Millisecond)
+	wg.Wait()
+
+	require.Equal(t, []string{"a", "b", "single"}, texts)
+	stats := e.BatchStats()
+	require.Equal(t, 0, stats[0].QueueDepth)
+	require.Equal(t, int64(2), stats[0].Batches)
+	require.Equal(t, map[int]int64{1: 1, 2: 1}, stats[0].BatchSizes)
+}
+
+func TestBatcher_DropsCancelledRequests(t *testing.T) {
+	fakeWorker(t, `{"text": "single", "finish_reason": "stop"}`)
+	e := newBatchingExecutor(t, BatchConfig{MaxBatchSize: 8, MaxWait: 300 * time.Millisecond})
+	model := newModelDir(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error, 1)
+	go func() {
+		_, err := e.Generate(ctx, model, GenerateRequest{Prompt: "gone"})
+		cancelled <- err
+	}()
+	require.Eventually(t, func() bool {
+		stats := e.BatchStats()
+		return len(stats) == 1 && stats[0].QueueDepth == 1
+	}, 2*time.Second, 5*time.Millisecond)
+	cancel()
+	require.ErrorIs(t, <-cancelled, context.Canceled)
+
+	generation, err := e.Generate(context.Background(), model, GenerateRequest{Prompt: "kept"})
+	require.NoError(t, err)
+	require.Equal(t, "single", generation.Text)
+
+	stats := e.BatchStats()
+	require.Equal(t, int64(1), stats[0].Requests)
+}
+
+func TestBatchKey(t *testing.T) {
+	temperature := 0.0
+	require.Equal(t, batchKey(InferenceChat, GenerateRequest{Prompt: "a"}), batchKey(InferenceChat, GenerateRequest{Prompt: "b"}))
+	require.NotEqual(t, batchKey(InferenceChat, GenerateRequest{}), batchKey(InferenceChat, GenerateRequest{Temperature: &temperature}))
+	require.NotEqual(t, batchKey(InferenceChat, GenerateRequest{}), batchKey(InferenceGenerate, GenerateRequest{}))
+	require.Equal(t, InferenceEmbed, batchKey(InferenceEmbed, embedRequest{Inputs: []string{"x"}}))
+}
@@ -0,0 +1,218 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Task metadata keys used by the command runtime
+const (
+	MetadataCommand     = "command" // Name of a command registered with the runtime
+	MetadataCommandArgs = "args"    // Optional JSON array of extra arguments
+)
+
+// maxCommandMessageSize bounds a single line of command output
+const maxCommandMessageSize = 64 << 20
+
+// CommandRequest is written as JSON to a command's stdin before it is
+// closed
+type CommandRequest struct {
+	TaskID        string            `json:"task_id"`
+	JobID         string            `json:"job_id,omitempty"`
+	ShardID       string            `json:"shard_id,omitempty"`
+	TaskType      string            `json:"task_type"`
+	ModelPath     string            `json:"model_path,omitempty"`
+	DatasetPath   string            `json:"dataset_path,omitempty"`
+	CheckpointCID string            `json:"checkpoint_cid,omitempty"`
+	Input         json.RawMessage   `json:"input,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+}
+
+// CommandMessage is one line of JSON written by a command to stdout
+type CommandMessage struct {
+	Type     string          `json:"type"` // "progress", "result" or "error"
+	Progress float64         `json:"progress,omitempty"`
+	Output   json.RawMessage `json:"output,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// commandRuntime runs binaries installed by the node operator. Tasks refer
+// to a command by the name it was registered under, never by path, so a
+// task cannot run arbitrary programs on the node.
+//
+// The contract with a command is:
+//   - It is started in the task directory with the registered path and the
+//     task's extra arguments. The directory is kept when the task is paused.
+//   - A CommandRequest is written to stdin, which is then closed. Input is
+//     the task's input data; data that is not valid JSON is sent as a
+//     JSON string.
+//   - It reports on stdout, one CommandMessage per line:
+//     {"type":"progress","progress":0.5}
+//     {"type":"result","output":<any JSON>}
+//     {"type":"error","error":"message"}
+//     The last result becomes the task's output. Other lines go to
+//     the task's log, as does stderr.
+//   - It exits 0 on success. A non-zero exit or an error message fails the
+//     task.
+//   - SIGUSR1 asks it to save its state in the task directory and exit 0.
+//     It is started again with the same request when the task resumes.
+//   - Loss, eval and checkpoint events can be written to ATLAS_EVENTS_FD
+//     like any other task process (see TaskEvent).
+type commandRuntime struct {
+	commands map[string]string
+}
+
+// NewCommandRuntime creates a runtime that can run the given commands,
+// keyed by the name tasks use for them
+func NewCommandRuntime(commands map[string]string) Runtime {
+	registered := make(map[string]string, len(commands))
+	for name, path := range commands {
+		registered[name] = path
+	}
+	return &commandRuntime{commands: registered}
+}
+
+func (r *commandRuntime) Prepare(ctx context.Context, run *TaskRun) error {
+	path, _, err := r.resolve(run.Task)
+	if err != nil {
+		return err
+	}
+	if _, err := exec.LookPath(path); err != nil {
+		return fmt.Errorf("command %s is not executable: %w", path, err)
+	}
+
+	if err := os.MkdirAll(run.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create task directory: %w", err)
+	}
+
+	// A result left by an earlier run must not be mistaken for this one's
+	os.Remove(filepath.Join(run.Dir, "result.json"))
+	return nil
+}
+
+func (r *commandRuntime) Run(ctx context.Context, run *TaskRun) error {
+	path, args, err := r.resolve(run.Task)
+	if err != nil {
+		return err
+	}
+
+	request, err := json.Marshal(newCommandRequest(run.Task))
+	if err != nil {
+		return fmt.Errorf("failed to encode command request: %w", err)
+	}
+
+	stdout, stdoutWriter := io.Pipe()
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Dir = run.Dir
+	cmd.Stdin = bytes.NewReader(request)
+	cmd.Stdout = stdoutWriter
+
+	var result json.RawMessage
+	var reportedError string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), maxCommandMessageSize)
+		for scanner.Scan() {
+			line := scanner.Bytes()
+			var msg CommandMessage
+			if json.Unmarshal(line, &msg) != nil || msg.Type == "" {
+				fmt.Fprintf(run.Log(), "%s\n", line)
+				continue
+			}
+			switch msg.Type {
+			case "progress":
+				run.SetProgress(msg.Progress)
+			case "result":
+				result = msg.Output
+			case "error":
+				reportedError = msg.Error
+			}
+		}
+		// Keep draining so the command never blocks on a full pipe
+		io.Copy(io.Discard, stdout)
+	}()
+
+	err = run.RunProcess(ctx, cmd)
+	stdoutWriter.Close()
+	<-done
+
+	if err != nil {
+		if reportedError != "" {
+			return fmt.Errorf("command failed: %s: %w", reportedError, err)
+		}
+		return fmt.Errorf("command failed: %w", err)
+	}
+	if reportedError != "" {
+		return fmt.Errorf("command reported an error: %s", reportedError)
+	}
+
+	if result != nil {
+		if err := os.WriteFile(filepath.Join(run.Dir, "result.json"), result, 0644); err != nil {
+			return fmt.Errorf("failed to save command result: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *commandRuntime) Collect(ctx context.Context, run *TaskRun) ([]byte, error) {
+	output, err := os.ReadFile(filepath.Join(run.Dir, "result.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return output, err
+}
+
+// resolve returns the path and arguments of the command a task asks for
+func (r *commandRuntime) resolve(task *Task) (string, []string, error) {
+	name := task.Metadata[MetadataCommand]
+	if name == "" {
+		return "", nil, fmt.Errorf("task metadata %q is required by the command runtime", MetadataCommand)
+	}
+
+	path, ok := r.commands[name]
+	if !ok {
+		return "", nil, fmt.Errorf("command not registered: %s", name)
+	}
+
+	var args []string
+	if raw := strings.TrimSpace(task.Metadata[MetadataCommandArgs]); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &args); err != nil {
+			return "", nil, fmt.Errorf("invalid command arguments: %w", err)
+		}
+	}
+
+	return path, args, nil
+}
+
+func newCommandRequest(task *Task) *CommandRequest {
+	request := &CommandRequest{
+		TaskID:        task.ID,
+		JobID:         task.JobID,
+		ShardID:       task.ShardID,
+		TaskType:      task.TaskType,
+		ModelPath:     task.ModelPath,
+		DatasetPath:   task.DatasetPath,
+		CheckpointCID: task.CheckpointCID,
+		Metadata:      task.Metadata,
+	}
+
+	if len(task.InputData) > 0 {
+		if json.Valid(task.InputData) {
+			request.Input = task.InputData
+		} else {
+			request.Input, _ = json.Marshal(string(task.InputData))
+		}
+	}
+
+	return request
+}
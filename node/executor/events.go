@@ -0,0 +1,218 @@
+package executor
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Task processes report progress and metrics by writing one JSON TaskEvent
+// per line to the file descriptor named in ATLAS_EVENTS_FD:
+//
+//	{"type":"progress","progress":0.25,"epoch":2,"step":400}
+//	{"type":"loss","value":0.318,"epoch":2,"step":400}
+//	{"type":"eval","metrics":{"accuracy":0.91,"f1":0.88},"epoch":2}
+//	{"type":"checkpoint","path":"checkpoint.pt","epoch":2}
+//	{"type":"error","message":"CUDA out of memory"}
+//
+// Progress updates Task.Progress as it arrives. Checkpoints are hashed as
+// they are reported, and published to IPFS if the executor proves tasks.
+// The last error message is added to the task's error if the process
+// fails. Other lines and unknown event types are ignored.
+const eventsFDEnv = "ATLAS_EVENTS_FD"
+
+// Task event types
+const (
+	EventProgress   = "progress"
+	EventLoss       = "loss"
+	EventEval       = "eval"
+	EventCheckpoint = "checkpoint"
+	EventError      = "error"
+)
+
+const (
+	// maxTaskEvents bounds the events kept per task; the oldest are dropped
+	maxTaskEvents = 10000
+
+	// maxEventSize bounds a single event line
+	maxEventSize = 1 << 20
+
+	// eventsDrainTimeout is how long events still buffered when a process
+	// exits are read before the stream is closed
+	eventsDrainTimeout = 2 * time.Second
+)
+
+// TaskEvent is a progress or metrics report from a task process. Time,
+// Hash and CID are set by the executor when the event is received.
+type TaskEvent struct {
+	Type     string             `json:"type"`
+	Time     time.Time          `json:"time"`
+	Progress float64            `json:"progress,omitempty"`
+	Value    float64            `json:"value,omitempty"`
+	Metrics  map[string]float64 `json:"metrics,omitempty"`
+	Path     string             `json:"path,omitempty"`
+	Message  string             `json:"message,omitempty"`
+	Epoch    int                `json:"epoch,omitempty"`
+	Step     int64              `json:"step,omitempty"`
+	Hash     string             `json:"hash,omitempty"` // sha256 of a checkpoint
+	CID      string             `json:"cid,omitempty"`  // CID of a published checkpoint
+}
+
+// eventStream reads task events from the pipe passed to a task process
+type eventStream struct {
+	reader    *os.File
+	writer    *os.File
+	done      chan struct{}
+	closeOnce sync.Once
+	lastError string
+	span      trace.Span // Span of the process, annotated with its reports
+}
+
+// openEventStream passes the write end of a new pipe to cmd and starts
+// recording the events read from it
+func (e *Executor) openEventStream(taskID string, cmd *exec.Cmd, span trace.Span) (*eventStream, error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create event pipe: %w", err)
+	}
+
+	cmd.ExtraFiles = append(cmd.ExtraFiles, writer)
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	// ExtraFiles start at fd 3
+	cmd.Env = append(env, fmt.Sprintf("%s=%d", eventsFDEnv, 2+len(cmd.ExtraFiles)))
+
+	stream := &eventStream{
+		reader: reader,
+		writer: writer,
+		done:   make(chan struct{}),
+		span:   span,
+	}
+	go stream.read(e, taskID)
+	return stream, nil
+}
+
+func (s *eventStream) read(e *Executor, taskID string) {
+	defer close(s.done)
+
+	scanner := bufio.NewScanner(s.reader)
+	scanner.Buffer(make([]byte, 4096), maxEventSize)
+	reported := false
+	for scanner.Scan() {
+		var event TaskEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		event.Time = time.Now()
+		if event.Type == EventCheckpoint && event.Path != "" {
+			e.recordCheckpoint(taskID, &event)
+		}
+		// The first report ends the process's startup; checkpoints mark
+		// the rest of the run
+		if !reported || event.Type == EventCheckpoint {
+			s.span.AddEvent("task "+event.Type, trace.WithAttributes(attribute.Int("epoch", event.Epoch), attribute.Int64("step", event.Step)))
+			reported = true
+		}
+		if event.Type == EventError {
+			s.lastError = event.Message
+		}
+		e.recordEvent(taskID, event)
+	}
+}
+
+// started closes the executor's copy of the write end once the process has
+// inherited it, so the stream ends when the process exits
+func (s *eventStream) started() {
+	s.writer.Close()
+}
+
+// close waits for buffered events to be read and returns the last error
+// message reported by the process. Processes that leave descendants holding
+// the pipe open are cut off after eventsDrainTimeout.
+func (s *eventStream) close() string {
+	s.closeOnce.Do(func() {
+		s.writer.Close()
+		select {
+		case <-s.done:
+		case <-time.After(eventsDrainTimeout):
+		}
+		s.reader.Close()
+		<-s.done
+	})
+	return s.lastError
+}
+
+// recordEvent applies a task event and appends it to the task's series
+func (e *Executor) recordEvent(taskID string, event TaskEvent) {
+	switch event.Type {
+	case EventProgress, EventLoss, EventEval, EventCheckpoint, EventError:
+	default:
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	task, exists := e.tasks[taskID]
+	if !exists {
+		return
+	}
+
+	if event.Type == EventProgress {
+		switch {
+		case event.Progress < 0:
+			event.Progress = 0
+		case event.Progress > 1:
+			event.Progress = 1
+		}
+		task.Progress = event.Progress
+	}
+	// The epoch is persisted so a task resumed after a restart continues
+	// where its checkpoint left off
+	if event.Type == EventCheckpoint {
+		task.ResumeEpoch = event.Epoch
+		e.persistLocked(task)
+	}
+
+	events := e.events[taskID]
+	if len(events) >= maxTaskEvents {
+		events = append(events[:0], events[len(events)-maxTaskEvents+1:]...)
+	}
+	e.events[taskID] = append(events, event)
+}
+
+// TaskMetrics returns the events reported by a task, oldest first
+func (e *Executor) TaskMetrics(taskID string) ([]TaskEvent, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if _, exists := e.tasks[taskID]; !exists {
+		return nil, fmt.Errorf("task not found: %s", taskID)
+	}
+
+	events := make([]TaskEvent, len(e.events[taskID]))
+	copy(events, e.events[taskID])
+	return events, nil
+}
+
+// resumeEpoch returns the epoch of the last checkpoint a task reported, or
+// 0 if it reported none
+func (e *Executor) resumeEpoch(taskID string) int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	task, exists := e.tasks[taskID]
+	if !exists {
+		return 0
+	}
+	return task.ResumeEpoch
+}
@@ -0,0 +1,108 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskEvents_ProgressIsLive(t *testing.T) {
+	workDir := t.TempDir()
+	e := NewExecutor(nil)
+	e.SetWorkDir(workDir)
+	e.runTask = shellTask(e, workDir, `
+echo '{"type":"progress","progress":0.4,"epoch":1}' >&$ATLAS_EVENTS_FD
+echo '{"type":"loss","value":0.75,"epoch":1,"step":100}' >&$ATLAS_EVENTS_FD
+echo 'not an event' >&$ATLAS_EVENTS_FD
+while [ ! -f continue ]; do sleep 0.01; done
+echo '{"type":"eval","metrics":{"accuracy":0.9},"epoch":1}' >&$ATLAS_EVENTS_FD
+echo '{"type":"checkpoint","path":"checkpoint.pt","epoch":1}' >&$ATLAS_EVENTS_FD
+`)
+
+	require.NoError(t, e.AddTask(&Task{ID: "task-1"}))
+	e.processTasks(context.Background())
+
+	// Progress is visible while the process is still running
+	require.Eventually(t, func() bool {
+		e.mu.RLock()
+		defer e.mu.RUnlock()
+		return e.tasks["task-1"].Progress == 0.4
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "task-1", "continue"), nil, 0644))
+	waitForStatus(t, e, "task-1", "completed")
+
+	events, err := e.TaskMetrics("task-1")
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+	require.Equal(t, EventProgress, events[0].Type)
+	require.Equal(t, EventLoss, events[1].Type)
+	require.Equal(t, 0.75, events[1].Value)
+	require.Equal(t, int64(100), events[1].Step)
+	require.Equal(t, 0.9, events[2].Metrics["accuracy"])
+	require.Equal(t, "checkpoint.pt", events[3].Path)
+	require.False(t, events[0].Time.IsZero())
+
+	_, err = e.TaskMetrics("missing")
+	require.Error(t, err)
+}
+
+func TestTaskEvents_ErrorMessage(t *testing.T) {
+	workDir := t.TempDir()
+	e := NewExecutor(nil)
+	e.SetWorkDir(workDir)
+	e.runTask = shellTask(e, workDir, `
+echo '{"type":"error","message":"CUDA out of memory"}' >&$ATLAS_EVENTS_FD
+exit 1
+`)
+
+	require.NoError(t, e.AddTask(&Task{ID: "task-1"}))
+	e.processTasks(context.Background())
+	waitForStatus(t, e, "task-1", "failed")
+
+	task, err := e.GetTask("task-1")
+	require.NoError(t, err)
+	require.ErrorContains(t, task.Error, "CUDA out of memory")
+}
+
+func TestRecordEvent_BoundsSeries(t *testing.T) {
+	e := NewExecutor(nil)
+	require.NoError(t, e.AddTask(&Task{ID: "task-1"}))
+
+	for i := 0; i < maxTaskEvents+10; i++ {
+		e.recordEvent("task-1", TaskEvent{Type: EventLoss, Step: int64(i)})
+	}
+	e.recordEvent("task-1", TaskEvent{Type: "unknown"})
+	e.recordEvent("task-1", TaskEvent{Type: EventProgress, Progress: 3})
+
+	events, err := e.TaskMetrics("task-1")
+	require.NoError(t, err)
+	require.Len(t, events, maxTaskEvents)
+	require.Equal(t, int64(11), events[0].Step)
+
+	task, err := e.GetTask("task-1")
+	require.NoError(t, err)
+	require.Equal(t, 1.0, task.Progress)
+}
+
+func TestRemoveTask_DropsEvents(t *testing.T) {
+	e := NewExecutor(nil)
+	e.SetWorkDir(t.TempDir())
+	require.NoError(t, e.OpenTaskStore())
+	defer e.Stop()
+	require.NoError(t, e.AddTask(&Task{ID: "task-1"}))
+	e.recordEvent("task-1", TaskEvent{Type: EventLoss, Step: 1})
+
+	require.ErrorContains(t, e.RemoveTask("task-1"), "cancel it before removing it")
+	require.NoError(t, e.CancelTask("task-1"))
+	require.NoError(t, e.RemoveTask("task-1"))
+
+	require.NotContains(t, e.events, "task-1")
+	_, err := e.TaskMetrics("task-1")
+	require.ErrorContains(t, err, "task not found")
+	require.NotContains(t, e.store.(*JournalStore).live, "task-1")
+}
@@ -0,0 +1,161 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// ChatMessage is one message of a chat conversation
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// GenerateRequest asks a language model to continue Prompt or, if Messages
+// is set, to answer a chat conversation. Zero values use model defaults.
+type GenerateRequest struct {
+	Prompt      string        `json:"prompt,omitempty"`
+	Messages    []ChatMessage `json:"messages,omitempty"`
+	MaxTokens   int           `json:"max_tokens,omitempty"`
+	Temperature *float64      `json:"temperature,omitempty"`
+	TopP        *float64      `json:"top_p,omitempty"`
+	Stop        []string      `json:"stop,omitempty"`
+}
+
+// Generation is the text produced for a GenerateRequest. FinishReason is
+// "stop" or "length".
+type Generation struct {
+	Text             string `json:"text"`
+	FinishReason     string `json:"finish_reason"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+}
+
+// GenerationChunk is one message of a streamed generation: a piece of
+// generated text, or last, the complete Generation or the error that ended
+// the stream
+type GenerationChunk struct {
+	Text       string
+	Generation *Generation
+	Err        error
+}
+
+// embedRequest is the input of an InferenceEmbed inference
+type embedRequest struct {
+	Inputs []string `json:"inputs"`
+}
+
+// Embeddings are the vectors produced for a list of inputs, in order
+type Embeddings struct {
+	Embeddings   [][]float64 `json:"embeddings"`
+	PromptTokens int         `json:"prompt_tokens"`
+}
+
+// Generate runs a text generation on the model at modelPath (a CID or local
+// path) outside the task queue, on the model's worker
+func (e *Executor) Generate(ctx context.Context, modelPath string, req GenerateRequest) (*Generation, error) {
+	var generation Generation
+	if err := e.infer(ctx, modelPath, InferenceInput{Operation: generateOperation(req), Data: req}, &generation); err != nil {
+		return nil, err
+	}
+	return &generation, nil
+}
+
+// GenerateStream runs a generation like Generate, sending its text on the
+// returned channel as the model produces it. The channel is closed after
+// the final chunk. Cancelling ctx stops the generation on the worker.
+// Streamed generations are not batched.
+func (e *Executor) GenerateStream(ctx context.Context, modelPath string, req GenerateRequest) <-chan GenerationChunk {
+	chunks := make(chan GenerationChunk, 16)
+	go func() {
+		defer close(chunks)
+		send := func(chunk GenerationChunk) bool {
+			select {
+			case chunks <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		ie := e.inference()
+		tokens := make(chan string)
+		result := make(chan batchOutcome, 1)
+		go func() {
+			ctx, finish := e.startInference(ctx, modelPath, generateOperation(req))
+			resp, err := ie.pool.InferStream(ctx, modelPath, InferenceInput{Operation: generateOperation(req), Data: req}, tokens)
+			finish(err)
+			result <- batchOutcome{resp: resp, err: err}
+		}()
+
+		for {
+			select {
+			case text := <-tokens:
+				if !send(GenerationChunk{Text: text}) {
+					return
+				}
+			case outcome := <-result:
+				if outcome.err != nil {
+					send(GenerationChunk{Err: outcome.err})
+					return
+				}
+				var generation Generation
+				if err := json.Unmarshal(outcome.resp.Result, &generation); err != nil {
+					send(GenerationChunk{Err: fmt.Errorf("failed to parse inference result: %w", err)})
+					return
+				}
+				send(GenerationChunk{Generation: &generation})
+				return
+			}
+		}
+	}()
+	return chunks
+}
+
+// Embed computes embeddings for inputs with the model at modelPath outside
+// the task queue
+func (e *Executor) Embed(ctx context.Context, modelPath string, inputs []string) (*Embeddings, error) {
+	var embeddings Embeddings
+	if err := e.infer(ctx, modelPath, InferenceInput{Operation: InferenceEmbed, Data: embedRequest{Inputs: inputs}}, &embeddings); err != nil {
+		return nil, err
+	}
+	if len(embeddings.Embeddings) != len(inputs) {
+		return nil, fmt.Errorf("model returned %d embeddings for %d inputs", len(embeddings.Embeddings), len(inputs))
+	}
+	return &embeddings, nil
+}
+
+// infer runs an inference on the model's worker, batched with other
+// requests for the model, and decodes its result into out
+func (e *Executor) infer(ctx context.Context, modelPath string, input InferenceInput, out interface{}) error {
+	ctx, finish := e.startInference(ctx, modelPath, input.Operation)
+	resp, err := e.inference().batcher.Infer(ctx, modelPath, input)
+	finish(err)
+	if err != nil {
+		return err
+	}
+	if len(resp.Result) == 0 {
+		return fmt.Errorf("inference output has no result")
+	}
+	if err := json.Unmarshal(resp.Result, out); err != nil {
+		return fmt.Errorf("failed to parse inference result: %w", err)
+	}
+	return nil
+}
+
+// inference returns the inference executor, initializing it if needed
+func (e *Executor) inference() *InferenceExecutor {
+	e.InitializeInferenceExecutor()
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.inferenceExecutor
+}
+
+// generateOperation is the inference operation for a GenerateRequest
+func generateOperation(req GenerateRequest) string {
+	if len(req.Messages) > 0 {
+		return InferenceChat
+	}
+	return InferenceGenerate
+}
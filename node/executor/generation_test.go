@@ -0,0 +1,103 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	modelDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(modelDir, "config.json"), []byte(`{}`), 0644))
+	capture := fakeWorker(t, `{"text": "hello", "finish_reason": "stop", "prompt_tokens": 5, "completion_tokens": 1}`)
+
+	e := NewExecutor(nil)
+	e.SetWorkDir(t.TempDir())
+	t.Cleanup(e.Stop)
+
+	temperature := 0.0
+	generation, err := e.Generate(context.Background(), modelDir, GenerateRequest{
+		Messages:    []ChatMessage{{Role: "user", Content: "hi"}},
+		MaxTokens:   8,
+		Temperature: &temperature,
+	})
+	require.NoError(t, err)
+	require.Equal(t, &Generation{Text: "hello", FinishReason: "stop", PromptTokens: 5, CompletionTokens: 1}, generation)
+
+	requests := capturedRequests(t, capture)
+	require.Len(t, requests, 1)
+	var data GenerateRequest
+	require.NoError(t, json.Unmarshal(requests[0].Data, &data))
+	require.Equal(t, InferenceChat, requests[0].Operation)
+	require.Equal(t, 8, data.MaxTokens)
+}
+
+func TestEmbed_ChecksCount(t *testing.T) {
+	modelDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(modelDir, "config.json"), []byte(`{}`), 0644))
+	fakeWorker(t, `{"embeddings": [[0.1, 0.2]], "prompt_tokens": 2}`)
+
+	e := NewExecutor(nil)
+	e.SetWorkDir(t.TempDir())
+	t.Cleanup(e.Stop)
+
+	embeddings, err := e.Embed(context.Background(), modelDir, []string{"a"})
+	require.NoError(t, err)
+	require.Equal(t, [][]float64{{0.1, 0.2}}, embeddings.Embeddings)
+
+	_, err = e.Embed(context.Background(), modelDir, []string{"a", "b"})
+	require.ErrorContains(t, err, "1 embeddings for 2 inputs")
+}
+
+func TestGenerateStream(t *testing.T) {
+	capture := fakeWorker(t, `{"text": "Hello", "finish_reason": "stop", "completion_tokens": 2}`)
+	e := newWorkerExecutor(t, DefaultWorkerPoolConfig())
+
+	var texts []string
+	var generation *Generation
+	for chunk := range e.GenerateStream(context.Background(), newModelDir(t), GenerateRequest{Prompt: "hi"}) {
+		require.NoError(t, chunk.Err)
+		if chunk.Generation != nil {
+			generation = chunk.Generation
+			continue
+		}
+		require.Nil(t, generation, "text after the final chunk")
+		texts = append(texts, chunk.Text)
+	}
+	require.Equal(t, []string{"Hel", "lo"}, texts)
+	require.Equal(t, &Generation{Text: "Hello", FinishReason: "stop", CompletionTokens: 2}, generation)
+
+	requests := capturedRequests(t, capture)
+	require.Len(t, requests, 1)
+	require.True(t, requests[0].Stream)
+	require.Equal(t, InferenceGenerate, requests[0].Operation)
+}
+
+func TestGenerateStream_CancelStopsWorker(t *testing.T) {
+	capture := fakeWorker(t, `{"text": "Hello", "finish_reason": "stop"}`)
+	e := newWorkerExecutor(t, DefaultWorkerPoolConfig())
+	model := newModelDir(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	chunks := e.GenerateStream(ctx, model, GenerateRequest{Prompt: "hold"})
+	require.Equal(t, "Hel", (<-chunks).Text)
+	cancel()
+	for range chunks {
+	}
+
+	// The worker was told to stop the generation
+	require.Eventually(t, func() bool {
+		requests := capturedRequests(t, capture)
+		return len(requests) == 2 && requests[1].Type == "cancel" && requests[1].Target == requests[0].ID
+	}, 5*time.Second, 20*time.Millisecond)
+
+	// and goes on serving
+	generation, err := e.Generate(context.Background(), model, GenerateRequest{Prompt: "next"})
+	require.NoError(t, err)
+	require.Equal(t, "Hello", generation.Text)
+}
@@ -5,343 +5,130 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"time"
-
-	"github.com/atlas/storage/manager"
 )
 
 type InferenceExecutor struct {
-	executor    *Executor
-	workDir     string
-	ipfsManager *manager.IPFSManager
-	modelCache  map[string]string
+	executor *Executor
+	workDir  string
+	pool     *WorkerPool
+	batcher  *Batcher
 }
 
+// Inference operations understood by scripts/inference.py. Predict feeds
+// Data to a tensor model; the others need a Hugging Face model directory.
+const (
+	InferencePredict  = "predict"
+	InferenceGenerate = "generate"
+	InferenceChat     = "chat"
+	InferenceEmbed    = "embed"
+)
+
 type InferenceInput struct {
 	Data      interface{} `json:"data"`
 	ModelType string      `json:"model_type,omitempty"`
 	Options   map[string]interface{} `json:"options,omitempty"`
+	Operation string      `json:"operation,omitempty"` // Defaults to InferencePredict
 }
 
 type InferenceOutput struct {
-	Result    interface{} `json:"result"`
-	LatencyMs int64       `json:"latency_ms"`
-	ModelID   string      `json:"model_id"`
+	Result        interface{} `json:"result"`
+	LatencyMs     int64       `json:"latency_ms"`
+	ModelID       string      `json:"model_id"`
+	ScriptVersion string      `json:"script_version,omitempty"`
 }
 
-func NewInferenceExecutor(e *Executor, workDir string, ipfsAPIURL string) *InferenceExecutor {
-	return &InferenceExecutor{
-		executor:    e,
-		workDir:     workDir,
-		ipfsManager: manager.NewIPFSManager(ipfsAPIURL),
-		modelCache:  make(map[string]string),
+// NewInferenceExecutor creates an inference executor whose model workers
+// use e's worker pool, batching and log settings, and its artifact cache
+func NewInferenceExecutor(e *Executor, workDir string) *InferenceExecutor {
+	ie := &InferenceExecutor{
+		executor: e,
+		workDir:  workDir,
 	}
+	ie.pool = NewWorkerPool(ie, workDir, e.logConfig, e.workerConfig)
+	ie.batcher = NewBatcher(ie.pool, e.batchConfig)
+	return ie
 }
 
+// ExecuteInference runs an inference on the worker for modelPath, batched
+// with other requests for the model, and returns its output. The model
+// stays loaded for later requests.
 func (ie *InferenceExecutor) ExecuteInference(ctx context.Context, task *Task, modelPath string, inputData []byte) (*InferenceOutput, error) {
-	startTime := time.Now()
-	
-	taskDir := filepath.Join(ie.workDir, task.ID)
-	if err := os.MkdirAll(taskDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create task directory: %w", err)
-	}
-
-	modelLocalPath, err := ie.ensureModelDownloaded(ctx, modelPath, taskDir)
-	if err != nil {
-		return nil, fmt.Errorf("failed to download model: %w", err)
-	}
-
-	inputPath := filepath.Join(taskDir, "input.json")
-	if err := os.WriteFile(inputPath, inputData, 0644); err != nil {
-		return nil, fmt.Errorf("failed to write input file: %w", err)
-	}
-
-	outputPath := filepath.Join(taskDir, "output.json")
-	
 	var input InferenceInput
 	if err := json.Unmarshal(inputData, &input); err != nil {
 		return nil, fmt.Errorf("failed to parse input: %w", err)
 	}
 
-	modelType := input.ModelType
-	if modelType == "" {
-		modelType = "auto"
-	}
-
-	scriptPath := filepath.Join(taskDir, "inference.py")
-	if err := ie.createInferenceScript(scriptPath, modelLocalPath, inputPath, outputPath, modelType); err != nil {
-		return nil, fmt.Errorf("failed to create inference script: %w", err)
-	}
-
-	cmd := exec.CommandContext(ctx, "python3", scriptPath)
-	cmd.Dir = taskDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Run(); err != nil {
-		cmd = exec.CommandContext(ctx, "python", scriptPath)
-		cmd.Dir = taskDir
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		
-		if err := cmd.Run(); err != nil {
-			return nil, fmt.Errorf("inference script execution failed: %w", err)
-		}
-	}
-
-	outputData, err := os.ReadFile(outputPath)
+	ctx, finish := ie.executor.startInference(ctx, modelPath, input.Operation)
+	resp, err := ie.batcher.Infer(ctx, modelPath, input)
+	finish(err)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read output file: %w", err)
+		return nil, fmt.Errorf("inference failed: %w", err)
 	}
 
 	var result interface{}
-	if err := json.Unmarshal(outputData, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse output: %w", err)
+	if len(resp.Result) > 0 {
+		if err := json.Unmarshal(resp.Result, &result); err != nil {
+			return nil, fmt.Errorf("failed to parse output: %w", err)
+		}
 	}
 
-	latency := time.Since(startTime).Milliseconds()
-
+	// The worker measures latency around the model call itself
 	return &InferenceOutput{
-		Result:    result,
-		LatencyMs: latency,
-		ModelID:   task.ModelPath,
+		Result:        result,
+		LatencyMs:     resp.LatencyMs,
+		ModelID:       modelPath,
+		ScriptVersion: resp.ScriptVersion,
 	}, nil
 }
 
-func (ie *InferenceExecutor) ensureModelDownloaded(ctx context.Context, modelPath string, taskDir string) (string, error) {
-	if cached, ok := ie.modelCache[modelPath]; ok {
-		if _, err := os.Stat(cached); err == nil {
-			return cached, nil
-		}
-	}
+// Workers describes the resident model workers
+func (ie *InferenceExecutor) Workers() []WorkerInfo {
+	return ie.pool.Workers()
+}
 
-	modelDir := filepath.Join(taskDir, "model")
-	if err := os.MkdirAll(modelDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create model directory: %w", err)
-	}
+// BatchStats describes how each model's requests have been batched
+func (ie *InferenceExecutor) BatchStats() []BatchStats {
+	return ie.batcher.Stats()
+}
 
-	if len(modelPath) >= 2 && (modelPath[:2] == "Qm" || modelPath[:2] == "ba") {
-		if err := ie.ipfsManager.GetFile(modelPath, modelDir); err != nil {
-			return "", fmt.Errorf("IPFS download failed: %w", err)
-		}
-	} else {
-		if _, err := os.Stat(modelPath); os.IsNotExist(err) {
-			return "", fmt.Errorf("local model file not found: %s", modelPath)
-		}
-		if err := copyFile(modelPath, filepath.Join(modelDir, filepath.Base(modelPath))); err != nil {
-			return "", fmt.Errorf("failed to copy model: %w", err)
-		}
-	}
+// Close stops the model workers
+func (ie *InferenceExecutor) Close() {
+	ie.pool.Close()
+}
 
-	modelFiles, err := findModelFiles(modelDir)
+// stageModel returns the local path of the model file or Hugging Face model
+// directory for modelPath, a CID or a local path, and the staged artifact
+// it is in. A model fetched by CID stays pinned in the artifact cache until
+// release is called.
+func (ie *InferenceExecutor) stageModel(ctx context.Context, modelPath string) (string, string, func(), error) {
+	path, release, err := ie.executor.stageArtifact(ctx, modelPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to find model files: %w", err)
+		return "", "", nil, err
 	}
 
-	if len(modelFiles) == 0 {
-		return "", fmt.Errorf("no model files found in %s", modelDir)
+	info, err := os.Stat(path)
+	if err != nil {
+		release()
+		return "", "", nil, fmt.Errorf("failed to stat model: %w", err)
+	}
+	if !info.IsDir() {
+		return path, path, release, nil
 	}
 
-	modelLocalPath := modelFiles[0]
-	ie.modelCache[modelPath] = modelLocalPath
-
-	return modelLocalPath, nil
-}
-
-func (ie *InferenceExecutor) createInferenceScript(scriptPath string, modelPath string, inputPath string, outputPath string, modelType string) error {
-	script := fmt.Sprintf(`#!/usr/bin/env python3
-import json
-import os
-import sys
-import time
-import torch
-import numpy as np
-from pathlib import Path
-
-def load_model(model_path):
-    """Load model from file"""
-    device = torch.device("cuda" if torch.cuda.is_available() else "cpu")
-    print(f"Loading model from: {model_path}", file=sys.stderr)
-    
-    if os.path.isdir(model_path):
-        model_files = [f for f in os.listdir(model_path) if f.endswith(('.pt', '.pth', '.onnx', '.h5'))]
-        if model_files:
-            model_path = os.path.join(model_path, model_files[0])
-        else:
-            raise FileNotFoundError(f"No model file found in directory: {model_path}")
-    
-    extension = Path(model_path).suffix.lower()
-    
-    if extension in ['.pt', '.pth']:
-        model_data = torch.load(model_path, map_location=device)
-        if isinstance(model_data, torch.nn.Module):
-            model = model_data.to(device)
-            model.eval()
-            return model, device, 'pytorch'
-        elif isinstance(model_data, dict):
-            if 'model' in model_data:
-                model = model_data['model'].to(device)
-                model.eval()
-                return model, device, 'pytorch'
-            else:
-                raise ValueError("Invalid PyTorch model format")
-        else:
-            raise ValueError(f"Unsupported PyTorch model type: {type(model_data)}")
-    
-    elif extension == '.onnx':
-        try:
-            import onnxruntime as ort
-            session = ort.InferenceSession(model_path)
-            return session, device, 'onnx'
-        except ImportError:
-            raise ImportError("ONNX Runtime not installed. Install with: pip install onnxruntime")
-    
-    elif extension == '.h5':
-        try:
-            import tensorflow as tf
-            model = tf.keras.models.load_model(model_path)
-            return model, device, 'tensorflow'
-        except ImportError:
-            raise ImportError("TensorFlow not installed. Install with: pip install tensorflow")
-    
-    else:
-        raise ValueError(f"Unsupported model format: {extension}")
-
-def prepare_input(input_data, model_type, framework):
-    """Prepare input data for model"""
-    if framework == 'pytorch':
-        if isinstance(input_data, list):
-            tensor = torch.tensor(input_data, dtype=torch.float32)
-        elif isinstance(input_data, dict):
-            if 'input_ids' in input_data:
-                tensor = torch.tensor(input_data['input_ids'], dtype=torch.long)
-            else:
-                tensor = torch.tensor(list(input_data.values())[0], dtype=torch.float32)
-        else:
-            tensor = torch.tensor(input_data, dtype=torch.float32)
-        
-        if tensor.dim() == 1:
-            tensor = tensor.unsqueeze(0)
-        
-        return tensor
-    
-    elif framework == 'onnx':
-        if isinstance(input_data, list):
-            array = np.array(input_data, dtype=np.float32)
-        elif isinstance(input_data, dict):
-            array = np.array(list(input_data.values())[0], dtype=np.float32)
-        else:
-            array = np.array(input_data, dtype=np.float32)
-        
-        if array.ndim == 1:
-            array = array.reshape(1, -1)
-        
-        return {list(input_data.keys())[0] if isinstance(input_data, dict) else 'input': array}
-    
-    elif framework == 'tensorflow':
-        if isinstance(input_data, list):
-            array = np.array(input_data, dtype=np.float32)
-        elif isinstance(input_data, dict):
-            array = np.array(list(input_data.values())[0], dtype=np.float32)
-        else:
-            array = np.array(input_data, dtype=np.float32)
-        
-        if array.ndim == 1:
-            array = array.reshape(1, -1)
-        
-        return array
-    
-    return input_data
-
-def run_inference(model, input_data, framework, device):
-    """Run inference on model"""
-    if framework == 'pytorch':
-        model_input = prepare_input(input_data, 'pytorch', framework)
-        model_input = model_input.to(device)
-        
-        with torch.no_grad():
-            output = model(model_input)
-        
-        if isinstance(output, torch.Tensor):
-            result = output.cpu().numpy().tolist()
-        elif isinstance(output, (list, tuple)):
-            result = [o.cpu().numpy().tolist() if isinstance(o, torch.Tensor) else o for o in output]
-        else:
-            result = output
-        
-        return result
-    
-    elif framework == 'onnx':
-        model_input = prepare_input(input_data, 'onnx', framework)
-        output_names = [output.name for output in model.get_outputs()]
-        outputs = model.run(output_names, model_input)
-        
-        if len(outputs) == 1:
-            result = outputs[0].tolist()
-        else:
-            result = [o.tolist() for o in outputs]
-        
-        return result
-    
-    elif framework == 'tensorflow':
-        model_input = prepare_input(input_data, 'tensorflow', framework)
-        output = model.predict(model_input, verbose=0)
-        return output.tolist()
-    
-    return None
-
-def main():
-    model_path = '%s'
-    input_path = '%s'
-    output_path = '%s'
-    model_type = '%s'
-    
-    try:
-        print("=" * 50, file=sys.stderr)
-        print("Atlas Inference Script", file=sys.stderr)
-        print("=" * 50, file=sys.stderr)
-        
-        with open(input_path, 'r') as f:
-            input_data = json.load(f)
-        
-        data = input_data.get('data', input_data)
-        
-        print(f"Loading model...", file=sys.stderr)
-        model, device, framework = load_model(model_path)
-        print(f"Model loaded on {device}, framework: {framework}", file=sys.stderr)
-        
-        print(f"Running inference...", file=sys.stderr)
-        start_time = time.time()
-        result = run_inference(model, data, framework, device)
-        inference_time = (time.time() - start_time) * 1000
-        
-        print(f"Inference completed in {inference_time:.2f}ms", file=sys.stderr)
-        
-        output = {
-            "result": result,
-            "latency_ms": int(inference_time),
-            "framework": framework
-        }
-        
-        with open(output_path, 'w') as f:
-            json.dump(output, f, indent=2)
-        
-        print(f"Output saved to {output_path}", file=sys.stderr)
-        sys.exit(0)
-    
-    except Exception as e:
-        print(f"Inference failed: {e}", file=sys.stderr)
-        import traceback
-        traceback.print_exc(file=sys.stderr)
-        sys.exit(1)
-
-if __name__ == '__main__':
-    main()
-`, modelPath, inputPath, outputPath, modelType)
-
-	return os.WriteFile(scriptPath, []byte(script), 0755)
+	modelFiles, err := findModelFiles(path)
+	if err != nil {
+		release()
+		return "", "", nil, fmt.Errorf("failed to find model files: %w", err)
+	}
+	if len(modelFiles) > 0 {
+		return modelFiles[0], path, release, nil
+	}
+	if hfDir := findHFModelDir(path); hfDir != "" {
+		return hfDir, path, release, nil
+	}
+	release()
+	return "", "", nil, fmt.Errorf("no model files found in %s", path)
 }
 
 func findModelFiles(dir string) ([]string, error) {
@@ -361,20 +148,18 @@ func findModelFiles(dir string) ([]string, error) {
 	return files, err
 }
 
-func copyFile(src, dst string) error {
-	sourceFile, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer sourceFile.Close()
-
-	destFile, err := os.Create(dst)
-	if err != nil {
-		return err
-	}
-	defer destFile.Close()
-
-	_, err = destFile.ReadFrom(sourceFile)
-	return err
+// findHFModelDir returns the directory holding a Hugging Face model's
+// config.json, or "" if there is none
+func findHFModelDir(dir string) string {
+	var found string
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || found != "" {
+			return nil
+		}
+		if !info.IsDir() && info.Name() == "config.json" {
+			found = filepath.Dir(path)
+		}
+		return nil
+	})
+	return found
 }
-
@@ -20,7 +20,7 @@ func TestInferenceExecutor_ExecuteInference(t *testing.T) {
 	executor.SetWorkDir(tempDir)
 	executor.SetIPFSAPIURL("/ip4/127.0.0.1/tcp/5001")
 	
-	inferenceExecutor := NewInferenceExecutor(executor, tempDir, "/ip4/127.0.0.1/tcp/5001")
+	inferenceExecutor := NewInferenceExecutor(executor, tempDir)
 	
 	task := &Task{
 		ID:        "test-inference-1",
@@ -45,24 +45,41 @@ func TestInferenceExecutor_ExecuteInference(t *testing.T) {
 	require.Greater(t, output.LatencyMs, int64(0))
 }
 
-func TestInferenceExecutor_CreateInferenceScript(t *testing.T) {
-	tempDir := t.TempDir()
+func TestWorkerPool_WritesScripts(t *testing.T) {
+	fakeWorker(t, `{}`)
 	executor := NewExecutor(nil)
-	inferenceExecutor := NewInferenceExecutor(executor, tempDir, "/ip4/127.0.0.1/tcp/5001")
-	
-	scriptPath := filepath.Join(tempDir, "inference.py")
-	modelPath := "/path/to/model.pt"
-	inputPath := filepath.Join(tempDir, "input.json")
-	outputPath := filepath.Join(tempDir, "output.json")
-	
-	err := inferenceExecutor.createInferenceScript(scriptPath, modelPath, inputPath, outputPath, "pytorch")
+	executor.SetWorkDir(t.TempDir())
+	defer executor.Stop()
+
+	// Parameters only go to params.json; the scripts are written verbatim
+	modelDir := filepath.Join(t.TempDir(), "model'); import os; os.system('id'); ('")
+	require.NoError(t, os.MkdirAll(modelDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(modelDir, "config.json"), []byte(`{}`), 0644))
+
+	executor.InitializeInferenceExecutor()
+	w, err := executor.inferenceExecutor.pool.acquire(context.Background(), modelDir, InferenceGenerate)
 	require.NoError(t, err)
-	require.FileExists(t, scriptPath)
-	
-	scriptContent, err := os.ReadFile(scriptPath)
+	defer executor.inferenceExecutor.pool.release(w)
+
+	scriptContent, err := os.ReadFile(filepath.Join(w.dir, "worker.py"))
+	require.NoError(t, err)
+	require.Equal(t, workerScript, scriptContent)
+	scriptContent, err = os.ReadFile(filepath.Join(w.dir, "inference.py"))
+	require.NoError(t, err)
+	require.Equal(t, inferenceScript, scriptContent)
+
+	paramsData, err := os.ReadFile(filepath.Join(w.dir, "params.json"))
 	require.NoError(t, err)
-	require.Contains(t, string(scriptContent), "Atlas Inference Script")
-	require.Contains(t, string(scriptContent), modelPath)
+	var params workerParams
+	require.NoError(t, json.Unmarshal(paramsData, &params))
+	require.Equal(t, modelDir, params.ModelPath)
+	require.Equal(t, InferenceGenerate, params.Preload)
+}
+
+func TestEmbeddedScriptVersions(t *testing.T) {
+	require.Contains(t, string(trainingScript), `SCRIPT_VERSION = "`+trainingScriptVersion+`"`)
+	require.Contains(t, string(inferenceScript), `SCRIPT_VERSION = "`+inferenceScriptVersion+`"`)
+	require.Contains(t, string(workerScript), `SCRIPT_VERSION = "`+workerScriptVersion+`"`)
 }
 
 func TestInferenceInput_Unmarshal(t *testing.T) {
@@ -0,0 +1,344 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultLogMaxSize is the size at which a task log file is rotated
+	DefaultLogMaxSize = 10 << 20
+
+	// DefaultLogMaxFiles is how many rotated files are kept per task
+	// besides the current one
+	DefaultLogMaxFiles = 3
+
+	// logPollInterval is how often a followed log is checked for new output
+	logPollInterval = 250 * time.Millisecond
+)
+
+// LogConfig caps the size of task logs. A task uses at most
+// MaxSize * (MaxFiles + 1) bytes of log files.
+type LogConfig struct {
+	MaxSize  int64
+	MaxFiles int
+}
+
+// SetLogConfig sets the size caps for task logs opened from now on
+func (e *Executor) SetLogConfig(config LogConfig) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.logConfig = config
+}
+
+// TaskLogPath returns the current log file of a task. Rotated files have
+// ".1", ".2", ... appended, ".1" being the newest. Logs live outside the
+// task directory so they outlive cancelled tasks.
+func TaskLogPath(workDir string, taskID string) string {
+	return filepath.Join(workDir, "logs", taskID+".log")
+}
+
+// LogPath returns the current log file of a task
+func (e *Executor) LogPath(taskID string) string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return TaskLogPath(e.workDir, taskID)
+}
+
+// rotatingLog is a size-capped log file that rotates into numbered files
+type rotatingLog struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+func openRotatingLog(path string, config LogConfig) (*rotatingLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	return &rotatingLog{
+		path:     path,
+		maxSize:  config.MaxSize,
+		maxFiles: config.MaxFiles,
+		file:     file,
+		size:     info.Size(),
+	}, nil
+}
+
+func (l *rotatingLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return 0, os.ErrClosed
+	}
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(p)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := l.file.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// rotate shifts path.N-1 to path.N down to path to path.1, dropping the
+// oldest file, and starts a new current file
+func (l *rotatingLog) rotate() error {
+	l.file.Close()
+
+	if l.maxFiles > 0 {
+		os.Remove(rotatedLogPath(l.path, l.maxFiles))
+		for i := l.maxFiles - 1; i >= 1; i-- {
+			os.Rename(rotatedLogPath(l.path, i), rotatedLogPath(l.path, i+1))
+		}
+		os.Rename(l.path, rotatedLogPath(l.path, 1))
+	}
+
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0644)
+	if err != nil {
+		l.file = nil
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	l.file = file
+	l.size = 0
+	return nil
+}
+
+func (l *rotatingLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+func rotatedLogPath(path string, n int) string {
+	return path + "." + strconv.Itoa(n)
+}
+
+// taskLog returns the open log of a task, opening it if needed
+func (e *Executor) taskLog(taskID string) (*rotatingLog, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if log, ok := e.logs[taskID]; ok {
+		return log, nil
+	}
+
+	log, err := openRotatingLog(TaskLogPath(e.workDir, taskID), e.logConfig)
+	if err != nil {
+		return nil, err
+	}
+	e.logs[taskID] = log
+	return log, nil
+}
+
+// closeTaskLog closes the log of a task once it stops running
+func (e *Executor) closeTaskLog(taskID string) {
+	e.mu.Lock()
+	log, ok := e.logs[taskID]
+	delete(e.logs, taskID)
+	e.mu.Unlock()
+
+	if ok {
+		log.Close()
+	}
+}
+
+// TailLogs returns up to the last n lines a task has logged
+func (e *Executor) TailLogs(taskID string, n int) ([]string, error) {
+	if _, err := e.GetTask(taskID); err != nil {
+		return nil, err
+	}
+
+	e.mu.RLock()
+	workDir := e.workDir
+	e.mu.RUnlock()
+
+	return TailTaskLog(workDir, taskID, n)
+}
+
+// FollowLogs writes the last n lines of a task's log to w and then streams
+// new output until the task stops running or ctx is cancelled
+func (e *Executor) FollowLogs(ctx context.Context, taskID string, n int, w io.Writer) error {
+	task, err := e.GetTask(taskID)
+	if err != nil {
+		return err
+	}
+
+	e.mu.RLock()
+	workDir := e.workDir
+	e.mu.RUnlock()
+
+	return followTaskLog(ctx, workDir, taskID, n, w, func() bool {
+		e.mu.RLock()
+		defer e.mu.RUnlock()
+		return task.Status != "pending" && task.Status != "in_progress"
+	})
+}
+
+// TailTaskLog returns up to the last n lines of a task's log files under
+// workDir, reading rotated files as needed
+func TailTaskLog(workDir string, taskID string, n int) ([]string, error) {
+	path := TaskLogPath(workDir, taskID)
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no logs for task %s", taskID)
+		}
+		return nil, err
+	}
+	return tailLog(path, n, -1)
+}
+
+// FollowTaskLog writes the last n lines of a task's log under workDir to w
+// and then streams new output until ctx is cancelled
+func FollowTaskLog(ctx context.Context, workDir string, taskID string, n int, w io.Writer) error {
+	return followTaskLog(ctx, workDir, taskID, n, w, nil)
+}
+
+// tailLog returns the last n lines of path and its rotated files. Only the
+// first limit bytes of the current file are read, unless limit is negative.
+func tailLog(path string, n int, limit int64) ([]string, error) {
+	var lines []string
+
+	for i := 0; len(lines) < n; i++ {
+		filePath := path
+		if i > 0 {
+			filePath = rotatedLogPath(path, i)
+		}
+
+		data, err := readLog(filePath, limit)
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		limit = -1
+
+		var fileLines []string
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 4096), len(data)+1)
+		for scanner.Scan() {
+			fileLines = append(fileLines, scanner.Text())
+		}
+		lines = append(fileLines, lines...)
+	}
+
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, nil
+}
+
+func readLog(path string, limit int64) ([]byte, error) {
+	if limit < 0 {
+		return os.ReadFile(path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(io.LimitReader(file, limit))
+}
+
+// followTaskLog tails and then follows a task log across rotations. It
+// returns once finished reports true and all output has been written, or
+// when ctx is cancelled.
+func followTaskLog(ctx context.Context, workDir string, taskID string, n int, w io.Writer, finished func() bool) error {
+	path := TaskLogPath(workDir, taskID)
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no logs for task %s", taskID)
+		}
+		return err
+	}
+	defer func() { file.Close() }()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	offset := info.Size()
+
+	if n > 0 {
+		lines, err := tailLog(path, n, offset)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+	}
+
+	ticker := time.NewTicker(logPollInterval)
+	defer ticker.Stop()
+
+	for {
+		done := finished != nil && finished()
+
+		copied, err := io.Copy(w, io.NewSectionReader(file, offset, 1<<62))
+		if err != nil {
+			return err
+		}
+		offset += copied
+
+		// Switch to the new file once the current one has been rotated
+		if current, err := os.Stat(path); err == nil {
+			opened, err := file.Stat()
+			if err == nil && !os.SameFile(current, opened) {
+				next, err := os.Open(path)
+				if err == nil {
+					file.Close()
+					file = next
+					offset = 0
+					continue
+				}
+			}
+		}
+
+		if done {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
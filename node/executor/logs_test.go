@@ -0,0 +1,106 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingLog_CapsFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "task-1.log")
+	log, err := openRotatingLog(path, LogConfig{MaxSize: 20, MaxFiles: 2})
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		_, err := fmt.Fprintf(log, "line %d\n", i)
+		require.NoError(t, err)
+	}
+	require.NoError(t, log.Close())
+
+	for _, p := range []string{path, rotatedLogPath(path, 1), rotatedLogPath(path, 2)} {
+		info, err := os.Stat(p)
+		require.NoError(t, err)
+		require.LessOrEqual(t, info.Size(), int64(20))
+	}
+	require.NoFileExists(t, rotatedLogPath(path, 3))
+
+	// Tail reads across rotated files; the oldest lines were dropped
+	lines, err := tailLog(path, 4, -1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"line 6", "line 7", "line 8", "line 9"}, lines)
+
+	lines, err = tailLog(path, 100, -1)
+	require.NoError(t, err)
+	require.Len(t, lines, 6)
+}
+
+func TestTaskLogs_CaptureOutput(t *testing.T) {
+	workDir := t.TempDir()
+	e := NewExecutor(nil)
+	e.SetWorkDir(workDir)
+	e.runTask = shellTask(e, workDir, `
+echo "to stdout"
+echo "to stderr" >&2
+exit 1
+`)
+
+	require.NoError(t, e.AddTask(&Task{ID: "task-1"}))
+	e.processTasks(context.Background())
+	waitForStatus(t, e, "task-1", "failed")
+
+	lines, err := e.TailLogs("task-1", 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"to stdout", "to stderr"}, lines)
+
+	lines, err = TailTaskLog(workDir, "task-1", 1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"to stderr"}, lines)
+
+	_, err = e.TailLogs("missing", 10)
+	require.Error(t, err)
+	_, err = TailTaskLog(workDir, "missing", 10)
+	require.ErrorContains(t, err, "no logs")
+}
+
+func TestTaskLogs_Follow(t *testing.T) {
+	workDir := t.TempDir()
+	e := NewExecutor(nil)
+	e.SetWorkDir(workDir)
+	e.SetLogConfig(LogConfig{MaxSize: 16, MaxFiles: 1})
+	e.runTask = shellTask(e, workDir, `
+echo "first"
+while [ ! -f continue ]; do sleep 0.01; done
+for i in 1 2 3 4 5; do echo "after $i"; sleep 0.1; done
+`)
+
+	require.NoError(t, e.AddTask(&Task{ID: "task-1"}))
+	e.processTasks(context.Background())
+	waitForFile(t, TaskLogPath(workDir, "task-1"))
+	require.Eventually(t, func() bool {
+		lines, _ := TailTaskLog(workDir, "task-1", 1)
+		return len(lines) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	var out bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		done <- e.FollowLogs(context.Background(), "task-1", 10, &out)
+	}()
+
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "task-1", "continue"), nil, 0644))
+
+	// Following stops on its own once the task has finished
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("FollowLogs did not return after the task finished")
+	}
+	require.Equal(t, "first\nafter 1\nafter 2\nafter 3\nafter 4\nafter 5\n", out.String())
+}
@@ -0,0 +1,21 @@
+package executor
+
+import "time"
+
+// Observer is told about finished task runs and inference requests, e.g. to
+// export metrics. Its methods are called synchronously and must not block.
+type Observer interface {
+	// TaskFinished is called when a task run ends, with the task in its
+	// final state (completed, failed, cancelled or paused)
+	TaskFinished(task Task, duration time.Duration)
+	// InferenceFinished is called when an inference request on a model
+	// worker is answered or fails
+	InferenceFinished(modelPath string, operation string, latency time.Duration, err error)
+}
+
+// SetObserver sets the observer of task runs and inference requests
+func (e *Executor) SetObserver(observer Observer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.observer = observer
+}
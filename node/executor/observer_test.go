@@ -0,0 +1,51 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordingObserver struct {
+	mu    sync.Mutex
+	tasks []Task
+}
+
+func (o *recordingObserver) TaskFinished(task Task, duration time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.tasks = append(o.tasks, task)
+}
+
+func (o *recordingObserver) InferenceFinished(modelPath string, operation string, latency time.Duration, err error) {
+}
+
+func TestObserver_TaskFinished(t *testing.T) {
+	e := NewExecutor(nil)
+	observer := &recordingObserver{}
+	e.SetObserver(observer)
+	e.runTask = func(ctx context.Context, task *Task) {
+		if task.ID == "bad" {
+			task.Error = errors.New("boom")
+		}
+	}
+
+	require.NoError(t, e.AddTask(&Task{ID: "good", TaskType: "training"}))
+	require.NoError(t, e.AddTask(&Task{ID: "bad", TaskType: "inference"}))
+	waitForTasks(t, e, context.Background())
+
+	require.Eventually(t, func() bool {
+		observer.mu.Lock()
+		defer observer.mu.Unlock()
+		return len(observer.tasks) == 2
+	}, 5*time.Second, 5*time.Millisecond)
+	statuses := map[string]string{}
+	for _, task := range observer.tasks {
+		statuses[task.ID] = task.Status
+	}
+	require.Equal(t, map[string]string{"good": "completed", "bad": "failed"}, statuses)
+}
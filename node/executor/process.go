@@ -0,0 +1,311 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/atlas/storage/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// pauseGracePeriod is how long a task gets to write its checkpoint after
+	// being asked to pause before its process group is killed
+	pauseGracePeriod = 60 * time.Second
+
+	// shutdownGracePeriod bounds how long Stop waits for running tasks
+	shutdownGracePeriod = 10 * time.Second
+
+	// pauseSignal asks a training script to checkpoint and exit
+	pauseSignal = syscall.SIGUSR1
+)
+
+const (
+	stopPause  = "pause"
+	stopCancel = "cancel"
+)
+
+// taskHandle tracks the context and process of a running task
+type taskHandle struct {
+	cancel   context.CancelFunc
+	done     chan struct{}
+	mu       sync.Mutex
+	cmd      *exec.Cmd
+	stopping string
+}
+
+func (h *taskHandle) setCmd(cmd *exec.Cmd) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.cmd = cmd
+}
+
+func (h *taskHandle) stopReason() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.stopping
+}
+
+// requestStop records why the task is being stopped and, for pauses, asks
+// the process to checkpoint. Cancellation takes precedence over pausing.
+func (h *taskHandle) requestStop(reason string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.stopping == stopCancel {
+		return
+	}
+	h.stopping = reason
+
+	if reason == stopPause && h.cmd != nil && h.cmd.Process != nil {
+		syscall.Kill(-h.cmd.Process.Pid, pauseSignal)
+	}
+}
+
+// wait blocks until the task goroutine exits or the timeout elapses
+func (h *taskHandle) wait(timeout time.Duration) bool {
+	select {
+	case <-h.done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// launch runs a task in its own cancellable context
+func (e *Executor) launch(ctx context.Context, task *Task) {
+	taskCtx, cancel := context.WithCancel(ctx)
+	handle := &taskHandle{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	e.mu.Lock()
+	e.handles[task.ID] = handle
+	e.mu.Unlock()
+
+	go func() {
+		defer func() {
+			cancel()
+			e.mu.Lock()
+			delete(e.handles, task.ID)
+			e.mu.Unlock()
+			close(handle.done)
+		}()
+		e.executeTask(taskCtx, task, handle)
+	}()
+}
+
+func (e *Executor) handle(taskID string) *taskHandle {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.handles[taskID]
+}
+
+// runProcess starts cmd in its own process group, records it on the task's
+// handle so it can be signalled, and waits for it. Cancelling ctx kills the
+// whole process group. Output not otherwise redirected goes to the task's
+// log (see TaskLogPath). Events the process reports are recorded while it
+// runs (see TaskEvent). When sandboxing is enabled the process also runs in
+// its own cgroup and namespaces, and its resource usage is recorded on the
+// task.
+func (e *Executor) runProcess(ctx context.Context, taskID string, cmd *exec.Cmd) (err error) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+
+	if cmd.Stdout == nil || cmd.Stderr == nil {
+		log, err := e.taskLog(taskID)
+		if err != nil {
+			return err
+		}
+		if cmd.Stdout == nil {
+			cmd.Stdout = log
+		}
+		if cmd.Stderr == nil {
+			cmd.Stderr = log
+		}
+	}
+
+	ctx, span := tracing.Start(ctx, tracerName, "task.process", attribute.String("task.id", taskID))
+	defer func() { tracing.End(span, err) }()
+
+	events, err := e.openEventStream(taskID, cmd, span)
+	if err != nil {
+		return err
+	}
+	defer events.close()
+	// Processes that trace themselves can continue the task's trace
+	cmd.Env = append(cmd.Env, tracing.Environ(ctx)...)
+
+	sandbox, err := e.prepareSandbox(taskID, cmd)
+	if err != nil {
+		return fmt.Errorf("failed to prepare sandbox: %w", err)
+	}
+	defer func() {
+		if usage := sandbox.finish(); usage != nil {
+			e.mu.Lock()
+			if task, exists := e.tasks[taskID]; exists {
+				task.Usage = usage
+			}
+			e.mu.Unlock()
+		}
+	}()
+
+	cmd.Cancel = func() error {
+		sandbox.kill()
+		return killProcessGroup(cmd)
+	}
+	cmd.WaitDelay = 5 * time.Second
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	span.AddEvent("process started", trace.WithAttributes(attribute.Int("pid", cmd.Process.Pid)))
+	events.started()
+	sandbox.started()
+
+	handle := e.handle(taskID)
+	if handle != nil {
+		handle.setCmd(cmd)
+		// A stop requested before the process started still applies
+		if handle.stopReason() == stopPause {
+			handle.requestStop(stopPause)
+		}
+	}
+
+	err = cmd.Wait()
+
+	if handle != nil {
+		handle.setCmd(nil)
+	}
+	reported := events.close()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil && reported != "" {
+		return fmt.Errorf("%s: %w", reported, err)
+	}
+	return err
+}
+
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return cmd.Process.Kill()
+	}
+	return nil
+}
+
+// pythonCommand builds a command running scriptPath with python3, or python
+// if python3 is not installed
+func pythonCommand(ctx context.Context, scriptPath string) *exec.Cmd {
+	interpreter := "python3"
+	if _, err := exec.LookPath(interpreter); err != nil {
+		interpreter = "python"
+	}
+	return exec.CommandContext(ctx, interpreter, scriptPath)
+}
+
+// PauseTask asks a running task to write a checkpoint and stop. If the task
+// does not exit within the grace period its process group is killed. The
+// task can later be resumed from the checkpoint with ResumeTask.
+func (e *Executor) PauseTask(taskID string) error {
+	task, err := e.GetTask(taskID)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	if task.Status != "in_progress" {
+		e.mu.Unlock()
+		return fmt.Errorf("task %s is not in progress (current: %s)", taskID, task.Status)
+	}
+	handle := e.handles[taskID]
+	if handle == nil {
+		// Nothing is running for this task; just record the pause
+		task.Status = "paused"
+		e.persistLocked(task)
+		e.mu.Unlock()
+		return nil
+	}
+	e.mu.Unlock()
+
+	handle.requestStop(stopPause)
+	if !handle.wait(pauseGracePeriod) {
+		handle.cancel()
+		handle.wait(shutdownGracePeriod)
+	}
+
+	return nil
+}
+
+// CancelTask stops a task for good: the process group is killed, the task
+// directory removed and the task marked cancelled.
+func (e *Executor) CancelTask(taskID string) error {
+	task, err := e.GetTask(taskID)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	handle := e.handles[taskID]
+	switch task.Status {
+	case "completed", "failed", "cancelled":
+		e.mu.Unlock()
+		return fmt.Errorf("task %s already finished (current: %s)", taskID, task.Status)
+	case "pending", "paused":
+		if handle == nil {
+			e.markCancelledLocked(task)
+			workDir := e.workDir
+			e.mu.Unlock()
+			return e.cleanupTaskDir(workDir, taskID)
+		}
+	}
+	workDir := e.workDir
+	e.mu.Unlock()
+
+	if handle != nil {
+		handle.requestStop(stopCancel)
+		handle.cancel()
+		handle.wait(shutdownGracePeriod)
+	}
+
+	return e.cleanupTaskDir(workDir, taskID)
+}
+
+// markCancelledLocked marks a task cancelled. The caller must hold e.mu.
+func (e *Executor) markCancelledLocked(task *Task) {
+	task.Status = "cancelled"
+	task.Error = nil
+	now := time.Now()
+	task.CompletedAt = &now
+	e.persistLocked(task)
+}
+
+// cleanupTaskDir removes a task's directory. It refuses any path that is not
+// a task directory directly under the work dir.
+func (e *Executor) cleanupTaskDir(workDir string, taskID string) error {
+	if err := ValidateTaskID(taskID); err != nil {
+		return fmt.Errorf("refusing to remove task directory: %w", err)
+	}
+	taskDir := filepath.Join(workDir, taskID)
+	if filepath.Dir(taskDir) != filepath.Clean(workDir) {
+		return fmt.Errorf("refusing to remove %s outside of %s", taskDir, workDir)
+	}
+	if err := os.RemoveAll(taskDir); err != nil {
+		return fmt.Errorf("failed to remove task directory: %w", err)
+	}
+	return nil
+}
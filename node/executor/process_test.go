@@ -0,0 +1,168 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// shellTask runs script with sh in the task directory through runProcess
+func shellTask(e *Executor, workDir string, script string) func(ctx context.Context, task *Task) {
+	return func(ctx context.Context, task *Task) {
+		taskDir := filepath.Join(workDir, task.ID)
+		if err := os.MkdirAll(taskDir, 0755); err != nil {
+			task.Error = err
+			return
+		}
+		cmd := exec.CommandContext(ctx, "sh", "-c", script)
+		cmd.Dir = taskDir
+		if err := e.runProcess(ctx, task.ID, cmd); err != nil {
+			task.Error = err
+		}
+	}
+}
+
+func waitForStatus(t *testing.T, e *Executor, taskID string, status string) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		task, err := e.GetTask(taskID)
+		if err != nil {
+			return false
+		}
+		e.mu.RLock()
+		defer e.mu.RUnlock()
+		return task.Status == status
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func waitForFile(t *testing.T, path string) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestPauseTask_CheckpointsAndResumes(t *testing.T) {
+	workDir := t.TempDir()
+	e := NewExecutor(nil)
+	e.SetWorkDir(workDir)
+	e.runTask = shellTask(e, workDir, `
+if [ -f checkpoint.pt ]; then echo resumed > resumed; exit 0; fi
+trap 'echo state > checkpoint.pt; exit 0' USR1
+touch started
+while true; do sleep 0.05; done
+`)
+
+	require.NoError(t, e.AddTask(&Task{ID: "task-1"}))
+	e.processTasks(context.Background())
+	waitForFile(t, filepath.Join(workDir, "task-1", "started"))
+
+	require.NoError(t, e.PauseTask("task-1"))
+	waitForStatus(t, e, "task-1", "paused")
+	require.FileExists(t, filepath.Join(workDir, "task-1", "checkpoint.pt"))
+
+	require.NoError(t, e.ResumeTask("task-1"))
+	e.processTasks(context.Background())
+	waitForStatus(t, e, "task-1", "completed")
+	require.FileExists(t, filepath.Join(workDir, "task-1", "resumed"))
+}
+
+func TestCancelTask_KillsProcessGroup(t *testing.T) {
+	workDir := t.TempDir()
+	e := NewExecutor(nil)
+	e.SetWorkDir(workDir)
+	e.runTask = shellTask(e, workDir, `
+sleep 30 &
+echo $! > child.pid
+wait
+`)
+
+	require.NoError(t, e.AddTask(&Task{ID: "task-1"}))
+	e.processTasks(context.Background())
+
+	pidPath := filepath.Join(workDir, "task-1", "child.pid")
+	waitForFile(t, pidPath)
+	var pid int
+	require.Eventually(t, func() bool {
+		data, err := os.ReadFile(pidPath)
+		if err != nil {
+			return false
+		}
+		pid, err = strconv.Atoi(strings.TrimSpace(string(data)))
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, e.CancelTask("task-1"))
+	waitForStatus(t, e, "task-1", "cancelled")
+
+	// The background child must have been killed along with the shell
+	require.Eventually(t, func() bool {
+		return syscall.Kill(pid, 0) == syscall.ESRCH
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, err := os.Stat(filepath.Join(workDir, "task-1"))
+	require.True(t, os.IsNotExist(err))
+
+	require.Error(t, e.CancelTask("task-1"))
+}
+
+func TestCancelTask_Pending(t *testing.T) {
+	e := NewExecutor(nil)
+	e.SetWorkDir(t.TempDir())
+
+	require.NoError(t, e.AddTask(&Task{ID: "task-1"}))
+	require.NoError(t, e.CancelTask("task-1"))
+
+	task, err := e.GetTask("task-1")
+	require.NoError(t, err)
+	require.Equal(t, "cancelled", task.Status)
+
+	// Cancelled tasks are never admitted
+	e.processTasks(context.Background())
+	require.Equal(t, "cancelled", task.Status)
+}
+
+func TestPauseTask_NotRunning(t *testing.T) {
+	e := NewExecutor(nil)
+	require.NoError(t, e.AddTask(&Task{ID: "task-1"}))
+	require.Error(t, e.PauseTask("task-1"))
+	require.Error(t, e.PauseTask("missing"))
+}
+
+func TestAddTask_RejectsUnsafeIDs(t *testing.T) {
+	workDir := filepath.Join(t.TempDir(), "work")
+	e := NewExecutor(nil)
+	e.SetWorkDir(workDir)
+
+	for _, id := range []string{"", ".", "..", "../x", "a/b", "cache", "workers", "tasks.journal", "task 1"} {
+		require.ErrorIs(t, e.AddTask(&Task{ID: id}), ErrInvalidTaskID, id)
+	}
+	require.Empty(t, e.ListTasks())
+
+	require.NoError(t, e.AddTask(&Task{ID: "job-1.task_2"}))
+}
+
+func TestCleanupTaskDir_StaysInWorkDir(t *testing.T) {
+	root := t.TempDir()
+	workDir := filepath.Join(root, "work")
+	require.NoError(t, os.MkdirAll(filepath.Join(workDir, "cache"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(workDir, "task-1"), 0755))
+
+	e := NewExecutor(nil)
+	require.Error(t, e.cleanupTaskDir(workDir, ".."))
+	require.Error(t, e.cleanupTaskDir(workDir, "cache"))
+	require.DirExists(t, root)
+	require.DirExists(t, filepath.Join(workDir, "cache"))
+
+	require.NoError(t, e.cleanupTaskDir(workDir, "task-1"))
+	require.NoDirExists(t, filepath.Join(workDir, "task-1"))
+}
@@ -0,0 +1,245 @@
+package executor
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/atlas/node/proof"
+	"github.com/atlas/storage/cache"
+	"github.com/atlas/storage/identity"
+	"github.com/atlas/storage/manager"
+)
+
+// SetProver makes the executor sign a proof of computation for every task
+// it completes. Checkpoints reported by tasks are then published to IPFS so
+// verifiers can replay the segments between them.
+func (e *Executor) SetProver(nodeID string, signer *identity.Identity) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.nodeID = nodeID
+	e.signer = signer
+}
+
+// publishCheckpoint uploads a checkpoint to IPFS and returns its CID
+func (e *Executor) publishCheckpoint(ctx context.Context, path string) (string, error) {
+	e.mu.RLock()
+	ipfs := manager.NewIPFSManager(e.ipfsAPIURL, e.ipfsFallbacks...)
+	e.mu.RUnlock()
+	return ipfs.AddFileContext(ctx, path)
+}
+
+const (
+	// checkpointPublishAttempts bounds how often a checkpoint upload is
+	// tried before the task's proof fails
+	checkpointPublishAttempts = 3
+
+	// checkpointPublishTimeout bounds a single checkpoint upload
+	checkpointPublishTimeout = 10 * time.Minute
+)
+
+// checkpointPublishRetryDelay is the wait before the second upload attempt;
+// it grows with each attempt
+var checkpointPublishRetryDelay = 5 * time.Second
+
+// checkpointUpload is a checkpoint being published. cid and err are set
+// before done is closed.
+type checkpointUpload struct {
+	epoch int
+	hash  string
+	done  chan struct{}
+	cid   string
+	err   error
+}
+
+// recordCheckpoint sets the hash of a reported checkpoint. It runs before
+// the checkpoint can be overwritten by the next one. When proofs are
+// enabled, the checkpoint is copied while it is hashed and the copy is
+// published in the background, so the task's events keep being read.
+func (e *Executor) recordCheckpoint(taskID string, event *TaskEvent) {
+	e.mu.RLock()
+	path := event.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(e.workDir, taskID, path)
+	}
+	proving := e.signer != nil
+	stagingDir := filepath.Join(e.workDir, "checkpoints")
+	e.mu.RUnlock()
+
+	if !proving {
+		hash, err := proof.HashFile(path)
+		if err != nil {
+			fmt.Printf("Warning: task %s reported checkpoint %s: %v\n", taskID, event.Path, err)
+			return
+		}
+		event.Hash = hash
+		return
+	}
+
+	staged, hash, err := stageCheckpoint(path, stagingDir, taskID)
+	if err != nil {
+		fmt.Printf("Warning: task %s reported checkpoint %s: %v\n", taskID, event.Path, err)
+		return
+	}
+	event.Hash = hash
+
+	upload := &checkpointUpload{epoch: event.Epoch, hash: hash, done: make(chan struct{})}
+	e.mu.Lock()
+	var previous *checkpointUpload
+	if uploads := e.uploads[taskID]; len(uploads) > 0 {
+		previous = uploads[len(uploads)-1]
+	}
+	e.uploads[taskID] = append(e.uploads[taskID], upload)
+	e.mu.Unlock()
+
+	go e.uploadCheckpoint(taskID, upload, previous, staged)
+}
+
+// stageCheckpoint copies a checkpoint of a task into dir and returns the
+// copy's path and the checkpoint's hash
+func stageCheckpoint(path string, dir string, taskID string) (string, string, error) {
+	source, err := os.Open(path)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer source.Close()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create checkpoint staging directory: %w", err)
+	}
+	staged, err := os.CreateTemp(dir, taskID+"-*")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to stage checkpoint: %w", err)
+	}
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(staged, hash), source)
+	if closeErr := staged.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(staged.Name())
+		return "", "", fmt.Errorf("failed to stage %s: %w", path, err)
+	}
+	return staged.Name(), fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// uploadCheckpoint publishes a staged checkpoint once the previous one of
+// the task is published, retrying failed uploads, and then removes the copy.
+// The CID is also set on the checkpoint's event.
+func (e *Executor) uploadCheckpoint(taskID string, upload *checkpointUpload, previous *checkpointUpload, staged string) {
+	defer close(upload.done)
+	defer os.Remove(staged)
+
+	if previous != nil {
+		<-previous.done
+	}
+
+	e.mu.RLock()
+	publish := e.publish
+	e.mu.RUnlock()
+
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(e.ctx, checkpointPublishTimeout)
+		upload.cid, upload.err = publish(ctx, staged)
+		cancel()
+		if upload.err == nil || attempt == checkpointPublishAttempts {
+			break
+		}
+		fmt.Printf("Warning: failed to publish checkpoint of task %s (attempt %d of %d): %v\n", taskID, attempt, checkpointPublishAttempts, upload.err)
+		select {
+		case <-e.ctx.Done():
+			upload.err = e.ctx.Err()
+			return
+		case <-time.After(time.Duration(attempt) * checkpointPublishRetryDelay):
+		}
+	}
+	if upload.err != nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	events := e.events[taskID]
+	for i := range events {
+		if events[i].Type == EventCheckpoint && events[i].Hash == upload.hash && events[i].CID == "" {
+			events[i].CID = upload.cid
+		}
+	}
+}
+
+// checkpointCIDs waits for the checkpoints a task reported to be published
+// and returns their CIDs by hash. It fails if any could not be published.
+func (e *Executor) checkpointCIDs(taskID string) (map[string]string, error) {
+	e.mu.RLock()
+	uploads := e.uploads[taskID]
+	e.mu.RUnlock()
+
+	cids := make(map[string]string, len(uploads))
+	for _, upload := range uploads {
+		<-upload.done
+		if upload.err != nil {
+			return nil, fmt.Errorf("failed to publish checkpoint of epoch %d: %w", upload.epoch, upload.err)
+		}
+		cids[upload.hash] = upload.cid
+	}
+	return cids, nil
+}
+
+// proveTask signs a proof that task produced output through the
+// checkpoints it reported. It returns nil if proofs are not enabled.
+func (e *Executor) proveTask(task *Task, output []byte) (*proof.ProofOfComputation, error) {
+	e.mu.RLock()
+	nodeID, signer := e.nodeID, e.signer
+	e.mu.RUnlock()
+
+	if signer == nil {
+		return nil, nil
+	}
+	cids, err := e.checkpointCIDs(task.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	var segments []proof.Segment
+	var unpublished []int
+	for _, event := range e.events[task.ID] {
+		if event.Type == EventCheckpoint && event.Hash != "" {
+			if cids[event.Hash] == "" {
+				unpublished = append(unpublished, event.Epoch)
+			}
+			segments = append(segments, proof.Segment{
+				Epoch:      event.Epoch,
+				Step:       event.Step,
+				Checkpoint: cids[event.Hash],
+				StateHash:  event.Hash,
+			})
+		}
+	}
+	var inputs []string
+	for _, input := range []string{task.ModelPath, task.DatasetPath, task.CheckpointCID} {
+		if cache.IsCID(input) {
+			inputs = append(inputs, input)
+		}
+	}
+	metrics := proof.ComputationMetrics{Iterations: len(segments)}
+	if task.StartedAt != nil {
+		metrics.TimeElapsed = time.Since(*task.StartedAt)
+	}
+	if task.Usage != nil {
+		metrics.MemoryUsed = task.Usage.MemoryPeakBytes
+	}
+	delete(e.uploads, task.ID)
+	e.mu.Unlock()
+
+	if len(unpublished) > 0 {
+		return nil, fmt.Errorf("checkpoints of epochs %v were not published", unpublished)
+	}
+	outputHash := fmt.Sprintf("%x", sha256.Sum256(output))
+	return proof.GenerateProof(task.ID, nodeID, inputs, outputHash, segments, metrics, signer)
+}
@@ -0,0 +1,117 @@
+package executor
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/atlas/node/proof"
+	"github.com/atlas/storage/identity"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProveTask(t *testing.T) {
+	workDir := t.TempDir()
+	command := writeCommand(t, t.TempDir(), `
+read -r request
+echo epoch-1 > checkpoint.pt
+echo '{"type":"checkpoint","path":"checkpoint.pt","epoch":1}' >&$ATLAS_EVENTS_FD
+sleep 0.1
+echo epoch-2 > checkpoint.pt
+echo '{"type":"checkpoint","path":"checkpoint.pt","epoch":2}' >&$ATLAS_EVENTS_FD
+sleep 0.1
+echo '{"type":"result","output":{"loss":0.1}}'
+`)
+
+	signer, err := identity.Generate()
+	require.NoError(t, err)
+	e := NewExecutor(nil)
+	e.SetWorkDir(workDir)
+	e.SetProver("node-1", signer)
+	var published []string
+	e.publish = func(ctx context.Context, path string) (string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		published = append(published, string(data))
+		return fmt.Sprintf("QmCheckpoint%d", len(published)), nil
+	}
+	e.RegisterRuntime("command", NewCommandRuntime(map[string]string{"train": command}))
+
+	require.NoError(t, e.AddTask(&Task{
+		ID:          "task-1",
+		TaskType:    "command",
+		DatasetPath: "QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG",
+		Metadata:    map[string]string{MetadataCommand: "train"},
+	}))
+	e.processTasks(context.Background())
+	waitForStatus(t, e, "task-1", "completed")
+
+	task, err := e.GetTask("task-1")
+	require.NoError(t, err)
+	computation := task.Proof
+	require.NotNil(t, computation)
+	require.Equal(t, "node-1", computation.NodeID)
+	require.Equal(t, []string{"QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG"}, computation.InputCIDs)
+	require.Equal(t, fmt.Sprintf("%x", sha256.Sum256(task.OutputData)), computation.OutputHash)
+
+	// Each checkpoint was hashed before the next replaced it
+	require.Equal(t, []proof.Segment{
+		{Epoch: 1, Checkpoint: "QmCheckpoint1", StateHash: fmt.Sprintf("%x", sha256.Sum256([]byte("epoch-1\n")))},
+		{Epoch: 2, Checkpoint: "QmCheckpoint2", StateHash: fmt.Sprintf("%x", sha256.Sum256([]byte("epoch-2\n")))},
+	}, computation.Segments)
+	require.Equal(t, []string{"epoch-1\n", "epoch-2\n"}, published)
+	staged, err := os.ReadDir(filepath.Join(workDir, "checkpoints"))
+	require.NoError(t, err)
+	require.Empty(t, staged)
+
+	metrics, err := e.TaskMetrics("task-1")
+	require.NoError(t, err)
+	require.Equal(t, "QmCheckpoint2", metrics[len(metrics)-1].CID)
+
+	valid, err := proof.VerifyProof(computation, signer.PublicKey())
+	require.NoError(t, err)
+	require.True(t, valid)
+}
+
+func TestProveTask_FailsWhenCheckpointIsNotPublished(t *testing.T) {
+	command := writeCommand(t, t.TempDir(), `
+read -r request
+echo epoch-1 > checkpoint.pt
+echo '{"type":"checkpoint","path":"checkpoint.pt","epoch":1}' >&$ATLAS_EVENTS_FD
+echo '{"type":"result","output":{"loss":0.1}}'
+`)
+
+	delay := checkpointPublishRetryDelay
+	checkpointPublishRetryDelay = time.Millisecond
+	defer func() { checkpointPublishRetryDelay = delay }()
+
+	signer, err := identity.Generate()
+	require.NoError(t, err)
+	e := NewExecutor(nil)
+	e.SetWorkDir(t.TempDir())
+	e.SetProver("node-1", signer)
+	var attempts atomic.Int32
+	e.publish = func(ctx context.Context, path string) (string, error) {
+		attempts.Add(1)
+		return "", errors.New("ipfs unavailable")
+	}
+	e.RegisterRuntime("command", NewCommandRuntime(map[string]string{"train": command}))
+
+	require.NoError(t, e.AddTask(&Task{ID: "task-1", TaskType: "command", Metadata: map[string]string{MetadataCommand: "train"}}))
+	e.processTasks(context.Background())
+	waitForStatus(t, e, "task-1", "failed")
+
+	task, err := e.GetTask("task-1")
+	require.NoError(t, err)
+	require.ErrorContains(t, task.Error, "failed to publish checkpoint of epoch 1: ipfs unavailable")
+	require.Nil(t, task.Proof)
+	require.EqualValues(t, checkpointPublishAttempts, attempts.Load())
+}
@@ -0,0 +1,94 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// pythonRuntime runs training tasks as Python scripts through the training
+// executor and inference tasks on the inference executor's model workers
+type pythonRuntime struct {
+	executor *Executor
+}
+
+// NewPythonRuntime creates the runtime for "training" and "inference" tasks
+func NewPythonRuntime(e *Executor) Runtime {
+	return &pythonRuntime{executor: e}
+}
+
+func (r *pythonRuntime) Prepare(ctx context.Context, run *TaskRun) error {
+	task := run.Task
+	switch task.TaskType {
+	case "training":
+		r.executor.InitializeTrainingExecutor()
+		release, err := r.trainingExecutor().prepareTraining(ctx, task, run.Dir, task.ModelPath, task.DatasetPath)
+		if err != nil {
+			return err
+		}
+		run.OnFinish(release)
+		return nil
+	case "inference":
+		if len(task.InputData) == 0 {
+			return fmt.Errorf("input data is required for inference tasks")
+		}
+		if err := os.MkdirAll(run.Dir, 0755); err != nil {
+			return fmt.Errorf("failed to create task directory: %w", err)
+		}
+		// The model is loaded by its worker when the task runs
+		r.executor.InitializeInferenceExecutor()
+		return nil
+	default:
+		return fmt.Errorf("python runtime does not support task type: %s", task.TaskType)
+	}
+}
+
+func (r *pythonRuntime) Run(ctx context.Context, run *TaskRun) error {
+	run.SetProgress(0.1) // Started
+
+	switch run.Task.TaskType {
+	case "training":
+		if err := r.trainingExecutor().runTraining(ctx, run.Task, run.Dir); err != nil {
+			return fmt.Errorf("training execution failed: %w", err)
+		}
+	case "inference":
+		output, err := r.inferenceExecutor().ExecuteInference(ctx, run.Task, run.Task.ModelPath, run.Task.InputData)
+		if err != nil {
+			return fmt.Errorf("inference execution failed: %w", err)
+		}
+		outputJSON, err := json.Marshal(output)
+		if err != nil {
+			return fmt.Errorf("failed to marshal output: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(run.Dir, "output.json"), outputJSON, 0644); err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *pythonRuntime) Collect(ctx context.Context, run *TaskRun) ([]byte, error) {
+	if run.Task.TaskType == "training" {
+		return r.trainingExecutor().collectTraining(run.Dir)
+	}
+
+	outputJSON, err := os.ReadFile(filepath.Join(run.Dir, "output.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output file: %w", err)
+	}
+	return outputJSON, nil
+}
+
+func (r *pythonRuntime) trainingExecutor() *TrainingExecutor {
+	r.executor.mu.RLock()
+	defer r.executor.mu.RUnlock()
+	return r.executor.trainingExecutor
+}
+
+func (r *pythonRuntime) inferenceExecutor() *InferenceExecutor {
+	r.executor.mu.RLock()
+	defer r.executor.mu.RUnlock()
+	return r.executor.inferenceExecutor
+}
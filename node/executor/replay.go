@@ -0,0 +1,135 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/atlas/node/proof"
+)
+
+// Replay re-executes one segment of a training task's proof with the
+// training script and returns the hash of the state it reaches. It is a
+// proof.ReplayFunc, so a verifier of other nodes' proofs is
+// proof.NewVerifier(keys, e.Replay).
+//
+// The model and dataset are the proof's first two inputs. The replay
+// resumes from the checkpoint published at the end of from or, for the
+// first segment, from the task's resume checkpoint if it had one, stops at
+// to's epoch and step, and hashes its checkpoint the way the checkpoints a
+// task reports are hashed. It runs sandboxed in its own directory under
+// <work-dir>/replays, with the default training requirements reserved.
+func (e *Executor) Replay(ctx context.Context, computation *proof.ProofOfComputation, from *proof.Segment, to proof.Segment) (string, error) {
+	if len(computation.InputCIDs) < 2 {
+		return "", fmt.Errorf("task %s cannot be replayed: its model and dataset are not content-addressed", computation.TaskID)
+	}
+	resume := ""
+	if from != nil {
+		resume = from.Checkpoint
+	} else if len(computation.InputCIDs) > 2 {
+		resume = computation.InputCIDs[2]
+	}
+
+	e.mu.RLock()
+	replaysDir := filepath.Join(e.workDir, "replays")
+	e.mu.RUnlock()
+	if err := os.MkdirAll(replaysDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create replay directory: %w", err)
+	}
+	dir, err := os.MkdirTemp(replaysDir, "replay-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create replay directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	modelPath, releaseModel, err := e.stageArtifact(ctx, computation.InputCIDs[0])
+	if err != nil {
+		return "", err
+	}
+	defer releaseModel()
+	datasetPath, releaseDataset, err := e.stageArtifact(ctx, computation.InputCIDs[1])
+	if err != nil {
+		return "", err
+	}
+	defer releaseDataset()
+	readOnly := []string{modelPath, datasetPath}
+
+	params := trainingParams{ModelPath: modelPath, DatasetPath: datasetPath, StopEpoch: &to.Epoch, StopStep: to.Step}
+	if resume != "" {
+		checkpointPath, releaseCheckpoint, err := e.stageArtifact(ctx, resume)
+		if err != nil {
+			return "", err
+		}
+		defer releaseCheckpoint()
+		if err := os.Symlink(checkpointPath, filepath.Join(dir, "resume_checkpoint.pt")); err != nil {
+			return "", fmt.Errorf("failed to restore checkpoint: %w", err)
+		}
+		readOnly = append(readOnly, checkpointPath)
+		if from != nil {
+			params.StartEpoch = from.Epoch
+		}
+	}
+
+	scriptPath := filepath.Join(dir, "train.py")
+	if err := writeScript(scriptPath, trainingScript, params); err != nil {
+		return "", err
+	}
+	if err := e.runReplay(ctx, scriptPath, readOnly); err != nil {
+		return "", err
+	}
+	return proof.HashFile(filepath.Join(dir, "checkpoint.pt"))
+}
+
+// runReplay runs a replay's training script like a task process: in its
+// own process group and sandbox, with its requirements reserved
+func (e *Executor) runReplay(ctx context.Context, scriptPath string, readOnly []string) error {
+	dir := filepath.Dir(scriptPath)
+	name := filepath.Base(dir)
+	req := e.requirementsFor(&Task{TaskType: "training"})
+	if e.resourceManager != nil {
+		allocation := "replay:" + name
+		if err := e.resourceManager.Allocate(allocation, req); err != nil {
+			return fmt.Errorf("failed to reserve resources for replay: %w", err)
+		}
+		defer e.release(allocation)
+	}
+
+	logPath := filepath.Join(dir, "replay.log")
+	log, err := os.Create(logPath)
+	if err != nil {
+		return fmt.Errorf("failed to create replay log: %w", err)
+	}
+	defer log.Close()
+	cmd := pythonCommand(ctx, scriptPath)
+	cmd.Dir = dir
+	cmd.Stdout = log
+	cmd.Stderr = log
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	sandbox, err := e.sandboxProcess(sandboxSpec{Name: name, Requirements: req, Dir: dir, ReadOnly: readOnly}, cmd)
+	if err != nil {
+		return fmt.Errorf("failed to prepare sandbox: %w", err)
+	}
+	defer sandbox.finish()
+	cmd.Cancel = func() error {
+		sandbox.kill()
+		return killProcessGroup(cmd)
+	}
+	cmd.WaitDelay = 5 * time.Second
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start replay: %w", err)
+	}
+	sandbox.started()
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		lines, _ := tailLog(logPath, 10, -1)
+		return fmt.Errorf("replay failed: %w: %s", err, strings.Join(lines, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/atlas/node/proof"
+	"github.com/atlas/storage/cache"
+	"github.com/atlas/storage/identity"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTrainScript stands in for python3 running train.py. It honours the
+// same parameters deterministically: the state starts as the model and
+// dataset or the resume checkpoint, and each epoch appends "epoch n".
+const fakeTrainScript = `#!/bin/sh
+param() { sed -n "s/.*\"$1\": \"\{0,1\}\([^\",]*\).*/\1/p" params.json; }
+epoch=$(param start_epoch); epoch=${epoch:-0}
+stop=$(param stop_epoch); stop=${stop:-3}
+cat "$(param model_path)" "$(param dataset_path)" > state
+[ -e resume_checkpoint.pt ] && cat resume_checkpoint.pt > state
+while [ "$epoch" -lt "$stop" ]; do
+  epoch=$((epoch + 1))
+  echo "epoch $epoch" >> state
+  cp state checkpoint.pt
+  if [ -n "$ATLAS_EVENTS_FD" ]; then
+    echo "{\"type\":\"checkpoint\",\"path\":\"checkpoint.pt\",\"epoch\":$epoch}" >&$ATLAS_EVENTS_FD
+    sleep 0.1
+  fi
+done
+[ -n "$(param stop_epoch)" ] || echo '{"script_version":"training/4","checkpoint":"checkpoint.pt"}' > result.json
+`
+
+// artifactStore fetches files by CID from memory, standing in for IPFS
+type artifactStore struct {
+	mu    sync.Mutex
+	files map[string]string
+}
+
+func (s *artifactStore) GetFile(cid string, outputPath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[cid]
+	if !ok {
+		return fmt.Errorf("%s not found", cid)
+	}
+	return os.WriteFile(outputPath, []byte(data), 0644)
+}
+
+func (s *artifactStore) add(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cid := fmt.Sprintf("QmCheckpoint%d", len(s.files))
+	s.files[cid] = string(data)
+	return cid, nil
+}
+
+func TestReplay_ReproducesTrainingSegments(t *testing.T) {
+	bin := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "python3"), []byte(fakeTrainScript), 0755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	signer, err := identity.Generate()
+	require.NoError(t, err)
+	workDir := t.TempDir()
+	e := NewExecutor(nil)
+	e.SetWorkDir(workDir)
+	e.SetProver("node-1", signer)
+	store := &artifactStore{files: map[string]string{"QmModel": "model\n", "QmDataset": "rows\n", "QmForged": "forged\n"}}
+	e.publish = func(ctx context.Context, path string) (string, error) { return store.add(path) }
+	artifacts, err := cache.Open(t.TempDir(), 0, store)
+	require.NoError(t, err)
+	e.SetArtifactCache(artifacts)
+
+	require.NoError(t, e.AddTask(&Task{ID: "task-1", TaskType: "training", ModelPath: "QmModel", DatasetPath: "QmDataset"}))
+	e.processTasks(context.Background())
+	waitForStatus(t, e, "task-1", "completed")
+	task, err := e.GetTask("task-1")
+	require.NoError(t, err)
+	computation := task.Proof
+	require.NotNil(t, computation)
+	require.Len(t, computation.Segments, 3)
+
+	// Every segment replays to the state the task reported
+	verifier := proof.NewVerifier(func(nodeID string) (string, error) { return signer.PublicKey(), nil }, e.Replay)
+	for i := range computation.Segments {
+		require.NoError(t, verifier.SpotCheck(context.Background(), computation, i), "segment %d", i)
+	}
+
+	// A segment does not follow from a different checkpoint
+	forged := &proof.Segment{Epoch: 1, Checkpoint: "QmForged"}
+	stateHash, err := e.Replay(context.Background(), computation, forged, computation.Segments[1])
+	require.NoError(t, err)
+	require.NotEqual(t, computation.Segments[1].StateHash, stateHash)
+
+	replays, err := os.ReadDir(filepath.Join(workDir, "replays"))
+	require.NoError(t, err)
+	require.Empty(t, replays)
+}
@@ -0,0 +1,201 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	"github.com/atlas/storage/tracing"
+)
+
+// Runtime executes tasks of some kind of workload. For each task the
+// executor calls Prepare, Run and, if Run succeeded, Collect.
+type Runtime interface {
+	// Prepare stages the task's model, data and programs in run.Dir
+	Prepare(ctx context.Context, run *TaskRun) error
+
+	// Run executes the task, reporting progress through run. It must stop
+	// when ctx is cancelled.
+	Run(ctx context.Context, run *TaskRun) error
+
+	// Collect returns the task's output, or nil if it has none
+	Collect(ctx context.Context, run *TaskRun) ([]byte, error)
+}
+
+// MetadataRuntime is the task metadata key naming the runtime for a task.
+// Without it the runtime is chosen by task type.
+const MetadataRuntime = "runtime"
+
+// runtimeByTaskType maps task types to the runtime that handles them when
+// the task does not name one. Other task types are looked up as runtime
+// names.
+var runtimeByTaskType = map[string]string{
+	"training":  "python",
+	"inference": "python",
+}
+
+// TaskRun is a task being executed by a runtime
+type TaskRun struct {
+	Task *Task
+	Dir  string // Task working directory, preserved across pauses
+
+	executor *Executor
+	finish   []func()
+}
+
+// OnFinish registers fn to be called once the run ends, whether it
+// succeeded, failed or was paused, e.g. to release what Prepare staged
+func (r *TaskRun) OnFinish(fn func()) {
+	r.finish = append(r.finish, fn)
+}
+
+// SetProgress records the task's progress between 0 and 1
+func (r *TaskRun) SetProgress(progress float64) {
+	r.executor.mu.Lock()
+	defer r.executor.mu.Unlock()
+	r.Task.Progress = progress
+}
+
+// RunProcess runs cmd as the task's process so that it can be paused,
+// cancelled and sandboxed by the executor
+func (r *TaskRun) RunProcess(ctx context.Context, cmd *exec.Cmd) error {
+	return r.executor.runProcess(ctx, r.Task.ID, cmd)
+}
+
+// Log returns the task's log, for output a runtime reads from the process
+// itself instead of leaving to RunProcess
+func (r *TaskRun) Log() io.Writer {
+	log, err := r.executor.taskLog(r.Task.ID)
+	if err != nil {
+		return io.Discard
+	}
+	return log
+}
+
+// RegisterRuntime makes a runtime available to tasks under name, replacing
+// any runtime already registered under it
+func (e *Executor) RegisterRuntime(name string, runtime Runtime) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.runtimes[name] = runtime
+}
+
+// Runtimes returns the names of the registered runtimes
+func (e *Executor) Runtimes() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	names := make([]string, 0, len(e.runtimes))
+	for name := range e.runtimes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runtimeFor picks the runtime named in the task's metadata, or the one for
+// its task type. Tasks without a type are training tasks.
+func (e *Executor) runtimeFor(task *Task) (Runtime, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	taskType := task.TaskType
+	if taskType == "" {
+		taskType = "training"
+	}
+	name := task.Metadata[MetadataRuntime]
+	if name == "" {
+		name = runtimeByTaskType[taskType]
+	}
+	if name == "" {
+		name = taskType
+	}
+
+	runtime, ok := e.runtimes[name]
+	if !ok {
+		if task.Metadata[MetadataRuntime] == "" {
+			return nil, fmt.Errorf("unknown task type: %s", taskType)
+		}
+		return nil, fmt.Errorf("unknown runtime: %s", name)
+	}
+	return runtime, nil
+}
+
+// runWithRuntime executes a task with its runtime and stores its output.
+// A task paused or cancelled while it runs is neither collected nor proved,
+// even if its runtime exits cleanly.
+func (e *Executor) runWithRuntime(ctx context.Context, task *Task) {
+	fail := func(err error) {
+		e.mu.Lock()
+		task.Error = err
+		e.mu.Unlock()
+	}
+
+	runtime, err := e.runtimeFor(task)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	e.mu.RLock()
+	run := &TaskRun{
+		Task:     task,
+		Dir:      filepath.Join(e.workDir, task.ID),
+		executor: e,
+	}
+	e.mu.RUnlock()
+	defer func() {
+		for _, fn := range run.finish {
+			fn()
+		}
+	}()
+
+	// Each step is a span of the task's trace
+	step := func(name string, fn func(ctx context.Context) error) error {
+		ctx, span := tracing.Start(ctx, tracerName, name)
+		err := fn(ctx)
+		tracing.End(span, err)
+		return err
+	}
+
+	if err := step("task.prepare", func(ctx context.Context) error { return runtime.Prepare(ctx, run) }); err != nil {
+		fail(fmt.Errorf("failed to prepare task: %w", err))
+		return
+	}
+
+	if err := step("task.execute", func(ctx context.Context) error { return runtime.Run(ctx, run) }); err != nil {
+		fail(err)
+		return
+	}
+	if handle := e.handle(task.ID); handle != nil && handle.stopReason() != "" {
+		return
+	}
+
+	var output []byte
+	err = step("task.collect", func(ctx context.Context) error {
+		var err error
+		output, err = runtime.Collect(ctx, run)
+		return err
+	})
+	if err != nil {
+		fail(fmt.Errorf("failed to collect task output: %w", err))
+		return
+	}
+
+	computation, err := e.proveTask(task, output)
+	if err != nil {
+		fail(fmt.Errorf("failed to prove task: %w", err))
+		return
+	}
+
+	e.mu.Lock()
+	if output != nil {
+		task.OutputData = output
+	}
+	task.Proof = computation
+	task.Progress = 1.0
+	e.mu.Unlock()
+}
@@ -0,0 +1,188 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/atlas/storage/identity"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRuntime struct {
+	output []byte
+}
+
+func (r *fakeRuntime) Prepare(ctx context.Context, run *TaskRun) error { return nil }
+
+func (r *fakeRuntime) Run(ctx context.Context, run *TaskRun) error {
+	run.SetProgress(0.5)
+	return nil
+}
+
+func (r *fakeRuntime) Collect(ctx context.Context, run *TaskRun) ([]byte, error) {
+	return r.output, nil
+}
+
+func TestRuntimeFor(t *testing.T) {
+	e := NewExecutor(nil)
+	custom := &fakeRuntime{}
+	e.RegisterRuntime("onnx", custom)
+	require.Equal(t, []string{"command", "onnx", "python"}, e.Runtimes())
+
+	training := &Task{ID: "task-1"}
+	runtime, err := e.runtimeFor(training)
+	require.NoError(t, err)
+	require.IsType(t, &pythonRuntime{}, runtime)
+	require.Empty(t, training.TaskType)
+
+	require.NoError(t, e.AddTask(training))
+	require.Equal(t, "training", training.TaskType)
+
+	runtime, err = e.runtimeFor(&Task{ID: "task-2", TaskType: "inference", Metadata: map[string]string{MetadataRuntime: "onnx"}})
+	require.NoError(t, err)
+	require.Same(t, custom, runtime)
+
+	runtime, err = e.runtimeFor(&Task{ID: "task-3", TaskType: "onnx"})
+	require.NoError(t, err)
+	require.Same(t, custom, runtime)
+
+	_, err = e.runtimeFor(&Task{ID: "task-4", TaskType: "unknown"})
+	require.ErrorContains(t, err, "unknown task type")
+
+	_, err = e.runtimeFor(&Task{ID: "task-5", Metadata: map[string]string{MetadataRuntime: "missing"}})
+	require.ErrorContains(t, err, "unknown runtime")
+}
+
+func TestRunWithRuntime_StoresOutput(t *testing.T) {
+	e := NewExecutor(nil)
+	e.SetWorkDir(t.TempDir())
+	e.RegisterRuntime("fake", &fakeRuntime{output: []byte(`{"ok":true}`)})
+
+	require.NoError(t, e.AddTask(&Task{ID: "task-1", TaskType: "fake"}))
+	e.processTasks(context.Background())
+	waitForStatus(t, e, "task-1", "completed")
+
+	task, err := e.GetTask("task-1")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"ok":true}`, string(task.OutputData))
+	require.Equal(t, 1.0, task.Progress)
+}
+
+// blockingRuntime runs until released and then exits cleanly, like a
+// command that checkpoints and exits 0 when paused
+type blockingRuntime struct {
+	fakeRuntime
+	release chan struct{}
+}
+
+func (r *blockingRuntime) Run(ctx context.Context, run *TaskRun) error {
+	<-r.release
+	return nil
+}
+
+func TestRunWithRuntime_PausedTaskIsNotCollected(t *testing.T) {
+	e := NewExecutor(nil)
+	e.SetWorkDir(t.TempDir())
+	signer, err := identity.Generate()
+	require.NoError(t, err)
+	e.SetProver("node-1", signer)
+	runtime := &blockingRuntime{fakeRuntime: fakeRuntime{output: []byte(`{"partial":true}`)}, release: make(chan struct{})}
+	e.RegisterRuntime("fake", runtime)
+
+	require.NoError(t, e.AddTask(&Task{ID: "task-1", TaskType: "fake"}))
+	e.processTasks(context.Background())
+	waitForStatus(t, e, "task-1", "in_progress")
+
+	paused := make(chan error, 1)
+	go func() { paused <- e.PauseTask("task-1") }()
+	require.Eventually(t, func() bool {
+		handle := e.handle("task-1")
+		return handle != nil && handle.stopReason() == stopPause
+	}, 5*time.Second, 10*time.Millisecond)
+	close(runtime.release)
+	require.NoError(t, <-paused)
+	waitForStatus(t, e, "task-1", "paused")
+
+	task, err := e.TaskSnapshot("task-1")
+	require.NoError(t, err)
+	require.Nil(t, task.OutputData)
+	require.Nil(t, task.Proof)
+	require.NoError(t, task.Error)
+}
+
+// writeCommand writes an executable shell script to dir
+func writeCommand(t *testing.T, dir string, script string) string {
+	t.Helper()
+	path := filepath.Join(dir, "command.sh")
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755))
+	return path
+}
+
+func TestCommandRuntime(t *testing.T) {
+	workDir := t.TempDir()
+	command := writeCommand(t, t.TempDir(), `
+read -r request
+echo '{"type":"progress","progress":0.5}'
+echo "plain log line"
+echo "{\"type\":\"result\",\"output\":{\"request\":$request,\"arg\":\"$1\"}}"
+`)
+
+	e := NewExecutor(nil)
+	e.SetWorkDir(workDir)
+	e.RegisterRuntime("command", NewCommandRuntime(map[string]string{"echo-request": command}))
+
+	require.NoError(t, e.AddTask(&Task{
+		ID:        "task-1",
+		TaskType:  "command",
+		InputData: []byte(`{"prompt":"hi"}`),
+		Metadata: map[string]string{
+			MetadataCommand:     "echo-request",
+			MetadataCommandArgs: `["--fast"]`,
+		},
+	}))
+	e.processTasks(context.Background())
+	waitForStatus(t, e, "task-1", "completed")
+
+	task, err := e.GetTask("task-1")
+	require.NoError(t, err)
+	require.NoError(t, task.Error)
+
+	var output struct {
+		Request CommandRequest `json:"request"`
+		Arg     string         `json:"arg"`
+	}
+	require.NoError(t, json.Unmarshal(task.OutputData, &output))
+	require.Equal(t, "task-1", output.Request.TaskID)
+	require.JSONEq(t, `{"prompt":"hi"}`, string(output.Request.Input))
+	require.Equal(t, "--fast", output.Arg)
+}
+
+func TestCommandRuntime_Errors(t *testing.T) {
+	command := writeCommand(t, t.TempDir(), `
+cat > /dev/null
+echo '{"type":"error","error":"model not found"}'
+exit 3
+`)
+
+	e := NewExecutor(nil)
+	e.SetWorkDir(t.TempDir())
+	e.RegisterRuntime("command", NewCommandRuntime(map[string]string{"failing": command}))
+
+	require.NoError(t, e.AddTask(&Task{ID: "reported", TaskType: "command", Metadata: map[string]string{MetadataCommand: "failing"}}))
+	require.NoError(t, e.AddTask(&Task{ID: "unregistered", TaskType: "command", Metadata: map[string]string{MetadataCommand: "/bin/sh"}}))
+	e.processTasks(context.Background())
+	waitForStatus(t, e, "reported", "failed")
+	waitForStatus(t, e, "unregistered", "failed")
+
+	task, err := e.GetTask("reported")
+	require.NoError(t, err)
+	require.ErrorContains(t, task.Error, "model not found")
+
+	task, err = e.GetTask("unregistered")
+	require.NoError(t, err)
+	require.ErrorContains(t, task.Error, "command not registered")
+}
@@ -0,0 +1,99 @@
+package executor
+
+import (
+	"path/filepath"
+
+	"github.com/atlas/node/resource"
+)
+
+// SandboxConfig controls how task processes are confined. Sandboxing is only
+// implemented on Linux; elsewhere tasks run as plain child processes.
+type SandboxConfig struct {
+	Enabled bool
+
+	// IsolateNetwork runs tasks in their own network namespace with only a
+	// loopback interface
+	IsolateNetwork bool
+
+	// PidsLimit caps the number of processes a task may create (0: no limit)
+	PidsLimit int64
+
+	// CgroupParent is the cgroup v2 directory task cgroups are created
+	// under. Defaults to the node's own cgroup.
+	CgroupParent string
+}
+
+// DefaultSandboxConfig returns the sandbox settings used by atlas-node
+func DefaultSandboxConfig() SandboxConfig {
+	return SandboxConfig{
+		Enabled:        true,
+		IsolateNetwork: true,
+		PidsLimit:      4096,
+	}
+}
+
+// sandboxSpec describes the confinement of one sandboxed process: a task's
+// or a model worker's
+type sandboxSpec struct {
+	Name         string                // Names the process's cgroup
+	Requirements resource.Requirements // Sizes the cgroup; zero values are unlimited
+	Dir          string                // The only directory of the work dir the process sees
+	ReadOnly     []string              // Paths mounted read-only, e.g. cached models
+}
+
+// ResourceUsage is the resource consumption of a task's processes, as
+// recorded by its cgroup
+type ResourceUsage struct {
+	CPUSeconds      float64 `json:"cpu_seconds"`
+	MemoryPeakBytes uint64  `json:"memory_peak_bytes"`
+	PidsPeak        uint64  `json:"pids_peak"`
+}
+
+// SetSandbox configures sandboxing for task processes
+func (e *Executor) SetSandbox(config SandboxConfig) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sandbox = config
+}
+
+// taskSandboxSpec returns the confinement of a process of the task running
+// in dir, by default the task directory. Its cgroup is sized like the
+// task's reservation, so tasks that declare no requirements get the policy
+// default for their type.
+func (e *Executor) taskSandboxSpec(taskID string, dir string) (sandboxSpec, bool) {
+	e.mu.RLock()
+	task := e.tasks[taskID]
+	spec := sandboxSpec{Name: "task-" + taskID, Dir: dir}
+	if task != nil {
+		spec.ReadOnly = append([]string(nil), e.taskMounts[taskID]...)
+	}
+	if spec.Dir == "" {
+		spec.Dir = filepath.Join(e.workDir, taskID)
+	}
+	e.mu.RUnlock()
+
+	if task == nil {
+		return sandboxSpec{}, false
+	}
+	spec.Requirements = e.requirementsFor(task)
+	return spec, true
+}
+
+// exposeToTask mounts paths read-only into the sandbox of the task's
+// processes, which otherwise see neither the artifact cache under the work
+// directory nor /tmp, until the returned function is called
+func (e *Executor) exposeToTask(taskID string, paths ...string) func() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, path := range paths {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		e.taskMounts[taskID] = append(e.taskMounts[taskID], path)
+	}
+	return func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		delete(e.taskMounts, taskID)
+	}
+}
@@ -0,0 +1,621 @@
+//go:build linux
+
+package executor
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/atlas/node/resource"
+)
+
+const (
+	cgroupMountPoint  = "/sys/fs/cgroup"
+	cgroup2SuperMagic = 0x63677270
+	cpuPeriodUsec     = 100000
+
+	// sandboxInitArg is argv[0] of the re-executed node binary that sets up
+	// mounts inside the task's namespaces before exec'ing the task
+	sandboxInitArg = "atlas-sandbox-init"
+	sandboxInitEnv = "ATLAS_SANDBOX_INIT"
+)
+
+var requiredControllers = []string{"cpu", "memory", "pids"}
+
+// sandboxState caches the one-time cgroup and namespace probes
+type sandboxState struct {
+	cgroupOnce   sync.Once
+	cgroupParent string
+	cgroupErr    error
+
+	namespaceOnce sync.Once
+	namespaceErr  error
+}
+
+// taskSandbox is the confinement applied to a single task process
+type taskSandbox struct {
+	cgroup *taskCgroup
+}
+
+type sandboxInitConfig struct {
+	Probe          bool     `json:"probe,omitempty"`
+	Path           string   `json:"path,omitempty"`
+	WorkDir        string   `json:"work_dir,omitempty"`
+	TaskDir        string   `json:"task_dir,omitempty"`
+	ReadOnly       []string `json:"read_only,omitempty"`
+	IsolateNetwork bool     `json:"isolate_network,omitempty"`
+}
+
+func init() {
+	if len(os.Args) > 0 && os.Args[0] == sandboxInitArg {
+		runSandboxInit()
+	}
+}
+
+// prepareSandbox confines a task's process to a cgroup sized from the
+// requirements reserved for the task and to its task directory, plus the
+// staged artifacts exposed to the task
+func (e *Executor) prepareSandbox(taskID string, cmd *exec.Cmd) (*taskSandbox, error) {
+	spec, ok := e.taskSandboxSpec(taskID, cmd.Dir)
+	if !ok {
+		return nil, nil
+	}
+	return e.sandboxProcess(spec, cmd)
+}
+
+// sandboxProcess places cmd in a cgroup sized from spec.Requirements and in
+// fresh mount and network namespaces. Either part falls back to running
+// without it when the host does not support it.
+func (e *Executor) sandboxProcess(spec sandboxSpec, cmd *exec.Cmd) (*taskSandbox, error) {
+	e.mu.RLock()
+	config := e.sandbox
+	workDir := e.workDir
+	e.mu.RUnlock()
+
+	if !config.Enabled {
+		return nil, nil
+	}
+	if cmd.Err != nil {
+		return nil, cmd.Err
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	sb := &taskSandbox{}
+
+	if parent, err := e.cgroupParent(config); err == nil {
+		cg, err := createTaskCgroup(parent, spec.Name, spec.Requirements, config.PidsLimit)
+		if err != nil {
+			fmt.Printf("Warning: running %s without resource limits: %v\n", spec.Name, err)
+		} else {
+			sb.cgroup = cg
+			cmd.SysProcAttr.UseCgroupFD = true
+			cmd.SysProcAttr.CgroupFD = int(cg.fd.Fd())
+		}
+	}
+
+	if e.namespacesSupported() {
+		if err := wrapInNamespaces(cmd, config, workDir, spec); err != nil {
+			sb.close()
+			return nil, err
+		}
+	}
+
+	return sb, nil
+}
+
+// started releases resources only needed while the process was created
+func (sb *taskSandbox) started() {
+	if sb == nil || sb.cgroup == nil {
+		return
+	}
+	sb.cgroup.fd.Close()
+}
+
+// kill terminates every process in the task's cgroup
+func (sb *taskSandbox) kill() {
+	if sb == nil || sb.cgroup == nil {
+		return
+	}
+	sb.cgroup.kill()
+}
+
+// finish collects the task's resource usage and removes its cgroup
+func (sb *taskSandbox) finish() *ResourceUsage {
+	if sb == nil || sb.cgroup == nil {
+		return nil
+	}
+	usage := readCgroupUsage(sb.cgroup.dir)
+	sb.close()
+	return usage
+}
+
+func (sb *taskSandbox) close() {
+	if sb == nil || sb.cgroup == nil {
+		return
+	}
+	sb.cgroup.fd.Close()
+	sb.cgroup.remove()
+	sb.cgroup = nil
+}
+
+func (e *Executor) cgroupParent(config SandboxConfig) (string, error) {
+	state := &e.sandboxState
+	state.cgroupOnce.Do(func() {
+		state.cgroupParent, state.cgroupErr = setupCgroupParent(config.CgroupParent)
+		if state.cgroupErr != nil {
+			fmt.Printf("Warning: task resource limits disabled: %v\n", state.cgroupErr)
+		}
+	})
+	return state.cgroupParent, state.cgroupErr
+}
+
+func (e *Executor) namespacesSupported() bool {
+	state := &e.sandboxState
+	state.namespaceOnce.Do(func() {
+		state.namespaceErr = probeNamespaces()
+		if state.namespaceErr != nil {
+			fmt.Printf("Warning: task namespace isolation disabled: %v\n", state.namespaceErr)
+		}
+	})
+	return state.namespaceErr == nil
+}
+
+func setupCgroupParent(configured string) (string, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(cgroupMountPoint, &st); err != nil || st.Type != cgroup2SuperMagic {
+		return "", fmt.Errorf("cgroup v2 is not mounted at %s", cgroupMountPoint)
+	}
+
+	parent := configured
+	ownCgroup := false
+	if parent == "" {
+		data, err := os.ReadFile("/proc/self/cgroup")
+		if err != nil {
+			return "", fmt.Errorf("failed to read own cgroup: %w", err)
+		}
+		path, err := parseCgroupV2Path(string(data))
+		if err != nil {
+			return "", err
+		}
+		parent = filepath.Join(cgroupMountPoint, path)
+		ownCgroup = true
+	}
+
+	if err := delegateControllers(parent, ownCgroup); err != nil {
+		return "", err
+	}
+	return parent, nil
+}
+
+// parseCgroupV2Path extracts the unified hierarchy path from
+// /proc/self/cgroup
+func parseCgroupV2Path(data string) (string, error) {
+	for _, line := range strings.Split(data, "\n") {
+		if strings.HasPrefix(line, "0::") {
+			return strings.TrimPrefix(line, "0::"), nil
+		}
+	}
+	return "", fmt.Errorf("process is not in a cgroup v2 hierarchy")
+}
+
+// delegateControllers enables the cpu, memory and pids controllers for
+// children of parent. A cgroup that has member processes cannot do that, so
+// when parent is the node's own cgroup the node first moves itself into an
+// "atlas-node" leaf.
+func delegateControllers(parent string, ownCgroup bool) error {
+	subtreeControl := filepath.Join(parent, "cgroup.subtree_control")
+	if missing := missingControllers(subtreeControl); len(missing) == 0 {
+		return nil
+	}
+
+	if missing := missingControllers(filepath.Join(parent, "cgroup.controllers")); len(missing) > 0 {
+		return fmt.Errorf("controllers %s not delegated to %s", strings.Join(missing, ", "), parent)
+	}
+
+	enable := "+" + strings.Join(requiredControllers, " +")
+	err := writeCgroupFile(parent, "cgroup.subtree_control", enable)
+	if err == nil {
+		return nil
+	}
+	if !ownCgroup || !errors.Is(err, syscall.EBUSY) {
+		return fmt.Errorf("failed to enable controllers in %s: %w", parent, err)
+	}
+
+	leaf := filepath.Join(parent, "atlas-node")
+	if err := os.Mkdir(leaf, 0755); err != nil && !os.IsExist(err) {
+		return fmt.Errorf("failed to create node cgroup: %w", err)
+	}
+	procs, err := os.ReadFile(filepath.Join(parent, "cgroup.procs"))
+	if err != nil {
+		return fmt.Errorf("failed to read cgroup members: %w", err)
+	}
+	for _, pid := range strings.Fields(string(procs)) {
+		if err := writeCgroupFile(leaf, "cgroup.procs", pid); err != nil && !errors.Is(err, syscall.ESRCH) {
+			return fmt.Errorf("failed to move process %s into %s: %w", pid, leaf, err)
+		}
+	}
+
+	if err := writeCgroupFile(parent, "cgroup.subtree_control", enable); err != nil {
+		return fmt.Errorf("failed to enable controllers in %s: %w", parent, err)
+	}
+	return nil
+}
+
+func missingControllers(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return requiredControllers
+	}
+
+	present := make(map[string]bool)
+	for _, controller := range strings.Fields(string(data)) {
+		present[controller] = true
+	}
+
+	var missing []string
+	for _, controller := range requiredControllers {
+		if !present[controller] {
+			missing = append(missing, controller)
+		}
+	}
+	return missing
+}
+
+type taskCgroup struct {
+	dir string
+	fd  *os.File
+}
+
+// createTaskCgroup creates the cgroup atlas-<name> under parent
+func createTaskCgroup(parent string, name string, req resource.Requirements, pidsLimit int64) (*taskCgroup, error) {
+	dir := filepath.Join(parent, "atlas-"+cgroupName(name))
+
+	// A cgroup left behind by a crashed node is empty and can be removed
+	os.Remove(dir)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create task cgroup: %w", err)
+	}
+
+	if err := writeCgroupLimits(dir, req, pidsLimit); err != nil {
+		os.Remove(dir)
+		return nil, err
+	}
+
+	fd, err := os.Open(dir)
+	if err != nil {
+		os.Remove(dir)
+		return nil, fmt.Errorf("failed to open task cgroup: %w", err)
+	}
+
+	return &taskCgroup{dir: dir, fd: fd}, nil
+}
+
+// writeCgroupLimits translates a task's resource requirements into cgroup
+// v2 limits. Zero-valued requirements are left unlimited.
+func writeCgroupLimits(dir string, req resource.Requirements, pidsLimit int64) error {
+	cpuMax := fmt.Sprintf("max %d", cpuPeriodUsec)
+	if req.CPU > 0 {
+		cpuMax = fmt.Sprintf("%d %d", req.CPU*cpuPeriodUsec, cpuPeriodUsec)
+	}
+
+	memoryMax := "max"
+	if req.MemoryGB > 0 {
+		memoryMax = strconv.FormatUint(req.MemoryGB<<30, 10)
+	}
+
+	pidsMax := "max"
+	if pidsLimit > 0 {
+		pidsMax = strconv.FormatInt(pidsLimit, 10)
+	}
+
+	limits := []struct{ file, value string }{
+		{"cpu.max", cpuMax},
+		{"memory.max", memoryMax},
+		{"pids.max", pidsMax},
+	}
+	for _, limit := range limits {
+		if err := writeCgroupFile(dir, limit.file, limit.value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", limit.file, err)
+		}
+	}
+	return nil
+}
+
+// readCgroupUsage reads CPU time and peak memory and process counts.
+// memory.peak and pids.peak need recent kernels; the current values are
+// used when they are missing.
+func readCgroupUsage(dir string) *ResourceUsage {
+	usage := &ResourceUsage{}
+
+	if file, err := os.Open(filepath.Join(dir, "cpu.stat")); err == nil {
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 2 && fields[0] == "usage_usec" {
+				if usec, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+					usage.CPUSeconds = float64(usec) / 1e6
+				}
+			}
+		}
+		file.Close()
+	}
+
+	usage.MemoryPeakBytes = readCgroupUint(dir, "memory.peak", "memory.current")
+	usage.PidsPeak = readCgroupUint(dir, "pids.peak", "pids.current")
+
+	return usage
+}
+
+func readCgroupUint(dir string, files ...string) uint64 {
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			continue
+		}
+		if value, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64); err == nil {
+			return value
+		}
+	}
+	return 0
+}
+
+func (cg *taskCgroup) kill() {
+	writeCgroupFile(cg.dir, "cgroup.kill", "1")
+}
+
+// remove deletes the cgroup once its processes have exited
+func (cg *taskCgroup) remove() {
+	for i := 0; i < 50; i++ {
+		err := os.Remove(cg.dir)
+		if err == nil || os.IsNotExist(err) {
+			return
+		}
+		if i == 0 {
+			cg.kill()
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	fmt.Printf("Warning: failed to remove task cgroup %s\n", cg.dir)
+}
+
+func writeCgroupFile(dir string, file string, value string) error {
+	f, err := os.OpenFile(filepath.Join(dir, file), os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(value)
+	return err
+}
+
+func cgroupName(taskID string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '.' || r == ' ' || r == '\n' {
+			return '_'
+		}
+		return r
+	}, taskID)
+}
+
+func namespaceFlags(isolateNetwork bool) uintptr {
+	flags := uintptr(syscall.CLONE_NEWNS)
+	if isolateNetwork {
+		flags |= syscall.CLONE_NEWNET
+	}
+	return flags
+}
+
+// setNamespaces adds the namespace clone flags to attr. Unprivileged nodes
+// also get a user namespace mapping their uid to root inside it.
+func setNamespaces(attr *syscall.SysProcAttr, isolateNetwork bool) {
+	attr.Cloneflags |= namespaceFlags(isolateNetwork)
+	if os.Geteuid() != 0 {
+		attr.Cloneflags |= syscall.CLONE_NEWUSER
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Geteuid(), Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getegid(), Size: 1}}
+		attr.GidMappingsEnableSetgroups = false
+	}
+}
+
+// probeNamespaces checks that the node may create the namespaces used for
+// tasks by starting the sandbox init in probe mode
+func probeNamespaces() error {
+	probe := exec.Command("/proc/self/exe")
+	probe.Args = []string{sandboxInitArg}
+	probe.Env = append(os.Environ(), sandboxInitEnv+`={"probe":true}`)
+	probe.SysProcAttr = &syscall.SysProcAttr{}
+	setNamespaces(probe.SysProcAttr, true)
+
+	if output, err := probe.CombinedOutput(); err != nil {
+		return fmt.Errorf("cannot create mount and network namespaces: %w %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// wrapInNamespaces rewrites cmd to start the node binary as sandbox init in
+// new namespaces. The init sets up the private mounts and then execs the
+// original command.
+func wrapInNamespaces(cmd *exec.Cmd, config SandboxConfig, workDir string, spec sandboxSpec) error {
+	data, err := json.Marshal(sandboxInitConfig{
+		Path:           cmd.Path,
+		WorkDir:        workDir,
+		TaskDir:        spec.Dir,
+		ReadOnly:       spec.ReadOnly,
+		IsolateNetwork: config.IsolateNetwork,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode sandbox config: %w", err)
+	}
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+
+	cmd.Path = "/proc/self/exe"
+	cmd.Args = append([]string{sandboxInitArg}, cmd.Args...)
+	cmd.Env = append(env, sandboxInitEnv+"="+string(data), "TMPDIR=/tmp")
+	setNamespaces(cmd.SysProcAttr, config.IsolateNetwork)
+
+	return nil
+}
+
+// runSandboxInit runs inside the task's namespaces. It never returns.
+func runSandboxInit() {
+	var config sandboxInitConfig
+	if err := json.Unmarshal([]byte(os.Getenv(sandboxInitEnv)), &config); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: invalid config: %v\n", err)
+		os.Exit(126)
+	}
+	os.Unsetenv(sandboxInitEnv)
+
+	if config.Probe {
+		os.Exit(0)
+	}
+
+	if err := setupSandboxMounts(config); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		os.Exit(126)
+	}
+	if config.IsolateNetwork {
+		if err := bringUpLoopback(); err != nil {
+			fmt.Fprintf(os.Stderr, "sandbox: failed to bring up loopback: %v\n", err)
+		}
+	}
+
+	err := syscall.Exec(config.Path, os.Args[1:], os.Environ())
+	fmt.Fprintf(os.Stderr, "sandbox: exec %s: %v\n", config.Path, err)
+	os.Exit(127)
+}
+
+// setupSandboxMounts gives the task a private /tmp and hides every other
+// task's directory: the work directory is replaced by an empty tmpfs with
+// only this task's directory bind-mounted back in. The read-only paths are
+// mounted back read-only at the same place.
+func setupSandboxMounts(config sandboxInitConfig) error {
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make mounts private: %w", err)
+	}
+
+	// Keep handles on the task directory and the read-only paths before
+	// covering the work directory
+	taskDir, err := os.Open(config.TaskDir)
+	if err != nil {
+		return fmt.Errorf("failed to open task directory: %w", err)
+	}
+	defer taskDir.Close()
+
+	readOnly := make([]*os.File, 0, len(config.ReadOnly))
+	defer func() {
+		for _, file := range readOnly {
+			file.Close()
+		}
+	}()
+	for _, path := range config.ReadOnly {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		readOnly = append(readOnly, file)
+	}
+
+	tmpfsFlags := uintptr(syscall.MS_NOSUID | syscall.MS_NODEV)
+	if err := syscall.Mount("tmpfs", "/tmp", "tmpfs", tmpfsFlags, "mode=1777"); err != nil {
+		return fmt.Errorf("failed to mount private /tmp: %w", err)
+	}
+	if config.WorkDir != "/tmp" && !strings.HasPrefix(config.WorkDir, "/tmp/") {
+		if err := syscall.Mount("tmpfs", config.WorkDir, "tmpfs", tmpfsFlags, "mode=0755"); err != nil {
+			return fmt.Errorf("failed to hide work directory: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(config.TaskDir, 0755); err != nil {
+		return fmt.Errorf("failed to recreate task directory: %w", err)
+	}
+	source := fmt.Sprintf("/proc/self/fd/%d", taskDir.Fd())
+	if err := syscall.Mount(source, config.TaskDir, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("failed to mount task directory: %w", err)
+	}
+
+	for _, file := range readOnly {
+		if err := bindReadOnly(file, file.Name()); err != nil {
+			return err
+		}
+	}
+
+	return os.Chdir(config.TaskDir)
+}
+
+// bindReadOnly bind-mounts the open file or directory source read-only at
+// target, creating the mount point if it is missing
+func bindReadOnly(source *os.File, target string) error {
+	info, err := source.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", target, err)
+	}
+	if info.IsDir() {
+		err = os.MkdirAll(target, 0755)
+	} else if err = os.MkdirAll(filepath.Dir(target), 0755); err == nil {
+		var mountPoint *os.File
+		if mountPoint, err = os.OpenFile(target, os.O_CREATE|os.O_RDONLY, 0644); err == nil {
+			mountPoint.Close()
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create mount point %s: %w", target, err)
+	}
+
+	path := fmt.Sprintf("/proc/self/fd/%d", source.Fd())
+	if err := syscall.Mount(path, target, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("failed to mount %s: %w", target, err)
+	}
+
+	// A remount may not drop the flags the mount was locked with
+	flags := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY)
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(target, &st); err == nil {
+		flags |= uintptr(st.Flags) & (syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC |
+			syscall.MS_NOATIME | syscall.MS_NODIRATIME | syscall.MS_RELATIME)
+	}
+	if err := syscall.Mount("", target, "", flags, ""); err != nil {
+		return fmt.Errorf("failed to make %s read-only: %w", target, err)
+	}
+	return nil
+}
+
+func bringUpLoopback() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	var ifr struct {
+		name  [syscall.IFNAMSIZ]byte
+		flags uint16
+		_     [22]byte
+	}
+	copy(ifr.name[:], "lo")
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCGIFFLAGS, uintptr(unsafe.Pointer(&ifr))); errno != 0 {
+		return errno
+	}
+	ifr.flags |= syscall.IFF_UP
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCSIFFLAGS, uintptr(unsafe.Pointer(&ifr))); errno != 0 {
+		return errno
+	}
+	return nil
+}
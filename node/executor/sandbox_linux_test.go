@@ -0,0 +1,231 @@
+//go:build linux
+
+package executor
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/atlas/node/resource"
+	"github.com/atlas/storage/cache"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCgroupV2Path(t *testing.T) {
+	path, err := parseCgroupV2Path("12:cpu,cpuacct:/legacy\n0::/system.slice/atlas-node.service\n")
+	require.NoError(t, err)
+	require.Equal(t, "/system.slice/atlas-node.service", path)
+
+	_, err = parseCgroupV2Path("12:cpu,cpuacct:/legacy\n")
+	require.Error(t, err)
+}
+
+// writeCgroupFixture fakes cgroup interface files in dir
+func writeCgroupFixture(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+}
+
+func TestWriteCgroupLimits(t *testing.T) {
+	dir := t.TempDir()
+	writeCgroupFixture(t, dir, map[string]string{"cpu.max": "", "memory.max": "", "pids.max": ""})
+
+	require.NoError(t, writeCgroupLimits(dir, resource.Requirements{CPU: 2, MemoryGB: 4}, 128))
+
+	cpuMax, _ := os.ReadFile(filepath.Join(dir, "cpu.max"))
+	memoryMax, _ := os.ReadFile(filepath.Join(dir, "memory.max"))
+	pidsMax, _ := os.ReadFile(filepath.Join(dir, "pids.max"))
+	require.Equal(t, "200000 100000", string(cpuMax))
+	require.Equal(t, "4294967296", string(memoryMax))
+	require.Equal(t, "128", string(pidsMax))
+
+	require.NoError(t, writeCgroupLimits(dir, resource.Requirements{}, 0))
+	cpuMax, _ = os.ReadFile(filepath.Join(dir, "cpu.max"))
+	memoryMax, _ = os.ReadFile(filepath.Join(dir, "memory.max"))
+	require.Equal(t, "max 100000", string(cpuMax))
+	require.Equal(t, "max", string(memoryMax))
+}
+
+func TestTaskSandboxSpec_SizedLikeReservation(t *testing.T) {
+	e := NewExecutor(nil)
+	e.SetWorkDir(t.TempDir())
+	require.NoError(t, e.AddTask(&Task{ID: "task-1", TaskType: "training"}))
+	require.NoError(t, e.AddTask(&Task{ID: "task-2", Requirements: resource.Requirements{CPU: 2, MemoryGB: 3}}))
+
+	// A task that declares nothing is limited to the default it reserves
+	spec, ok := e.taskSandboxSpec("task-1", "")
+	require.True(t, ok)
+	require.Equal(t, DefaultTaskRequirements, spec.Requirements)
+	require.NotZero(t, spec.Requirements.MemoryGB)
+
+	spec, ok = e.taskSandboxSpec("task-2", "")
+	require.True(t, ok)
+	require.Equal(t, resource.Requirements{CPU: 2, MemoryGB: 3}, spec.Requirements)
+
+	_, ok = e.taskSandboxSpec("task-3", "")
+	require.False(t, ok)
+}
+
+func TestReadCgroupUsage(t *testing.T) {
+	dir := t.TempDir()
+	writeCgroupFixture(t, dir, map[string]string{
+		"cpu.stat":       "usage_usec 2500000\nuser_usec 2000000\nsystem_usec 500000\n",
+		"memory.peak":    "104857600\n",
+		"memory.current": "4096\n",
+		"pids.current":   "3\n",
+	})
+
+	usage := readCgroupUsage(dir)
+	require.Equal(t, 2.5, usage.CPUSeconds)
+	require.Equal(t, uint64(104857600), usage.MemoryPeakBytes)
+	// pids.peak is missing on older kernels
+	require.Equal(t, uint64(3), usage.PidsPeak)
+}
+
+func TestDelegateControllers_NotDelegated(t *testing.T) {
+	dir := t.TempDir()
+	writeCgroupFixture(t, dir, map[string]string{
+		"cgroup.controllers":     "cpuset cpu io\n",
+		"cgroup.subtree_control": "",
+	})
+
+	err := delegateControllers(dir, false)
+	require.ErrorContains(t, err, "memory, pids")
+}
+
+func TestSandbox_IsolatesTaskDirectory(t *testing.T) {
+	workDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(workDir, "other-task"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "other-task", "secret"), []byte("x"), 0644))
+
+	e := NewExecutor(nil)
+	e.SetWorkDir(workDir)
+	config := DefaultSandboxConfig()
+	// No controllers are delegated here, so tasks run without resource limits
+	config.CgroupParent = t.TempDir()
+	e.SetSandbox(config)
+	if !e.namespacesSupported() {
+		t.Skip("mount and network namespaces are not available")
+	}
+
+	e.runTask = shellTask(e, workDir, `
+echo output > result
+if [ -e ../other-task/secret ]; then echo visible > leaked; fi
+`)
+
+	require.NoError(t, e.AddTask(&Task{ID: "task-1"}))
+	e.processTasks(context.Background())
+	waitForStatus(t, e, "task-1", "completed")
+
+	// Writes to the task directory reach the host, other tasks stay hidden
+	require.FileExists(t, filepath.Join(workDir, "task-1", "result"))
+	require.NoFileExists(t, filepath.Join(workDir, "task-1", "leaked"))
+}
+
+// sandboxWorkerScript is a model worker that answers every request with
+// what it can do with its model and whether $OTHER_TASK is visible
+const sandboxWorkerScript = `#!/bin/sh
+model=$(sed -n 's/.*"model_path": "\([^"]*\)".*/\1/p' params.json)
+echo '{"type":"ready","ok":true,"script_version":"worker/test"}'
+while read -r line; do
+  id=$(printf '%s\n' "$line" | sed -n 's/^{"id":"\([^"]*\)".*/\1/p')
+  case "$line" in *'"type":"shutdown"'*) exit 0 ;; esac
+  readable=false; cat "$model/config.json" >/dev/null 2>&1 && readable=true
+  writable=false; touch "$model/written" 2>/dev/null && writable=true
+  other=false; [ -e "$OTHER_TASK" ] && other=true
+  printf '{"id":"%s","ok":true,"result":{"readable":%s,"writable":%s,"other":%s}}\n' "$id" $readable $writable $other
+done
+`
+
+func TestSandbox_IsolatesModelWorker(t *testing.T) {
+	workDir := t.TempDir()
+	secret := filepath.Join(workDir, "other-task", "secret")
+	require.NoError(t, os.MkdirAll(filepath.Dir(secret), 0755))
+	require.NoError(t, os.WriteFile(secret, []byte("x"), 0644))
+
+	// The sandbox hides /tmp, so the worker's python3 lives elsewhere
+	bin, err := os.MkdirTemp("/var/tmp", "atlas-worker-")
+	if err != nil {
+		t.Skipf("no directory outside /tmp for the worker: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(bin) })
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "python3"), []byte(sandboxWorkerScript), 0755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("OTHER_TASK", secret)
+
+	e := NewExecutor(nil)
+	e.SetWorkDir(workDir)
+	config := DefaultSandboxConfig()
+	config.CgroupParent = t.TempDir()
+	e.SetSandbox(config)
+	if !e.namespacesSupported() {
+		t.Skip("mount and network namespaces are not available")
+	}
+	t.Cleanup(e.Stop)
+	e.InitializeInferenceExecutor()
+
+	model := newModelDir(t)
+	output, err := e.inferenceExecutor.pool.Infer(context.Background(), model, InferenceInput{Operation: "predict"})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"readable":true,"writable":false,"other":false}`, string(output.Result))
+	require.NoFileExists(t, filepath.Join(model, "written"))
+}
+
+func TestSandbox_ExposesStagedArtifacts(t *testing.T) {
+	workDir := t.TempDir()
+	e := NewExecutor(nil)
+	e.SetWorkDir(workDir)
+	config := DefaultSandboxConfig()
+	config.CgroupParent = t.TempDir()
+	e.SetSandbox(config)
+	if !e.namespacesSupported() {
+		t.Skip("mount and network namespaces are not available")
+	}
+	// The cache lives in the work directory, which the sandbox covers
+	artifacts, err := cache.Open(filepath.Join(workDir, "cache"), 0, modelFetcher{})
+	require.NoError(t, err)
+	e.SetArtifactCache(artifacts)
+	dataset := filepath.Join(t.TempDir(), "data.txt")
+	require.NoError(t, os.WriteFile(dataset, []byte("rows"), 0644))
+
+	te := &TrainingExecutor{executor: e, workDir: workDir}
+	e.runTask = func(ctx context.Context, task *Task) {
+		taskDir := filepath.Join(workDir, task.ID)
+		release, err := te.prepareTraining(ctx, task, taskDir, "QmModel", dataset)
+		if err != nil {
+			task.Error = err
+			return
+		}
+		defer release()
+		cmd := exec.CommandContext(ctx, "sh", "-c", `
+model=$(sed -n 's/.*"model_path": "\([^"]*\)".*/\1/p' params.json)
+data=$(sed -n 's/.*"dataset_path": "\([^"]*\)".*/\1/p' params.json)
+cat "$model/config.json" > model
+cat "$data" > data
+touch "$model/written" 2>/dev/null && echo yes > written
+true
+`)
+		cmd.Dir = taskDir
+		task.Error = e.runProcess(ctx, task.ID, cmd)
+	}
+
+	require.NoError(t, e.AddTask(&Task{ID: "task-1"}))
+	e.processTasks(context.Background())
+	waitForStatus(t, e, "task-1", "completed")
+
+	// Both artifacts were readable, and the cached model stayed read-only
+	taskDir := filepath.Join(workDir, "task-1")
+	model, _ := os.ReadFile(filepath.Join(taskDir, "model"))
+	data, _ := os.ReadFile(filepath.Join(taskDir, "data"))
+	require.Equal(t, "{}", string(model))
+	require.Equal(t, "rows", string(data))
+	require.NoFileExists(t, filepath.Join(taskDir, "written"))
+	e.mu.RLock()
+	require.Empty(t, e.taskMounts)
+	e.mu.RUnlock()
+}
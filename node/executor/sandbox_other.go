@@ -0,0 +1,26 @@
+//go:build !linux
+
+package executor
+
+import "os/exec"
+
+type sandboxState struct{}
+
+// taskSandbox is a no-op outside Linux
+type taskSandbox struct{}
+
+func (e *Executor) prepareSandbox(taskID string, cmd *exec.Cmd) (*taskSandbox, error) {
+	return nil, nil
+}
+
+func (e *Executor) sandboxProcess(spec sandboxSpec, cmd *exec.Cmd) (*taskSandbox, error) {
+	return nil, nil
+}
+
+func (sb *taskSandbox) started() {}
+
+func (sb *taskSandbox) kill() {}
+
+func (sb *taskSandbox) finish() *ResourceUsage {
+	return nil
+}
@@ -0,0 +1,103 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/atlas/node/resource"
+)
+
+// DefaultTaskRequirements are reserved for tasks that declare no
+// requirements when the scheduling policy sets no default
+var DefaultTaskRequirements = resource.Requirements{CPU: 1, MemoryGB: 1}
+
+// ErrTaskTypeNotAccepted is returned for tasks of a type the scheduling
+// policy excludes
+var ErrTaskTypeNotAccepted = errors.New("task type not accepted by this node")
+
+// SchedulingPolicy limits which tasks the executor takes on and how many run
+// at once
+type SchedulingPolicy struct {
+	// TaskTypes lists the accepted task types; empty accepts all. Tasks
+	// without a type are training tasks.
+	TaskTypes []string
+
+	// MaxConcurrentTasks caps the number of running tasks (0: no limit
+	// beyond the node's resources)
+	MaxConcurrentTasks int
+
+	// DefaultRequirements are reserved for tasks that declare no
+	// requirements, by task type. Types without an entry reserve
+	// FallbackRequirements, or DefaultTaskRequirements if that is zero.
+	DefaultRequirements  map[string]resource.Requirements
+	FallbackRequirements resource.Requirements
+}
+
+// SetSchedulingPolicy sets the scheduling policy for tasks added and
+// admitted from now on
+func (e *Executor) SetSchedulingPolicy(policy SchedulingPolicy) {
+	e.mu.Lock()
+	e.policy = policy
+	e.mu.Unlock()
+	e.notify()
+}
+
+// acceptsLocked checks the task's type against the policy
+func (e *Executor) acceptsLocked(task *Task) error {
+	if len(e.policy.TaskTypes) == 0 {
+		return nil
+	}
+	taskType := task.TaskType
+	if taskType == "" {
+		taskType = "training"
+	}
+	for _, accepted := range e.policy.TaskTypes {
+		if accepted == taskType {
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot add task %s of type %s: %w", task.ID, taskType, ErrTaskTypeNotAccepted)
+}
+
+// requirementsFor returns the resources to reserve for a task: its declared
+// requirements, or the policy's default for its type
+func (e *Executor) requirementsFor(task *Task) resource.Requirements {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if task.Requirements != (resource.Requirements{}) {
+		return task.Requirements
+	}
+	taskType := task.TaskType
+	if taskType == "" {
+		taskType = "training"
+	}
+	if req, ok := e.policy.DefaultRequirements[taskType]; ok {
+		return req
+	}
+	if e.policy.FallbackRequirements != (resource.Requirements{}) {
+		return e.policy.FallbackRequirements
+	}
+	return DefaultTaskRequirements
+}
+
+// atConcurrencyLimit reports whether MaxConcurrentTasks tasks are running
+func (e *Executor) atConcurrencyLimit() bool {
+	e.mu.RLock()
+	limit := e.policy.MaxConcurrentTasks
+	e.mu.RUnlock()
+	return limit > 0 && e.RunningTasks() >= limit
+}
+
+// RunningTasks returns the number of tasks in progress
+func (e *Executor) RunningTasks() int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	running := 0
+	for _, task := range e.tasks {
+		if task.Status == "in_progress" {
+			running++
+		}
+	}
+	return running
+}
@@ -0,0 +1,49 @@
+package executor
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// The Python scripts run by the executor are embedded in the binary and
+// written to the task directory unchanged. They read their parameters from
+// params.json, so nothing taken from a task ever becomes Python source.
+// Bump a script's version whenever it changes; scripts report it in their
+// results.
+const (
+	trainingScriptVersion  = "training/4"
+	inferenceScriptVersion = "inference/6"
+	workerScriptVersion    = "worker/3"
+)
+
+//go:embed scripts/train.py
+var trainingScript []byte
+
+//go:embed scripts/inference.py
+var inferenceScript []byte
+
+//go:embed scripts/worker.py
+var workerScript []byte
+
+// scriptParamsFile is the parameter file scripts read from their working
+// directory. ATLAS_PARAMS_FILE overrides it.
+const scriptParamsFile = "params.json"
+
+// writeScript writes an embedded script to scriptPath and its parameters
+// to params.json next to it
+func writeScript(scriptPath string, script []byte, params interface{}) error {
+	data, err := json.MarshalIndent(params, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode script parameters: %w", err)
+	}
+
+	paramsPath := filepath.Join(filepath.Dir(scriptPath), scriptParamsFile)
+	if err := os.WriteFile(paramsPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write script parameters: %w", err)
+	}
+
+	return os.WriteFile(scriptPath, script, 0755)
+}
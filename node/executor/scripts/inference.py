@@ -0,0 +1,372 @@
+#!/usr/bin/env python3
+# Atlas inference script. All parameters are read from the JSON file named
+# by ATLAS_PARAMS_FILE (default: params.json in the working directory).
+import json
+import os
+import sys
+import time
+import torch
+import numpy as np
+from pathlib import Path
+
+SCRIPT_VERSION = "inference/6"
+
+def load_params():
+    with open(os.environ.get("ATLAS_PARAMS_FILE", "params.json"), "r") as f:
+        return json.load(f)
+
+# Progress and errors go to the executor as JSON lines on ATLAS_EVENTS_FD
+events = None
+if "ATLAS_EVENTS_FD" in os.environ:
+    events = os.fdopen(int(os.environ["ATLAS_EVENTS_FD"]), "w", buffering=1)
+
+def emit(event_type, **fields):
+    if events is None:
+        return
+    fields["type"] = event_type
+    events.write(json.dumps(fields) + "\n")
+
+def load_model(model_path):
+    """Load model from file"""
+    device = torch.device("cuda" if torch.cuda.is_available() else "cpu")
+    print(f"Loading model from: {model_path}", file=sys.stderr)
+    
+    if os.path.isdir(model_path):
+        model_files = [f for f in os.listdir(model_path) if f.endswith(('.pt', '.pth', '.onnx', '.h5'))]
+        if model_files:
+            model_path = os.path.join(model_path, model_files[0])
+        else:
+            raise FileNotFoundError(f"No model file found in directory: {model_path}")
+    
+    extension = Path(model_path).suffix.lower()
+    
+    if extension in ['.pt', '.pth']:
+        model_data = torch.load(model_path, map_location=device)
+        if isinstance(model_data, torch.nn.Module):
+            model = model_data.to(device)
+            model.eval()
+            return model, device, 'pytorch'
+        elif isinstance(model_data, dict):
+            if 'model' in model_data:
+                model = model_data['model'].to(device)
+                model.eval()
+                return model, device, 'pytorch'
+            else:
+                raise ValueError("Invalid PyTorch model format")
+        else:
+            raise ValueError(f"Unsupported PyTorch model type: {type(model_data)}")
+    
+    elif extension == '.onnx':
+        try:
+            import onnxruntime as ort
+            session = ort.InferenceSession(model_path)
+            return session, device, 'onnx'
+        except ImportError:
+            raise ImportError("ONNX Runtime not installed. Install with: pip install onnxruntime")
+    
+    elif extension == '.h5':
+        try:
+            import tensorflow as tf
+            model = tf.keras.models.load_model(model_path)
+            return model, device, 'tensorflow'
+        except ImportError:
+            raise ImportError("TensorFlow not installed. Install with: pip install tensorflow")
+    
+    else:
+        raise ValueError(f"Unsupported model format: {extension}")
+
+def prepare_input(input_data, model_type, framework):
+    """Prepare input data for model"""
+    if framework == 'pytorch':
+        if isinstance(input_data, list):
+            tensor = torch.tensor(input_data, dtype=torch.float32)
+        elif isinstance(input_data, dict):
+            if 'input_ids' in input_data:
+                tensor = torch.tensor(input_data['input_ids'], dtype=torch.long)
+            else:
+                tensor = torch.tensor(list(input_data.values())[0], dtype=torch.float32)
+        else:
+            tensor = torch.tensor(input_data, dtype=torch.float32)
+        
+        if tensor.dim() == 1:
+            tensor = tensor.unsqueeze(0)
+        
+        return tensor
+    
+    elif framework == 'onnx':
+        if isinstance(input_data, list):
+            array = np.array(input_data, dtype=np.float32)
+        elif isinstance(input_data, dict):
+            array = np.array(list(input_data.values())[0], dtype=np.float32)
+        else:
+            array = np.array(input_data, dtype=np.float32)
+        
+        if array.ndim == 1:
+            array = array.reshape(1, -1)
+        
+        return {list(input_data.keys())[0] if isinstance(input_data, dict) else 'input': array}
+    
+    elif framework == 'tensorflow':
+        if isinstance(input_data, list):
+            array = np.array(input_data, dtype=np.float32)
+        elif isinstance(input_data, dict):
+            array = np.array(list(input_data.values())[0], dtype=np.float32)
+        else:
+            array = np.array(input_data, dtype=np.float32)
+        
+        if array.ndim == 1:
+            array = array.reshape(1, -1)
+        
+        return array
+    
+    return input_data
+
+def run_inference(model, input_data, framework, device):
+    """Run inference on model"""
+    if framework == 'pytorch':
+        model_input = prepare_input(input_data, 'pytorch', framework)
+        model_input = model_input.to(device)
+        
+        with torch.no_grad():
+            output = model(model_input)
+        
+        if isinstance(output, torch.Tensor):
+            result = output.cpu().numpy().tolist()
+        elif isinstance(output, (list, tuple)):
+            result = [o.cpu().numpy().tolist() if isinstance(o, torch.Tensor) else o for o in output]
+        else:
+            result = output
+        
+        return result
+    
+    elif framework == 'onnx':
+        model_input = prepare_input(input_data, 'onnx', framework)
+        output_names = [output.name for output in model.get_outputs()]
+        outputs = model.run(output_names, model_input)
+        
+        if len(outputs) == 1:
+            result = outputs[0].tolist()
+        else:
+            result = [o.tolist() for o in outputs]
+        
+        return result
+    
+    elif framework == 'tensorflow':
+        model_input = prepare_input(input_data, 'tensorflow', framework)
+        output = model.predict(model_input, verbose=0)
+        return output.tolist()
+    
+    return None
+
+def load_text_model(model_path, operation):
+    """Load a Hugging Face model directory for text operations"""
+    from transformers import AutoModel, AutoModelForCausalLM, AutoTokenizer
+
+    device = torch.device("cuda" if torch.cuda.is_available() else "cpu")
+    print(f"Loading text model from: {model_path}", file=sys.stderr)
+    tokenizer = AutoTokenizer.from_pretrained(model_path)
+    if operation == 'embed':
+        model = AutoModel.from_pretrained(model_path)
+    else:
+        model = AutoModelForCausalLM.from_pretrained(model_path)
+    model.to(device)
+    model.eval()
+    return tokenizer, model, device
+
+def build_prompt(tokenizer, request, operation):
+    """Turn a generate or chat request into a prompt string"""
+    if operation != 'chat':
+        return request.get('prompt', '')
+    messages = request.get('messages', [])
+    if getattr(tokenizer, 'chat_template', None):
+        return tokenizer.apply_chat_template(messages, tokenize=False, add_generation_prompt=True)
+    lines = [f"{m['role']}: {m['content']}" for m in messages]
+    return "\n".join(lines) + "\nassistant:"
+
+def generation_kwargs(tokenizer, request):
+    """model.generate arguments for a request's sampling parameters"""
+    kwargs = {
+        'max_new_tokens': request.get('max_tokens') or 256,
+        'pad_token_id': tokenizer.pad_token_id if tokenizer.pad_token_id is not None else tokenizer.eos_token_id,
+    }
+    temperature = request.get('temperature')
+    if temperature is None or temperature > 0:
+        kwargs['do_sample'] = True
+        kwargs['temperature'] = temperature if temperature is not None else 1.0
+        kwargs['top_p'] = request.get('top_p') or 1.0
+    else:
+        kwargs['do_sample'] = False
+    return kwargs
+
+def completion(tokenizer, request, new_tokens, prompt_tokens, max_tokens):
+    """Decode generated tokens and apply the request's stop strings"""
+    text = tokenizer.decode(new_tokens, skip_special_tokens=True)
+
+    finish_reason = 'length' if len(new_tokens) >= max_tokens else 'stop'
+    for stop in request.get('stop') or []:
+        index = text.find(stop)
+        if index >= 0:
+            text = text[:index]
+            finish_reason = 'stop'
+
+    return {
+        "text": text,
+        "finish_reason": finish_reason,
+        "prompt_tokens": prompt_tokens,
+        "completion_tokens": int(len(new_tokens)),
+    }
+
+def streaming_kwargs(tokenizer, on_text=None, should_stop=None):
+    """model.generate arguments that report text as it is produced and
+    stop early once should_stop returns True"""
+    from transformers import StoppingCriteria, StoppingCriteriaList, TextStreamer
+
+    kwargs = {}
+    if on_text is not None:
+        class Streamer(TextStreamer):
+            def on_finalized_text(self, text, stream_end=False):
+                if text:
+                    on_text(text)
+
+        kwargs['streamer'] = Streamer(tokenizer, skip_prompt=True, skip_special_tokens=True)
+    if should_stop is not None:
+        class Stop(StoppingCriteria):
+            def __call__(self, input_ids, scores, **kwargs):
+                return should_stop()
+
+        kwargs['stopping_criteria'] = StoppingCriteriaList([Stop()])
+    return kwargs
+
+def generate(tokenizer, model, device, request, operation, on_text=None, should_stop=None):
+    """Generate a completion for a generate or chat request. on_text, if
+    set, receives the text as it is generated."""
+    prompt = build_prompt(tokenizer, request, operation)
+    inputs = tokenizer(prompt, return_tensors='pt').to(device)
+    prompt_tokens = int(inputs['input_ids'].shape[1])
+
+    kwargs = generation_kwargs(tokenizer, request)
+    with torch.no_grad():
+        output = model.generate(**inputs, **kwargs, **streaming_kwargs(tokenizer, on_text, should_stop))
+    new_tokens = output[0][prompt_tokens:]
+    return completion(tokenizer, request, new_tokens, prompt_tokens, kwargs['max_new_tokens'])
+
+def generate_batch(tokenizer, model, device, requests, operation, should_stop=None):
+    """Generate completions for requests sharing sampling parameters in one
+    model.generate call"""
+    if len(requests) == 1:
+        return [generate(tokenizer, model, device, requests[0], operation, should_stop=should_stop)]
+
+    prompts = [build_prompt(tokenizer, request, operation) for request in requests]
+    if tokenizer.pad_token is None:
+        tokenizer.pad_token = tokenizer.eos_token
+    # Decoder-only models continue from the right, so pad on the left
+    tokenizer.padding_side = 'left'
+    inputs = tokenizer(prompts, return_tensors='pt', padding=True).to(device)
+    width = int(inputs['input_ids'].shape[1])
+
+    kwargs = generation_kwargs(tokenizer, requests[0])
+    with torch.no_grad():
+        output = model.generate(**inputs, **kwargs, **streaming_kwargs(tokenizer, should_stop=should_stop))
+
+    results = []
+    for i, request in enumerate(requests):
+        new_tokens = output[i][width:]
+        # Shorter completions are padded to the longest one
+        new_tokens = new_tokens[new_tokens != tokenizer.pad_token_id]
+        prompt_tokens = int(inputs['attention_mask'][i].sum())
+        results.append(completion(tokenizer, request, new_tokens, prompt_tokens, kwargs['max_new_tokens']))
+    return results
+
+def embed_texts(tokenizer, model, device, texts):
+    """Mean-pooled, normalized embeddings and token counts for texts"""
+    inputs = tokenizer(texts, padding=True, truncation=True, return_tensors='pt').to(device)
+    with torch.no_grad():
+        hidden = model(**inputs).last_hidden_state
+    mask = inputs['attention_mask'].unsqueeze(-1).float()
+    pooled = (hidden * mask).sum(1) / mask.sum(1).clamp(min=1e-9)
+    pooled = torch.nn.functional.normalize(pooled, p=2, dim=1)
+    return pooled.cpu().tolist(), inputs['attention_mask'].sum(1).tolist()
+
+def embed(tokenizer, model, device, request):
+    """Mean-pooled, normalized embeddings for each input"""
+    return embed_batch(tokenizer, model, device, [request])[0]
+
+def embed_batch(tokenizer, model, device, requests):
+    """Embeddings for several requests computed in one forward pass"""
+    texts = [text for request in requests for text in request.get('inputs', [])]
+    vectors, tokens = embed_texts(tokenizer, model, device, texts) if texts else ([], [])
+
+    results = []
+    offset = 0
+    for request in requests:
+        count = len(request.get('inputs', []))
+        results.append({
+            "embeddings": vectors[offset:offset + count],
+            "prompt_tokens": int(sum(tokens[offset:offset + count])),
+        })
+        offset += count
+    return results
+
+def main():
+    params = load_params()
+    model_path = params['model_path']
+    input_path = params['input_path']
+    output_path = params['output_path']
+    model_type = params.get('model_type', 'auto')
+    
+    try:
+        print("=" * 50, file=sys.stderr)
+        print("Atlas Inference Script", file=sys.stderr)
+        print("=" * 50, file=sys.stderr)
+        
+        with open(input_path, 'r') as f:
+            input_data = json.load(f)
+        
+        data = input_data.get('data', input_data)
+        operation = input_data.get('operation') or 'predict'
+        
+        print(f"Loading model...", file=sys.stderr)
+        if operation in ('generate', 'chat', 'embed'):
+            tokenizer, model, device = load_text_model(model_path, operation)
+            framework = 'transformers'
+        elif operation == 'predict':
+            model, device, framework = load_model(model_path)
+        else:
+            raise ValueError(f"Unsupported operation: {operation}")
+        print(f"Model loaded on {device}, framework: {framework}", file=sys.stderr)
+        emit("progress", progress=0.5)
+        
+        print(f"Running {operation}...", file=sys.stderr)
+        start_time = time.time()
+        if operation == 'embed':
+            result = embed(tokenizer, model, device, data)
+        elif operation in ('generate', 'chat'):
+            result = generate(tokenizer, model, device, data, operation)
+        else:
+            result = run_inference(model, data, framework, device)
+        inference_time = (time.time() - start_time) * 1000
+        
+        print(f"Inference completed in {inference_time:.2f}ms", file=sys.stderr)
+        
+        output = {
+            "result": result,
+            "latency_ms": int(inference_time),
+            "framework": framework,
+            "script_version": SCRIPT_VERSION
+        }
+        
+        with open(output_path, 'w') as f:
+            json.dump(output, f, indent=2)
+        
+        print(f"Output saved to {output_path}", file=sys.stderr)
+        sys.exit(0)
+    
+    except Exception as e:
+        print(f"Inference failed: {e}", file=sys.stderr)
+        emit("error", message=str(e))
+        import traceback
+        traceback.print_exc(file=sys.stderr)
+        sys.exit(1)
+
+if __name__ == '__main__':
+    main()
@@ -0,0 +1,86 @@
+#!/usr/bin/env python3
+# Atlas training script. All parameters are read from the JSON file named
+# by ATLAS_PARAMS_FILE (default: params.json in the working directory).
+import json
+import os
+import signal
+import sys
+import torch
+import torch.nn as nn
+from torch.utils.data import DataLoader
+
+SCRIPT_VERSION = "training/4"
+
+
+def load_params():
+    with open(os.environ.get("ATLAS_PARAMS_FILE", "params.json"), "r") as f:
+        return json.load(f)
+
+
+# Progress and metrics go to the executor as JSON lines on ATLAS_EVENTS_FD
+events = None
+if "ATLAS_EVENTS_FD" in os.environ:
+    events = os.fdopen(int(os.environ["ATLAS_EVENTS_FD"]), "w", buffering=1)
+
+
+def emit(event_type, **fields):
+    if events is None:
+        return
+    fields["type"] = event_type
+    events.write(json.dumps(fields) + "\n")
+
+
+params = load_params()
+model_path = params["model_path"]
+dataset_path = params["dataset_path"]
+# A resumed run continues after the epoch its checkpoint was taken at. A
+# replay of one segment stops at the segment's end, stop_epoch (and
+# stop_step, for scripts that checkpoint within epochs), and writes no
+# result.
+start_epoch = params.get("start_epoch", 0)
+stop_epoch = params.get("stop_epoch")
+
+# The executor sends SIGUSR1 to pause: checkpoint and exit
+pause_requested = False
+
+def request_pause(signum, frame):
+    global pause_requested
+    pause_requested = True
+
+signal.signal(signal.SIGUSR1, request_pause)
+
+# Load model
+model = torch.load(model_path)
+
+# Resume from checkpoint if one was restored
+if os.path.exists('resume_checkpoint.pt'):
+    model.load_state_dict(torch.load('resume_checkpoint.pt'))
+
+# Load dataset
+# dataset = load_dataset(dataset_path)
+
+# Training loop
+optimizer = torch.optim.Adam(model.parameters())
+criterion = nn.CrossEntropyLoss()
+
+epochs = 10
+for epoch in range(start_epoch, epochs):
+    if stop_epoch is not None and epoch >= stop_epoch:
+        torch.save(model.state_dict(), 'checkpoint.pt')
+        emit("checkpoint", path="checkpoint.pt", epoch=epoch)
+        sys.exit(0)
+    # Training code here
+    if pause_requested:
+        torch.save(model.state_dict(), 'checkpoint.pt')
+        emit("checkpoint", path="checkpoint.pt", epoch=epoch)
+        sys.exit(0)
+    emit("progress", progress=(epoch + 1) / epochs, epoch=epoch + 1)
+
+# Save checkpoint
+torch.save(model.state_dict(), 'checkpoint.pt')
+emit("checkpoint", path="checkpoint.pt", epoch=epochs)
+if stop_epoch is not None:
+    sys.exit(0)
+
+with open('result.json', 'w') as f:
+    json.dump({"script_version": SCRIPT_VERSION, "checkpoint": "checkpoint.pt"}, f)
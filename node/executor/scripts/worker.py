@@ -0,0 +1,223 @@
+#!/usr/bin/env python3
+# Atlas model worker. Loads a model once and serves inference requests until
+# told to shut down. Parameters are read from the JSON file named by
+# ATLAS_PARAMS_FILE (default: params.json in the working directory).
+#
+# Protocol: one JSON request per line on stdin, one JSON response per line
+# on stdout. Everything else the worker or its libraries print goes to
+# stderr. A "batch" request carries the data of several requests for the
+# same operation and is answered with one result per request, in order. A
+# generation with "stream" set sends its text as "token" messages before
+# its response. A "cancel" message stops the request it targets.
+import json
+import os
+import queue
+import sys
+import threading
+import time
+import traceback
+
+SCRIPT_VERSION = "worker/3"
+
+# Keep the real stdout for protocol messages only
+protocol = os.fdopen(os.dup(1), "w", buffering=1)
+os.dup2(2, 1)
+sys.stdout = sys.stderr
+
+import inference  # noqa: E402  (imports torch, which may print)
+
+def send(message):
+    protocol.write(json.dumps(message) + "\n")
+
+class Cancelled(Exception):
+    pass
+
+class Stream:
+    """Sends a generation's text as it is produced. Text that could be the
+    start of a stop string is held back until it is known not to be, and
+    generation stops once a stop string appears."""
+
+    def __init__(self, request_id, stops):
+        self.request_id = request_id
+        self.stops = [stop for stop in stops or [] if stop]
+        self.text = ''
+        self.sent = 0
+        self.stopped = False
+
+    def on_text(self, delta):
+        if self.stopped:
+            return
+        self.text += delta
+        for stop in self.stops:
+            index = self.text.find(stop)
+            if index >= 0:
+                self.text = self.text[:index]
+                self.stopped = True
+        hold = 0 if self.stopped else max([len(stop) - 1 for stop in self.stops] or [0])
+        self.emit(max(self.sent, len(self.text) - hold))
+
+    def finish(self, text):
+        """Send whatever of the final text has not been sent yet"""
+        if text.startswith(self.text[:self.sent]):
+            self.text = text
+            self.emit(len(text))
+
+    def emit(self, end):
+        if end > self.sent:
+            send({"id": self.request_id, "type": "token", "text": self.text[self.sent:end]})
+            self.sent = end
+
+def load_params():
+    with open(os.environ.get("ATLAS_PARAMS_FILE", "params.json"), "r") as f:
+        return json.load(f)
+
+class Worker:
+    """Holds the loaded model, one instance per kind of operation"""
+
+    def __init__(self, model_path):
+        self.model_path = model_path
+        self.models = {}
+
+    def load(self, operation):
+        kind = operation if operation in ('predict', 'embed') else 'generate'
+        if kind not in self.models:
+            if kind == 'predict':
+                self.models[kind] = inference.load_model(self.model_path)
+            else:
+                self.models[kind] = inference.load_text_model(self.model_path, operation)
+        return self.models[kind]
+
+    def handle(self, request, should_stop=None):
+        operation = request.get('operation') or 'predict'
+        data = request.get('data')
+        if operation == 'predict':
+            model, device, framework = self.load(operation)
+            return inference.run_inference(model, data, framework, device)
+        if operation in ('generate', 'chat'):
+            tokenizer, model, device = self.load(operation)
+            if not request.get('stream'):
+                return inference.generate(tokenizer, model, device, data, operation, should_stop=should_stop)
+            stream = Stream(request.get('id'), data.get('stop'))
+            result = inference.generate(tokenizer, model, device, data, operation,
+                                        on_text=stream.on_text,
+                                        should_stop=lambda: stream.stopped or should_stop())
+            stream.finish(result['text'])
+            return result
+        if operation == 'embed':
+            tokenizer, model, device = self.load(operation)
+            return inference.embed(tokenizer, model, device, data)
+        raise ValueError(f"Unsupported operation: {operation}")
+
+    def handle_batch(self, request, should_stop=None):
+        operation = request.get('operation') or 'predict'
+        items = request.get('requests') or []
+        if operation in ('generate', 'chat'):
+            tokenizer, model, device = self.load(operation)
+            results = inference.generate_batch(tokenizer, model, device, items, operation, should_stop=should_stop)
+            return [{"ok": True, "result": result} for result in results]
+        if operation == 'embed':
+            tokenizer, model, device = self.load(operation)
+            results = inference.embed_batch(tokenizer, model, device, items)
+            return [{"ok": True, "result": result} for result in results]
+
+        # Tensor inputs have no common shape; run them one at a time
+        results = []
+        for data in items:
+            try:
+                results.append({"ok": True, "result": self.handle({'operation': operation, 'data': data})})
+            except Exception as e:
+                traceback.print_exc(file=sys.stderr)
+                results.append({"ok": False, "error": str(e)})
+        return results
+
+def main():
+    params = load_params()
+    worker = Worker(params['model_path'])
+
+    preload = params.get('preload')
+    if preload:
+        try:
+            worker.load(preload)
+        except Exception as e:
+            traceback.print_exc(file=sys.stderr)
+            send({"type": "ready", "ok": False, "error": str(e)})
+            sys.exit(1)
+    send({"type": "ready", "ok": True, "script_version": SCRIPT_VERSION})
+
+    # Requests are read on their own thread so a cancel can arrive while a
+    # request runs
+    requests = queue.Queue()
+    cancelled = set()
+    threading.Thread(target=read_requests, args=(requests, cancelled), daemon=True).start()
+
+    while True:
+        request = requests.get()
+        if request is None:
+            break
+
+        request_id = request.get('id')
+        request_type = request.get('type', 'infer')
+        if request_type == 'ping':
+            send({"id": request_id, "ok": True})
+            continue
+
+        def should_stop():
+            return request_id in cancelled
+
+        start_time = time.time()
+        try:
+            if should_stop():
+                raise Cancelled()
+            if request_type == 'batch':
+                response = {"id": request_id, "ok": True, "results": worker.handle_batch(request, should_stop)}
+            else:
+                response = {"id": request_id, "ok": True, "result": worker.handle(request, should_stop)}
+            if should_stop():
+                raise Cancelled()
+        except Cancelled:
+            response = {"id": request_id, "ok": False, "error": "cancelled"}
+        except Exception as e:
+            traceback.print_exc(file=sys.stderr)
+            response = {"id": request_id, "ok": False, "error": str(e)}
+        finally:
+            forget_cancelled(cancelled, request_id)
+        response["latency_ms"] = int((time.time() - start_time) * 1000)
+        send(response)
+
+def forget_cancelled(cancelled, request_id):
+    """Drop cancellations of requests that have been answered. Request IDs
+    increase, so that is every ID up to the one just handled."""
+    try:
+        handled = int(request_id)
+    except (TypeError, ValueError):
+        cancelled.discard(request_id)
+        return
+    for target in list(cancelled):
+        try:
+            if int(target) <= handled:
+                cancelled.discard(target)
+        except (TypeError, ValueError):
+            cancelled.discard(target)
+
+def read_requests(requests, cancelled):
+    """Queue requests from stdin; record cancellations as they arrive"""
+    for line in sys.stdin:
+        line = line.strip()
+        if not line:
+            continue
+        try:
+            request = json.loads(line)
+        except ValueError:
+            continue
+
+        request_type = request.get('type', 'infer')
+        if request_type == 'shutdown':
+            break
+        if request_type == 'cancel':
+            cancelled.add(request.get('target'))
+            continue
+        requests.put(request)
+    requests.put(None)
+
+if __name__ == '__main__':
+    main()
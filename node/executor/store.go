@@ -0,0 +1,354 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/atlas/node/proof"
+	"github.com/atlas/node/resource"
+)
+
+// TaskStore persists executor tasks so they survive node restarts.
+type TaskStore interface {
+	Save(task *Task) error
+	Delete(taskID string) error
+	Load() ([]*Task, error)
+	Close() error
+}
+
+// taskRecord is the on-disk form of a Task. Task.Error is an error value,
+// so it is stored as its message.
+type taskRecord struct {
+	ID            string                    `json:"id"`
+	JobID         string                    `json:"job_id"`
+	ShardID       string                    `json:"shard_id"`
+	Status        string                    `json:"status"`
+	Progress      float64                   `json:"progress"`
+	CheckpointCID string                    `json:"checkpoint_cid,omitempty"`
+	ResumeEpoch   int                       `json:"resume_epoch,omitempty"`
+	TaskType      string                    `json:"task_type"`
+	ModelPath     string                    `json:"model_path"`
+	DatasetPath   string                    `json:"dataset_path"`
+	InputData     []byte                    `json:"input_data,omitempty"`
+	OutputData    []byte                    `json:"output_data,omitempty"`
+	CreatedAt     time.Time                 `json:"created_at"`
+	StartedAt     *time.Time                `json:"started_at,omitempty"`
+	CompletedAt   *time.Time                `json:"completed_at,omitempty"`
+	Error         string                    `json:"error,omitempty"`
+	Requirements  resource.Requirements     `json:"requirements"`
+	Priority      int                       `json:"priority,omitempty"`
+	Usage         *ResourceUsage            `json:"usage,omitempty"`
+	Metadata      map[string]string         `json:"metadata,omitempty"`
+	Proof         *proof.ProofOfComputation `json:"proof,omitempty"`
+}
+
+type journalEntry struct {
+	Op     string      `json:"op"` // "put" or "delete"
+	TaskID string      `json:"task_id"`
+	Task   *taskRecord `json:"task,omitempty"`
+}
+
+// compactMinEntries keeps Save from rewriting small journals over and over.
+const compactMinEntries = 64
+
+// JournalStore is an append-only JSON-lines journal of task snapshots.
+// The journal is compacted on open, and by Save once it holds more than
+// compactMinEntries entries, whenever it holds more than twice as many
+// entries as live tasks.
+type JournalStore struct {
+	path    string
+	file    *os.File
+	entries int
+	live    map[string]bool
+	mu      sync.Mutex
+}
+
+func NewJournalStore(path string) (*JournalStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	s := &JournalStore{
+		path: path,
+		live: make(map[string]bool),
+	}
+
+	tasks, entries, size, err := s.replay()
+	if err != nil {
+		return nil, err
+	}
+	// Drop a torn final line so appends start on a line of their own
+	if info, err := os.Stat(path); err == nil && info.Size() > size {
+		if err := os.Truncate(path, size); err != nil {
+			return nil, fmt.Errorf("failed to truncate torn task journal entry: %w", err)
+		}
+	}
+	s.entries = entries
+	for _, task := range tasks {
+		s.live[task.ID] = true
+	}
+
+	if s.entries > 2*len(s.live) {
+		if err := s.compact(tasks); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open task journal: %w", err)
+	}
+	s.file = file
+
+	return s, nil
+}
+
+func (s *JournalStore) Save(task *Task) error {
+	return s.append(journalEntry{Op: "put", TaskID: task.ID, Task: newTaskRecord(task)})
+}
+
+func (s *JournalStore) Delete(taskID string) error {
+	return s.append(journalEntry{Op: "delete", TaskID: taskID})
+}
+
+// Load returns the latest snapshot of every task in the journal.
+func (s *JournalStore) Load() ([]*Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tasks, _, _, err := s.replay()
+	return tasks, err
+}
+
+func (s *JournalStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (s *JournalStore) append(entry journalEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal entry: %w", err)
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return fmt.Errorf("task journal is closed")
+	}
+	if _, err := s.file.Write(data); err != nil {
+		return fmt.Errorf("failed to append to task journal: %w", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync task journal: %w", err)
+	}
+
+	s.entries++
+	if entry.Op == "delete" {
+		delete(s.live, entry.TaskID)
+	} else {
+		s.live[entry.TaskID] = true
+	}
+
+	if s.entries > compactMinEntries && s.entries > 2*len(s.live) {
+		return s.compactOpen()
+	}
+	return nil
+}
+
+// compactOpen compacts the journal while it is open for appending and
+// reopens it. The caller must hold s.mu.
+func (s *JournalStore) compactOpen() error {
+	tasks, _, _, err := s.replay()
+	if err != nil {
+		return err
+	}
+	if err := s.compact(tasks); err != nil {
+		return err
+	}
+
+	s.file.Close()
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		s.file = nil
+		return fmt.Errorf("failed to reopen task journal: %w", err)
+	}
+	s.file = file
+	return nil
+}
+
+// replay reads the journal and returns the surviving tasks in the order they
+// were first written, along with the number of entries read and the size
+// of the journal up to its last complete line. An unterminated final line
+// (e.g. from a crash mid-write) is torn and ignored; any other line that
+// does not parse is an error.
+func (s *JournalStore) replay() ([]*Task, int, int64, error) {
+	file, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, 0, nil
+	}
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to open task journal: %w", err)
+	}
+	defer file.Close()
+
+	records := make(map[string]*taskRecord)
+	var order []string
+	entries := 0
+	var size int64
+
+	reader := bufio.NewReaderSize(file, 64*1024)
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("failed to read task journal: %w", err)
+		}
+		size += int64(len(line))
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		var entry journalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, 0, 0, fmt.Errorf("corrupt task journal entry on line %d: %w", lineNumber, err)
+		}
+		entries++
+
+		switch entry.Op {
+		case "put":
+			if entry.Task == nil {
+				continue
+			}
+			if _, ok := records[entry.TaskID]; !ok {
+				order = append(order, entry.TaskID)
+			}
+			records[entry.TaskID] = entry.Task
+		case "delete":
+			delete(records, entry.TaskID)
+		}
+	}
+
+	tasks := make([]*Task, 0, len(records))
+	for _, id := range order {
+		if record, ok := records[id]; ok {
+			tasks = append(tasks, record.toTask())
+		}
+	}
+
+	return tasks, entries, size, nil
+}
+
+// compact rewrites the journal with a single entry per live task.
+func (s *JournalStore) compact(tasks []*Task) error {
+	tmpPath := s.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create compacted journal: %w", err)
+	}
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for _, task := range tasks {
+		if err := encoder.Encode(journalEntry{Op: "put", TaskID: task.ID, Task: newTaskRecord(task)}); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to write compacted journal: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write compacted journal: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync compacted journal: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace task journal: %w", err)
+	}
+
+	s.entries = len(tasks)
+	return nil
+}
+
+func newTaskRecord(task *Task) *taskRecord {
+	record := &taskRecord{
+		ID:            task.ID,
+		JobID:         task.JobID,
+		ShardID:       task.ShardID,
+		Status:        task.Status,
+		Progress:      task.Progress,
+		CheckpointCID: task.CheckpointCID,
+		ResumeEpoch:   task.ResumeEpoch,
+		TaskType:      task.TaskType,
+		ModelPath:     task.ModelPath,
+		DatasetPath:   task.DatasetPath,
+		InputData:     task.InputData,
+		OutputData:    task.OutputData,
+		CreatedAt:     task.CreatedAt,
+		StartedAt:     task.StartedAt,
+		CompletedAt:   task.CompletedAt,
+		Requirements:  task.Requirements,
+		Priority:      task.Priority,
+		Usage:         task.Usage,
+		Metadata:      task.Metadata,
+		Proof:         task.Proof,
+	}
+	if task.Error != nil {
+		record.Error = task.Error.Error()
+	}
+	return record
+}
+
+func (r *taskRecord) toTask() *Task {
+	task := &Task{
+		ID:            r.ID,
+		JobID:         r.JobID,
+		ShardID:       r.ShardID,
+		Status:        r.Status,
+		Progress:      r.Progress,
+		CheckpointCID: r.CheckpointCID,
+		ResumeEpoch:   r.ResumeEpoch,
+		TaskType:      r.TaskType,
+		ModelPath:     r.ModelPath,
+		DatasetPath:   r.DatasetPath,
+		InputData:     r.InputData,
+		OutputData:    r.OutputData,
+		CreatedAt:     r.CreatedAt,
+		StartedAt:     r.StartedAt,
+		CompletedAt:   r.CompletedAt,
+		Requirements:  r.Requirements,
+		Priority:      r.Priority,
+		Usage:         r.Usage,
+		Metadata:      r.Metadata,
+		Proof:         r.Proof,
+	}
+	if r.Error != "" {
+		task.Error = errors.New(r.Error)
+	}
+	return task
+}
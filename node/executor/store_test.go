@@ -0,0 +1,185 @@
+package executor
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournalStore_SaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.journal")
+
+	store, err := NewJournalStore(path)
+	require.NoError(t, err)
+
+	task := &Task{ID: "task-1", JobID: "job-1", Status: "pending", TaskType: "training"}
+	require.NoError(t, store.Save(task))
+
+	task.Status = "failed"
+	task.Error = errors.New("boom")
+	require.NoError(t, store.Save(task))
+
+	require.NoError(t, store.Save(&Task{ID: "task-2", Status: "pending"}))
+	require.NoError(t, store.Delete("task-2"))
+	require.NoError(t, store.Close())
+
+	reopened, err := NewJournalStore(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	tasks, err := reopened.Load()
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	require.Equal(t, "task-1", tasks[0].ID)
+	require.Equal(t, "failed", tasks[0].Status)
+	require.EqualError(t, tasks[0].Error, "boom")
+}
+
+func TestJournalStore_CompactsOnOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.journal")
+
+	store, err := NewJournalStore(path)
+	require.NoError(t, err)
+
+	task := &Task{ID: "task-1", Status: "pending"}
+	for i := 0; i < 10; i++ {
+		task.Progress = float64(i) / 10
+		require.NoError(t, store.Save(task))
+	}
+	require.NoError(t, store.Close())
+
+	reopened, err := NewJournalStore(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+	require.Equal(t, 1, reopened.entries)
+
+	tasks, err := reopened.Load()
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	require.InDelta(t, 0.9, tasks[0].Progress, 1e-9)
+}
+
+func TestJournalStore_CompactsOnSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.journal")
+
+	store, err := NewJournalStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+
+	tasks := []*Task{{ID: "task-1", Status: "running"}, {ID: "task-2", Status: "running"}}
+	for i := 0; i < 1000; i++ {
+		task := tasks[i%len(tasks)]
+		task.Progress = float64(i) / 1000
+		require.NoError(t, store.Save(task))
+		require.LessOrEqual(t, store.entries, compactMinEntries)
+	}
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, store.entries, bytes.Count(data, []byte("\n")))
+
+	loaded, err := store.Load()
+	require.NoError(t, err)
+	require.Len(t, loaded, 2)
+	require.InDelta(t, 0.999, loaded[1].Progress, 1e-9)
+}
+
+func TestJournalStore_IgnoresTruncatedTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.journal")
+
+	store, err := NewJournalStore(path)
+	require.NoError(t, err)
+	require.NoError(t, store.Save(&Task{ID: "task-1", Status: "pending"}))
+	require.NoError(t, store.Close())
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"op":"put","task_id":"task-2","task":{"id":`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	reopened, err := NewJournalStore(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	tasks, err := reopened.Load()
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+}
+
+func TestJournalStore_AppendsAfterTruncatedTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.journal")
+
+	store, err := NewJournalStore(path)
+	require.NoError(t, err)
+	require.NoError(t, store.Save(&Task{ID: "task-1", Status: "pending"}))
+	require.NoError(t, store.Close())
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"op":"put","task_id":"task-2","ta`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	// Entries saved after the torn line survive the next open
+	reopened, err := NewJournalStore(path)
+	require.NoError(t, err)
+	require.NoError(t, reopened.Save(&Task{ID: "task-3", Status: "pending"}))
+	require.NoError(t, reopened.Save(&Task{ID: "task-4", Status: "pending"}))
+	require.NoError(t, reopened.Close())
+
+	reopened, err = NewJournalStore(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+	tasks, err := reopened.Load()
+	require.NoError(t, err)
+	require.Len(t, tasks, 3)
+	require.Equal(t, "task-4", tasks[2].ID)
+}
+
+func TestJournalStore_RejectsCorruptEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.journal")
+	require.NoError(t, os.WriteFile(path, []byte(`{"op":"put","task_id":"task-1","task":{"id":"task-1"}}`+"\n{not json}\n"+`{"op":"delete","task_id":"task-1"}`+"\n"), 0644))
+
+	_, err := NewJournalStore(path)
+	require.ErrorContains(t, err, "line 2")
+}
+
+func TestExecutor_RecoverTasks(t *testing.T) {
+	workDir := t.TempDir()
+
+	first := NewExecutor(nil)
+	first.SetWorkDir(workDir)
+	require.NoError(t, first.OpenTaskStore())
+
+	require.NoError(t, first.AddTask(&Task{ID: "done", Status: "completed"}))
+	require.NoError(t, first.AddTask(&Task{ID: "running", Status: "in_progress", CheckpointCID: "QmCheckpoint"}))
+	require.NoError(t, first.AddTask(&Task{ID: "queued"}))
+	first.recordEvent("running", TaskEvent{Type: EventCheckpoint, Epoch: 3})
+	first.store.Close()
+
+	second := NewExecutor(nil)
+	second.SetWorkDir(workDir)
+	require.NoError(t, second.OpenTaskStore())
+	defer second.Stop()
+
+	interrupted, err := second.RecoverTasks()
+	require.NoError(t, err)
+	require.Len(t, interrupted, 1)
+	require.Equal(t, "running", interrupted[0].ID)
+	require.Equal(t, "paused", interrupted[0].Status)
+	require.Len(t, second.ListTasks(), 3)
+
+	require.NoError(t, second.ResumeTask("running"))
+	task, err := second.GetTask("running")
+	require.NoError(t, err)
+	require.Equal(t, "pending", task.Status)
+	require.Equal(t, "QmCheckpoint", task.CheckpointCID)
+	require.Equal(t, 3, second.resumeEpoch("running"))
+
+	require.Error(t, second.ResumeTask("done"))
+}
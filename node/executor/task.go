@@ -2,10 +2,22 @@ package executor
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"sync"
 	"time"
+
+	"github.com/atlas/node/proof"
+	"github.com/atlas/node/resource"
+	"github.com/atlas/storage/cache"
+	"github.com/atlas/storage/identity"
+	"github.com/atlas/storage/manager"
+	"github.com/atlas/storage/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Task struct {
@@ -15,6 +27,7 @@ type Task struct {
 	Status        string
 	Progress      float64
 	CheckpointCID string
+	ResumeEpoch   int    // Epoch of the last checkpoint the task reported
 	TaskType      string // "training", "inference", etc.
 	ModelPath     string // IPFS CID or local path
 	DatasetPath   string // IPFS CID or local path
@@ -24,30 +37,75 @@ type Task struct {
 	StartedAt     *time.Time
 	CompletedAt   *time.Time
 	Error         error
+	Requirements  resource.Requirements     // Resources reserved while the task runs
+	Priority      int                       // Higher priority tasks are admitted first
+	Usage         *ResourceUsage            // Resources consumed, when sandboxed
+	Metadata      map[string]string         // Runtime selection and runtime-specific settings
+	Proof         *proof.ProofOfComputation // Signed when the task completes, if proofs are enabled
 }
 
 type Executor struct {
-	resourceManager   interface{}
+	resourceManager   ResourceAllocator
 	tasks             map[string]*Task
 	trainingExecutor  *TrainingExecutor
 	inferenceExecutor *InferenceExecutor
 	workDir           string
 	ipfsAPIURL        string
+	ipfsFallbacks     []string
+	artifacts         *cache.Cache
+	observer          Observer
+	store             TaskStore
+	handles           map[string]*taskHandle
+	events            map[string][]TaskEvent
+	logs              map[string]*rotatingLog
+	logConfig         LogConfig
+	workerConfig      WorkerPoolConfig
+	batchConfig       BatchConfig
+	wake              chan struct{}
+	runtimes          map[string]Runtime
+	runTask           func(ctx context.Context, task *Task)
+	sandbox           SandboxConfig
+	sandboxState      sandboxState
+	taskMounts        map[string][]string // Staged artifacts each task's sandbox mounts read-only
+	policy            SchedulingPolicy
+	draining          bool
+	nodeID            string
+	signer            *identity.Identity // Signs proofs of computation if set
+	publish           func(ctx context.Context, path string) (string, error)
+	uploads           map[string][]*checkpointUpload // Checkpoints each task is publishing, in the order reported
 	mu                sync.RWMutex
 	ctx               context.Context
 	cancel            context.CancelFunc
 }
 
-func NewExecutor(resourceManager interface{}) *Executor {
+// NewExecutor creates an executor. Tasks are admitted only when
+// resourceManager can reserve their requirements; a nil resourceManager
+// disables admission control.
+func NewExecutor(resourceManager ResourceAllocator) *Executor {
 	ctx, cancel := context.WithCancel(context.Background())
-	return &Executor{
+	e := &Executor{
 		resourceManager: resourceManager,
 		tasks:          make(map[string]*Task),
+		handles:        make(map[string]*taskHandle),
+		events:         make(map[string][]TaskEvent),
+		logs:           make(map[string]*rotatingLog),
+		logConfig:      LogConfig{MaxSize: DefaultLogMaxSize, MaxFiles: DefaultLogMaxFiles},
+		workerConfig:   DefaultWorkerPoolConfig(),
+		batchConfig:    DefaultBatchConfig(),
+		runtimes:       make(map[string]Runtime),
+		taskMounts:     make(map[string][]string),
+		uploads:        make(map[string][]*checkpointUpload),
 		workDir:        "/tmp/atlas-tasks",
 		ipfsAPIURL:     "/ip4/127.0.0.1/tcp/5001",
+		wake:           make(chan struct{}, 1),
 		ctx:            ctx,
 		cancel:         cancel,
 	}
+	e.runtimes["python"] = NewPythonRuntime(e)
+	e.runtimes["command"] = NewCommandRuntime(nil)
+	e.runTask = e.runWithRuntime
+	e.publish = e.publishCheckpoint
+	return e
 }
 
 // SetWorkDir sets the working directory for tasks
@@ -57,11 +115,118 @@ func (e *Executor) SetWorkDir(workDir string) {
 	e.workDir = workDir
 }
 
-// SetIPFSAPIURL sets the IPFS API URL
-func (e *Executor) SetIPFSAPIURL(ipfsAPIURL string) {
+// SetIPFSAPIURL sets the IPFS API URL and the nodes tried when it fails
+func (e *Executor) SetIPFSAPIURL(ipfsAPIURL string, fallbackURLs ...string) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	e.ipfsAPIURL = ipfsAPIURL
+	e.ipfsFallbacks = fallbackURLs
+}
+
+// SetArtifactCache sets the cache models and datasets given by CID are
+// fetched into. Without one, a cache without a quota is opened under the
+// working directory on first use.
+func (e *Executor) SetArtifactCache(artifacts *cache.Cache) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.artifacts = artifacts
+}
+
+// ArtifactCache returns the artifact cache, opening the default one if none
+// was set
+func (e *Executor) ArtifactCache() (*cache.Cache, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.artifacts == nil {
+		artifacts, err := cache.Open(filepath.Join(e.workDir, "cache"), 0, manager.NewIPFSManager(e.ipfsAPIURL, e.ipfsFallbacks...))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open artifact cache: %w", err)
+		}
+		e.artifacts = artifacts
+	}
+	return e.artifacts, nil
+}
+
+// stageArtifact returns a local path for path, a CID or a local path. CIDs
+// are fetched into the artifact cache and stay pinned until release is
+// called.
+func (e *Executor) stageArtifact(ctx context.Context, path string) (string, func(), error) {
+	if !cache.IsCID(path) {
+		if _, err := os.Stat(path); err != nil {
+			return "", nil, fmt.Errorf("local file not found: %s", path)
+		}
+		return path, func() {}, nil
+	}
+
+	artifacts, err := e.ArtifactCache()
+	if err != nil {
+		return "", nil, err
+	}
+	handle, err := artifacts.Acquire(ctx, path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to fetch %s: %w", path, err)
+	}
+	return handle.Path, handle.Release, nil
+}
+
+// SetTaskStore sets the store used to persist tasks across restarts
+func (e *Executor) SetTaskStore(store TaskStore) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.store = store
+}
+
+// OpenTaskStore opens the task journal under the working directory
+func (e *Executor) OpenTaskStore() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.store != nil {
+		return nil
+	}
+
+	store, err := NewJournalStore(filepath.Join(e.workDir, "tasks.journal"))
+	if err != nil {
+		return fmt.Errorf("failed to open task store: %w", err)
+	}
+	e.store = store
+	return nil
+}
+
+// RecoverTasks reloads tasks from the task store. Tasks that were in progress
+// when the node stopped are marked paused and returned so they can be resumed
+// from their last checkpoint with ResumeTask.
+func (e *Executor) RecoverTasks() ([]*Task, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.store == nil {
+		return nil, fmt.Errorf("task store not opened")
+	}
+
+	tasks, err := e.store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tasks: %w", err)
+	}
+
+	var interrupted []*Task
+	for _, task := range tasks {
+		if _, exists := e.tasks[task.ID]; exists {
+			continue
+		}
+		if task.TaskType == "" {
+			task.TaskType = "training"
+		}
+		if task.Status == "in_progress" {
+			task.Status = "paused"
+			e.persistLocked(task)
+			interrupted = append(interrupted, task)
+		}
+		e.tasks[task.ID] = task
+	}
+
+	return interrupted, nil
 }
 
 // InitializeTrainingExecutor initializes the training executor
@@ -69,8 +234,47 @@ func (e *Executor) InitializeTrainingExecutor() {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	if e.trainingExecutor == nil {
-		e.trainingExecutor = NewTrainingExecutor(e, e.workDir, e.ipfsAPIURL)
+		e.trainingExecutor = NewTrainingExecutor(e, e.workDir, e.ipfsAPIURL, e.ipfsFallbacks...)
+	}
+}
+
+// SetWorkerPoolConfig sets how model workers are managed. It takes effect
+// when the inference executor is initialized.
+func (e *Executor) SetWorkerPoolConfig(config WorkerPoolConfig) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.workerConfig = config
+}
+
+// SetBatchConfig sets how inference requests are batched. It takes effect
+// when the inference executor is initialized.
+func (e *Executor) SetBatchConfig(config BatchConfig) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.batchConfig = config
+}
+
+// BatchStats describes the queue depth and batch sizes of each model's
+// inference requests
+func (e *Executor) BatchStats() []BatchStats {
+	e.mu.RLock()
+	ie := e.inferenceExecutor
+	e.mu.RUnlock()
+	if ie == nil {
+		return []BatchStats{}
+	}
+	return ie.BatchStats()
+}
+
+// ModelWorkers describes the resident model workers
+func (e *Executor) ModelWorkers() []WorkerInfo {
+	e.mu.RLock()
+	ie := e.inferenceExecutor
+	e.mu.RUnlock()
+	if ie == nil {
+		return []WorkerInfo{}
 	}
+	return ie.Workers()
 }
 
 // InitializeInferenceExecutor initializes the inference executor
@@ -78,7 +282,7 @@ func (e *Executor) InitializeInferenceExecutor() {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	if e.inferenceExecutor == nil {
-		e.inferenceExecutor = NewInferenceExecutor(e, e.workDir, e.ipfsAPIURL)
+		e.inferenceExecutor = NewInferenceExecutor(e, e.workDir)
 	}
 }
 
@@ -103,31 +307,87 @@ func (e *Executor) Start(ctx context.Context) error {
 			return ctx.Err()
 		case <-ticker.C:
 			e.processTasks(execCtx)
+		case <-e.wake:
+			e.processTasks(execCtx)
 		}
 	}
 }
 
+// ErrInvalidTaskID is returned for task IDs that cannot name a task
+// directory under the work dir
+var ErrInvalidTaskID = errors.New("invalid task ID")
+
+var taskIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// reservedWorkDirNames are the work dir entries that are not task directories
+var reservedWorkDirNames = map[string]bool{
+	"cache":             true,
+	"workers":           true,
+	"replays":           true,
+	"checkpoints":       true,
+	"logs":              true,
+	"tasks.journal":     true,
+	"tasks.journal.tmp": true,
+	"admin.sock":        true,
+	"admin.token":       true,
+	"traces.jsonl":      true,
+}
+
+// ValidateTaskID checks that a task ID is a single path element that does
+// not collide with the executor's own files in the work dir
+func ValidateTaskID(taskID string) error {
+	if taskID == "" {
+		return fmt.Errorf("task ID cannot be empty: %w", ErrInvalidTaskID)
+	}
+	if !taskIDPattern.MatchString(taskID) || taskID == "." || taskID == ".." {
+		return fmt.Errorf("task ID %q may only contain letters, digits, '.', '_' and '-': %w", taskID, ErrInvalidTaskID)
+	}
+	if reservedWorkDirNames[taskID] {
+		return fmt.Errorf("task ID %q is reserved: %w", taskID, ErrInvalidTaskID)
+	}
+	return nil
+}
+
 // AddTask adds a new task to the executor
 func (e *Executor) AddTask(task *Task) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	
-	if task.ID == "" {
-		return fmt.Errorf("task ID cannot be empty")
+	if err := ValidateTaskID(task.ID); err != nil {
+		return err
 	}
 	
 	if _, exists := e.tasks[task.ID]; exists {
 		return fmt.Errorf("task %s already exists", task.ID)
 	}
 	
+	if e.draining {
+		return fmt.Errorf("cannot add task %s: %w", task.ID, ErrDraining)
+	}
+	
+	if err := e.acceptsLocked(task); err != nil {
+		return err
+	}
+	
 	if task.Status == "" {
 		task.Status = "pending"
 	}
+	// Tasks without a type are training tasks
+	if task.TaskType == "" {
+		task.TaskType = "training"
+	}
 	if task.CreatedAt.IsZero() {
 		task.CreatedAt = time.Now()
 	}
 	
+	if e.store != nil {
+		if err := e.store.Save(task); err != nil {
+			return fmt.Errorf("failed to persist task %s: %w", task.ID, err)
+		}
+	}
+	
 	e.tasks[task.ID] = task
+	e.notify()
 	return nil
 }
 
@@ -145,171 +405,122 @@ func (e *Executor) GetTask(taskID string) (*Task, error) {
 }
 
 func (e *Executor) processTasks(ctx context.Context) {
-	e.mu.RLock()
-	tasks := make([]*Task, 0, len(e.tasks))
-	for _, task := range e.tasks {
-		tasks = append(tasks, task)
-	}
-	e.mu.RUnlock()
-	
-	for _, task := range tasks {
-		e.mu.Lock()
-		status := task.Status
-		e.mu.Unlock()
-		
-		switch status {
-		case "pending":
-			// Start pending tasks
-			e.startTask(ctx, task)
-		case "in_progress":
-			// Tasks in progress are handled by goroutines
-			// This is just a check to see if they're still running
-			continue
-		case "completed", "failed", "paused":
-			// Skip completed, failed, or paused tasks
-			continue
-		}
-	}
+	// Tasks in progress are handled by goroutines; completed, failed and
+	// paused tasks are skipped. Pending tasks wait in the admission queue.
+	e.admitPending(ctx)
 }
 
-func (e *Executor) startTask(ctx context.Context, task *Task) {
+// startTask moves an admitted task to in_progress and runs it. It returns
+// false if the task was no longer pending.
+func (e *Executor) startTask(ctx context.Context, task *Task) bool {
 	e.mu.Lock()
 	if task.Status != "pending" {
 		e.mu.Unlock()
-		return
+		return false
 	}
 	task.Status = "in_progress"
 	now := time.Now()
 	task.StartedAt = &now
+	e.persistLocked(task)
 	e.mu.Unlock()
 	
 	// Execute task in goroutine
-	go e.executeTask(ctx, task)
+	e.launch(ctx, task)
+	return true
 }
 
-func (e *Executor) executeTask(ctx context.Context, task *Task) {
+func (e *Executor) executeTask(ctx context.Context, task *Task, handle *taskHandle) {
+	ctx, span := e.startTaskSpan(ctx, task)
 	defer func() {
 		e.mu.Lock()
 		if task.Status == "in_progress" {
-			if task.Error != nil {
-				task.Status = "failed"
-			} else {
-				task.Status = "completed"
-				now := time.Now()
-				task.CompletedAt = &now
-				task.Progress = 1.0
+			switch handle.stopReason() {
+			case stopPause:
+				task.Status = "paused"
+				task.Error = nil
+			case stopCancel:
+				e.markCancelledLocked(task)
+			default:
+				e.finishLocked(task)
 			}
 		}
+		e.persistLocked(task)
+		observer := e.observer
+		finished := snapshotLocked(task)
 		e.mu.Unlock()
+		
+		e.closeTaskLog(task.ID)
+		e.release(task.ID)
+		e.notify()
+		if observer != nil && finished.StartedAt != nil {
+			observer.TaskFinished(finished, time.Since(*finished.StartedAt))
+		}
+		span.SetAttributes(attribute.String("task.status", finished.Status))
+		tracing.End(span, finished.Error)
 	}()
 	
-	// Execute based on task type
-	switch task.TaskType {
-	case "training":
-		e.InitializeTrainingExecutor()
-		e.executeTrainingTask(ctx, task)
-	case "inference":
-		e.InitializeInferenceExecutor()
-		e.executeInferenceTask(ctx, task)
-	default:
-		// Default to training if not specified
-		if task.TaskType == "" {
-			task.TaskType = "training"
-			e.executeTrainingTask(ctx, task)
-		} else {
-			task.Error = fmt.Errorf("unknown task type: %s", task.TaskType)
-			return
-		}
-	}
+	e.runTask(ctx, task)
 }
 
-func (e *Executor) executeTrainingTask(ctx context.Context, task *Task) {
-	e.mu.RLock()
-	trainingExecutor := e.trainingExecutor
-	e.mu.RUnlock()
-	
-	if trainingExecutor == nil {
-		task.Error = fmt.Errorf("training executor not initialized")
-		return
+// finishLocked marks a task completed or failed. The caller must hold e.mu.
+func (e *Executor) finishLocked(task *Task) {
+	if task.Error != nil {
+		task.Status = "failed"
+	} else {
+		task.Status = "completed"
+		now := time.Now()
+		task.CompletedAt = &now
+		task.Progress = 1.0
 	}
-	
-	// Update progress during execution
-	e.mu.Lock()
-	task.Progress = 0.1 // Started
-	e.mu.Unlock()
-	
-	// Execute training
-	if err := trainingExecutor.ExecuteTraining(ctx, task, task.ModelPath, task.DatasetPath); err != nil {
-		task.Error = fmt.Errorf("training execution failed: %w", err)
-		return
-	}
-	
-	e.mu.Lock()
-	task.Progress = 1.0 // Completed
-	e.mu.Unlock()
 }
 
-func (e *Executor) executeInferenceTask(ctx context.Context, task *Task) {
-	e.mu.RLock()
-	inferenceExecutor := e.inferenceExecutor
-	e.mu.RUnlock()
-	
-	if inferenceExecutor == nil {
-		task.Error = fmt.Errorf("inference executor not initialized")
-		return
-	}
-	
-	if len(task.InputData) == 0 {
-		task.Error = fmt.Errorf("input data is required for inference tasks")
-		return
-	}
-	
-	e.mu.Lock()
-	task.Progress = 0.1
-	e.mu.Unlock()
-	
-	output, err := inferenceExecutor.ExecuteInference(ctx, task, task.ModelPath, task.InputData)
+// ExecuteTask manually starts a task execution
+func (e *Executor) ExecuteTask(taskID string) error {
+	task, err := e.GetTask(taskID)
 	if err != nil {
-		task.Error = fmt.Errorf("inference execution failed: %w", err)
-		return
+		return err
 	}
 	
-	outputJSON, err := json.Marshal(output)
-	if err != nil {
-		task.Error = fmt.Errorf("failed to marshal output: %w", err)
-		return
+	e.mu.RLock()
+	status := task.Status
+	draining := e.draining
+	e.mu.RUnlock()
+	if draining {
+		return fmt.Errorf("cannot start task %s: %w", taskID, ErrDraining)
+	}
+	if status != "pending" && status != "paused" {
+		return fmt.Errorf("task %s is not in pending or paused state (current: %s)", taskID, status)
 	}
 	
-	e.mu.Lock()
-	task.OutputData = outputJSON
-	task.Progress = 1.0
-	e.mu.Unlock()
-}
-
-// ExecuteTask manually starts a task execution
-func (e *Executor) ExecuteTask(taskID string) error {
-	task, err := e.GetTask(taskID)
-	if err != nil {
+	if err := e.admit(task); err != nil {
 		return err
 	}
 	
 	e.mu.Lock()
 	if task.Status != "pending" && task.Status != "paused" {
 		e.mu.Unlock()
+		e.release(task.ID)
 		return fmt.Errorf("task %s is not in pending or paused state (current: %s)", taskID, task.Status)
 	}
 	task.Status = "in_progress"
 	now := time.Now()
 	task.StartedAt = &now
+	e.persistLocked(task)
 	e.mu.Unlock()
 	
 	// Execute in background
-	go e.executeTask(e.ctx, task)
+	e.launch(e.ctx, task)
 	return nil
 }
 
-// StopTask stops/pauses a running task
+// StopTask stops/pauses a running task. See PauseTask.
 func (e *Executor) StopTask(taskID string) error {
+	return e.PauseTask(taskID)
+}
+
+// ResumeTask re-queues a paused task. Training tasks resume from the
+// checkpoint recorded in CheckpointCID, if any.
+func (e *Executor) ResumeTask(taskID string) error {
 	task, err := e.GetTask(taskID)
 	if err != nil {
 		return err
@@ -318,12 +529,76 @@ func (e *Executor) StopTask(taskID string) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	
-	if task.Status == "in_progress" {
-		task.Status = "paused"
-		return nil
+	if task.Status != "paused" {
+		return fmt.Errorf("task %s is not paused (current: %s)", taskID, task.Status)
 	}
 	
-	return fmt.Errorf("task %s is not in progress (current: %s)", taskID, task.Status)
+	task.Status = "pending"
+	task.Error = nil
+	e.persistLocked(task)
+	e.notify()
+	return nil
+}
+
+// SetTaskCheckpoint records the latest checkpoint CID for a task
+func (e *Executor) SetTaskCheckpoint(taskID string, checkpointCID string) error {
+	task, err := e.GetTask(taskID)
+	if err != nil {
+		return err
+	}
+	
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	
+	task.CheckpointCID = checkpointCID
+	e.persistLocked(task)
+	return nil
+}
+
+// TaskSnapshot returns a copy of a task that is safe to read while the task
+// runs
+func (e *Executor) TaskSnapshot(taskID string) (Task, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	
+	task, ok := e.tasks[taskID]
+	if !ok {
+		return Task{}, fmt.Errorf("task not found: %s", taskID)
+	}
+	
+	return snapshotLocked(task), nil
+}
+
+// ListTaskSnapshots returns copies of all tasks, oldest first
+func (e *Executor) ListTaskSnapshots() []Task {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	
+	tasks := make([]Task, 0, len(e.tasks))
+	for _, task := range e.tasks {
+		tasks = append(tasks, snapshotLocked(task))
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return tasks[i].CreatedAt.Before(tasks[j].CreatedAt)
+	})
+	
+	return tasks
+}
+
+// snapshotLocked copies a task. The caller must hold e.mu.
+func snapshotLocked(task *Task) Task {
+	snapshot := *task
+	if task.Metadata != nil {
+		snapshot.Metadata = make(map[string]string, len(task.Metadata))
+		for key, value := range task.Metadata {
+			snapshot.Metadata[key] = value
+		}
+	}
+	if task.Usage != nil {
+		usage := *task.Usage
+		snapshot.Usage = &usage
+	}
+	return snapshot
 }
 
 // ListTasks returns all tasks
@@ -339,20 +614,89 @@ func (e *Executor) ListTasks() []*Task {
 	return tasks
 }
 
-// Stop stops the executor and cancels all running tasks
+// RemoveTask forgets a completed, failed or cancelled task, along with the
+// events it reported. Other tasks must be cancelled first.
+func (e *Executor) RemoveTask(taskID string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	task, ok := e.tasks[taskID]
+	if !ok {
+		return fmt.Errorf("task not found: %s", taskID)
+	}
+	switch task.Status {
+	case "completed", "failed", "cancelled":
+	default:
+		return fmt.Errorf("task %s is %s; cancel it before removing it", taskID, task.Status)
+	}
+
+	if e.store != nil {
+		if err := e.store.Delete(taskID); err != nil {
+			return fmt.Errorf("failed to remove task %s from the task store: %w", taskID, err)
+		}
+	}
+	delete(e.tasks, taskID)
+	delete(e.events, taskID)
+	delete(e.uploads, taskID)
+	return nil
+}
+
+// Stop stops the executor. Running tasks are asked to checkpoint and are
+// killed if they do not exit within the shutdown grace period.
 func (e *Executor) Stop() {
+	e.mu.RLock()
+	handles := make([]*taskHandle, 0, len(e.handles))
+	for _, handle := range e.handles {
+		handles = append(handles, handle)
+	}
+	e.mu.RUnlock()
+	
+	for _, handle := range handles {
+		handle.requestStop(stopPause)
+	}
+	deadline := time.Now().Add(shutdownGracePeriod)
+	for _, handle := range handles {
+		handle.wait(time.Until(deadline))
+	}
+	
+	e.mu.RLock()
+	inferenceExecutor := e.inferenceExecutor
+	e.mu.RUnlock()
+	if inferenceExecutor != nil {
+		inferenceExecutor.Close()
+	}
+	
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	
 	if e.cancel != nil {
 		e.cancel()
 	}
+	for _, handle := range handles {
+		handle.cancel()
+	}
 	
 	// Mark all in-progress tasks as paused
 	for _, task := range e.tasks {
 		if task.Status == "in_progress" {
 			task.Status = "paused"
+			e.persistLocked(task)
 		}
 	}
+	
+	if e.store != nil {
+		e.store.Close()
+		e.store = nil
+	}
+}
+
+// persistLocked writes the task to the task store. The caller must hold e.mu.
+func (e *Executor) persistLocked(task *Task) {
+	if e.store == nil {
+		return
+	}
+	if err := e.store.Save(task); err != nil {
+		fmt.Printf("Warning: failed to persist task %s: %v\n", task.ID, err)
+	}
 }
 
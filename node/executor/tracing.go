@@ -0,0 +1,57 @@
+package executor
+
+import (
+	"context"
+	"time"
+
+	"github.com/atlas/storage/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Task metadata may carry the W3C trace context ("traceparent" and
+// "tracestate") of whoever submitted the task. A task's spans continue that
+// trace, and its processes receive it as TRACEPARENT and TRACESTATE.
+const tracerName = "github.com/atlas/node/executor"
+
+// startTaskSpan starts the span covering a run of task
+func (e *Executor) startTaskSpan(ctx context.Context, task *Task) (context.Context, trace.Span) {
+	e.mu.RLock()
+	carrier := make(map[string]string, len(task.Metadata))
+	for key, value := range task.Metadata {
+		carrier[key] = value
+	}
+	attributes := []attribute.KeyValue{
+		attribute.String("task.id", task.ID),
+		attribute.String("task.type", task.TaskType),
+		attribute.String("task.job_id", task.JobID),
+	}
+	if task.StartedAt != nil {
+		attributes = append(attributes, attribute.Int64("task.queued_ms", task.StartedAt.Sub(task.CreatedAt).Milliseconds()))
+	}
+	e.mu.RUnlock()
+
+	return tracing.Start(tracing.Extract(ctx, carrier), tracerName, "task", attributes...)
+}
+
+// startInference starts the span of an inference request. The returned
+// function ends it and reports the request to the observer.
+func (e *Executor) startInference(ctx context.Context, modelPath string, operation string) (context.Context, func(err error)) {
+	if operation == "" {
+		operation = InferencePredict
+	}
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, tracerName, "inference",
+		attribute.String("model", modelPath), attribute.String("inference.operation", operation))
+
+	return ctx, func(err error) {
+		tracing.End(span, err)
+
+		e.mu.RLock()
+		observer := e.observer
+		e.mu.RUnlock()
+		if observer != nil {
+			observer.InferenceFinished(modelPath, operation, time.Since(start), err)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTaskSpan_ContinuesSubmittedTrace(t *testing.T) {
+	spans := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	e := NewExecutor(nil)
+	var runSpan trace.SpanContext
+	e.runTask = func(ctx context.Context, task *Task) {
+		runSpan = trace.SpanContextFromContext(ctx)
+	}
+	require.NoError(t, e.AddTask(&Task{
+		ID:       "traced",
+		TaskType: "training",
+		Metadata: map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+	}))
+	waitForTasks(t, e, context.Background())
+
+	require.Eventually(t, func() bool { return len(spans.GetSpans()) == 1 }, time.Second, 5*time.Millisecond)
+	span := spans.GetSpans()[0]
+	require.Equal(t, "task", span.Name)
+	require.Equal(t, traceID, span.SpanContext.TraceID())
+	require.Equal(t, "00f067aa0ba902b7", span.Parent.SpanID().String())
+	require.Equal(t, span.SpanContext.SpanID(), runSpan.SpanID())
+	require.Contains(t, span.Attributes, attribute.String("task.status", "completed"))
+}
@@ -2,9 +2,9 @@ package executor
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	
 	"github.com/atlas/storage/manager"
@@ -16,99 +16,127 @@ type TrainingExecutor struct {
 	ipfsManager *manager.IPFSManager
 }
 
-func NewTrainingExecutor(e *Executor, workDir string, ipfsAPIURL string) *TrainingExecutor {
+func NewTrainingExecutor(e *Executor, workDir string, ipfsAPIURL string, fallbackURLs ...string) *TrainingExecutor {
 	return &TrainingExecutor{
 		executor:    e,
 		workDir:     workDir,
-		ipfsManager: manager.NewIPFSManager(ipfsAPIURL),
+		ipfsManager: manager.NewIPFSManager(ipfsAPIURL, fallbackURLs...),
 	}
 }
 
+// ExecuteTraining prepares and runs a training task
 func (te *TrainingExecutor) ExecuteTraining(ctx context.Context, task *Task, modelPath string, datasetPath string) error {
-	// Create working directory for task
 	taskDir := filepath.Join(te.workDir, task.ID)
+	release, err := te.prepareTraining(ctx, task, taskDir, modelPath, datasetPath)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return te.runTraining(ctx, task, taskDir)
+}
+
+// prepareTraining stages the model and dataset, restores the checkpoint to
+// resume from and writes the training script. The model and dataset stay
+// pinned in the artifact cache, and mounted into the task's sandbox, until
+// release is called.
+func (te *TrainingExecutor) prepareTraining(ctx context.Context, task *Task, taskDir string, modelPath string, datasetPath string) (release func(), err error) {
+	// Create working directory for task
 	if err := os.MkdirAll(taskDir, 0755); err != nil {
-		return fmt.Errorf("failed to create task directory: %w", err)
+		return nil, fmt.Errorf("failed to create task directory: %w", err)
 	}
 
-	// Download model and dataset if needed
-	if err := te.downloadIfNeeded(ctx, modelPath, taskDir); err != nil {
-		return err
+	// Fetch the model and dataset into the artifact cache if given by CID
+	modelLocalPath, releaseModel, err := te.executor.stageArtifact(ctx, modelPath)
+	if err != nil {
+		return nil, err
 	}
-	if err := te.downloadIfNeeded(ctx, datasetPath, taskDir); err != nil {
-		return err
+	datasetLocalPath, releaseDataset, err := te.executor.stageArtifact(ctx, datasetPath)
+	if err != nil {
+		releaseModel()
+		return nil, err
+	}
+	// The sandbox hides the artifact cache, so the task sees only these
+	unexpose := te.executor.exposeToTask(task.ID, modelLocalPath, datasetLocalPath)
+	release = func() {
+		unexpose()
+		releaseModel()
+		releaseDataset()
+	}
+	defer func() {
+		if err != nil {
+			release()
+		}
+	}()
+
+	// Resume from the last checkpoint if the task was paused or interrupted.
+	// A checkpoint written locally on pause takes precedence over the last
+	// uploaded one. Training continues after the epoch it was taken at, so
+	// the segments in the task's proof can be replayed.
+	params := trainingParams{ModelPath: modelLocalPath, DatasetPath: datasetLocalPath}
+	localCheckpoint := filepath.Join(taskDir, "checkpoint.pt")
+	if _, err := os.Stat(localCheckpoint); err == nil {
+		if err := os.Rename(localCheckpoint, filepath.Join(taskDir, "resume_checkpoint.pt")); err != nil {
+			return nil, fmt.Errorf("failed to restore local checkpoint: %w", err)
+		}
+		params.StartEpoch = te.executor.resumeEpoch(task.ID)
+	} else if task.CheckpointCID != "" {
+		resumePath := filepath.Join(taskDir, "resume_checkpoint.pt")
+		if err := te.ipfsManager.GetFileContext(ctx, task.CheckpointCID, resumePath); err != nil {
+			return nil, fmt.Errorf("failed to download resume checkpoint: %w", err)
+		}
+		params.StartEpoch = te.executor.resumeEpoch(task.ID)
 	}
 
-	// Execute training script
 	scriptPath := filepath.Join(taskDir, "train.py")
-	if err := te.createTrainingScript(scriptPath, modelPath, datasetPath); err != nil {
-		return err
+	if err := te.createTrainingScript(scriptPath, params); err != nil {
+		return nil, err
 	}
+	return release, nil
+}
 
-	// Try python3 first, fallback to python
-	cmd := exec.CommandContext(ctx, "python3", scriptPath)
+// runTraining executes the training script written by prepareTraining
+func (te *TrainingExecutor) runTraining(ctx context.Context, task *Task, taskDir string) error {
+	// Run in its own process group so pause and cancel reach the whole tree
+	cmd := pythonCommand(ctx, filepath.Join(taskDir, "train.py"))
 	cmd.Dir = taskDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Run(); err != nil {
-		// Fallback to python
-		cmd = exec.CommandContext(ctx, "python", scriptPath)
-		cmd.Dir = taskDir
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("training failed: %w", err)
-		}
+
+	if err := te.executor.runProcess(ctx, task.ID, cmd); err != nil {
+		return fmt.Errorf("training failed: %w", err)
 	}
 
 	return nil
 }
 
-func (te *TrainingExecutor) downloadIfNeeded(ctx context.Context, path string, destDir string) error {
-	// Check if path is IPFS CID (starts with Qm for v0 or ba for v1)
-	if len(path) >= 2 && (path[:2] == "Qm" || path[:2] == "ba") {
-		// Download from IPFS
-		destPath := filepath.Join(destDir, "downloaded_file")
-		if err := te.ipfsManager.GetFile(path, destPath); err != nil {
-			return fmt.Errorf("IPFS download failed: %w", err)
-		}
-		return nil
-	}
-	
-	// Assume it's a local path - verify file exists
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return fmt.Errorf("local file not found: %s", path)
-	}
-	
-	return nil
+// trainingParams are the parameters read by scripts/train.py
+type trainingParams struct {
+	ModelPath   string `json:"model_path"`
+	DatasetPath string `json:"dataset_path"`
+	StartEpoch  int    `json:"start_epoch,omitempty"` // Epoch the resume checkpoint was taken at
+	StopEpoch   *int   `json:"stop_epoch,omitempty"`  // Where a replay stops and checkpoints
+	StopStep    int64  `json:"stop_step,omitempty"`
 }
 
-func (te *TrainingExecutor) createTrainingScript(scriptPath string, modelPath string, datasetPath string) error {
-	script := fmt.Sprintf(`
-import torch
-import torch.nn as nn
-from torch.utils.data import DataLoader
-
-# Load model
-model = torch.load('%s')
-
-# Load dataset
-# dataset = load_dataset('%s')
+// trainingResult is written by scripts/train.py when training completes
+type trainingResult struct {
+	ScriptVersion string `json:"script_version"`
+	Checkpoint    string `json:"checkpoint"`
+}
 
-# Training loop
-optimizer = torch.optim.Adam(model.parameters())
-criterion = nn.CrossEntropyLoss()
+func (te *TrainingExecutor) createTrainingScript(scriptPath string, params trainingParams) error {
+	return writeScript(scriptPath, trainingScript, params)
+}
 
-for epoch in range(10):
-    # Training code here
-    pass
+// collectTraining reads the result written by the training script
+func (te *TrainingExecutor) collectTraining(taskDir string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(taskDir, "result.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read training result: %w", err)
+	}
 
-# Save checkpoint
-torch.save(model.state_dict(), 'checkpoint.pt')
-`, modelPath, datasetPath)
+	var result trainingResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse training result: %w", err)
+	}
 
-	return os.WriteFile(scriptPath, []byte(script), 0755)
+	return data, nil
 }
-
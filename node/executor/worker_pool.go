@@ -0,0 +1,832 @@
+package executor
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/atlas/node/resource"
+)
+
+// Inference runs in model workers: long-lived Python processes
+// (scripts/worker.py) that load a model once and serve requests until they
+// are stopped. Requests and responses are JSON lines on the worker's stdin
+// and stdout; anything else the worker prints goes to its log.
+//
+//	-> {"id":"7","type":"infer","operation":"chat","data":{...}}
+//	<- {"id":"7","ok":true,"result":{...},"latency_ms":42}
+//	-> {"id":"8","type":"batch","operation":"embed","requests":[{...},{...}]}
+//	<- {"id":"8","ok":true,"results":[{"ok":true,"result":{...}},{...}],"latency_ms":42}
+//	-> {"id":"9","type":"infer","operation":"generate","stream":true,"data":{...}}
+//	<- {"id":"9","type":"token","text":"Hel"}
+//	<- {"id":"9","type":"token","text":"lo"}
+//	<- {"id":"9","ok":true,"result":{...}}
+//	-> {"type":"cancel","target":"9"}
+//	-> {"id":"10","type":"ping"}
+//	<- {"id":"10","ok":true}
+//	-> {"type":"shutdown"}
+//
+// A request whose caller goes away is cancelled; the worker stops working
+// on it and answers {"ok":false,"error":"cancelled"}.
+//
+// A worker announces itself with {"type":"ready","ok":true} once it is
+// ready to serve, or {"type":"ready","ok":false,"error":"..."} before
+// exiting if the model could not be loaded.
+
+// WorkerPoolConfig controls the model workers
+type WorkerPoolConfig struct {
+	MaxModels      int           // Models resident at once; the least recently used idle one is evicted
+	IdleTimeout    time.Duration // Workers unused this long are stopped; zero keeps them
+	HealthInterval time.Duration // How often idle workers are health checked
+	HealthTimeout  time.Duration // How long a worker has to answer a health check
+	StartTimeout   time.Duration // How long a worker has to download and load its model
+	MaxRestarts    int           // Consecutive crashes after which a worker is no longer restarted; negative never restarts
+
+	// Requirements are reserved for each worker while it runs and, when
+	// sandboxing is enabled, size its cgroup. Zero takes the scheduling
+	// policy's default for inference tasks.
+	Requirements resource.Requirements
+}
+
+// DefaultWorkerPoolConfig returns the worker pool defaults
+func DefaultWorkerPoolConfig() WorkerPoolConfig {
+	return WorkerPoolConfig{
+		MaxModels:      2,
+		IdleTimeout:    10 * time.Minute,
+		HealthInterval: 30 * time.Second,
+		HealthTimeout:  10 * time.Second,
+		StartTimeout:   10 * time.Minute,
+		MaxRestarts:    3,
+	}
+}
+
+const (
+	// workerStopTimeout is how long a worker gets to exit after shutdown
+	workerStopTimeout = 5 * time.Second
+
+	// maxWorkerMessageSize bounds a single response line
+	maxWorkerMessageSize = 64 << 20
+)
+
+// ErrWorkerPoolClosed is returned for requests made after the pool is closed
+var ErrWorkerPoolClosed = errors.New("worker pool is closed")
+
+// WorkerInfo describes a resident model worker
+type WorkerInfo struct {
+	ModelPath string    `json:"model_path"`
+	PID       int       `json:"pid"`
+	Requests  int64     `json:"requests"`
+	InFlight  int       `json:"in_flight"`
+	Restarts  int       `json:"restarts"`
+	StartedAt time.Time `json:"started_at"`
+	LastUsed  time.Time `json:"last_used"`
+}
+
+type workerRequest struct {
+	ID        string        `json:"id,omitempty"`
+	Type      string        `json:"type"`
+	Operation string        `json:"operation,omitempty"`
+	Data      interface{}   `json:"data,omitempty"`
+	Requests  []interface{} `json:"requests,omitempty"` // Data of each request in a batch
+	Stream    bool          `json:"stream,omitempty"`   // Send generated text as "token" messages
+	Target    string        `json:"target,omitempty"`   // Request a "cancel" applies to
+}
+
+type workerResponse struct {
+	ID            string          `json:"id,omitempty"`
+	Type          string          `json:"type,omitempty"`
+	OK            bool            `json:"ok"`
+	Result        json.RawMessage `json:"result,omitempty"`
+	Results       []workerResult  `json:"results,omitempty"`
+	Text          string          `json:"text,omitempty"` // Generated text of a "token" message
+	Error         string          `json:"error,omitempty"`
+	LatencyMs     int64           `json:"latency_ms,omitempty"`
+	ScriptVersion string          `json:"script_version,omitempty"`
+}
+
+// workerResult is the outcome of one request in a batch
+type workerResult struct {
+	OK     bool            `json:"ok"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// workerParams are the parameters read by scripts/worker.py
+type workerParams struct {
+	ModelPath string `json:"model_path"`
+	Preload   string `json:"preload,omitempty"` // Operation whose model is loaded before the worker reports ready
+}
+
+// WorkerPool keeps one worker per model, up to MaxModels of them
+type WorkerPool struct {
+	inference *InferenceExecutor
+	workDir   string
+	logConfig LogConfig
+	config    WorkerPoolConfig
+
+	mu       sync.Mutex
+	workers  map[string]*modelWorker
+	restarts map[string]int
+	changed  chan struct{} // Closed and replaced whenever a worker becomes free or goes away
+	closed   bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// modelWorker is one worker process
+type modelWorker struct {
+	pool      *WorkerPool
+	modelPath string
+	dir       string
+	cmd       *exec.Cmd
+	log       *rotatingLog
+
+	ready    chan struct{} // Closed once the worker is serving or failed to start
+	startErr error
+	done     chan struct{} // Closed once the process has exited
+	readyMsg chan workerResponse
+	version  string // Script version the worker reported when ready
+
+	releaseModel func()       // Unpins the model in the artifact cache
+	allocation   string       // ID of the worker's resource reservation, if any
+	sandbox      *taskSandbox // Cgroup of the worker process, if sandboxed
+	cleanupOnce  sync.Once
+
+	writeMu sync.Mutex
+	stdin   io.WriteCloser
+	encoder *json.Encoder
+
+	mu      sync.Mutex
+	pending map[string]*pendingCall
+	nextID  uint64
+	exitErr error
+
+	// Guarded by pool.mu
+	loaded    bool
+	inflight  int
+	requests  int64
+	startedAt time.Time
+	lastUsed  time.Time
+}
+
+// pendingCall is a request waiting for its response
+type pendingCall struct {
+	ctx    context.Context
+	done   chan workerResponse
+	tokens chan<- string // Receives streamed text, if the request streams
+}
+
+// NewWorkerPool creates a pool whose workers live under workDir/workers.
+// Zero config values take their defaults.
+func NewWorkerPool(ie *InferenceExecutor, workDir string, logConfig LogConfig, config WorkerPoolConfig) *WorkerPool {
+	defaults := DefaultWorkerPoolConfig()
+	if config.MaxModels <= 0 {
+		config.MaxModels = defaults.MaxModels
+	}
+	if config.HealthInterval <= 0 {
+		config.HealthInterval = defaults.HealthInterval
+	}
+	if config.HealthTimeout <= 0 {
+		config.HealthTimeout = defaults.HealthTimeout
+	}
+	if config.StartTimeout <= 0 {
+		config.StartTimeout = defaults.StartTimeout
+	}
+	if config.MaxRestarts == 0 {
+		config.MaxRestarts = defaults.MaxRestarts
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &WorkerPool{
+		inference: ie,
+		workDir:   workDir,
+		logConfig: logConfig,
+		config:    config,
+		workers:   make(map[string]*modelWorker),
+		restarts:  make(map[string]int),
+		changed:   make(chan struct{}),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	go p.maintain()
+	return p
+}
+
+// Infer runs one request on the worker for modelPath, starting the worker
+// if the model is not resident
+func (p *WorkerPool) Infer(ctx context.Context, modelPath string, input InferenceInput) (*workerResponse, error) {
+	operation := input.Operation
+	if operation == "" {
+		operation = InferencePredict
+	}
+	return p.send(ctx, modelPath, workerRequest{Type: "infer", Operation: operation, Data: input.Data}, 1, nil)
+}
+
+// InferStream runs a generation like Infer and sends its text to tokens as
+// the worker produces it. All text has been sent by the time it returns.
+func (p *WorkerPool) InferStream(ctx context.Context, modelPath string, input InferenceInput, tokens chan<- string) (*workerResponse, error) {
+	req := workerRequest{Type: "infer", Operation: input.Operation, Data: input.Data, Stream: true}
+	return p.send(ctx, modelPath, req, 1, tokens)
+}
+
+// InferBatch runs several requests for the same operation on the worker
+// for modelPath as one batch. The response holds one result per request.
+func (p *WorkerPool) InferBatch(ctx context.Context, modelPath string, operation string, data []interface{}) (*workerResponse, error) {
+	if operation == "" {
+		operation = InferencePredict
+	}
+	resp, err := p.send(ctx, modelPath, workerRequest{Type: "batch", Operation: operation, Requests: data}, len(data), nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Results) != len(data) {
+		return nil, fmt.Errorf("model worker returned %d results for a batch of %d", len(resp.Results), len(data))
+	}
+	return resp, nil
+}
+
+// send runs req, which carries requests inference requests, on the worker
+// for modelPath
+func (p *WorkerPool) send(ctx context.Context, modelPath string, req workerRequest, requests int, tokens chan<- string) (*workerResponse, error) {
+	w, err := p.acquire(ctx, modelPath, req.Operation)
+	if err != nil {
+		return nil, err
+	}
+	defer p.release(w)
+
+	resp, err := w.call(ctx, req, tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	w.requests += int64(requests)
+	delete(p.restarts, modelPath)
+	p.mu.Unlock()
+
+	resp.ScriptVersion = w.version
+	return resp, nil
+}
+
+// Workers describes the resident workers, sorted by model path
+func (p *WorkerPool) Workers() []WorkerInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	infos := make([]WorkerInfo, 0, len(p.workers))
+	for _, w := range p.workers {
+		if !w.loaded {
+			continue
+		}
+		infos = append(infos, WorkerInfo{
+			ModelPath: w.modelPath,
+			PID:       w.cmd.Process.Pid,
+			Requests:  w.requests,
+			InFlight:  w.inflight,
+			Restarts:  p.restarts[w.modelPath],
+			StartedAt: w.startedAt,
+			LastUsed:  w.lastUsed,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModelPath < infos[j].ModelPath
+	})
+	return infos
+}
+
+// Close stops all workers. Requests in flight fail.
+func (p *WorkerPool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	p.cancel()
+	workers := make([]*modelWorker, 0, len(p.workers))
+	for _, w := range p.workers {
+		workers = append(workers, w)
+		p.removeLocked(w)
+	}
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, w := range workers {
+		wg.Add(1)
+		go func(w *modelWorker) {
+			defer wg.Done()
+			w.stop()
+		}(w)
+	}
+	wg.Wait()
+}
+
+// acquire returns a ready worker for modelPath with the caller counted as
+// in flight. At the model cap the least recently used idle worker is
+// evicted; if every worker is busy acquire waits for one to finish.
+func (p *WorkerPool) acquire(ctx context.Context, modelPath string, operation string) (*modelWorker, error) {
+	p.mu.Lock()
+	var evicted *modelWorker
+	for {
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrWorkerPoolClosed
+		}
+
+		w := p.workers[modelPath]
+		if w != nil && w.exited() {
+			// Crashed before the restart logic saw it
+			p.removeLocked(w)
+			continue
+		}
+		if w == nil && len(p.workers) >= p.config.MaxModels {
+			if victim := p.idlestLocked(); victim != nil {
+				p.removeLocked(victim)
+				go victim.stop()
+				evicted = victim
+				continue
+			}
+
+			changed := p.changed
+			p.mu.Unlock()
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			p.mu.Lock()
+			continue
+		}
+		if w == nil {
+			var after <-chan struct{}
+			if evicted != nil {
+				// Let the evicted model's memory go before loading the next
+				after = evicted.done
+			}
+			w = p.startLocked(modelPath, operation, after)
+		}
+
+		w.inflight++
+		w.lastUsed = time.Now()
+		p.mu.Unlock()
+
+		select {
+		case <-w.ready:
+		case <-ctx.Done():
+			p.release(w)
+			return nil, ctx.Err()
+		}
+		if w.startErr != nil {
+			p.release(w)
+			return nil, w.startErr
+		}
+		return w, nil
+	}
+}
+
+func (p *WorkerPool) release(w *modelWorker) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	w.inflight--
+	w.lastUsed = time.Now()
+	p.notifyLocked()
+}
+
+// idlestLocked returns the least recently used worker with nothing in
+// flight, or nil. The caller must hold p.mu.
+func (p *WorkerPool) idlestLocked() *modelWorker {
+	var idlest *modelWorker
+	for _, w := range p.workers {
+		if !w.loaded || w.inflight > 0 {
+			continue
+		}
+		if idlest == nil || w.lastUsed.Before(idlest.lastUsed) {
+			idlest = w
+		}
+	}
+	return idlest
+}
+
+// removeLocked takes a worker out of the pool. The caller must hold p.mu.
+func (p *WorkerPool) removeLocked(w *modelWorker) {
+	if p.workers[w.modelPath] == w {
+		delete(p.workers, w.modelPath)
+		p.notifyLocked()
+	}
+}
+
+func (p *WorkerPool) notifyLocked() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// startLocked adds a worker for modelPath and starts it in the background,
+// once after (if set) is closed. The caller must hold p.mu.
+func (p *WorkerPool) startLocked(modelPath string, preload string, after <-chan struct{}) *modelWorker {
+	sum := sha256.Sum256([]byte(modelPath))
+	name := hex.EncodeToString(sum[:8])
+
+	w := &modelWorker{
+		pool:      p,
+		modelPath: modelPath,
+		ready:     make(chan struct{}),
+		done:      make(chan struct{}),
+		readyMsg:  make(chan workerResponse, 1),
+		pending:   make(map[string]*pendingCall),
+		startedAt: time.Now(),
+		lastUsed:  time.Now(),
+	}
+	p.workers[modelPath] = w
+
+	go func() {
+		if after != nil {
+			<-after
+		}
+
+		ctx, cancel := context.WithTimeout(p.ctx, p.config.StartTimeout)
+		defer cancel()
+
+		if err := w.start(ctx, name, preload); err != nil {
+			w.startErr = fmt.Errorf("failed to start worker for %s: %w", modelPath, err)
+			if w.dir != "" {
+				os.RemoveAll(w.dir)
+			}
+			w.cleanup()
+			p.mu.Lock()
+			p.removeLocked(w)
+			p.mu.Unlock()
+			close(w.ready)
+			return
+		}
+
+		p.mu.Lock()
+		w.loaded = true
+		w.startedAt = time.Now()
+		p.mu.Unlock()
+		close(w.ready)
+	}()
+	return w
+}
+
+// exited is called once a worker's process is gone. Workers that crashed
+// while in the pool are restarted, up to MaxRestarts times in a row.
+func (p *WorkerPool) exited(w *modelWorker) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.workers[w.modelPath] != w || !w.loaded {
+		// Stopped on purpose, or never started
+		return
+	}
+	p.removeLocked(w)
+	go os.RemoveAll(w.dir)
+	if p.closed {
+		return
+	}
+
+	fmt.Printf("Warning: model worker for %s exited unexpectedly: %v\n", w.modelPath, w.exitErr)
+	if p.restarts[w.modelPath] >= p.config.MaxRestarts {
+		fmt.Printf("Warning: not restarting model worker for %s after %d restarts\n", w.modelPath, p.restarts[w.modelPath])
+		delete(p.restarts, w.modelPath)
+		return
+	}
+	p.restarts[w.modelPath]++
+	p.startLocked(w.modelPath, "", nil)
+}
+
+// maintain stops idle workers and health checks the others
+func (p *WorkerPool) maintain() {
+	ticker := time.NewTicker(p.config.HealthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			p.checkWorkers()
+		}
+	}
+}
+
+func (p *WorkerPool) checkWorkers() {
+	now := time.Now()
+	var idle, check []*modelWorker
+
+	p.mu.Lock()
+	for _, w := range p.workers {
+		if !w.loaded || w.inflight > 0 {
+			continue
+		}
+		if p.config.IdleTimeout > 0 && now.Sub(w.lastUsed) >= p.config.IdleTimeout {
+			p.removeLocked(w)
+			idle = append(idle, w)
+		} else {
+			check = append(check, w)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, w := range idle {
+		go w.stop()
+	}
+	for _, w := range check {
+		go p.healthCheck(w)
+	}
+}
+
+// healthCheck pings a worker and kills it if it does not answer, which
+// restarts it
+func (p *WorkerPool) healthCheck(w *modelWorker) {
+	ctx, cancel := context.WithTimeout(p.ctx, p.config.HealthTimeout)
+	defer cancel()
+
+	_, err := w.call(ctx, workerRequest{Type: "ping"}, nil)
+	if err == nil || p.ctx.Err() != nil {
+		return
+	}
+
+	p.mu.Lock()
+	inPool := p.workers[w.modelPath] == w
+	p.mu.Unlock()
+	if inPool {
+		fmt.Printf("Warning: model worker for %s failed its health check: %v\n", w.modelPath, err)
+		killProcessGroup(w.cmd)
+	}
+}
+
+// requirements returns the resources each worker reserves
+func (p *WorkerPool) requirements() resource.Requirements {
+	if p.config.Requirements != (resource.Requirements{}) {
+		return p.config.Requirements
+	}
+	return p.inference.executor.requirementsFor(&Task{TaskType: "inference"})
+}
+
+// start prepares a directory for the worker, stages the model and runs the
+// worker until it reports ready. The model stays pinned in the artifact
+// cache, and the worker's resources reserved, until the worker exits. Each
+// worker gets its own directory so a replacement never shares files with a
+// worker being stopped. Like a task process, the worker runs in the
+// executor's sandbox; it sees only its directory and, read-only, its model.
+func (w *modelWorker) start(ctx context.Context, name string, preload string) error {
+	p := w.pool
+	workersDir := filepath.Join(p.workDir, "workers")
+	if err := os.MkdirAll(workersDir, 0755); err != nil {
+		return fmt.Errorf("failed to create worker directory: %w", err)
+	}
+	dir, err := os.MkdirTemp(workersDir, name+"-")
+	if err != nil {
+		return fmt.Errorf("failed to create worker directory: %w", err)
+	}
+	w.dir = dir
+
+	modelLocalPath, modelRoot, release, err := p.inference.stageModel(ctx, w.modelPath)
+	if err != nil {
+		return fmt.Errorf("failed to download model: %w", err)
+	}
+	w.releaseModel = release
+
+	e := p.inference.executor
+	req := p.requirements()
+	if e.resourceManager != nil {
+		allocation := "worker:" + filepath.Base(dir)
+		if err := e.resourceManager.Allocate(allocation, req); err != nil {
+			return fmt.Errorf("failed to reserve resources for worker: %w", err)
+		}
+		w.allocation = allocation
+	}
+
+	// The worker imports the inference script as a module
+	if err := os.WriteFile(filepath.Join(w.dir, "inference.py"), inferenceScript, 0755); err != nil {
+		return fmt.Errorf("failed to write inference script: %w", err)
+	}
+	scriptPath := filepath.Join(w.dir, "worker.py")
+	if err := writeScript(scriptPath, workerScript, workerParams{ModelPath: modelLocalPath, Preload: preload}); err != nil {
+		return fmt.Errorf("failed to write worker script: %w", err)
+	}
+
+	w.log, err = openRotatingLog(TaskLogPath(p.workDir, "worker-"+name), p.logConfig)
+	if err != nil {
+		return err
+	}
+
+	cmd := pythonCommand(context.Background(), scriptPath)
+	cmd.Dir = w.dir
+	cmd.Stderr = w.log
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	w.sandbox, err = e.sandboxProcess(sandboxSpec{
+		Name:         "worker-" + filepath.Base(dir),
+		Requirements: req,
+		Dir:          dir,
+		ReadOnly:     []string{modelRoot},
+	}, cmd)
+	if err != nil {
+		w.log.Close()
+		return fmt.Errorf("failed to sandbox worker: %w", err)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		w.log.Close()
+		return fmt.Errorf("failed to create worker stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		w.log.Close()
+		return fmt.Errorf("failed to create worker stdout: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		w.log.Close()
+		return fmt.Errorf("failed to start worker: %w", err)
+	}
+	w.sandbox.started()
+	w.cmd = cmd
+	w.stdin = stdin
+	w.encoder = json.NewEncoder(stdin)
+	go w.readResponses(stdout)
+
+	select {
+	case msg := <-w.readyMsg:
+		if !msg.OK {
+			killProcessGroup(cmd)
+			return fmt.Errorf("failed to load model: %s", msg.Error)
+		}
+		w.version = msg.ScriptVersion
+		return nil
+	case <-w.done:
+		return fmt.Errorf("worker exited before it was ready (%v); see %s", w.exitErr, w.log.path)
+	case <-ctx.Done():
+		killProcessGroup(cmd)
+		<-w.done
+		return fmt.Errorf("worker did not become ready: %w", ctx.Err())
+	}
+}
+
+// readResponses delivers responses to their callers until the worker
+// exits, then fails whatever is still pending
+func (w *modelWorker) readResponses(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxWorkerMessageSize)
+	for scanner.Scan() {
+		var resp workerResponse
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			fmt.Fprintf(w.log, "unexpected worker output: %s\n", scanner.Text())
+			continue
+		}
+		switch resp.Type {
+		case "ready":
+			select {
+			case w.readyMsg <- resp:
+			default:
+			}
+			continue
+		case "token":
+			w.mu.Lock()
+			call := w.pending[resp.ID]
+			w.mu.Unlock()
+			if call != nil && call.tokens != nil {
+				select {
+				case call.tokens <- resp.Text:
+				case <-call.ctx.Done():
+				}
+			}
+			continue
+		}
+
+		w.mu.Lock()
+		call := w.pending[resp.ID]
+		delete(w.pending, resp.ID)
+		w.mu.Unlock()
+		if call != nil {
+			call.done <- resp
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(w.log, "failed to read worker output: %v\n", err)
+		killProcessGroup(w.cmd)
+	}
+
+	err := w.cmd.Wait()
+	if err == nil {
+		err = errors.New("exited")
+	}
+
+	w.mu.Lock()
+	w.exitErr = err
+	pending := w.pending
+	w.pending = nil
+	w.mu.Unlock()
+	for _, call := range pending {
+		call.done <- workerResponse{Error: fmt.Sprintf("model worker exited: %v", err)}
+	}
+
+	w.log.Close()
+	w.cleanup()
+	close(w.done)
+	w.pool.exited(w)
+}
+
+// cleanup unpins the worker's model, removes its cgroup and releases its
+// resources. It may be called more than once.
+func (w *modelWorker) cleanup() {
+	w.cleanupOnce.Do(func() {
+		if w.releaseModel != nil {
+			w.releaseModel()
+		}
+		w.sandbox.finish()
+		if w.allocation != "" {
+			w.pool.inference.executor.release(w.allocation)
+		}
+	})
+}
+
+// call sends a request and waits for its response, passing streamed text
+// to tokens. If ctx ends first the worker is told to cancel the request and
+// its response is discarded when it arrives.
+func (w *modelWorker) call(ctx context.Context, req workerRequest, tokens chan<- string) (*workerResponse, error) {
+	call := &pendingCall{ctx: ctx, done: make(chan workerResponse, 1), tokens: tokens}
+
+	w.mu.Lock()
+	if w.pending == nil {
+		w.mu.Unlock()
+		return nil, fmt.Errorf("model worker exited: %v", w.exitErr)
+	}
+	w.nextID++
+	req.ID = strconv.FormatUint(w.nextID, 10)
+	w.pending[req.ID] = call
+	w.mu.Unlock()
+
+	if err := w.write(req); err != nil {
+		w.forget(req.ID)
+		return nil, fmt.Errorf("failed to send request to model worker: %w", err)
+	}
+
+	select {
+	case resp := <-call.done:
+		if !resp.OK {
+			return nil, errors.New(resp.Error)
+		}
+		return &resp, nil
+	case <-ctx.Done():
+		w.forget(req.ID)
+		if req.Type != "ping" {
+			w.write(workerRequest{Type: "cancel", Target: req.ID})
+		}
+		return nil, ctx.Err()
+	}
+}
+
+func (w *modelWorker) write(req workerRequest) error {
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+	return w.encoder.Encode(req)
+}
+
+func (w *modelWorker) forget(id string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.pending, id)
+}
+
+func (w *modelWorker) exited() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// stop asks the worker to shut down, kills it if it does not exit in time
+// and removes its directory
+func (w *modelWorker) stop() {
+	<-w.ready
+	if w.startErr == nil {
+		w.writeMu.Lock()
+		w.encoder.Encode(workerRequest{Type: "shutdown"})
+		w.stdin.Close()
+		w.writeMu.Unlock()
+
+		select {
+		case <-w.done:
+		case <-time.After(workerStopTimeout):
+			killProcessGroup(w.cmd)
+			<-w.done
+		}
+	}
+	os.RemoveAll(w.dir)
+}
@@ -0,0 +1,278 @@
+package executor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/atlas/node/resource"
+	"github.com/atlas/storage/cache"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWorkerScript speaks the worker protocol. Infer requests are appended
+// to $FAKE_WORKER_CAPTURE and answered with $FAKE_WORKER_RESULT, with PID
+// replaced by the worker's process ID. Batches of generate requests are
+// captured too and answered by echoing each prompt. Streamed requests send
+// "Hel" and "lo" as tokens first; one mentioning "hold" instead waits for
+// its cancel message, which is captured too. A request mentioning "crash"
+// kills the worker, and pings go unanswered while $FAKE_WORKER_HANG exists.
+const fakeWorkerScript = `#!/bin/sh
+if [ -n "$FAKE_WORKER_FAIL" ]; then
+  echo '{"type":"ready","ok":false,"error":"cannot load model"}'
+  exit 1
+fi
+echo '{"type":"ready","ok":true,"script_version":"worker/test"}'
+while read -r line; do
+  id=$(printf '%s\n' "$line" | sed -n 's/^{"id":"\([^"]*\)".*/\1/p')
+  case "$line" in
+  *'"type":"ping"'*)
+    while [ -e "$FAKE_WORKER_HANG" ]; do sleep 0.05; done
+    echo "{\"id\":\"$id\",\"ok\":true}" ;;
+  *'"type":"shutdown"'*) exit 0 ;;
+  *crash*) exit 3 ;;
+  *'"type":"batch"'*)
+    printf '%s\n' "$line" >> "$FAKE_WORKER_CAPTURE"
+    results=$(printf '%s\n' "$line" | sed -e 's/.*"requests":\[\(.*\)\]}$/\1/' \
+      -e 's/{"prompt":"\([^"]*\)"[^}]*}/{"ok":true,"result":{"text":"\1","finish_reason":"stop"}}/g')
+    printf '{"id":"%s","ok":true,"results":[%s],"latency_ms":2}\n' "$id" "$results" ;;
+  *'"type":"cancel"'*) ;;
+  *'"stream":true'*)
+    printf '%s\n' "$line" >> "$FAKE_WORKER_CAPTURE"
+    echo "{\"id\":\"$id\",\"type\":\"token\",\"text\":\"Hel\"}"
+    case "$line" in *hold*)
+      read -r cancel
+      printf '%s\n' "$cancel" >> "$FAKE_WORKER_CAPTURE"
+      echo "{\"id\":\"$id\",\"ok\":false,\"error\":\"cancelled\"}"
+      continue ;;
+    esac
+    echo "{\"id\":\"$id\",\"type\":\"token\",\"text\":\"lo\"}"
+    printf '{"id":"%s","ok":true,"result":%s,"latency_ms":2}\n' "$id" "$(cat "$FAKE_WORKER_RESULT")" ;;
+  *)
+    printf '%s\n' "$line" >> "$FAKE_WORKER_CAPTURE"
+    printf '{"id":"%s","ok":true,"result":%s,"latency_ms":2}\n' "$id" "$(sed "s/PID/$$/" "$FAKE_WORKER_RESULT")" ;;
+  esac
+done
+`
+
+// fakeWorker puts a python3 on PATH that runs fakeWorkerScript and answers
+// every request with result. It returns the file requests are captured in.
+func fakeWorker(t *testing.T, result string) string {
+	t.Helper()
+	bin := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "python3"), []byte(fakeWorkerScript), 0755))
+	resultPath := filepath.Join(bin, "result.json")
+	require.NoError(t, os.WriteFile(resultPath, []byte(result), 0644))
+
+	capture := filepath.Join(bin, "requests.jsonl")
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_WORKER_RESULT", resultPath)
+	t.Setenv("FAKE_WORKER_CAPTURE", capture)
+	t.Setenv("FAKE_WORKER_HANG", filepath.Join(bin, "hang"))
+	return capture
+}
+
+type capturedRequest struct {
+	ID        string            `json:"id"`
+	Type      string            `json:"type"`
+	Operation string            `json:"operation"`
+	Stream    bool              `json:"stream"`
+	Target    string            `json:"target"`
+	Data      json.RawMessage   `json:"data"`
+	Requests  []json.RawMessage `json:"requests"`
+}
+
+func capturedRequests(t *testing.T, capture string) []capturedRequest {
+	t.Helper()
+	file, err := os.Open(capture)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var requests []capturedRequest
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var req capturedRequest
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &req))
+		requests = append(requests, req)
+	}
+	return requests
+}
+
+func newModelDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{}`), 0644))
+	return dir
+}
+
+// modelFetcher fetches every CID as a Hugging Face model directory
+type modelFetcher struct{}
+
+func (modelFetcher) GetFile(cid string, outputPath string) error {
+	if err := os.MkdirAll(outputPath, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(outputPath, "config.json"), []byte(`{}`), 0644)
+}
+
+func newWorkerExecutor(t *testing.T, config WorkerPoolConfig) *Executor {
+	t.Helper()
+	e := NewExecutor(nil)
+	e.SetWorkDir(t.TempDir())
+	e.SetWorkerPoolConfig(config)
+	t.Cleanup(e.Stop)
+	return e
+}
+
+func TestWorkerPool_ReusesWorker(t *testing.T) {
+	capture := fakeWorker(t, `{"text": "PID", "finish_reason": "stop"}`)
+	e := newWorkerExecutor(t, DefaultWorkerPoolConfig())
+	model := newModelDir(t)
+
+	first, err := e.Generate(context.Background(), model, GenerateRequest{Prompt: "a"})
+	require.NoError(t, err)
+	second, err := e.Generate(context.Background(), model, GenerateRequest{Prompt: "b"})
+	require.NoError(t, err)
+
+	// Both requests were served by the same process
+	require.Equal(t, first.Text, second.Text)
+	require.Len(t, capturedRequests(t, capture), 2)
+
+	workers := e.ModelWorkers()
+	require.Len(t, workers, 1)
+	require.Equal(t, model, workers[0].ModelPath)
+	require.Equal(t, int64(2), workers[0].Requests)
+	require.Equal(t, first.Text, strconv.Itoa(workers[0].PID))
+}
+
+func TestWorkerPool_RestartsCrashedWorker(t *testing.T) {
+	fakeWorker(t, `{"text": "PID", "finish_reason": "stop"}`)
+	e := newWorkerExecutor(t, DefaultWorkerPoolConfig())
+	model := newModelDir(t)
+
+	first, err := e.Generate(context.Background(), model, GenerateRequest{Prompt: "ok"})
+	require.NoError(t, err)
+
+	_, err = e.Generate(context.Background(), model, GenerateRequest{Prompt: "crash"})
+	require.ErrorContains(t, err, "model worker exited")
+
+	require.Eventually(t, func() bool {
+		workers := e.ModelWorkers()
+		return len(workers) == 1 && workers[0].Restarts == 1
+	}, 5*time.Second, 20*time.Millisecond)
+
+	second, err := e.Generate(context.Background(), model, GenerateRequest{Prompt: "ok"})
+	require.NoError(t, err)
+	require.NotEqual(t, first.Text, second.Text)
+}
+
+func TestWorkerPool_StartFailure(t *testing.T) {
+	fakeWorker(t, `{}`)
+	t.Setenv("FAKE_WORKER_FAIL", "1")
+	e := newWorkerExecutor(t, DefaultWorkerPoolConfig())
+
+	_, err := e.Generate(context.Background(), newModelDir(t), GenerateRequest{Prompt: "a"})
+	require.ErrorContains(t, err, "cannot load model")
+	require.Empty(t, e.ModelWorkers())
+}
+
+func TestWorkerPool_EvictsAndStopsIdleWorkers(t *testing.T) {
+	fakeWorker(t, `{"text": "PID", "finish_reason": "stop"}`)
+	e := newWorkerExecutor(t, WorkerPoolConfig{
+		MaxModels:      1,
+		IdleTimeout:    300 * time.Millisecond,
+		HealthInterval: 50 * time.Millisecond,
+	})
+	first, second := newModelDir(t), newModelDir(t)
+
+	_, err := e.Generate(context.Background(), first, GenerateRequest{Prompt: "a"})
+	require.NoError(t, err)
+	_, err = e.Generate(context.Background(), second, GenerateRequest{Prompt: "b"})
+	require.NoError(t, err)
+
+	// Only one model may be resident, so the first was evicted
+	workers := e.ModelWorkers()
+	require.Len(t, workers, 1)
+	require.Equal(t, second, workers[0].ModelPath)
+
+	require.Eventually(t, func() bool {
+		return len(e.ModelWorkers()) == 0
+	}, 5*time.Second, 20*time.Millisecond)
+	require.Eventually(t, func() bool {
+		entries, err := os.ReadDir(filepath.Join(e.workDir, "workers"))
+		return err == nil && len(entries) == 0
+	}, 5*time.Second, 20*time.Millisecond)
+}
+
+func TestWorkerPool_HealthCheck(t *testing.T) {
+	fakeWorker(t, `{"text": "PID", "finish_reason": "stop"}`)
+	e := newWorkerExecutor(t, WorkerPoolConfig{
+		HealthInterval: 50 * time.Millisecond,
+		HealthTimeout:  100 * time.Millisecond,
+	})
+	model := newModelDir(t)
+
+	_, err := e.Generate(context.Background(), model, GenerateRequest{Prompt: "a"})
+	require.NoError(t, err)
+	pid := e.ModelWorkers()[0].PID
+
+	// A worker that stops answering pings is replaced
+	hang := os.Getenv("FAKE_WORKER_HANG")
+	require.NoError(t, os.WriteFile(hang, nil, 0644))
+	require.Eventually(t, func() bool {
+		workers := e.ModelWorkers()
+		return len(workers) == 1 && workers[0].PID != pid
+	}, 5*time.Second, 20*time.Millisecond)
+	require.NoError(t, os.Remove(hang))
+
+	_, err = e.Generate(context.Background(), model, GenerateRequest{Prompt: "b"})
+	require.NoError(t, err)
+}
+
+func TestWorkerPool_PinsCachedModel(t *testing.T) {
+	fakeWorker(t, `{"text": "PID", "finish_reason": "stop"}`)
+	e := newWorkerExecutor(t, WorkerPoolConfig{MaxModels: 1})
+	artifacts, err := cache.Open(t.TempDir(), 0, modelFetcher{})
+	require.NoError(t, err)
+	e.SetArtifactCache(artifacts)
+
+	_, err = e.Generate(context.Background(), "QmModelA", GenerateRequest{Prompt: "a"})
+	require.NoError(t, err)
+	require.Equal(t, 1, artifacts.Stats().Pinned)
+
+	// Evicting the worker unpins its model, which stays cached
+	_, err = e.Generate(context.Background(), "QmModelB", GenerateRequest{Prompt: "b"})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		stats := artifacts.Stats()
+		return stats.Entries == 2 && stats.Pinned == 1
+	}, 5*time.Second, 20*time.Millisecond)
+	for _, entry := range artifacts.Entries() {
+		require.Equal(t, entry.CID == "QmModelB", entry.Pins == 1, entry.CID)
+	}
+}
+
+func TestWorkerPool_ReservesResources(t *testing.T) {
+	fakeWorker(t, `{"text": "PID", "finish_reason": "stop"}`)
+	manager := resource.NewManagerWithCapacity(4, 8, 0, 100)
+	e := NewExecutor(manager)
+	e.SetWorkDir(t.TempDir())
+	e.SetWorkerPoolConfig(WorkerPoolConfig{MaxModels: 1, Requirements: resource.Requirements{CPU: 2, MemoryGB: 4}})
+
+	_, err := e.Generate(context.Background(), newModelDir(t), GenerateRequest{Prompt: "a"})
+	require.NoError(t, err)
+	allocations := manager.GetAllocations()
+	require.Len(t, allocations, 1)
+	require.Equal(t, 2, allocations[0].CPU)
+	require.Equal(t, uint64(4), allocations[0].Memory)
+
+	// A task cannot take what the worker holds; stopping the worker frees it
+	require.Error(t, manager.Allocate("task-1", resource.Requirements{CPU: 3}))
+	e.Stop()
+	require.Eventually(t, func() bool { return len(manager.GetAllocations()) == 0 }, 5*time.Second, 20*time.Millisecond)
+}
@@ -2,26 +2,123 @@ package health
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/atlas/node/network"
+	"github.com/atlas/node/resource"
+	"github.com/atlas/storage/identity"
 	"github.com/ipfs/go-ipfs-api"
 )
 
 type Monitor struct {
 	nodeID    string
+	signer    *identity.Identity
 	ipfsAPI   *api.Shell
 	lastBeat  time.Time
+	onBeat    func(err error)
+	load      func() resource.Utilization
+	peers     func() []network.PeerMeasurement
+	submit    func(ctx context.Context, beat ChainHeartbeat) error
+	submitErr string // Last chain submission error, printed once
 }
 
-func NewMonitor() *Monitor {
+// Heartbeat is the message published on the node's heartbeat topic
+type Heartbeat struct {
+	NodeID      string                `json:"node_id"`
+	Timestamp   string                `json:"timestamp"`
+	Utilization *resource.Utilization `json:"utilization,omitempty"`
+
+	// Peers is this node's row of the bandwidth matrix
+	Peers []network.PeerMeasurement `json:"peers,omitempty"`
+
+	// Signature is the node's ed25519 signature of the heartbeat without it
+	Signature string `json:"signature,omitempty"`
+}
+
+// ChainHeartbeat is the heartbeat submitted to the chain as the compute
+// module's MsgUpdateHeartbeat, which records the node as online along with
+// its utilization
+type ChainHeartbeat struct {
+	NodeID      string
+	Timestamp   int64 // Unix seconds; the chain rejects stale or replayed ones
+	Utilization *ChainUtilization
+	Signature   string // Of Message, with identity.DomainHeartbeat
+}
+
+// ChainUtilization is the chain's NodeUtilization: percentages from 0 to
+// 100 over the sampling window
+type ChainUtilization struct {
+	CPUPercent    float64
+	MemoryPercent float64
+	DiskPercent   float64
+	GPUPercent    float64
+	HasGPU        bool
+	ActiveTasks   uint32
+	WindowSeconds uint32
+}
+
+// Message is what the node signs, as the chain's HeartbeatMessage: the node
+// ID, the timestamp and, if reported, the utilization fields, one per line
+// and space-separated respectively
+func (b ChainHeartbeat) Message() []byte {
+	lines := []string{b.NodeID, strconv.FormatInt(b.Timestamp, 10)}
+	if u := b.Utilization; u != nil {
+		lines = append(lines, strings.Join([]string{
+			strconv.FormatFloat(u.CPUPercent, 'g', -1, 64),
+			strconv.FormatFloat(u.MemoryPercent, 'g', -1, 64),
+			strconv.FormatFloat(u.DiskPercent, 'g', -1, 64),
+			strconv.FormatFloat(u.GPUPercent, 'g', -1, 64),
+			strconv.FormatBool(u.HasGPU),
+			strconv.FormatUint(uint64(u.ActiveTasks), 10),
+			strconv.FormatUint(uint64(u.WindowSeconds), 10),
+		}, " "))
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// NewMonitor creates a monitor sending heartbeats for nodeID, signed with
+// signer
+func NewMonitor(nodeID string, signer *identity.Identity) *Monitor {
 	return &Monitor{
-		nodeID:   generateNodeID(),
+		nodeID:   nodeID,
+		signer:   signer,
 		ipfsAPI:  api.NewShell("localhost:5001"),
 		lastBeat: time.Now(),
 	}
 }
 
+// OnHeartbeat sets a function called with the outcome of every heartbeat.
+// It must be set before Start.
+func (m *Monitor) OnHeartbeat(fn func(err error)) {
+	m.onBeat = fn
+}
+
+// SetUtilization sets the function whose summary is sent with every
+// heartbeat, usually a resource.Sampler's Summary. It must be set before
+// Start.
+func (m *Monitor) SetUtilization(fn func() resource.Utilization) {
+	m.load = fn
+}
+
+// SetPeers sets the function whose measurements of other nodes are sent
+// with every heartbeat, usually the Row of a network.Prober's matrix. It
+// must be set before Start.
+func (m *Monitor) SetPeers(fn func() []network.PeerMeasurement) {
+	m.peers = fn
+}
+
+// SetChainSubmitter sets the function every signed heartbeat is also
+// submitted to the chain with, usually a validator.BlockchainClient's
+// SubmitHeartbeat. Monitors without a signer submit nothing, since the chain
+// only accepts signed heartbeats. It must be set before Start.
+func (m *Monitor) SetChainSubmitter(fn func(ctx context.Context, beat ChainHeartbeat) error) {
+	m.submit = fn
+}
+
 func (m *Monitor) Start(ctx context.Context) error {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
@@ -32,18 +129,100 @@ func (m *Monitor) Start(ctx context.Context) error {
 			return ctx.Err()
 		case <-ticker.C:
 			m.sendHeartbeat()
+			m.submitHeartbeat(ctx)
 		}
 	}
 }
 
 func (m *Monitor) sendHeartbeat() {
 	topic := fmt.Sprintf("/atlas/heartbeat/%s", m.nodeID)
-	message := fmt.Sprintf(`{"node_id":"%s","timestamp":"%s"}`, m.nodeID, time.Now().Format(time.RFC3339))
-	m.ipfsAPI.PubSubPublish(topic, message)
+	err := m.ipfsAPI.PubSubPublish(topic, string(m.heartbeat()))
+	if m.onBeat != nil {
+		m.onBeat(err)
+	}
+	if err != nil {
+		return
+	}
 	m.lastBeat = time.Now()
 }
 
-func generateNodeID() string {
-	return fmt.Sprintf("node-%d", time.Now().UnixNano())
+// submitHeartbeat submits a signed heartbeat to the chain. A failure is
+// printed when it differs from the last one, so an unreachable chain does
+// not flood the log.
+func (m *Monitor) submitHeartbeat(ctx context.Context) {
+	if m.submit == nil || m.signer == nil {
+		return
+	}
+	err := m.submit(ctx, m.chainHeartbeat(time.Now()))
+	if err == nil {
+		m.submitErr = ""
+		return
+	}
+	if err.Error() != m.submitErr {
+		fmt.Printf("Warning: failed to submit heartbeat to chain: %v\n", err)
+		m.submitErr = err.Error()
+	}
+}
+
+// chainHeartbeat builds and signs the heartbeat submitted to the chain at
+// now
+func (m *Monitor) chainHeartbeat(now time.Time) ChainHeartbeat {
+	beat := ChainHeartbeat{NodeID: m.nodeID, Timestamp: now.Unix()}
+	if m.load != nil {
+		load := m.load()
+		beat.Utilization = &ChainUtilization{
+			CPUPercent:    load.CPU,
+			MemoryPercent: load.Memory,
+			DiskPercent:   load.Disk,
+			ActiveTasks:   uint32(load.ActiveTasks),
+			WindowSeconds: uint32(load.WindowSeconds),
+		}
+		if load.GPU != nil {
+			beat.Utilization.HasGPU = true
+			beat.Utilization.GPUPercent = *load.GPU
+		}
+	}
+	if m.signer != nil {
+		beat.Signature = m.signer.Sign(identity.DomainHeartbeat, beat.Message())
+	}
+	return beat
+}
+
+// heartbeat encodes the next heartbeat message
+func (m *Monitor) heartbeat() []byte {
+	beat := Heartbeat{NodeID: m.nodeID, Timestamp: time.Now().Format(time.RFC3339)}
+	if m.load != nil {
+		utilization := m.load()
+		beat.Utilization = &utilization
+	}
+	if m.peers != nil {
+		beat.Peers = m.peers()
+	}
+	if m.signer != nil {
+		unsigned, _ := json.Marshal(beat)
+		beat.Signature = m.signer.Sign(identity.DomainHeartbeat, unsigned)
+	}
+	message, _ := json.Marshal(beat)
+	return message
+}
+
+// VerifyHeartbeat decodes a heartbeat and checks its signature against
+// publicKey, the key its node registered on chain
+func VerifyHeartbeat(data []byte, publicKey string) (*Heartbeat, error) {
+	var beat Heartbeat
+	if err := json.Unmarshal(data, &beat); err != nil {
+		return nil, fmt.Errorf("invalid heartbeat: %w", err)
+	}
+	signature := beat.Signature
+	beat.Signature = ""
+	unsigned, err := json.Marshal(beat)
+	if err != nil {
+		return nil, fmt.Errorf("invalid heartbeat: %w", err)
+	}
+	if err := identity.Verify(publicKey, identity.DomainHeartbeat, unsigned, signature); err != nil {
+		return nil, fmt.Errorf("heartbeat from %s: %w", beat.NodeID, err)
+	}
+	beat.Signature = signature
+	return &beat, nil
 }
 
@@ -0,0 +1,106 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/atlas/node/network"
+	"github.com/atlas/node/resource"
+	"github.com/atlas/storage/identity"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeartbeatUtilization(t *testing.T) {
+	m := NewMonitor("node-1", nil)
+
+	var beat map[string]interface{}
+	require.NoError(t, json.Unmarshal(m.heartbeat(), &beat))
+	require.Equal(t, m.nodeID, beat["node_id"])
+	require.NotContains(t, beat, "utilization")
+
+	gpu := 12.5
+	m.SetUtilization(func() resource.Utilization {
+		return resource.Utilization{CPU: 40, Memory: 55.5, Disk: 20, GPU: &gpu, ActiveTasks: 2, WindowSeconds: 300, Samples: 30}
+	})
+	var withLoad Heartbeat
+	require.NoError(t, json.Unmarshal(m.heartbeat(), &withLoad))
+	require.NotNil(t, withLoad.Utilization)
+	require.Equal(t, 40.0, withLoad.Utilization.CPU)
+	require.Equal(t, 12.5, *withLoad.Utilization.GPU)
+	require.Equal(t, 2, withLoad.Utilization.ActiveTasks)
+	require.Empty(t, withLoad.Peers)
+
+	m.SetPeers(func() []network.PeerMeasurement {
+		return []network.PeerMeasurement{{Peer: "node-2", Address: "10.0.0.2:7947", LatencyMs: 0.4, DownloadMbps: 940}}
+	})
+	var withPeers Heartbeat
+	require.NoError(t, json.Unmarshal(m.heartbeat(), &withPeers))
+	require.Len(t, withPeers.Peers, 1)
+	require.Equal(t, "node-2", withPeers.Peers[0].Peer)
+	require.Equal(t, 940.0, withPeers.Peers[0].DownloadMbps)
+}
+
+func TestHeartbeatSignature(t *testing.T) {
+	signer, err := identity.Generate()
+	require.NoError(t, err)
+	other, err := identity.Generate()
+	require.NoError(t, err)
+
+	m := NewMonitor("node-1", signer)
+	m.SetUtilization(func() resource.Utilization {
+		return resource.Utilization{CPU: 40.3, Memory: 55.5, WindowSeconds: 300, Samples: 30}
+	})
+	data := m.heartbeat()
+
+	beat, err := VerifyHeartbeat(data, signer.PublicKey())
+	require.NoError(t, err)
+	require.Equal(t, "node-1", beat.NodeID)
+	require.Equal(t, 40.3, beat.Utilization.CPU)
+
+	_, err = VerifyHeartbeat(data, other.PublicKey())
+	require.ErrorIs(t, err, identity.ErrInvalidSignature)
+
+	// Unsigned and altered heartbeats are rejected
+	_, err = VerifyHeartbeat(NewMonitor("node-1", nil).heartbeat(), signer.PublicKey())
+	require.ErrorIs(t, err, identity.ErrInvalidSignature)
+	altered := bytes.Replace(data, []byte(`"cpu":40.3`), []byte(`"cpu":1`), 1)
+	require.NotEqual(t, data, altered)
+	_, err = VerifyHeartbeat(altered, signer.PublicKey())
+	require.ErrorIs(t, err, identity.ErrInvalidSignature)
+}
+
+func TestChainHeartbeat(t *testing.T) {
+	signer, err := identity.Generate()
+	require.NoError(t, err)
+
+	m := NewMonitor("node-1", signer)
+	gpu := 80.0
+	m.SetUtilization(func() resource.Utilization {
+		return resource.Utilization{CPU: 42.5, Memory: 60, GPU: &gpu, ActiveTasks: 2, WindowSeconds: 300, Samples: 30}
+	})
+	var submitted []ChainHeartbeat
+	m.SetChainSubmitter(func(ctx context.Context, beat ChainHeartbeat) error {
+		submitted = append(submitted, beat)
+		return nil
+	})
+
+	// The message matches the chain's HeartbeatMessage byte for byte
+	beat := m.chainHeartbeat(time.Unix(1700000000, 0))
+	require.Equal(t, "node-1\n1700000000\n42.5 60 0 80 true 2 300", string(beat.Message()))
+	require.NoError(t, identity.Verify(signer.PublicKey(), identity.DomainHeartbeat, beat.Message(), beat.Signature))
+	require.Equal(t, "node-1\n1700000000", string(ChainHeartbeat{NodeID: "node-1", Timestamp: 1700000000}.Message()))
+
+	m.submitHeartbeat(context.Background())
+	require.Len(t, submitted, 1)
+	require.NotZero(t, submitted[0].Timestamp)
+	require.NotEmpty(t, submitted[0].Signature)
+
+	// Unsigned heartbeats would be rejected, so none are submitted
+	unsigned := NewMonitor("node-1", nil)
+	unsigned.SetChainSubmitter(m.submit)
+	unsigned.submitHeartbeat(context.Background())
+	require.Len(t, submitted, 1)
+}
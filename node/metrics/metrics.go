@@ -0,0 +1,264 @@
+// Package metrics exports the node's Prometheus metrics. Each Metrics has
+// its own registry, so tests can inspect what a node recorded.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/atlas/node/executor"
+	"github.com/atlas/node/resource"
+	"github.com/atlas/storage/cache"
+	"github.com/atlas/storage/manager"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// TaskLister lists the executor's tasks; *executor.Executor implements it
+type TaskLister interface {
+	ListTaskSnapshots() []executor.Task
+}
+
+// ResourceSource reports capacity and allocations; *resource.Manager
+// implements it
+type ResourceSource interface {
+	Capacity() resource.Requirements
+	Allocated() resource.Requirements
+}
+
+// CacheSource reports artifact cache counters; *cache.Cache implements it
+type CacheSource interface {
+	Stats() cache.Stats
+}
+
+// Sources are read each time the metrics are scraped. Nil sources are
+// skipped.
+type Sources struct {
+	Tasks     TaskLister
+	Resources ResourceSource
+	Cache     CacheSource
+	IPFS      func() manager.TransferStats
+}
+
+// Metrics records the node's metrics. It implements executor.Observer.
+// A nil *Metrics records nothing.
+type Metrics struct {
+	registry         *prometheus.Registry
+	taskDuration     *prometheus.HistogramVec
+	inferenceLatency *prometheus.HistogramVec
+	inferenceErrors  *prometheus.CounterVec
+	heartbeats       *prometheus.CounterVec
+	chainTxFailures  *prometheus.CounterVec
+}
+
+// New creates the node's metrics in a new registry
+func New(sources Sources) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		taskDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "atlas_task_duration_seconds",
+			Help:    "Duration of task runs by task type and final status.",
+			Buckets: prometheus.ExponentialBuckets(1, 4, 9), // 1s to ~18h
+		}, []string{"type", "status"}),
+		inferenceLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "atlas_inference_duration_seconds",
+			Help:    "Latency of inference requests on model workers, including queueing and model loading.",
+			Buckets: prometheus.ExponentialBuckets(0.01, 2, 14), // 10ms to ~80s
+		}, []string{"model", "operation"}),
+		inferenceErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "atlas_inference_errors_total",
+			Help: "Inference requests that failed.",
+		}, []string{"model", "operation"}),
+		heartbeats: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "atlas_heartbeats_total",
+			Help: "Heartbeats sent by result.",
+		}, []string{"result"}),
+		chainTxFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "atlas_chain_tx_failures_total",
+			Help: "Chain transactions that failed by message type.",
+		}, []string{"msg"}),
+	}
+	m.registry.MustRegister(
+		m.taskDuration,
+		m.inferenceLatency,
+		m.inferenceErrors,
+		m.heartbeats,
+		m.chainTxFailures,
+		&sourceCollector{sources: sources},
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Registry returns the registry holding the node's metrics
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ListenAndServe serves the metrics at /metrics on address until ctx is
+// cancelled
+func (m *Metrics) ListenAndServe(ctx context.Context, address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("metrics server failed: %w", err)
+	}
+	return nil
+}
+
+// TaskFinished records the duration of a task run
+func (m *Metrics) TaskFinished(task executor.Task, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.taskDuration.WithLabelValues(taskType(task), task.Status).Observe(duration.Seconds())
+}
+
+// InferenceFinished records the latency of an inference request
+func (m *Metrics) InferenceFinished(modelPath string, operation string, latency time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.inferenceLatency.WithLabelValues(modelPath, operation).Observe(latency.Seconds())
+	if err != nil {
+		m.inferenceErrors.WithLabelValues(modelPath, operation).Inc()
+	}
+}
+
+// Heartbeat records the outcome of a heartbeat
+func (m *Metrics) Heartbeat(err error) {
+	if m == nil {
+		return
+	}
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	m.heartbeats.WithLabelValues(result).Inc()
+}
+
+// ChainTxFailed records a chain transaction of type msg that failed
+func (m *Metrics) ChainTxFailed(msg string) {
+	if m == nil {
+		return
+	}
+	m.chainTxFailures.WithLabelValues(msg).Inc()
+}
+
+func taskType(task executor.Task) string {
+	if task.TaskType == "" {
+		return "unknown"
+	}
+	return task.TaskType
+}
+
+var (
+	tasksDesc = prometheus.NewDesc("atlas_tasks",
+		"Tasks known to the executor by type and status.", []string{"type", "status"}, nil)
+	resourceCapacityDesc = prometheus.NewDesc("atlas_resource_capacity",
+		"Node capacity by resource (cpu, memory_gb, gpus, disk_gb).", []string{"resource"}, nil)
+	resourceAllocatedDesc = prometheus.NewDesc("atlas_resource_allocated",
+		"Resources reserved by running tasks.", []string{"resource"}, nil)
+	ipfsBytesDesc = prometheus.NewDesc("atlas_ipfs_transfer_bytes_total",
+		"Bytes moved to and from IPFS by direction.", []string{"direction"}, nil)
+	ipfsErrorsDesc = prometheus.NewDesc("atlas_ipfs_transfer_errors_total",
+		"Failed IPFS transfers by direction.", []string{"direction"}, nil)
+	cacheHitsDesc = prometheus.NewDesc("atlas_cache_hits_total",
+		"Artifact cache lookups served from disk.", nil, nil)
+	cacheMissesDesc = prometheus.NewDesc("atlas_cache_misses_total",
+		"Artifact cache lookups that fetched the artifact.", nil, nil)
+	cacheHitRatioDesc = prometheus.NewDesc("atlas_cache_hit_ratio",
+		"Fraction of artifact cache lookups served from disk.", nil, nil)
+	cacheEvictionsDesc = prometheus.NewDesc("atlas_cache_evictions_total",
+		"Artifacts evicted from the cache.", nil, nil)
+	cacheSizeDesc = prometheus.NewDesc("atlas_cache_size_bytes",
+		"Size of the artifacts in the cache.", nil, nil)
+	cacheQuotaDesc = prometheus.NewDesc("atlas_cache_quota_bytes",
+		"Disk quota of the artifact cache (0 for none).", nil, nil)
+)
+
+// sourceCollector reads its sources when the metrics are scraped
+type sourceCollector struct {
+	sources Sources
+}
+
+func (c *sourceCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		tasksDesc, resourceCapacityDesc, resourceAllocatedDesc, ipfsBytesDesc, ipfsErrorsDesc,
+		cacheHitsDesc, cacheMissesDesc, cacheHitRatioDesc, cacheEvictionsDesc, cacheSizeDesc, cacheQuotaDesc,
+	} {
+		ch <- desc
+	}
+}
+
+func (c *sourceCollector) Collect(ch chan<- prometheus.Metric) {
+	if c.sources.Tasks != nil {
+		counts := make(map[[2]string]int)
+		for _, task := range c.sources.Tasks.ListTaskSnapshots() {
+			counts[[2]string{taskType(task), task.Status}]++
+		}
+		for key, count := range counts {
+			ch <- prometheus.MustNewConstMetric(tasksDesc, prometheus.GaugeValue, float64(count), key[0], key[1])
+		}
+	}
+
+	if c.sources.Resources != nil {
+		collectRequirements(ch, resourceCapacityDesc, c.sources.Resources.Capacity())
+		collectRequirements(ch, resourceAllocatedDesc, c.sources.Resources.Allocated())
+	}
+
+	if c.sources.IPFS != nil {
+		stats := c.sources.IPFS()
+		ch <- prometheus.MustNewConstMetric(ipfsBytesDesc, prometheus.CounterValue, float64(stats.BytesDownloaded), "download")
+		ch <- prometheus.MustNewConstMetric(ipfsBytesDesc, prometheus.CounterValue, float64(stats.BytesUploaded), "upload")
+		ch <- prometheus.MustNewConstMetric(ipfsErrorsDesc, prometheus.CounterValue, float64(stats.DownloadErrors), "download")
+		ch <- prometheus.MustNewConstMetric(ipfsErrorsDesc, prometheus.CounterValue, float64(stats.UploadErrors), "upload")
+	}
+
+	if c.sources.Cache != nil {
+		stats := c.sources.Cache.Stats()
+		ratio := 0.0
+		if lookups := stats.Hits + stats.Misses; lookups > 0 {
+			ratio = float64(stats.Hits) / float64(lookups)
+		}
+		ch <- prometheus.MustNewConstMetric(cacheHitsDesc, prometheus.CounterValue, float64(stats.Hits))
+		ch <- prometheus.MustNewConstMetric(cacheMissesDesc, prometheus.CounterValue, float64(stats.Misses))
+		ch <- prometheus.MustNewConstMetric(cacheHitRatioDesc, prometheus.GaugeValue, ratio)
+		ch <- prometheus.MustNewConstMetric(cacheEvictionsDesc, prometheus.CounterValue, float64(stats.Evictions))
+		ch <- prometheus.MustNewConstMetric(cacheSizeDesc, prometheus.GaugeValue, float64(stats.Size))
+		ch <- prometheus.MustNewConstMetric(cacheQuotaDesc, prometheus.GaugeValue, float64(stats.Quota))
+	}
+}
+
+func collectRequirements(ch chan<- prometheus.Metric, desc *prometheus.Desc, req resource.Requirements) {
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(req.CPU), "cpu")
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(req.MemoryGB), "memory_gb")
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(req.GPUs), "gpus")
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(req.DiskGB), "disk_gb")
+}
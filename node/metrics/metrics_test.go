@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atlas/node/executor"
+	"github.com/atlas/node/resource"
+	"github.com/atlas/storage/cache"
+	"github.com/atlas/storage/manager"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTasks []executor.Task
+
+func (f fakeTasks) ListTaskSnapshots() []executor.Task { return f }
+
+type fakeCache cache.Stats
+
+func (f fakeCache) Stats() cache.Stats { return cache.Stats(f) }
+
+func TestMetrics_Sources(t *testing.T) {
+	resources := resource.NewManagerWithCapacity(8, 32, 1, 100)
+	require.NoError(t, resources.Allocate("task-1", resource.Requirements{CPU: 2, MemoryGB: 4}))
+
+	m := New(Sources{
+		Tasks: fakeTasks{
+			{ID: "a", TaskType: "training", Status: "completed"},
+			{ID: "b", TaskType: "training", Status: "completed"},
+			{ID: "c", TaskType: "inference", Status: "in_progress"},
+		},
+		Resources: resources,
+		Cache:     fakeCache{Hits: 3, Misses: 1, Size: 1024},
+		IPFS: func() manager.TransferStats {
+			return manager.TransferStats{BytesDownloaded: 2048, DownloadErrors: 1}
+		},
+	})
+
+	expected := `
+# HELP atlas_tasks Tasks known to the executor by type and status.
+# TYPE atlas_tasks gauge
+atlas_tasks{status="completed",type="training"} 2
+atlas_tasks{status="in_progress",type="inference"} 1
+# HELP atlas_resource_allocated Resources reserved by running tasks.
+# TYPE atlas_resource_allocated gauge
+atlas_resource_allocated{resource="cpu"} 2
+atlas_resource_allocated{resource="disk_gb"} 0
+atlas_resource_allocated{resource="gpus"} 0
+atlas_resource_allocated{resource="memory_gb"} 4
+# HELP atlas_cache_hit_ratio Fraction of artifact cache lookups served from disk.
+# TYPE atlas_cache_hit_ratio gauge
+atlas_cache_hit_ratio 0.75
+# HELP atlas_ipfs_transfer_bytes_total Bytes moved to and from IPFS by direction.
+# TYPE atlas_ipfs_transfer_bytes_total counter
+atlas_ipfs_transfer_bytes_total{direction="download"} 2048
+atlas_ipfs_transfer_bytes_total{direction="upload"} 0
+`
+	require.NoError(t, testutil.GatherAndCompare(m.Registry(), strings.NewReader(expected),
+		"atlas_tasks", "atlas_resource_allocated", "atlas_cache_hit_ratio", "atlas_ipfs_transfer_bytes_total"))
+}
+
+func TestMetrics_Events(t *testing.T) {
+	m := New(Sources{})
+	m.TaskFinished(executor.Task{TaskType: "training", Status: "failed"}, 90*time.Second)
+	m.InferenceFinished("QmModel", "chat", 250*time.Millisecond, nil)
+	m.InferenceFinished("QmModel", "chat", time.Second, errors.New("worker exited"))
+	m.Heartbeat(nil)
+	m.Heartbeat(errors.New("ipfs down"))
+	m.Heartbeat(nil)
+	m.ChainTxFailed("register_node")
+
+	require.Equal(t, 2.0, testutil.ToFloat64(m.heartbeats.WithLabelValues("success")))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.heartbeats.WithLabelValues("failure")))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.inferenceErrors.WithLabelValues("QmModel", "chat")))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.chainTxFailures.WithLabelValues("register_node")))
+	count, err := testutil.GatherAndCount(m.Registry(), "atlas_task_duration_seconds", "atlas_inference_duration_seconds")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	// A nil Metrics records nothing
+	var disabled *Metrics
+	disabled.Heartbeat(nil)
+	disabled.TaskFinished(executor.Task{}, time.Second)
+
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(recorder.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), `atlas_inference_duration_seconds_count{model="QmModel",operation="chat"} 2`)
+}
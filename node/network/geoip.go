@@ -0,0 +1,76 @@
+package network
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// GeoIPDB resolves IP addresses to locations from a local MaxMind-format
+// (mmdb) database such as GeoLite2-City or DB-IP City Lite
+type GeoIPDB struct {
+	reader *maxminddb.Reader
+}
+
+// geoIPRecord holds the fields read from a city database record
+type geoIPRecord struct {
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	Location struct {
+		Latitude  float64 `maxminddb:"latitude"`
+		Longitude float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+}
+
+// OpenGeoIPDB opens an mmdb file
+func OpenGeoIPDB(path string) (*GeoIPDB, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database: %w", err)
+	}
+	return &GeoIPDB{reader: reader}, nil
+}
+
+// Lookup returns the location of ip, with English names
+func (db *GeoIPDB) Lookup(ip string) (*Geolocation, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, fmt.Errorf("invalid IP address %q", ip)
+	}
+	var record geoIPRecord
+	_, found, err := db.reader.LookupNetwork(parsed, &record)
+	if err != nil {
+		return nil, fmt.Errorf("GeoIP lookup of %s failed: %w", ip, err)
+	}
+	if !found {
+		return nil, fmt.Errorf("%s is not in the GeoIP database", ip)
+	}
+
+	geo := &Geolocation{
+		IP:          ip,
+		Country:     record.Country.Names["en"],
+		CountryCode: record.Country.ISOCode,
+		City:        record.City.Names["en"],
+		Lat:         record.Location.Latitude,
+		Lon:         record.Location.Longitude,
+		Source:      LocationGeoIP,
+	}
+	if len(record.Subdivisions) > 0 {
+		geo.Region = record.Subdivisions[0].Names["en"]
+	}
+	return geo, nil
+}
+
+// Close closes the database file
+func (db *GeoIPDB) Close() error {
+	return db.reader.Close()
+}
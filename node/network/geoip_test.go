@@ -0,0 +1,76 @@
+package network
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// geoip-city-test.mmdb is an IPv4 city database holding 81.2.69.0/24
+// (London) and 89.160.20.128/25 (Linköping)
+var testGeoIPDB = filepath.Join("testdata", "geoip-city-test.mmdb")
+
+func TestGeoIPDB(t *testing.T) {
+	db, err := OpenGeoIPDB(testGeoIPDB)
+	require.NoError(t, err)
+	defer db.Close()
+
+	geo, err := db.Lookup("81.2.69.142")
+	require.NoError(t, err)
+	require.Equal(t, &Geolocation{
+		IP:          "81.2.69.142",
+		Country:     "United Kingdom",
+		CountryCode: "GB",
+		Region:      "England",
+		City:        "London",
+		Lat:         51.5142,
+		Lon:         -0.0931,
+		Source:      LocationGeoIP,
+	}, geo)
+
+	geo, err = db.Lookup("89.160.20.130")
+	require.NoError(t, err)
+	require.Equal(t, "Linköping", geo.City)
+	require.Equal(t, "SE", geo.CountryCode)
+
+	_, err = db.Lookup("10.0.0.1")
+	require.ErrorContains(t, err, "not in the GeoIP database")
+	_, err = db.Lookup("not-an-ip")
+	require.Error(t, err)
+
+	_, err = OpenGeoIPDB(filepath.Join(t.TempDir(), "missing.mmdb"))
+	require.Error(t, err)
+}
+
+func TestDetectLocation(t *testing.T) {
+	ctx := context.Background()
+
+	// A declared location wins over the database
+	geo, err := DetectLocation(ctx, LocationConfig{
+		Declared: Geolocation{IP: "81.2.69.142", CountryCode: "DE", City: "Berlin"},
+		GeoIPDB:  testGeoIPDB,
+		Offline:  true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Berlin", geo.City)
+	require.Equal(t, LocationDeclared, geo.Source)
+
+	geo, err = DetectLocation(ctx, LocationConfig{
+		Declared: Geolocation{IP: "81.2.69.142"},
+		GeoIPDB:  testGeoIPDB,
+		Offline:  true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "London", geo.City)
+	require.Equal(t, LocationGeoIP, geo.Source)
+
+	// Offline, the public IP has to be configured
+	_, err = DetectLocation(ctx, LocationConfig{GeoIPDB: testGeoIPDB, Offline: true})
+	require.Error(t, err)
+
+	geo, err = DetectLocation(ctx, LocationConfig{Offline: true})
+	require.NoError(t, err)
+	require.Nil(t, geo)
+}
@@ -9,15 +9,81 @@ import (
 	"time"
 )
 
+// Sources of a Geolocation
+const (
+	LocationDeclared = "declared"
+	LocationGeoIP    = "geoip"
+	LocationOnline   = "online"
+)
+
 type Geolocation struct {
-	IP      string
-	Country string
-	Region  string
-	City    string
-	Lat     float64
-	Lon     float64
+	IP          string
+	Country     string
+	CountryCode string // ISO 3166-1 alpha-2
+	Region      string
+	City        string
+	Lat         float64
+	Lon         float64
+	Source      string // LocationDeclared, LocationGeoIP or LocationOnline
+}
+
+// LocationConfig selects how DetectLocation finds the node's location
+type LocationConfig struct {
+	// Declared is the location configured by the operator, used as is if
+	// it names a place or coordinates. Its IP, if set, is the public IP
+	// looked up otherwise.
+	Declared Geolocation
+
+	// GeoIPDB is the path of a MaxMind-format (mmdb) city database
+	GeoIPDB string
+
+	// Offline forbids asking ipify.org for the public IP and ip-api.com
+	// for its location
+	Offline bool
+}
+
+// DetectLocation returns the declared location if there is one. Otherwise
+// it looks the public IP up in the GeoIP database, or online if there is
+// none. It returns nil without an error if no source is available.
+func DetectLocation(ctx context.Context, config LocationConfig) (*Geolocation, error) {
+	declared := config.Declared
+	if declared.Country != "" || declared.CountryCode != "" || declared.Region != "" || declared.City != "" || declared.Lat != 0 || declared.Lon != 0 {
+		declared.Source = LocationDeclared
+		return &declared, nil
+	}
+	if config.GeoIPDB == "" && config.Offline {
+		return nil, nil
+	}
+
+	ip := declared.IP
+	if ip == "" {
+		if config.Offline {
+			return nil, fmt.Errorf("a GeoIP lookup needs the node's public IP when online lookups are disabled")
+		}
+		var err error
+		if ip, err = getPublicIP(ctx); err != nil {
+			return nil, fmt.Errorf("failed to get public IP: %w", err)
+		}
+	}
+
+	if config.GeoIPDB != "" {
+		db, err := OpenGeoIPDB(config.GeoIPDB)
+		if err != nil {
+			return nil, err
+		}
+		defer db.Close()
+		return db.Lookup(ip)
+	}
+
+	geo, err := getLocationFromIP(ctx, ip)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get geolocation: %w", err)
+	}
+	geo.IP = ip
+	return geo, nil
 }
 
+// GetGeolocation looks the node's public IP up online
 func GetGeolocation(ctx context.Context) (*Geolocation, error) {
 	ip, err := getPublicIP(ctx)
 	if err != nil {
@@ -78,6 +144,7 @@ func getLocationFromIP(ctx context.Context, ip string) (*Geolocation, error) {
 	var result struct {
 		Status      string  `json:"status"`
 		Country     string  `json:"country"`
+		CountryCode string  `json:"countryCode"`
 		RegionName  string  `json:"regionName"`
 		City        string  `json:"city"`
 		Lat         float64 `json:"lat"`
@@ -94,10 +161,12 @@ func getLocationFromIP(ctx context.Context, ip string) (*Geolocation, error) {
 	
 	return &Geolocation{
 		Country: result.Country,
+		CountryCode: result.CountryCode,
 		Region:  result.RegionName,
 		City:   result.City,
 		Lat:    result.Lat,
 		Lon:    result.Lon,
+		Source: LocationOnline,
 	}, nil
 }
 
@@ -0,0 +1,222 @@
+package network
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Defaults of ProberConfig
+const (
+	DefaultProbeSample   = 3
+	DefaultProbeInterval = 10 * time.Minute
+	probeTimeout         = 30 * time.Second
+)
+
+// Peer is another node's probe server
+type Peer struct {
+	ID      string
+	Address string // host:port
+}
+
+// Matrix holds the latest measurement between pairs of nodes. A node
+// fills in its own row; rows reported by other nodes can be merged in
+// with Set.
+type Matrix struct {
+	rows map[string]map[string]PeerMeasurement
+	mu   sync.RWMutex
+}
+
+// NewMatrix creates an empty matrix
+func NewMatrix() *Matrix {
+	return &Matrix{rows: make(map[string]map[string]PeerMeasurement)}
+}
+
+// Set records a measurement from one node to measurement.Peer, replacing
+// the previous one
+func (m *Matrix) Set(from string, measurement PeerMeasurement) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	row, ok := m.rows[from]
+	if !ok {
+		row = make(map[string]PeerMeasurement)
+		m.rows[from] = row
+	}
+	row[measurement.Peer] = measurement
+}
+
+// Get returns the latest measurement from one node to another
+func (m *Matrix) Get(from string, to string) (PeerMeasurement, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	measurement, ok := m.rows[from][to]
+	return measurement, ok
+}
+
+// Row returns the measurements from a node, the reachable peers first in
+// order of latency
+func (m *Matrix) Row(from string) []PeerMeasurement {
+	m.mu.RLock()
+	row := make([]PeerMeasurement, 0, len(m.rows[from]))
+	for _, measurement := range m.rows[from] {
+		row = append(row, measurement)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(row, func(i, j int) bool {
+		if (row[i].Error == "") != (row[j].Error == "") {
+			return row[i].Error == ""
+		}
+		if row[i].LatencyMs != row[j].LatencyMs {
+			return row[i].LatencyMs < row[j].LatencyMs
+		}
+		return row[i].Peer < row[j].Peer
+	})
+	return row
+}
+
+// Snapshot copies the matrix, indexed by source and then destination node
+func (m *Matrix) Snapshot() map[string]map[string]PeerMeasurement {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	snapshot := make(map[string]map[string]PeerMeasurement, len(m.rows))
+	for from, row := range m.rows {
+		snapshot[from] = make(map[string]PeerMeasurement, len(row))
+		for to, measurement := range row {
+			snapshot[from][to] = measurement
+		}
+	}
+	return snapshot
+}
+
+// ProberConfig configures a Prober
+type ProberConfig struct {
+	Self       string        // ID of this node, the matrix row it fills in
+	Peers      []Peer        // Peers to choose from
+	SampleSize int           // Peers probed per round (default DefaultProbeSample)
+	Bytes      int64         // Bytes transferred each way (default DefaultProbeBytes)
+	Interval   time.Duration // Time between rounds (default DefaultProbeInterval)
+}
+
+// Prober measures the bandwidth and latency to a sample of peers each
+// round, choosing those measured longest ago, and keeps the results in a
+// Matrix
+type Prober struct {
+	config ProberConfig
+	matrix *Matrix
+}
+
+// NewProber creates a prober. It probes only when Start runs or Probe is
+// called.
+func NewProber(config ProberConfig) *Prober {
+	if config.SampleSize <= 0 {
+		config.SampleSize = DefaultProbeSample
+	}
+	if config.Bytes <= 0 {
+		config.Bytes = DefaultProbeBytes
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultProbeInterval
+	}
+	return &Prober{config: config, matrix: NewMatrix()}
+}
+
+// Matrix returns the matrix the prober fills in
+func (p *Prober) Matrix() *Matrix {
+	return p.matrix
+}
+
+// Start probes a sample of peers every interval until ctx is cancelled.
+// The first round is an interval away; call Probe for one right away.
+func (p *Prober) Start(ctx context.Context) {
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.Probe(ctx)
+		}
+	}
+}
+
+// Probe measures the next sample of peers one after the other, so the
+// measurements do not compete for bandwidth, and returns the results.
+// Peers that cannot be reached are recorded with an error.
+func (p *Prober) Probe(ctx context.Context) []PeerMeasurement {
+	var results []PeerMeasurement
+	for _, peer := range p.sample() {
+		if ctx.Err() != nil {
+			break
+		}
+		probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+		measurement, err := ProbePeer(probeCtx, peer.Address, p.config.Bytes)
+		cancel()
+		if err != nil {
+			measurement = &PeerMeasurement{Address: peer.Address, MeasuredAt: time.Now(), Error: err.Error()}
+		}
+		measurement.Peer = peer.ID
+		p.matrix.Set(p.config.Self, *measurement)
+		results = append(results, *measurement)
+	}
+	return results
+}
+
+// sample picks the peers not measured yet, then those measured longest ago
+func (p *Prober) sample() []Peer {
+	peers := make([]Peer, 0, len(p.config.Peers))
+	for _, peer := range p.config.Peers {
+		if peer.ID != p.config.Self {
+			peers = append(peers, peer)
+		}
+	}
+	measuredAt := func(peer Peer) time.Time {
+		measurement, _ := p.matrix.Get(p.config.Self, peer.ID)
+		return measurement.MeasuredAt
+	}
+	sort.SliceStable(peers, func(i, j int) bool {
+		return measuredAt(peers[i]).Before(measuredAt(peers[j]))
+	})
+	if len(peers) > p.config.SampleSize {
+		peers = peers[:p.config.SampleSize]
+	}
+	return peers
+}
+
+// Summary condenses this node's row into the median latency and bandwidth
+// to the peers that answered. It returns false if none has.
+func (p *Prober) Summary() (*SpeedTestResult, bool) {
+	var latency, download, upload []float64
+	for _, measurement := range p.matrix.Row(p.config.Self) {
+		if measurement.Error != "" {
+			continue
+		}
+		latency = append(latency, measurement.LatencyMs)
+		download = append(download, measurement.DownloadMbps)
+		upload = append(upload, measurement.UploadMbps)
+	}
+	if len(latency) == 0 {
+		return nil, false
+	}
+	return &SpeedTestResult{
+		DownloadSpeedMbps: median(download),
+		UploadSpeedMbps:   median(upload),
+		LatencyMs:         median(latency),
+		Source:            SourcePeers,
+		Peers:             len(latency),
+	}, true
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return math.Round((sorted[middle-1]+sorted[middle])/2*100) / 100
+	}
+	return math.Round(sorted[middle]*100) / 100
+}
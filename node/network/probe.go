@@ -0,0 +1,222 @@
+package network
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// Probe protocol: the client sends an operation byte and an 8-byte
+// big-endian size, and may send several requests on one connection.
+const (
+	opPing     = 'P' // Server echoes one byte
+	opDownload = 'D' // Server sends size bytes
+	opUpload   = 'U' // Client sends size bytes, server acknowledges with one byte
+)
+
+// Defaults of the probe server and client
+const (
+	DefaultProbeBytes    = 4 << 20
+	DefaultMaxProbeBytes = 64 << 20
+	probePings           = 3
+	probeIdleTimeout     = 30 * time.Second
+	maxProbeConnections  = 4
+)
+
+// PeerMeasurement is the result of probing one peer
+type PeerMeasurement struct {
+	Peer         string    `json:"peer"`
+	Address      string    `json:"address"`
+	LatencyMs    float64   `json:"latency_ms"`
+	DownloadMbps float64   `json:"download_mbps"`
+	UploadMbps   float64   `json:"upload_mbps"`
+	MeasuredAt   time.Time `json:"measured_at"`
+	Error        string    `json:"error,omitempty"` // Set if the peer could not be measured
+}
+
+// ProbeServer answers bandwidth and latency probes from other nodes
+type ProbeServer struct {
+	MaxBytes int64 // Largest transfer a client may request
+	slots    chan struct{}
+}
+
+// NewProbeServer creates a probe server serving at most a few probes at
+// once, so probes cannot starve the node's tasks of bandwidth
+func NewProbeServer() *ProbeServer {
+	return &ProbeServer{
+		MaxBytes: DefaultMaxProbeBytes,
+		slots:    make(chan struct{}, maxProbeConnections),
+	}
+}
+
+// ListenAndServe serves probes on address until ctx is cancelled
+func (s *ProbeServer) ListenAndServe(ctx context.Context, address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen for probes on %s: %w", address, err)
+	}
+	return s.Serve(ctx, listener)
+}
+
+// Serve serves probes on listener until ctx is cancelled
+func (s *ProbeServer) Serve(ctx context.Context, listener net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("probe server failed: %w", err)
+		}
+		select {
+		case s.slots <- struct{}{}:
+			go func() {
+				defer func() { <-s.slots }()
+				s.handle(conn)
+			}()
+		default:
+			conn.Close()
+		}
+	}
+}
+
+// handle answers requests on a connection until the client closes it
+func (s *ProbeServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	header := make([]byte, 9)
+	buffer := make([]byte, 32<<10)
+	for {
+		conn.SetDeadline(time.Now().Add(probeIdleTimeout))
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		size := int64(binary.BigEndian.Uint64(header[1:]))
+		if size < 0 || size > s.MaxBytes {
+			return
+		}
+
+		switch header[0] {
+		case opPing:
+			if _, err := conn.Write([]byte{opPing}); err != nil {
+				return
+			}
+		case opDownload:
+			for sent := int64(0); sent < size; {
+				n := int64(len(buffer))
+				if size-sent < n {
+					n = size - sent
+				}
+				if _, err := conn.Write(buffer[:n]); err != nil {
+					return
+				}
+				sent += n
+			}
+		case opUpload:
+			if _, err := io.CopyN(io.Discard, conn, size); err != nil {
+				return
+			}
+			if _, err := conn.Write([]byte{opUpload}); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// ProbePeer measures the round-trip latency to a peer's probe server and
+// the bandwidth of transferring size bytes each way
+func ProbePeer(ctx context.Context, address string, size int64) (*PeerMeasurement, error) {
+	if size <= 0 {
+		size = DefaultProbeBytes
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to peer %s: %w", address, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(probeIdleTimeout))
+	}
+
+	measurement := &PeerMeasurement{Address: address, MeasuredAt: time.Now()}
+
+	// The fastest ping is the one least delayed by anything but the network
+	var latency time.Duration
+	reply := make([]byte, 1)
+	for i := 0; i < probePings; i++ {
+		start := time.Now()
+		if err := writeProbeRequest(conn, opPing, 0); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return nil, fmt.Errorf("ping to %s failed: %w", address, err)
+		}
+		if elapsed := time.Since(start); i == 0 || elapsed < latency {
+			latency = elapsed
+		}
+	}
+	measurement.LatencyMs = float64(latency.Microseconds()) / 1000
+
+	start := time.Now()
+	if err := writeProbeRequest(conn, opDownload, size); err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(io.Discard, conn, size); err != nil {
+		return nil, fmt.Errorf("download from %s failed: %w", address, err)
+	}
+	measurement.DownloadMbps = mbps(size, time.Since(start))
+
+	start = time.Now()
+	if err := writeProbeRequest(conn, opUpload, size); err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(conn, zeroReader{}, size); err != nil {
+		return nil, fmt.Errorf("upload to %s failed: %w", address, err)
+	}
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return nil, fmt.Errorf("upload to %s was not acknowledged: %w", address, err)
+	}
+	measurement.UploadMbps = mbps(size, time.Since(start))
+
+	return measurement, nil
+}
+
+func writeProbeRequest(conn net.Conn, op byte, size int64) error {
+	header := make([]byte, 9)
+	header[0] = op
+	binary.BigEndian.PutUint64(header[1:], uint64(size))
+	if _, err := conn.Write(header); err != nil {
+		return fmt.Errorf("failed to send probe request: %w", err)
+	}
+	return nil
+}
+
+// mbps converts a transfer to megabits per second
+func mbps(size int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		elapsed = time.Microsecond
+	}
+	return float64(size) * 8 / elapsed.Seconds() / 1e6
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
@@ -0,0 +1,76 @@
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// startProbeServer serves probes on a loopback port until the test ends
+func startProbeServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go NewProbeServer().Serve(ctx, listener)
+	return listener.Addr().String()
+}
+
+func TestProbePeer(t *testing.T) {
+	address := startProbeServer(t)
+
+	measurement, err := ProbePeer(context.Background(), address, 1<<20)
+	require.NoError(t, err)
+	require.Equal(t, address, measurement.Address)
+	require.Greater(t, measurement.LatencyMs, 0.0)
+	require.Greater(t, measurement.DownloadMbps, 0.0)
+	require.Greater(t, measurement.UploadMbps, 0.0)
+
+	// The server hangs up on transfers above its limit
+	_, err = ProbePeer(context.Background(), address, DefaultMaxProbeBytes+1)
+	require.Error(t, err)
+}
+
+func TestProber(t *testing.T) {
+	live := startProbeServer(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	dead := listener.Addr().String()
+	listener.Close()
+
+	prober := NewProber(ProberConfig{
+		Self:       "node-1",
+		Peers:      []Peer{{ID: "node-1", Address: live}, {ID: "node-2", Address: live}, {ID: "node-3", Address: dead}, {ID: "node-4", Address: live}},
+		SampleSize: 2,
+		Bytes:      64 << 10,
+	})
+	_, ok := prober.Summary()
+	require.False(t, ok)
+
+	// Peers are probed in rounds, never the node itself
+	first := prober.Probe(context.Background())
+	require.Len(t, first, 2)
+	require.Equal(t, "node-2", first[0].Peer)
+	require.Equal(t, "node-3", first[1].Peer)
+	require.NotEmpty(t, first[1].Error)
+	second := prober.Probe(context.Background())
+	require.Len(t, second, 2)
+	require.Equal(t, "node-4", second[0].Peer)
+
+	row := prober.Matrix().Row("node-1")
+	require.Len(t, row, 3)
+	require.Equal(t, "node-3", row[2].Peer)
+	_, found := prober.Matrix().Get("node-1", "node-1")
+	require.False(t, found)
+
+	summary, ok := prober.Summary()
+	require.True(t, ok)
+	require.Equal(t, SourcePeers, summary.Source)
+	require.GreaterOrEqual(t, summary.Peers, 2)
+	require.Greater(t, summary.DownloadSpeedMbps, 0.0)
+
+	snapshot := prober.Matrix().Snapshot()
+	require.Len(t, snapshot["node-1"], 3)
+}
@@ -8,12 +8,22 @@ import (
 	"time"
 )
 
+// Sources of a SpeedTestResult
+const (
+	SourcePeers    = "peers"
+	SourceInternet = "internet"
+)
+
 type SpeedTestResult struct {
 	DownloadSpeedMbps float64
 	UploadSpeedMbps   float64
 	LatencyMs         float64
+	Source            string // SourcePeers or SourceInternet
+	Peers             int    // Peers the medians are taken over
 }
 
+// SpeedTest measures the connection to public internet services. Nodes
+// probing their peers use it only as a fallback.
 func SpeedTest(ctx context.Context) (*SpeedTestResult, error) {
 	downloadSpeed, err := testDownloadSpeed(ctx)
 	if err != nil {
@@ -31,6 +41,7 @@ func SpeedTest(ctx context.Context) (*SpeedTestResult, error) {
 		DownloadSpeedMbps: downloadSpeed,
 		UploadSpeedMbps:   uploadSpeed,
 		LatencyMs:         latency,
+		Source:            SourceInternet,
 	}, nil
 }
 
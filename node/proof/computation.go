@@ -2,48 +2,118 @@ package proof
 
 import (
 	"crypto/sha256"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/atlas/storage/identity"
 )
 
+// ProofOfComputation is a node's signed claim that it turned the inputs
+// into the output through the training states in Segments. The chain keeps
+// the signed fields; the full proof is published so verifiers can replay
+// segments of it.
 type ProofOfComputation struct {
-	TaskID      string
-	NodeID      string
-	Timestamp   time.Time
-	Hash        string
-	Signature   string
-	Metrics     ComputationMetrics
+	TaskID     string    `json:"task_id"`
+	NodeID     string    `json:"node_id"`
+	Timestamp  time.Time `json:"timestamp"`
+	InputCIDs  []string  `json:"input_cids"`  // Model, dataset and resume checkpoint
+	OutputHash string    `json:"output_hash"` // sha256 of the task output
+	StateRoot  string    `json:"state_root"`  // Merkle root of Segments
+	Segments   []Segment `json:"segments"`
+	Hash       string    `json:"hash"`      // Digest of the fields above
+	Signature  string    `json:"signature"` // The node's ed25519 signature of Hash
+
+	// Metrics are reported alongside the proof but not committed to
+	Metrics ComputationMetrics `json:"metrics"`
+}
+
+// Segment is a stretch of training ending in a checkpoint, e.g. an epoch
+type Segment struct {
+	Epoch      int    `json:"epoch"`
+	Step       int64  `json:"step,omitempty"`
+	Checkpoint string `json:"checkpoint,omitempty"` // CID of the checkpoint, needed to replay the next segment
+	StateHash  string `json:"state_hash"`           // sha256 of the checkpoint
 }
 
 type ComputationMetrics struct {
-	Iterations   int
-	TimeElapsed  time.Duration
-	MemoryUsed   uint64
-	GPUUtilization float64
+	Iterations     int           `json:"iterations"`
+	TimeElapsed    time.Duration `json:"time_elapsed"`
+	MemoryUsed     uint64        `json:"memory_used"`
+	GPUUtilization float64       `json:"gpu_utilization"`
 }
 
-func GenerateProof(taskID string, nodeID string, metrics ComputationMetrics) (*ProofOfComputation, error) {
-	timestamp := time.Now()
-	
-	data := fmt.Sprintf("%s:%s:%d:%d", taskID, nodeID, timestamp.Unix(), metrics.Iterations)
-	hash := sha256.Sum256([]byte(data))
-	
+// GenerateProof creates a proof of computation signed by the node's key
+func GenerateProof(taskID string, nodeID string, inputCIDs []string, outputHash string, segments []Segment, metrics ComputationMetrics, signer *identity.Identity) (*ProofOfComputation, error) {
+	if signer == nil {
+		return nil, fmt.Errorf("a node key is required to sign proofs")
+	}
+	if outputHash == "" {
+		return nil, fmt.Errorf("a proof must commit to an output")
+	}
+
 	proof := &ProofOfComputation{
-		TaskID:    taskID,
-		NodeID:    nodeID,
-		Timestamp: timestamp,
-		Hash:      fmt.Sprintf("%x", hash),
-		Metrics:   metrics,
+		TaskID:     taskID,
+		NodeID:     nodeID,
+		Timestamp:  time.Now(),
+		InputCIDs:  inputCIDs,
+		OutputHash: outputHash,
+		StateRoot:  MerkleRoot(segments),
+		Segments:   segments,
+		Metrics:    metrics,
 	}
-	
+	proof.Hash = proof.digest()
+	proof.Signature = signer.Sign(identity.DomainProof, []byte(proof.Hash))
+
 	return proof, nil
 }
 
-func VerifyProof(proof *ProofOfComputation) (bool, error) {
-	data := fmt.Sprintf("%s:%s:%d:%d", proof.TaskID, proof.NodeID, proof.Timestamp.Unix(), proof.Metrics.Iterations)
+// VerifyProof checks that the proof is intact and signed by the holder of
+// publicKey, the key the node registered on chain
+func VerifyProof(proof *ProofOfComputation, publicKey string) (bool, error) {
+	if proof.StateRoot != MerkleRoot(proof.Segments) || proof.Hash != proof.digest() {
+		return false, nil
+	}
+	if err := identity.Verify(publicKey, identity.DomainProof, []byte(proof.Hash), proof.Signature); err != nil {
+		if errors.Is(err, identity.ErrInvalidSignature) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// digest covers the task, node, timestamp, inputs, output and training
+// states. x/training computes the same digest from the fields it is sent.
+func (p *ProofOfComputation) digest() string {
+	data := strings.Join([]string{
+		p.TaskID,
+		p.NodeID,
+		strconv.FormatInt(p.Timestamp.Unix(), 10),
+		strings.Join(p.InputCIDs, ","),
+		p.OutputHash,
+		p.StateRoot,
+		strconv.Itoa(len(p.Segments)),
+	}, "\n")
 	hash := sha256.Sum256([]byte(data))
-	expectedHash := fmt.Sprintf("%x", hash)
-	
-	return proof.Hash == expectedHash, nil
+	return fmt.Sprintf("%x", hash)
 }
 
+// HashFile returns the hex sha256 of a file, e.g. a checkpoint
+func HashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
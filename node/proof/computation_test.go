@@ -1,47 +1,197 @@
 package proof
 
 import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/atlas/storage/identity"
 	"github.com/stretchr/testify/require"
 )
 
+// state stands in for a deterministic training run: the state after each
+// epoch depends on the state before it
+func state(epoch int) string {
+	hash := ""
+	for i := 1; i <= epoch; i++ {
+		hash = fmt.Sprintf("%x", sha256.Sum256([]byte(fmt.Sprintf("%s:%d", hash, i))))
+	}
+	return hash
+}
+
+func testSegments(n int) []Segment {
+	segments := make([]Segment, n)
+	for i := range segments {
+		segments[i] = Segment{Epoch: i + 1, Checkpoint: fmt.Sprintf("QmCheckpoint%d", i+1), StateHash: state(i + 1)}
+	}
+	return segments
+}
+
 func TestGenerateProof(t *testing.T) {
 	metrics := ComputationMetrics{
-		Iterations:    100,
-		TimeElapsed:   5 * time.Second,
-		MemoryUsed:    1024,
+		Iterations:     100,
+		TimeElapsed:    5 * time.Second,
+		MemoryUsed:     1024,
 		GPUUtilization: 0.85,
 	}
-	
-	proof, err := GenerateProof("task-1", "node-1", metrics)
+
+	signer, err := identity.Generate()
+	require.NoError(t, err)
+
+	proof, err := GenerateProof("task-1", "node-1", []string{"QmModel", "QmDataset"}, "abc123", testSegments(3), metrics, signer)
 	require.NoError(t, err)
 	require.NotNil(t, proof)
 	require.Equal(t, "task-1", proof.TaskID)
 	require.Equal(t, "node-1", proof.NodeID)
+	require.Equal(t, MerkleRoot(testSegments(3)), proof.StateRoot)
 	require.NotEmpty(t, proof.Hash)
+	require.NotEmpty(t, proof.Signature)
 	require.Equal(t, metrics, proof.Metrics)
+
+	_, err = GenerateProof("task-1", "node-1", nil, "abc123", nil, metrics, nil)
+	require.Error(t, err)
+	_, err = GenerateProof("task-1", "node-1", nil, "", nil, metrics, signer)
+	require.Error(t, err)
 }
 
 func TestVerifyProof(t *testing.T) {
-	metrics := ComputationMetrics{
-		Iterations:    100,
-		TimeElapsed:   5 * time.Second,
-		MemoryUsed:    1024,
-		GPUUtilization: 0.85,
-	}
-	
-	proof, err := GenerateProof("task-1", "node-1", metrics)
+	signer, err := identity.Generate()
 	require.NoError(t, err)
-	
-	valid, err := VerifyProof(proof)
+	other, err := identity.Generate()
+	require.NoError(t, err)
+
+	proof, err := GenerateProof("task-1", "node-1", []string{"QmModel", "QmDataset"}, "abc123", testSegments(3), ComputationMetrics{Iterations: 100}, signer)
+	require.NoError(t, err)
+
+	valid, err := VerifyProof(proof, signer.PublicKey())
 	require.NoError(t, err)
 	require.True(t, valid)
-	
-	proof.Hash = "invalid"
-	valid, err = VerifyProof(proof)
+
+	// Anyone can recompute the hash, but not the signature
+	valid, err = VerifyProof(proof, other.PublicKey())
 	require.NoError(t, err)
 	require.False(t, valid)
+
+	// Metrics are not committed to
+	proof.Metrics.Iterations = 1000
+	valid, err = VerifyProof(proof, signer.PublicKey())
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	for _, tamper := range []func(p *ProofOfComputation){
+		func(p *ProofOfComputation) { p.OutputHash = "def456" },
+		func(p *ProofOfComputation) { p.InputCIDs = []string{"QmOtherDataset"} },
+		func(p *ProofOfComputation) { p.Segments[1].StateHash = state(7) },
+		func(p *ProofOfComputation) { p.Segments = p.Segments[:2] },
+		func(p *ProofOfComputation) { p.Hash = "invalid" },
+	} {
+		tampered := *proof
+		tampered.Segments = append([]Segment(nil), proof.Segments...)
+		tamper(&tampered)
+		valid, err = VerifyProof(&tampered, signer.PublicKey())
+		require.NoError(t, err)
+		require.False(t, valid)
+	}
 }
 
+func TestMerklePath(t *testing.T) {
+	for n := 1; n <= 7; n++ {
+		segments := testSegments(n)
+		root := MerkleRoot(segments)
+		for i := range segments {
+			path, err := MerklePath(segments, i)
+			require.NoError(t, err)
+			require.True(t, VerifySegment(root, segments[i], i, n, path), "segment %d of %d", i, n)
+			if n > 1 {
+				require.False(t, VerifySegment(root, segments[(i+1)%n], i, n, path), "wrong segment %d of %d", i, n)
+			}
+		}
+	}
+
+	segments := testSegments(5)
+	path, err := MerklePath(segments, 4)
+	require.NoError(t, err)
+	require.False(t, VerifySegment(MerkleRoot(segments), segments[4], 3, 5, path))
+	require.False(t, VerifySegment(MerkleRoot(segments), segments[4], 4, 6, path))
+	_, err = MerklePath(segments, 5)
+	require.Error(t, err)
+}
+
+func TestSpotCheck(t *testing.T) {
+	signer, err := identity.Generate()
+	require.NoError(t, err)
+	keys := func(nodeID string) (string, error) { return signer.PublicKey(), nil }
+
+	var replayed []int
+	replay := func(ctx context.Context, proof *ProofOfComputation, from *Segment, to Segment) (string, error) {
+		start := 0
+		if from != nil {
+			require.Equal(t, state(from.Epoch), from.StateHash)
+			start = from.Epoch
+		}
+		replayed = append(replayed, start)
+		return state(to.Epoch), nil
+	}
+	verifier := NewVerifier(keys, replay)
+
+	proof, err := GenerateProof("task-1", "node-1", []string{"QmModel", "QmDataset"}, "abc123", testSegments(4), ComputationMetrics{}, signer)
+	require.NoError(t, err)
+	require.NoError(t, verifier.SpotCheck(context.Background(), proof, 0))
+	require.NoError(t, verifier.SpotCheck(context.Background(), proof, 2))
+	require.Equal(t, []int{0, 2}, replayed)
+	index, err := verifier.SpotCheckRandom(context.Background(), proof)
+	require.NoError(t, err)
+	require.Less(t, index, 4)
+
+	// A node that skipped training after epoch 2 cannot produce epoch 3
+	segments := testSegments(4)
+	segments[2].StateHash = "made-up"
+	cheat, err := GenerateProof("task-1", "node-1", []string{"QmModel", "QmDataset"}, "abc123", segments, ComputationMetrics{}, signer)
+	require.NoError(t, err)
+	require.NoError(t, verifier.Verify(cheat))
+	require.ErrorIs(t, verifier.SpotCheck(context.Background(), cheat, 2), ErrSegmentMismatch)
+
+	cheat.OutputHash = "other"
+	require.ErrorIs(t, verifier.SpotCheck(context.Background(), cheat, 0), ErrInvalidProof)
+	require.Error(t, verifier.SpotCheck(context.Background(), proof, 4))
+}
+
+func TestReplay(t *testing.T) {
+	signer, err := identity.Generate()
+	require.NoError(t, err)
+	replay := func(ctx context.Context, proof *ProofOfComputation, from *Segment, to Segment) (string, error) {
+		return state(to.Epoch), nil
+	}
+	verifier := NewVerifier(func(string) (string, error) { return signer.PublicKey(), nil }, replay)
+
+	// A referee reports the state it reached, whatever the proof claims
+	segments := testSegments(3)
+	segments[1].StateHash = "made-up"
+	proof, err := GenerateProof("task-1", "node-1", nil, "abc123", segments, ComputationMetrics{}, signer)
+	require.NoError(t, err)
+	stateHash, err := verifier.Replay(context.Background(), proof, 1)
+	require.NoError(t, err)
+	require.Equal(t, state(2), stateHash)
+
+	proof.Segments[0].Checkpoint = ""
+	_, err = verifier.Replay(context.Background(), proof, 1)
+	require.Error(t, err)
+
+	signature := SignSegmentResult("task-1-1", stateHash, signer)
+	require.NoError(t, identity.Verify(signer.PublicKey(), identity.DomainSegment, []byte("task-1-1\n"+stateHash), signature))
+}
+
+func TestHashFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.pt")
+	require.NoError(t, os.WriteFile(path, []byte("weights"), 0644))
+	hash, err := HashFile(path)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("%x", sha256.Sum256([]byte("weights"))), hash)
+
+	_, err = HashFile(filepath.Join(t.TempDir(), "missing"))
+	require.Error(t, err)
+}
@@ -0,0 +1,117 @@
+package proof
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// The segments of a proof form a Merkle tree as in RFC 6962: leaves and
+// interior nodes are hashed with distinct prefixes, and a tree of n leaves
+// splits at the largest power of two below n, so no leaf is duplicated.
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+// leafHash commits to everything in a segment
+func leafHash(s Segment) []byte {
+	data := fmt.Sprintf("%d:%d:%s:%s", s.Epoch, s.Step, s.Checkpoint, s.StateHash)
+	sum := sha256.Sum256(append([]byte{leafPrefix}, data...))
+	return sum[:]
+}
+
+func nodeHash(left, right []byte) []byte {
+	data := make([]byte, 0, 1+len(left)+len(right))
+	data = append(data, nodePrefix)
+	data = append(data, left...)
+	sum := sha256.Sum256(append(data, right...))
+	return sum[:]
+}
+
+// MerkleRoot returns the hex root of the segments' tree. A proof without
+// segments has the hash of no data as its root.
+func MerkleRoot(segments []Segment) string {
+	if len(segments) == 0 {
+		sum := sha256.Sum256(nil)
+		return hex.EncodeToString(sum[:])
+	}
+	leaves := make([][]byte, len(segments))
+	for i, segment := range segments {
+		leaves[i] = leafHash(segment)
+	}
+	return hex.EncodeToString(root(leaves))
+}
+
+func root(leaves [][]byte) []byte {
+	if len(leaves) == 1 {
+		return leaves[0]
+	}
+	k := split(len(leaves))
+	return nodeHash(root(leaves[:k]), root(leaves[k:]))
+}
+
+// split returns the largest power of two below n
+func split(n int) int {
+	k := 1
+	for k*2 < n {
+		k *= 2
+	}
+	return k
+}
+
+// MerklePath returns the hex sibling hashes from segment index up to the
+// root, so the segment can be checked against a root alone
+func MerklePath(segments []Segment, index int) ([]string, error) {
+	if index < 0 || index >= len(segments) {
+		return nil, fmt.Errorf("segment %d out of range", index)
+	}
+	leaves := make([][]byte, len(segments))
+	for i, segment := range segments {
+		leaves[i] = leafHash(segment)
+	}
+	var path []string
+	for _, sibling := range auditPath(leaves, index) {
+		path = append(path, hex.EncodeToString(sibling))
+	}
+	return path, nil
+}
+
+func auditPath(leaves [][]byte, index int) [][]byte {
+	if len(leaves) == 1 {
+		return nil
+	}
+	k := split(len(leaves))
+	if index < k {
+		return append(auditPath(leaves[:k], index), root(leaves[k:]))
+	}
+	return append(auditPath(leaves[k:], index-k), root(leaves[:k]))
+}
+
+// VerifySegment checks that segment is number index of count segments
+// under root, given its MerklePath
+func VerifySegment(root string, segment Segment, index int, count int, path []string) bool {
+	if index < 0 || index >= count {
+		return false
+	}
+	hash := leafHash(segment)
+	fn, sn := index, count-1
+	for _, hexSibling := range path {
+		sibling, err := hex.DecodeString(hexSibling)
+		if err != nil || sn == 0 {
+			return false
+		}
+		if fn%2 == 1 || fn == sn {
+			hash = nodeHash(sibling, hash)
+			for fn%2 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			hash = nodeHash(hash, sibling)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	return sn == 0 && hex.EncodeToString(hash) == root
+}
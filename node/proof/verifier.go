@@ -0,0 +1,109 @@
+package proof
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"github.com/atlas/storage/identity"
+)
+
+var (
+	// ErrInvalidProof is returned for proofs that are not intact or not
+	// signed by their node
+	ErrInvalidProof = errors.New("invalid proof")
+
+	// ErrSegmentMismatch is returned when replaying a segment does not
+	// reproduce the state the proof claims
+	ErrSegmentMismatch = errors.New("replayed state does not match proof")
+)
+
+// ReplayFunc re-executes one segment of a task. It starts from the
+// checkpoint at the end of from, or from the task's inputs if from is nil,
+// trains up to the end of to and returns the hash of the resulting state.
+// Replays must be deterministic for honest proofs to pass.
+type ReplayFunc func(ctx context.Context, proof *ProofOfComputation, from *Segment, to Segment) (stateHash string, err error)
+
+// Verifier checks proofs against the keys nodes registered and spot-checks
+// them by replaying single segments
+type Verifier struct {
+	keys   identity.KeyResolver
+	replay ReplayFunc
+}
+
+// NewVerifier creates a verifier looking node keys up with keys and
+// replaying segments with replay
+func NewVerifier(keys identity.KeyResolver, replay ReplayFunc) *Verifier {
+	return &Verifier{keys: keys, replay: replay}
+}
+
+// Verify checks the proof's integrity and signature
+func (v *Verifier) Verify(proof *ProofOfComputation) error {
+	publicKey, err := v.keys(proof.NodeID)
+	if err != nil {
+		return fmt.Errorf("no key for node %s: %w", proof.NodeID, err)
+	}
+	valid, err := VerifyProof(proof, publicKey)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return fmt.Errorf("proof of task %s: %w", proof.TaskID, ErrInvalidProof)
+	}
+	return nil
+}
+
+// SpotCheck verifies the proof and replays segment index from the
+// checkpoint before it
+func (v *Verifier) SpotCheck(ctx context.Context, proof *ProofOfComputation, index int) error {
+	if err := v.Verify(proof); err != nil {
+		return err
+	}
+	stateHash, err := v.Replay(ctx, proof, index)
+	if err != nil {
+		return err
+	}
+	if stateHash != proof.Segments[index].StateHash {
+		return fmt.Errorf("segment %d of task %s: %w", index, proof.TaskID, ErrSegmentMismatch)
+	}
+	return nil
+}
+
+// Replay re-executes segment index from the checkpoint before it and
+// returns the hash of the state it reaches, e.g. as a referee of a
+// challenge to the segment
+func (v *Verifier) Replay(ctx context.Context, proof *ProofOfComputation, index int) (string, error) {
+	if index < 0 || index >= len(proof.Segments) {
+		return "", fmt.Errorf("segment %d out of range", index)
+	}
+
+	var from *Segment
+	if index > 0 {
+		from = &proof.Segments[index-1]
+		if from.Checkpoint == "" {
+			return "", fmt.Errorf("segment %d cannot be replayed: checkpoint %d was not published", index, index-1)
+		}
+	}
+	stateHash, err := v.replay(ctx, proof, from, proof.Segments[index])
+	if err != nil {
+		return "", fmt.Errorf("failed to replay segment %d: %w", index, err)
+	}
+	return stateHash, nil
+}
+
+// SpotCheckRandom spot-checks a segment chosen at random and returns its
+// index
+func (v *Verifier) SpotCheckRandom(ctx context.Context, proof *ProofOfComputation) (int, error) {
+	if len(proof.Segments) == 0 {
+		return -1, fmt.Errorf("proof of task %s has no segments", proof.TaskID)
+	}
+	index := rand.Intn(len(proof.Segments))
+	return index, v.SpotCheck(ctx, proof, index)
+}
+
+// SignSegmentResult signs the state a referee replayed for a challenge, as
+// x/validation expects it
+func SignSegmentResult(challengeID string, stateHash string, signer *identity.Identity) string {
+	return signer.Sign(identity.DomainSegment, []byte(challengeID+"\n"+stateHash))
+}
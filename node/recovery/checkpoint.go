@@ -1,26 +1,30 @@
 package recovery
 
 import (
-	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"time"
+	"github.com/atlas/storage/identity"
 	"github.com/atlas/storage/manager"
 )
 
 type Checkpoint struct {
+	NodeID      string // Node that saved and signed the checkpoint
 	TaskID      string
 	Epoch       int
 	Iteration   int
 	CID         string
 	Timestamp   time.Time
-	Signature   string
+	Signature   string // The node's ed25519 signature
 }
 
-func SaveCheckpoint(ipfsManager *manager.IPFSManager, taskID string, epoch int, iteration int, modelPath string) (*Checkpoint, error) {
+func SaveCheckpoint(ipfsManager *manager.IPFSManager, nodeID string, signer *identity.Identity, taskID string, epoch int, iteration int, modelPath string) (*Checkpoint, error) {
+	if signer == nil {
+		return nil, fmt.Errorf("a node key is required to sign checkpoints")
+	}
 	checkpointDir := filepath.Join("/tmp/checkpoints", taskID, fmt.Sprintf("epoch_%d_iter_%d", epoch, iteration))
 	if err := os.MkdirAll(checkpointDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create checkpoint directory: %w", err)
@@ -48,16 +52,15 @@ func SaveCheckpoint(ipfsManager *manager.IPFSManager, taskID string, epoch int,
 		return nil, fmt.Errorf("failed to upload checkpoint: %w", err)
 	}
 
-	signature := calculateSignature(taskID, epoch, iteration, cid)
-
 	checkpoint := &Checkpoint{
+		NodeID:    nodeID,
 		TaskID:    taskID,
 		Epoch:     epoch,
 		Iteration: iteration,
 		CID:       cid,
 		Timestamp: time.Now(),
-		Signature: signature,
 	}
+	checkpoint.Sign(signer)
 
 	return checkpoint, nil
 }
@@ -69,10 +72,11 @@ func LoadCheckpoint(ipfsManager *manager.IPFSManager, cid string, outputPath str
 	return nil
 }
 
-func ValidateCheckpoint(cp *Checkpoint) error {
-	expectedSignature := calculateSignature(cp.TaskID, cp.Epoch, cp.Iteration, cp.CID)
-	if cp.Signature != expectedSignature {
-		return fmt.Errorf("invalid checkpoint signature")
+// ValidateCheckpoint checks the checkpoint's signature against publicKey,
+// the key its node registered on chain, and its age
+func ValidateCheckpoint(cp *Checkpoint, publicKey string) error {
+	if err := identity.Verify(publicKey, identity.DomainCheckpoint, cp.signedBytes(), cp.Signature); err != nil {
+		return fmt.Errorf("invalid checkpoint signature: %w", err)
 	}
 
 	age := time.Since(cp.Timestamp)
@@ -83,10 +87,14 @@ func ValidateCheckpoint(cp *Checkpoint) error {
 	return nil
 }
 
-func calculateSignature(taskID string, epoch int, iteration int, cid string) string {
-	data := fmt.Sprintf("%s:%d:%d:%s", taskID, epoch, iteration, cid)
-	hash := sha256.Sum256([]byte(data))
-	return fmt.Sprintf("%x", hash)
+// Sign signs the checkpoint with the node's key
+func (cp *Checkpoint) Sign(signer *identity.Identity) {
+	cp.Signature = signer.Sign(identity.DomainCheckpoint, cp.signedBytes())
+}
+
+// signedBytes covers every field but the signature
+func (cp *Checkpoint) signedBytes() []byte {
+	return []byte(fmt.Sprintf("%s:%s:%d:%d:%s:%d", cp.NodeID, cp.TaskID, cp.Epoch, cp.Iteration, cp.CID, cp.Timestamp.Unix()))
 }
 
 func copyFile(src, dst string) error {
@@ -8,12 +8,16 @@ import (
 	"os"
 	"path/filepath"
 	"time"
+	"github.com/atlas/storage/identity"
 	"github.com/atlas/storage/manager"
 )
 
 type CheckpointManager struct {
 	ipfsManager *manager.IPFSManager
 	checkpointDir string
+	nodeID string
+	signer *identity.Identity
+	keys identity.KeyResolver
 }
 
 func NewCheckpointManager(ipfsAPIURL string, checkpointDir string) *CheckpointManager {
@@ -23,7 +27,33 @@ func NewCheckpointManager(ipfsAPIURL string, checkpointDir string) *CheckpointMa
 	}
 }
 
+// SetIdentity sets the node whose key signs saved checkpoints
+func (cm *CheckpointManager) SetIdentity(nodeID string, signer *identity.Identity) {
+	cm.nodeID = nodeID
+	cm.signer = signer
+}
+
+// SetKeyResolver sets where the public keys of other nodes are looked up
+// to verify their checkpoints
+func (cm *CheckpointManager) SetKeyResolver(keys identity.KeyResolver) {
+	cm.keys = keys
+}
+
+// publicKey returns the key checkpoints of nodeID must be signed with
+func (cm *CheckpointManager) publicKey(nodeID string) (string, error) {
+	if cm.signer != nil && nodeID == cm.nodeID {
+		return cm.signer.PublicKey(), nil
+	}
+	if cm.keys == nil {
+		return "", fmt.Errorf("no public key known for node %s", nodeID)
+	}
+	return cm.keys(nodeID)
+}
+
 func (cm *CheckpointManager) SaveCheckpoint(ctx context.Context, taskID string, epoch int, iteration int, modelPath string) (*Checkpoint, error) {
+	if cm.signer == nil {
+		return nil, fmt.Errorf("a node key is required to sign checkpoints")
+	}
 	checkpointPath := filepath.Join(cm.checkpointDir, taskID, fmt.Sprintf("epoch_%d_iter_%d", epoch, iteration))
 	if err := os.MkdirAll(checkpointPath, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create checkpoint directory: %w", err)
@@ -46,31 +76,38 @@ func (cm *CheckpointManager) SaveCheckpoint(ctx context.Context, taskID string,
 		return nil, fmt.Errorf("failed to write metadata: %w", err)
 	}
 
-	cid, err := cm.ipfsManager.AddFile(checkpointPath)
+	cid, err := cm.ipfsManager.AddFileContext(ctx, checkpointPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to upload checkpoint: %w", err)
 	}
 
 	checkpoint := &Checkpoint{
+		NodeID:    cm.nodeID,
 		TaskID:    taskID,
 		Epoch:     epoch,
 		Iteration: iteration,
 		CID:       cid,
 		Timestamp: time.Now(),
 	}
+	checkpoint.Sign(cm.signer)
 
 	return checkpoint, nil
 }
 
+// LoadCheckpoint verifies the checkpoint's signature, then downloads it
 func (cm *CheckpointManager) LoadCheckpoint(ctx context.Context, checkpoint *Checkpoint, outputPath string) error {
-	if err := cm.ipfsManager.GetFile(checkpoint.CID, outputPath); err != nil {
-		return fmt.Errorf("failed to download checkpoint: %w", err)
+	publicKey, err := cm.publicKey(checkpoint.NodeID)
+	if err != nil {
+		return fmt.Errorf("checkpoint validation failed: %w", err)
 	}
-
-	if err := ValidateCheckpoint(checkpoint); err != nil {
+	if err := ValidateCheckpoint(checkpoint, publicKey); err != nil {
 		return fmt.Errorf("checkpoint validation failed: %w", err)
 	}
 
+	if err := cm.ipfsManager.GetFileContext(ctx, checkpoint.CID, outputPath); err != nil {
+		return fmt.Errorf("failed to download checkpoint: %w", err)
+	}
+
 	return nil
 }
 
@@ -0,0 +1,201 @@
+package resource
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// cgroupMountPoint is where cgroup hierarchies are mounted, relative to the
+// filesystem root
+const cgroupMountPoint = "sys/fs/cgroup"
+
+// unlimitedMemory is the smallest cgroup v1 memory limit treated as no limit.
+// The kernel reports "no limit" as the largest page-aligned int64.
+const unlimitedMemory = 1 << 62
+
+// CgroupLimits are the limits the node's cgroup places on it. Zero values
+// mean no limit.
+type CgroupLimits struct {
+	Version     int     // 1 or 2; 0 if no cgroup was found
+	CPUQuota    float64 // CPUs worth of time allowed per period (cpu.max, cpu.cfs_quota_us)
+	CPUSet      int     // CPUs the cgroup may run on (cpuset.cpus.effective)
+	MemoryBytes uint64  // memory.max, memory.limit_in_bytes
+}
+
+// CPUs is the number of whole CPUs the limits allow, or 0 if the CPU is not
+// limited. A fractional quota is rounded down, but never below one CPU.
+func (l CgroupLimits) CPUs() int {
+	cpus := l.CPUSet
+	if l.CPUQuota > 0 {
+		quota := int(math.Max(1, math.Floor(l.CPUQuota)))
+		if cpus == 0 || quota < cpus {
+			cpus = quota
+		}
+	}
+	return cpus
+}
+
+// ReadCgroupLimits reads the limits of the current process's cgroup below
+// root, the filesystem root ("/" outside of tests). Both cgroup v2 and the
+// cpu, cpuset and memory controllers of cgroup v1 are supported. With v2 the
+// limits of visible ancestor cgroups are taken into account as well.
+func ReadCgroupLimits(root string) (CgroupLimits, error) {
+	file, err := os.Open(filepath.Join(root, "proc/self/cgroup"))
+	if err != nil {
+		return CgroupLimits{}, fmt.Errorf("failed to read process cgroups: %w", err)
+	}
+	defer file.Close()
+
+	// Lines are hierarchy-ID:controller-list:path; v2 has an empty list
+	v1 := make(map[string]string)
+	v2, unified := "", false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[1] == "" {
+			v2, unified = parts[2], true
+			continue
+		}
+		for _, controller := range strings.Split(parts[1], ",") {
+			v1[controller] = parts[2]
+		}
+		v1[parts[1]] = parts[2]
+	}
+	if err := scanner.Err(); err != nil {
+		return CgroupLimits{}, fmt.Errorf("failed to read process cgroups: %w", err)
+	}
+
+	mount := filepath.Join(root, cgroupMountPoint)
+	// Hybrid hosts mount the v1 controllers next to an empty v2 hierarchy
+	if _, ok := v1["cpu"]; ok {
+		return readCgroupV1(mount, v1), nil
+	}
+	if _, ok := v1["memory"]; ok {
+		return readCgroupV1(mount, v1), nil
+	}
+	if unified {
+		return readCgroupV2(mount, v2), nil
+	}
+	return CgroupLimits{}, nil
+}
+
+// cgroupDirs returns the directories of a cgroup from the leaf up to the
+// mount. In a container without its own cgroup namespace the path is that of
+// the host, and the container's cgroup is mounted at the root instead.
+func cgroupDirs(mount string, path string) []string {
+	dir := filepath.Join(mount, path)
+	if _, err := os.Stat(dir); err != nil {
+		return []string{mount}
+	}
+	dirs := []string{dir}
+	for dir != mount && strings.HasPrefix(dir, mount) {
+		dir = filepath.Dir(dir)
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+func readCgroupV2(mount string, path string) CgroupLimits {
+	limits := CgroupLimits{Version: 2}
+	for _, dir := range cgroupDirs(mount, path) {
+		if fields := strings.Fields(readCgroupFile(dir, "cpu.max")); len(fields) == 2 && fields[0] != "max" {
+			quota, qerr := strconv.ParseFloat(fields[0], 64)
+			period, perr := strconv.ParseFloat(fields[1], 64)
+			if qerr == nil && perr == nil && period > 0 {
+				if limits.CPUQuota == 0 || quota/period < limits.CPUQuota {
+					limits.CPUQuota = quota / period
+				}
+			}
+		}
+		if value := readCgroupFile(dir, "memory.max"); value != "" && value != "max" {
+			if bytes, err := strconv.ParseUint(value, 10, 64); err == nil && (limits.MemoryBytes == 0 || bytes < limits.MemoryBytes) {
+				limits.MemoryBytes = bytes
+			}
+		}
+		if limits.CPUSet == 0 {
+			limits.CPUSet = countCPUs(readCgroupFile(dir, "cpuset.cpus.effective"))
+		}
+	}
+	return limits
+}
+
+func readCgroupV1(mount string, paths map[string]string) CgroupLimits {
+	limits := CgroupLimits{Version: 1}
+	if dir, ok := cgroupV1Controller(mount, paths, "cpu"); ok {
+		quota, qerr := strconv.ParseFloat(readCgroupFile(dir, "cpu.cfs_quota_us"), 64)
+		period, perr := strconv.ParseFloat(readCgroupFile(dir, "cpu.cfs_period_us"), 64)
+		if qerr == nil && perr == nil && quota > 0 && period > 0 {
+			limits.CPUQuota = quota / period
+		}
+	}
+	if dir, ok := cgroupV1Controller(mount, paths, "memory"); ok {
+		if bytes, err := strconv.ParseUint(readCgroupFile(dir, "memory.limit_in_bytes"), 10, 64); err == nil && bytes < unlimitedMemory {
+			limits.MemoryBytes = bytes
+		}
+	}
+	if dir, ok := cgroupV1Controller(mount, paths, "cpuset"); ok {
+		limits.CPUSet = countCPUs(readCgroupFile(dir, "cpuset.effective_cpus"))
+		if limits.CPUSet == 0 {
+			limits.CPUSet = countCPUs(readCgroupFile(dir, "cpuset.cpus"))
+		}
+	}
+	return limits
+}
+
+// cgroupV1Controller finds the directory of the process's cgroup in a v1
+// controller's hierarchy. Co-mounted controllers such as cpu,cpuacct are
+// mounted under their joint name, usually with a symlink for each.
+func cgroupV1Controller(mount string, paths map[string]string, controller string) (string, bool) {
+	path, ok := paths[controller]
+	if !ok {
+		return "", false
+	}
+	for name := range paths {
+		if name != controller && !strings.Contains(","+name+",", ","+controller+",") {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(mount, name)); err == nil {
+			return cgroupDirs(filepath.Join(mount, name), path)[0], true
+		}
+	}
+	return "", false
+}
+
+func readCgroupFile(dir string, name string) string {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// countCPUs counts the CPUs in a cpuset list such as "0-3,8,10-11"
+func countCPUs(list string) int {
+	count := 0
+	for _, part := range strings.Split(list, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		low, high, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(low)
+		if err != nil {
+			return 0
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(high); err != nil || last < first {
+				return 0
+			}
+		}
+		count += last - first + 1
+	}
+	return count
+}
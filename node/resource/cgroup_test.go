@@ -0,0 +1,63 @@
+package resource
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadCgroupLimits(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    CgroupLimits
+		cpus    int
+	}{
+		// The pod's memory limit is lower than the container's, the
+		// container's CPU quota lower than the pod's
+		{"cgroup-v2-pod", CgroupLimits{Version: 2, CPUQuota: 2.5, CPUSet: 8, MemoryBytes: 8 << 30}, 2},
+		// With a cgroup namespace the node's cgroup is the mount root
+		{"cgroup-v2-namespaced", CgroupLimits{Version: 2, CPUQuota: 0.5, CPUSet: 4, MemoryBytes: 2 << 30}, 1},
+		// Without one the host path does not exist in the container's mount
+		{"cgroup-v1-docker", CgroupLimits{Version: 1, CPUQuota: 3, CPUSet: 5, MemoryBytes: 4 << 30}, 3},
+		{"cgroup-v1-unlimited", CgroupLimits{Version: 1}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			limits, err := ReadCgroupLimits(filepath.Join("testdata", tt.fixture))
+			require.NoError(t, err)
+			require.Equal(t, tt.want, limits)
+			require.Equal(t, tt.cpus, limits.CPUs())
+		})
+	}
+
+	_, err := ReadCgroupLimits(t.TempDir())
+	require.Error(t, err)
+}
+
+func TestApplyCgroupLimits(t *testing.T) {
+	m := NewManagerWithCapacity(32, 64, 0, 100)
+	m.applyCgroupLimits(filepath.Join("testdata", "cgroup-v2-pod"))
+	require.Equal(t, 2, m.CPUCount)
+	require.Equal(t, uint64(8), m.MemoryGB)
+
+	// Limits above the host's capacity change nothing
+	m = NewManagerWithCapacity(1, 1, 0, 100)
+	m.applyCgroupLimits(filepath.Join("testdata", "cgroup-v1-docker"))
+	require.Equal(t, 1, m.CPUCount)
+	require.Equal(t, uint64(1), m.MemoryGB)
+}
+
+func TestNewManagerWithOptions(t *testing.T) {
+	workDir := filepath.Join(t.TempDir(), "not", "created")
+	m, err := NewManagerWithOptions(Options{WorkDir: workDir, Root: filepath.Join("testdata", "cgroup-v2-namespaced")})
+	require.NoError(t, err)
+	require.Equal(t, 1, m.CPUCount)
+	require.Equal(t, 2, m.Limits.Version)
+	_, err = os.Stat(workDir)
+	require.True(t, os.IsNotExist(err))
+
+	_, err = NewManagerWithOptions(Options{Root: t.TempDir(), Reserved: Requirements{CPU: 1 << 20}})
+	require.Error(t, err)
+}
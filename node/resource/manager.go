@@ -2,11 +2,15 @@ package resource
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/atlas/node/network"
@@ -19,9 +23,16 @@ type Manager struct {
 	StorageGB   uint64
 	NetworkSpeed *network.SpeedTestResult
 	Geolocation *network.Geolocation
+	Limits      CgroupLimits // Limits of the node's cgroup, applied to CPUCount and MemoryGB
+	prober      *network.Prober
+	skipInternetSpeedTest bool
+	location    network.LocationConfig
 	allocations map[string]*ResourceAllocation
 	allocatedCPU int
 	allocatedMemory uint64
+	allocatedGPUs int
+	allocatedStorage uint64
+	mu sync.Mutex
 }
 
 type GPU struct {
@@ -30,34 +41,104 @@ type GPU struct {
 	Utilization float64
 }
 
+// Options control how NewManagerWithOptions detects the node's capacity
+type Options struct {
+	// WorkDir is where tasks store their files; storage is the space free
+	// on its filesystem. Defaults to "/".
+	WorkDir string
+
+	// Reserved is held back for the host, as with Reserve
+	Reserved Requirements
+
+	// Root is the filesystem root cgroup limits are read below (default "/")
+	Root string
+
+	// Prober measures the network against other nodes. DetectResources
+	// falls back to the internet speed test until a peer has answered.
+	Prober *network.Prober
+
+	// SkipInternetSpeedTest disables the fallback, for nodes without
+	// internet access
+	SkipInternetSpeedTest bool
+
+	// Location selects the sources of the node's location
+	Location network.LocationConfig
+}
+
 func NewManager() *Manager {
+	m, err := NewManagerWithOptions(Options{})
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	return m
+}
+
+// NewManagerWithOptions creates a manager, detecting the resources available
+// to the node. CPU and memory are capped by the limits of the node's cgroup,
+// so a node in a container offers only what the container may use. The
+// manager is returned even if the reservation cannot be made.
+func NewManagerWithOptions(options Options) (*Manager, error) {
 	m := &Manager{
 		CPUCount: runtime.NumCPU(),
 		GPUs:     []GPU{},
+		allocations: make(map[string]*ResourceAllocation),
+		prober:      options.Prober,
+		skipInternetSpeedTest: options.SkipInternetSpeedTest,
+		location:    options.Location,
 	}
 	
 	// Auto-detect all resources
 	m.detectMemory()
-	m.detectStorage()
+	m.detectStorage(options.WorkDir)
 	m.detectGPUs()
+	m.applyCgroupLimits(options.Root)
 	
 	// Network and geolocation are async and can be done later
 	// They're expensive operations, so we'll do them on demand
 	
-	return m
+	if err := m.Reserve(options.Reserved); err != nil {
+		return m, fmt.Errorf("failed to reserve resources for the host: %w", err)
+	}
+	return m, nil
 }
 
-// DetectResources performs full resource detection including network tests
+// applyCgroupLimits lowers the CPU and memory capacity to the limits of the
+// node's cgroup
+func (m *Manager) applyCgroupLimits(root string) {
+	if root == "" {
+		root = "/"
+	}
+	limits, err := ReadCgroupLimits(root)
+	if err != nil {
+		return
+	}
+	m.Limits = limits
+	if cpus := limits.CPUs(); cpus > 0 && cpus < m.CPUCount {
+		m.CPUCount = cpus
+	}
+	if memoryGB := limits.MemoryBytes / (1024 * 1024 * 1024); limits.MemoryBytes > 0 && (m.MemoryGB == 0 || memoryGB < m.MemoryGB) {
+		m.MemoryGB = memoryGB
+	}
+}
+
+// DetectResources performs full resource detection including network tests.
+// The network speed is the median of the peers measured so far, or that
+// of the internet speed test if no peer has been measured and the test is
+// enabled.
 func (m *Manager) DetectResources(ctx context.Context) error {
 	// Detect network speed
-	speedResult, err := network.SpeedTest(ctx)
-	if err != nil {
-		return fmt.Errorf("speed test failed: %w", err)
+	if speedResult, ok := m.peerSpeed(); ok {
+		m.NetworkSpeed = speedResult
+	} else if !m.skipInternetSpeedTest {
+		speedResult, err := network.SpeedTest(ctx)
+		if err != nil {
+			return fmt.Errorf("speed test failed: %w", err)
+		}
+		m.NetworkSpeed = speedResult
 	}
-	m.NetworkSpeed = speedResult
 	
 	// Detect geolocation
-	geo, err := network.GetGeolocation(ctx)
+	geo, err := network.DetectLocation(ctx, m.location)
 	if err != nil {
 		return fmt.Errorf("geolocation failed: %w", err)
 	}
@@ -66,6 +147,13 @@ func (m *Manager) DetectResources(ctx context.Context) error {
 	return nil
 }
 
+func (m *Manager) peerSpeed() (*network.SpeedTestResult, bool) {
+	if m.prober == nil {
+		return nil, false
+	}
+	return m.prober.Summary()
+}
+
 func (m *Manager) detectMemory() {
 	// Get system memory
 	var info syscall.Sysinfo_t
@@ -83,28 +171,37 @@ func (m *Manager) detectMemory() {
 	}
 }
 
-func (m *Manager) detectStorage() {
+// detectStorage reports the space free on the filesystem holding dir, the
+// nearest existing ancestor if dir does not exist yet
+func (m *Manager) detectStorage(dir string) {
+	if dir == "" {
+		dir = "/"
+	}
+	for {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+
 	// Get disk space using syscall
 	var stat syscall.Statfs_t
-	err := syscall.Statfs("/", &stat)
+	err := syscall.Statfs(dir, &stat)
 	if err == nil {
-		// Calculate total space in GB
-		totalBytes := stat.Blocks * uint64(stat.Bsize)
-		m.StorageGB = totalBytes / (1024 * 1024 * 1024)
+		// Space available to unprivileged users, in GB
+		freeBytes := stat.Bavail * uint64(stat.Bsize)
+		m.StorageGB = freeBytes / (1024 * 1024 * 1024)
 	} else {
 		// Fallback: try using df command
-		cmd := exec.Command("df", "-BG", "/")
+		cmd := exec.Command("df", "-BG", "--output=avail", dir)
 		output, err := cmd.Output()
 		if err == nil {
 			lines := strings.Split(string(output), "\n")
 			if len(lines) > 1 {
-				fields := strings.Fields(lines[1])
-				if len(fields) > 1 {
-					// Remove 'G' suffix and parse
-					sizeStr := strings.TrimSuffix(fields[1], "G")
-					if size, err := strconv.ParseUint(sizeStr, 10, 64); err == nil {
-						m.StorageGB = size
-					}
+				// Remove 'G' suffix and parse
+				sizeStr := strings.TrimSuffix(strings.TrimSpace(lines[1]), "G")
+				if size, err := strconv.ParseUint(sizeStr, 10, 64); err == nil {
+					m.StorageGB = size
 				}
 			}
 		}
@@ -164,68 +261,130 @@ func (m *Manager) GetResources() map[string]interface{} {
 		resources["network_download_mbps"] = m.NetworkSpeed.DownloadSpeedMbps
 		resources["network_upload_mbps"] = m.NetworkSpeed.UploadSpeedMbps
 		resources["network_latency_ms"] = m.NetworkSpeed.LatencyMs
+		resources["network_source"] = m.NetworkSpeed.Source
 	}
 	
 	if m.Geolocation != nil {
 		resources["region"] = m.Geolocation.Region
 		resources["country"] = m.Geolocation.Country
+		resources["country_code"] = m.Geolocation.CountryCode
+		resources["city"] = m.Geolocation.City
+		resources["latitude"] = m.Geolocation.Lat
+		resources["longitude"] = m.Geolocation.Lon
+		resources["location_source"] = m.Geolocation.Source
 		resources["ip"] = m.Geolocation.IP
 	}
 	
 	return resources
 }
 
+// ErrExceedsCapacity is returned when a request can never be satisfied,
+// even with nothing else allocated
+var ErrExceedsCapacity = errors.New("request exceeds node capacity")
+
+// Requirements describes the resources a task needs while it runs
+type Requirements struct {
+	CPU      int    `json:"cpu"`
+	MemoryGB uint64 `json:"memory_gb"`
+	GPUs     int    `json:"gpus"`
+	DiskGB   uint64 `json:"disk_gb"`
+}
+
 type ResourceAllocation struct {
 	TaskID string
 	CPU    int
 	Memory uint64
+	GPUs   int
+	Disk   uint64
 }
 
-func NewManager() *Manager {
+// NewManagerWithCapacity creates a manager with fixed capacity instead of
+// auto-detecting it
+func NewManagerWithCapacity(cpu int, memoryGB uint64, gpuCount int, storageGB uint64) *Manager {
 	m := &Manager{
-		CPUCount: runtime.NumCPU(),
-		GPUs:     []GPU{},
+		CPUCount:    cpu,
+		MemoryGB:    memoryGB,
+		StorageGB:   storageGB,
+		GPUs:        []GPU{},
 		allocations: make(map[string]*ResourceAllocation),
 	}
 	
-	m.detectMemory()
-	m.detectStorage()
-	m.detectGPUs()
+	for i := 0; i < gpuCount; i++ {
+		m.GPUs = append(m.GPUs, GPU{ID: strconv.Itoa(i)})
+	}
 	
 	return m
 }
 
 func (m *Manager) AllocateResources(taskID string, cpu int, memory uint64) error {
-	if cpu > m.CPUCount {
-		return fmt.Errorf("insufficient CPU: requested %d, available %d", cpu, m.CPUCount)
+	return m.Allocate(taskID, Requirements{CPU: cpu, MemoryGB: memory})
+}
+
+// Allocate reserves resources for a task. It fails without reserving
+// anything if any requirement cannot be met.
+func (m *Manager) Allocate(taskID string, req Requirements) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.allocations[taskID]; exists {
+		return fmt.Errorf("resources already allocated for task %s", taskID)
+	}
+
+	if req.CPU > m.CPUCount {
+		return fmt.Errorf("insufficient CPU: requested %d, available %d: %w", req.CPU, m.CPUCount, ErrExceedsCapacity)
 	}
 	
-	if memory > m.MemoryGB {
-		return fmt.Errorf("insufficient memory: requested %d GB, available %d GB", memory, m.MemoryGB)
+	if req.MemoryGB > m.MemoryGB {
+		return fmt.Errorf("insufficient memory: requested %d GB, available %d GB: %w", req.MemoryGB, m.MemoryGB, ErrExceedsCapacity)
+	}
+
+	if req.GPUs > len(m.GPUs) {
+		return fmt.Errorf("insufficient GPUs: requested %d, available %d: %w", req.GPUs, len(m.GPUs), ErrExceedsCapacity)
+	}
+
+	if req.DiskGB > m.StorageGB {
+		return fmt.Errorf("insufficient storage: requested %d GB, available %d GB: %w", req.DiskGB, m.StorageGB, ErrExceedsCapacity)
 	}
 
 	availableCPU := m.CPUCount - m.allocatedCPU
-	if cpu > availableCPU {
-		return fmt.Errorf("insufficient CPU: requested %d, available %d (total %d, allocated %d)", cpu, availableCPU, m.CPUCount, m.allocatedCPU)
+	if req.CPU > availableCPU {
+		return fmt.Errorf("insufficient CPU: requested %d, available %d (total %d, allocated %d)", req.CPU, availableCPU, m.CPUCount, m.allocatedCPU)
 	}
 
 	availableMemory := m.MemoryGB - m.allocatedMemory
-	if memory > availableMemory {
-		return fmt.Errorf("insufficient memory: requested %d GB, available %d GB (total %d GB, allocated %d GB)", memory, availableMemory, m.MemoryGB, m.allocatedMemory)
+	if req.MemoryGB > availableMemory {
+		return fmt.Errorf("insufficient memory: requested %d GB, available %d GB (total %d GB, allocated %d GB)", req.MemoryGB, availableMemory, m.MemoryGB, m.allocatedMemory)
+	}
+
+	availableGPUs := len(m.GPUs) - m.allocatedGPUs
+	if req.GPUs > availableGPUs {
+		return fmt.Errorf("insufficient GPUs: requested %d, available %d (total %d, allocated %d)", req.GPUs, availableGPUs, len(m.GPUs), m.allocatedGPUs)
+	}
+
+	availableStorage := m.StorageGB - m.allocatedStorage
+	if req.DiskGB > availableStorage {
+		return fmt.Errorf("insufficient storage: requested %d GB, available %d GB (total %d GB, allocated %d GB)", req.DiskGB, availableStorage, m.StorageGB, m.allocatedStorage)
 	}
 
 	m.allocations[taskID] = &ResourceAllocation{
 		TaskID: taskID,
-		CPU:    cpu,
-		Memory: memory,
+		CPU:    req.CPU,
+		Memory: req.MemoryGB,
+		GPUs:   req.GPUs,
+		Disk:   req.DiskGB,
 	}
-	m.allocatedCPU += cpu
-	m.allocatedMemory += memory
+	m.allocatedCPU += req.CPU
+	m.allocatedMemory += req.MemoryGB
+	m.allocatedGPUs += req.GPUs
+	m.allocatedStorage += req.DiskGB
 
 	return nil
 }
 
 func (m *Manager) ReleaseResources(taskID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	allocation, exists := m.allocations[taskID]
 	if !exists {
 		return nil
@@ -233,8 +392,65 @@ func (m *Manager) ReleaseResources(taskID string) error {
 
 	m.allocatedCPU -= allocation.CPU
 	m.allocatedMemory -= allocation.Memory
+	m.allocatedGPUs -= allocation.GPUs
+	m.allocatedStorage -= allocation.Disk
 	delete(m.allocations, taskID)
 
 	return nil
 }
 
+// GetAllocations returns a snapshot of the current per-task allocations
+func (m *Manager) GetAllocations() []ResourceAllocation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	allocations := make([]ResourceAllocation, 0, len(m.allocations))
+	for _, allocation := range m.allocations {
+		allocations = append(allocations, *allocation)
+	}
+	return allocations
+}
+
+
+// Capacity returns the node's total resources
+func (m *Manager) Capacity() Requirements {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return Requirements{CPU: m.CPUCount, MemoryGB: m.MemoryGB, GPUs: len(m.GPUs), DiskGB: m.StorageGB}
+}
+
+// Allocated returns the resources currently reserved by tasks
+func (m *Manager) Allocated() Requirements {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return Requirements{CPU: m.allocatedCPU, MemoryGB: m.allocatedMemory, GPUs: m.allocatedGPUs, DiskGB: m.allocatedStorage}
+}
+
+// Reserve holds resources back for the host by removing them from the
+// node's capacity, so they are neither reported to the chain nor allocated
+// to tasks. Reserved GPUs are taken from the end of the GPU list.
+func (m *Manager) Reserve(req Requirements) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if req.CPU >= m.CPUCount {
+		return fmt.Errorf("cannot reserve %d of %d CPUs: at least one must remain", req.CPU, m.CPUCount)
+	}
+	if req.MemoryGB > m.MemoryGB {
+		return fmt.Errorf("cannot reserve %d GB of %d GB memory", req.MemoryGB, m.MemoryGB)
+	}
+	if req.GPUs > len(m.GPUs) {
+		return fmt.Errorf("cannot reserve %d of %d GPUs", req.GPUs, len(m.GPUs))
+	}
+	if req.DiskGB > m.StorageGB {
+		return fmt.Errorf("cannot reserve %d GB of %d GB storage", req.DiskGB, m.StorageGB)
+	}
+
+	m.CPUCount -= req.CPU
+	m.MemoryGB -= req.MemoryGB
+	m.GPUs = m.GPUs[:len(m.GPUs)-req.GPUs]
+	m.StorageGB -= req.DiskGB
+	return nil
+}
@@ -2,6 +2,8 @@ package resource
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -70,3 +72,63 @@ func TestReleaseResources(t *testing.T) {
 	require.NoError(t, err)
 }
 
+
+func TestAllocateGPUAndDisk(t *testing.T) {
+	m := NewManagerWithCapacity(8, 32, 1, 100)
+
+	err := m.Allocate("task-1", Requirements{CPU: 1, MemoryGB: 1, GPUs: 1, DiskGB: 60})
+	require.NoError(t, err)
+
+	err = m.Allocate("task-2", Requirements{CPU: 1, MemoryGB: 1, GPUs: 1})
+	require.Error(t, err)
+
+	err = m.Allocate("task-3", Requirements{CPU: 1, MemoryGB: 1, DiskGB: 50})
+	require.Error(t, err)
+
+	err = m.Allocate("task-1", Requirements{CPU: 1})
+	require.Error(t, err)
+
+	require.NoError(t, m.ReleaseResources("task-1"))
+	require.NoError(t, m.Allocate("task-2", Requirements{CPU: 1, MemoryGB: 1, GPUs: 1, DiskGB: 100}))
+}
+
+func TestAllocateConcurrent(t *testing.T) {
+	m := NewManagerWithCapacity(8, 16, 2, 100)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			m.Allocate(fmt.Sprintf("task-%d", i), Requirements{CPU: 1, MemoryGB: 1, GPUs: i % 2})
+		}(i)
+	}
+	wg.Wait()
+
+	allocations := m.GetAllocations()
+	require.Len(t, allocations, 8)
+
+	cpu, gpus := 0, 0
+	var memory uint64
+	for _, allocation := range allocations {
+		cpu += allocation.CPU
+		memory += allocation.Memory
+		gpus += allocation.GPUs
+	}
+	require.LessOrEqual(t, cpu, m.CPUCount)
+	require.LessOrEqual(t, memory, m.MemoryGB)
+	require.LessOrEqual(t, gpus, len(m.GPUs))
+	require.Equal(t, cpu, m.allocatedCPU)
+}
+
+func TestReserve(t *testing.T) {
+	m := NewManagerWithCapacity(8, 32, 2, 100)
+	require.NoError(t, m.Reserve(Requirements{CPU: 2, MemoryGB: 4, GPUs: 1, DiskGB: 20}))
+	require.Equal(t, Requirements{CPU: 6, MemoryGB: 28, GPUs: 1, DiskGB: 80}, m.Capacity())
+
+	err := m.Allocate("task", Requirements{GPUs: 2})
+	require.ErrorIs(t, err, ErrExceedsCapacity)
+
+	require.Error(t, m.Reserve(Requirements{CPU: 6}))
+	require.Error(t, m.Reserve(Requirements{GPUs: 2}))
+}
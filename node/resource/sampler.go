@@ -0,0 +1,300 @@
+package resource
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Defaults of SamplerConfig
+const (
+	DefaultSampleInterval = 10 * time.Second
+	DefaultSampleWindow   = 5 * time.Minute
+)
+
+// Utilization summarizes the node's load over the sampler's window.
+// Percentages are averages from 0 to 100.
+type Utilization struct {
+	CPU           float64  `json:"cpu"`
+	Memory        float64  `json:"memory"`
+	Disk          float64  `json:"disk"`
+	GPU           *float64 `json:"gpu,omitempty"` // Omitted on nodes without a GPU
+	ActiveTasks   int      `json:"active_tasks"`  // Latest count
+	WindowSeconds int      `json:"window_seconds"`
+	Samples       int      `json:"samples"`
+}
+
+// Sample is one reading of the node's utilization
+type Sample struct {
+	Time        time.Time
+	CPU         float64
+	Memory      float64
+	Disk        float64
+	GPU         float64
+	HasCPU      bool // False for the first sample, which has nothing to compare CPU time with
+	HasGPU      bool
+	ActiveTasks int
+}
+
+// SamplerConfig configures a Sampler
+type SamplerConfig struct {
+	Interval time.Duration // Time between samples (default DefaultSampleInterval)
+	Window   time.Duration // Samples older than this are dropped (default DefaultSampleWindow)
+	WorkDir  string        // Disk usage is that of the work directory's filesystem (default "/")
+	Root     string        // Filesystem root /proc is read below (default "/")
+
+	// ActiveTasks returns the number of running tasks, if set
+	ActiveTasks func() int
+
+	// GPU returns the utilization of each GPU in percent. Defaults to
+	// asking nvidia-smi; an error means the node has no usable GPU.
+	GPU func() ([]float64, error)
+}
+
+// Sampler tracks the node's CPU, memory, disk and GPU utilization and its
+// active task count over a sliding window
+type Sampler struct {
+	config  SamplerConfig
+	samples []Sample
+	cpu     cpuTimes
+	mu      sync.Mutex
+}
+
+// cpuTimes are the busy and total jiffies from /proc/stat
+type cpuTimes struct {
+	busy, total uint64
+}
+
+// NewSampler creates a sampler. It samples only when Start runs or Sample
+// is called.
+func NewSampler(config SamplerConfig) *Sampler {
+	if config.Interval <= 0 {
+		config.Interval = DefaultSampleInterval
+	}
+	if config.Window <= 0 {
+		config.Window = DefaultSampleWindow
+	}
+	if config.WorkDir == "" {
+		config.WorkDir = "/"
+	}
+	if config.Root == "" {
+		config.Root = "/"
+	}
+	if config.GPU == nil {
+		config.GPU = nvidiaGPUUtilization
+	}
+	return &Sampler{config: config}
+}
+
+// Start samples every interval until ctx is cancelled
+func (s *Sampler) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	s.Sample()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Sample()
+		}
+	}
+}
+
+// Sample takes a sample now and adds it to the window
+func (s *Sampler) Sample() Sample {
+	sample := Sample{Time: time.Now()}
+
+	if times, err := readCPUTimes(s.config.Root); err == nil {
+		s.mu.Lock()
+		previous := s.cpu
+		s.cpu = times
+		s.mu.Unlock()
+		if previous.total > 0 && times.total > previous.total && times.busy >= previous.busy {
+			sample.CPU = 100 * float64(times.busy-previous.busy) / float64(times.total-previous.total)
+			sample.HasCPU = true
+		}
+	}
+	if memory, err := readMemoryUsage(s.config.Root); err == nil {
+		sample.Memory = memory
+	}
+	if disk, err := diskUsage(s.config.WorkDir); err == nil {
+		sample.Disk = disk
+	}
+	if gpus, err := s.config.GPU(); err == nil && len(gpus) > 0 {
+		sample.GPU = average(gpus)
+		sample.HasGPU = true
+	}
+	if s.config.ActiveTasks != nil {
+		sample.ActiveTasks = s.config.ActiveTasks()
+	}
+
+	s.add(sample)
+	return sample
+}
+
+// add appends a sample and drops those that left the window
+func (s *Sampler) add(sample Sample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.samples = append(s.samples, sample)
+	cutoff := sample.Time.Add(-s.config.Window)
+	drop := 0
+	for drop < len(s.samples) && s.samples[drop].Time.Before(cutoff) {
+		drop++
+	}
+	s.samples = append(s.samples[:0], s.samples[drop:]...)
+}
+
+// Summary averages the samples in the window
+func (s *Sampler) Summary() Utilization {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	summary := Utilization{
+		WindowSeconds: int(s.config.Window / time.Second),
+		Samples:       len(s.samples),
+	}
+	if len(s.samples) == 0 {
+		return summary
+	}
+
+	var cpu, gpu []float64
+	var memory, disk float64
+	for _, sample := range s.samples {
+		if sample.HasCPU {
+			cpu = append(cpu, sample.CPU)
+		}
+		if sample.HasGPU {
+			gpu = append(gpu, sample.GPU)
+		}
+		memory += sample.Memory
+		disk += sample.Disk
+	}
+	summary.CPU = round(average(cpu))
+	summary.Memory = round(memory / float64(len(s.samples)))
+	summary.Disk = round(disk / float64(len(s.samples)))
+	if len(gpu) > 0 {
+		value := round(average(gpu))
+		summary.GPU = &value
+	}
+	summary.ActiveTasks = s.samples[len(s.samples)-1].ActiveTasks
+	return summary
+}
+
+// readCPUTimes reads the aggregate "cpu" line of /proc/stat
+func readCPUTimes(root string) (cpuTimes, error) {
+	file, err := os.Open(filepath.Join(root, "proc/stat"))
+	if err != nil {
+		return cpuTimes{}, fmt.Errorf("failed to read CPU times: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[0] != "cpu" {
+			continue
+		}
+		var times cpuTimes
+		for i, field := range fields[1:] {
+			value, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return cpuTimes{}, fmt.Errorf("invalid CPU time %q: %w", field, err)
+			}
+			// Guest time is already counted in user time
+			if i >= 8 {
+				break
+			}
+			times.total += value
+			// idle and iowait
+			if i != 3 && i != 4 {
+				times.busy += value
+			}
+		}
+		return times, nil
+	}
+	return cpuTimes{}, fmt.Errorf("no cpu line in /proc/stat")
+}
+
+// readMemoryUsage returns the percentage of memory not available to new
+// processes, from /proc/meminfo
+func readMemoryUsage(root string) (float64, error) {
+	file, err := os.Open(filepath.Join(root, "proc/meminfo"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read memory usage: %w", err)
+	}
+	defer file.Close()
+
+	var total, available uint64
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			total, _ = strconv.ParseUint(fields[1], 10, 64)
+		case "MemAvailable:":
+			available, _ = strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	if total == 0 || available > total {
+		return 0, fmt.Errorf("no memory totals in /proc/meminfo")
+	}
+	return 100 * float64(total-available) / float64(total), nil
+}
+
+// diskUsage returns the percentage of dir's filesystem in use
+func diskUsage(dir string) (float64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, fmt.Errorf("failed to read disk usage: %w", err)
+	}
+	if stat.Blocks == 0 {
+		return 0, nil
+	}
+	return 100 * float64(stat.Blocks-stat.Bfree) / float64(stat.Blocks), nil
+}
+
+// nvidiaGPUUtilization asks nvidia-smi for the utilization of each GPU
+func nvidiaGPUUtilization() ([]float64, error) {
+	output, err := exec.Command("nvidia-smi", "--query-gpu=utilization.gpu", "--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return nil, fmt.Errorf("nvidia-smi failed: %w", err)
+	}
+	var utilization []float64
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if value, err := strconv.ParseFloat(strings.TrimSpace(line), 64); err == nil {
+			utilization = append(utilization, value)
+		}
+	}
+	return utilization, nil
+}
+
+func average(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+	return sum / float64(len(values))
+}
+
+// round keeps one decimal, which is all a heartbeat needs
+func round(value float64) float64 {
+	return float64(int64(value*10+0.5)) / 10
+}
@@ -0,0 +1,75 @@
+package resource
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeProc(t *testing.T, root string, stat string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "proc"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "proc", "stat"), []byte(stat), 0644))
+	meminfo := "MemTotal:       16000000 kB\nMemFree:         2000000 kB\nMemAvailable:    4000000 kB\n"
+	require.NoError(t, os.WriteFile(filepath.Join(root, "proc", "meminfo"), []byte(meminfo), 0644))
+}
+
+func TestSampler(t *testing.T) {
+	root := t.TempDir()
+	tasks := 2
+	sampler := NewSampler(SamplerConfig{
+		Root:        root,
+		WorkDir:     root,
+		ActiveTasks: func() int { return tasks },
+		GPU:         func() ([]float64, error) { return []float64{40, 60}, nil },
+	})
+
+	// user nice system idle iowait irq softirq steal guest guest_nice
+	writeProc(t, root, "cpu  100 0 100 700 100 0 0 0 50 0\ncpu0 100 0 100 700 100 0 0 0 50 0\n")
+	first := sampler.Sample()
+	require.False(t, first.HasCPU)
+
+	writeProc(t, root, "cpu  400 0 200 1000 100 0 0 0 90 0\n")
+	tasks = 3
+	second := sampler.Sample()
+	require.True(t, second.HasCPU)
+	require.InDelta(t, 57.1, second.CPU, 0.1) // 400 busy of 700 jiffies
+
+	summary := sampler.Summary()
+	require.Equal(t, 2, summary.Samples)
+	require.Equal(t, 57.1, summary.CPU)
+	require.Equal(t, 75.0, summary.Memory)
+	require.Greater(t, summary.Disk, 0.0)
+	require.NotNil(t, summary.GPU)
+	require.Equal(t, 50.0, *summary.GPU)
+	require.Equal(t, 3, summary.ActiveTasks)
+	require.Equal(t, 300, summary.WindowSeconds)
+}
+
+func TestSampler_Window(t *testing.T) {
+	sampler := NewSampler(SamplerConfig{
+		Root:   t.TempDir(),
+		Window: time.Minute,
+		GPU:    func() ([]float64, error) { return nil, errors.New("no GPU") },
+	})
+	now := time.Now()
+	sampler.add(Sample{Time: now.Add(-2 * time.Minute), CPU: 100, HasCPU: true, Memory: 100})
+	sampler.add(Sample{Time: now.Add(-30 * time.Second), CPU: 20, HasCPU: true, Memory: 40})
+	sampler.add(Sample{Time: now, CPU: 40, HasCPU: true, Memory: 60, ActiveTasks: 1})
+
+	summary := sampler.Summary()
+	require.Equal(t, 2, summary.Samples)
+	require.Equal(t, 30.0, summary.CPU)
+	require.Equal(t, 50.0, summary.Memory)
+	require.Nil(t, summary.GPU)
+	require.Equal(t, 1, summary.ActiveTasks)
+
+	// Nothing to read below the root
+	sample := sampler.Sample()
+	require.False(t, sample.HasCPU)
+	require.False(t, sample.HasGPU)
+}
@@ -0,0 +1,82 @@
+package serving
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Model is a model served by the node. Path is the IPFS CID or local path
+// the model is loaded from.
+type Model struct {
+	ID      string
+	Path    string
+	Created time.Time
+}
+
+// Registry holds the models the node serves. Requests name a model by its
+// ID or by its CID.
+type Registry struct {
+	mu     sync.RWMutex
+	models map[string]*Model
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		models: make(map[string]*Model),
+	}
+}
+
+// Add registers a model under id, replacing any model with the same ID
+func (r *Registry) Add(id string, path string) error {
+	if id == "" || path == "" {
+		return fmt.Errorf("model ID and path are required")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.models[id] = &Model{ID: id, Path: path, Created: time.Now()}
+	return nil
+}
+
+// Remove stops serving a model
+func (r *Registry) Remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.models, id)
+}
+
+// Resolve finds a model by ID or CID
+func (r *Registry) Resolve(name string) (*Model, error) {
+	name = strings.TrimSpace(name)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if model, ok := r.models[name]; ok {
+		return model, nil
+	}
+	for _, model := range r.models {
+		if model.Path == name {
+			return model, nil
+		}
+	}
+	return nil, fmt.Errorf("model not found: %s", name)
+}
+
+// List returns the served models sorted by ID
+func (r *Registry) List() []Model {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	models := make([]Model, 0, len(r.models))
+	for _, model := range r.models {
+		models = append(models, *model)
+	}
+	sort.Slice(models, func(i, j int) bool {
+		return models[i].ID < models[j].ID
+	})
+	return models
+}
@@ -0,0 +1,179 @@
+package serving
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/atlas/node/executor"
+)
+
+// Request and response bodies of the OpenAI API endpoints the node serves.
+// Only the fields the node acts on are decoded; others are ignored.
+
+// StringList is a JSON string or array of strings, as used by "prompt",
+// "input" and "stop"
+type StringList []string
+
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = StringList{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("expected a string or an array of strings")
+	}
+	*l = list
+	return nil
+}
+
+type ChatCompletionRequest struct {
+	Model         string                 `json:"model"`
+	Messages      []executor.ChatMessage `json:"messages"`
+	MaxTokens     int                    `json:"max_tokens,omitempty"`
+	Temperature   *float64               `json:"temperature,omitempty"`
+	TopP          *float64               `json:"top_p,omitempty"`
+	N             int                    `json:"n,omitempty"`
+	Stop          StringList             `json:"stop,omitempty"`
+	Stream        bool                   `json:"stream,omitempty"`
+	StreamOptions *StreamOptions         `json:"stream_options,omitempty"`
+}
+
+type ChatCompletionResponse struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []ChatChoice `json:"choices"`
+	Usage   Usage        `json:"usage"`
+}
+
+type ChatChoice struct {
+	Index        int                  `json:"index"`
+	Message      executor.ChatMessage `json:"message"`
+	FinishReason string               `json:"finish_reason"`
+}
+
+type CompletionRequest struct {
+	Model         string         `json:"model"`
+	Prompt        StringList     `json:"prompt"`
+	MaxTokens     int            `json:"max_tokens,omitempty"`
+	Temperature   *float64       `json:"temperature,omitempty"`
+	TopP          *float64       `json:"top_p,omitempty"`
+	N             int            `json:"n,omitempty"`
+	Stop          StringList     `json:"stop,omitempty"`
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+}
+
+type CompletionResponse struct {
+	ID      string             `json:"id"`
+	Object  string             `json:"object"`
+	Created int64              `json:"created"`
+	Model   string             `json:"model"`
+	Choices []CompletionChoice `json:"choices"`
+	Usage   Usage              `json:"usage"`
+}
+
+type CompletionChoice struct {
+	Index        int         `json:"index"`
+	Text         string      `json:"text"`
+	Logprobs     interface{} `json:"logprobs"`
+	FinishReason string      `json:"finish_reason"`
+}
+
+// StreamOptions tunes a streamed response
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// ChatCompletionChunk is one event of a streamed chat completion. The
+// final event carries the finish reason; a usage event with no choices may
+// follow it.
+type ChatCompletionChunk struct {
+	ID      string            `json:"id"`
+	Object  string            `json:"object"`
+	Created int64             `json:"created"`
+	Model   string            `json:"model"`
+	Choices []ChatChunkChoice `json:"choices"`
+	Usage   *Usage            `json:"usage,omitempty"`
+}
+
+type ChatChunkChoice struct {
+	Index        int       `json:"index"`
+	Delta        ChatDelta `json:"delta"`
+	FinishReason *string   `json:"finish_reason"`
+}
+
+type ChatDelta struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// CompletionChunk is one event of a streamed completion
+type CompletionChunk struct {
+	ID      string                  `json:"id"`
+	Object  string                  `json:"object"`
+	Created int64                   `json:"created"`
+	Model   string                  `json:"model"`
+	Choices []CompletionChunkChoice `json:"choices"`
+	Usage   *Usage                  `json:"usage,omitempty"`
+}
+
+type CompletionChunkChoice struct {
+	Index        int         `json:"index"`
+	Text         string      `json:"text"`
+	Logprobs     interface{} `json:"logprobs"`
+	FinishReason *string     `json:"finish_reason"`
+}
+
+type EmbeddingRequest struct {
+	Model          string     `json:"model"`
+	Input          StringList `json:"input"`
+	EncodingFormat string     `json:"encoding_format,omitempty"`
+}
+
+type EmbeddingResponse struct {
+	Object string      `json:"object"`
+	Data   []Embedding `json:"data"`
+	Model  string      `json:"model"`
+	Usage  Usage       `json:"usage"`
+}
+
+type Embedding struct {
+	Object    string    `json:"object"`
+	Embedding []float64 `json:"embedding"`
+	Index     int       `json:"index"`
+}
+
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens,omitempty"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type ModelObject struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+	CID     string `json:"cid,omitempty"`
+}
+
+type ModelList struct {
+	Object string        `json:"object"`
+	Data   []ModelObject `json:"data"`
+}
+
+// APIError is the OpenAI error body
+type APIError struct {
+	Message string  `json:"message"`
+	Type    string  `json:"type"`
+	Param   *string `json:"param"`
+	Code    *string `json:"code"`
+}
+
+type errorResponse struct {
+	Error APIError `json:"error"`
+}
@@ -0,0 +1,399 @@
+package serving
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/atlas/node/executor"
+)
+
+const (
+	// maxRequestSize bounds request bodies
+	maxRequestSize = 16 << 20
+
+	// maxChoices bounds "n" on completion requests
+	maxChoices = 8
+)
+
+// Backend runs inference for the API. *executor.Executor implements it.
+type Backend interface {
+	Generate(ctx context.Context, modelPath string, req executor.GenerateRequest) (*executor.Generation, error)
+	GenerateStream(ctx context.Context, modelPath string, req executor.GenerateRequest) <-chan executor.GenerationChunk
+	Embed(ctx context.Context, modelPath string, inputs []string) (*executor.Embeddings, error)
+}
+
+// Server serves an OpenAI-compatible API for the models in a Registry
+type Server struct {
+	registry *Registry
+	backend  Backend
+	apiKey   string
+	mux      *http.ServeMux
+}
+
+// NewServer creates a server. If apiKey is set, requests must carry it as
+// a bearer token like the OpenAI API expects.
+func NewServer(registry *Registry, backend Backend, apiKey string) *Server {
+	s := &Server{
+		registry: registry,
+		backend:  backend,
+		apiKey:   apiKey,
+		mux:      http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/v1/chat/completions", s.handleChatCompletions)
+	s.mux.HandleFunc("/v1/completions", s.handleCompletions)
+	s.mux.HandleFunc("/v1/embeddings", s.handleEmbeddings)
+	s.mux.HandleFunc("/v1/models", s.handleModels)
+	s.mux.HandleFunc("/v1/models/", s.handleModel)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.apiKey != "" {
+		key, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(key), []byte(s.apiKey)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid_request_error", "invalid_api_key", "Incorrect API key provided")
+			return
+		}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe serves the API on address until ctx is cancelled
+func (s *Server) ListenAndServe(ctx context.Context, address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+
+	server := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("inference server failed: %w", err)
+	}
+	return nil
+}
+
+func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req ChatCompletionRequest
+	model, ok := s.decode(w, r, &req, &req.Model)
+	if !ok {
+		return
+	}
+	if len(req.Messages) == 0 {
+		writeInvalid(w, "messages", "'messages' must contain at least one message")
+		return
+	}
+	n, ok := choiceCount(w, req.N, req.Stream)
+	if !ok {
+		return
+	}
+	if req.Stream {
+		s.streamChatCompletion(w, r, model, req)
+		return
+	}
+
+	resp := ChatCompletionResponse{
+		ID:      newID("chatcmpl-"),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model.ID,
+		Choices: []ChatChoice{},
+	}
+	reqs := make([]executor.GenerateRequest, n)
+	for i := range reqs {
+		reqs[i] = executor.GenerateRequest{
+			Messages:    req.Messages,
+			MaxTokens:   req.MaxTokens,
+			Temperature: req.Temperature,
+			TopP:        req.TopP,
+			Stop:        req.Stop,
+		}
+	}
+	generations, err := s.generateAll(r.Context(), model.Path, reqs)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	for i, generation := range generations {
+		resp.Choices = append(resp.Choices, ChatChoice{
+			Index:        i,
+			Message:      executor.ChatMessage{Role: "assistant", Content: generation.Text},
+			FinishReason: generation.FinishReason,
+		})
+		// The prompt is the same for every choice
+		resp.Usage.PromptTokens = generation.PromptTokens
+		resp.Usage.CompletionTokens += generation.CompletionTokens
+	}
+	resp.Usage.TotalTokens = resp.Usage.PromptTokens + resp.Usage.CompletionTokens
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleCompletions(w http.ResponseWriter, r *http.Request) {
+	var req CompletionRequest
+	model, ok := s.decode(w, r, &req, &req.Model)
+	if !ok {
+		return
+	}
+	if len(req.Prompt) == 0 {
+		writeInvalid(w, "prompt", "'prompt' is required")
+		return
+	}
+	n, ok := choiceCount(w, req.N, req.Stream)
+	if !ok {
+		return
+	}
+	if req.Stream {
+		if len(req.Prompt) > 1 {
+			writeInvalid(w, "prompt", "streaming supports a single prompt")
+			return
+		}
+		s.streamCompletion(w, r, model, req)
+		return
+	}
+
+	resp := CompletionResponse{
+		ID:      newID("cmpl-"),
+		Object:  "text_completion",
+		Created: time.Now().Unix(),
+		Model:   model.ID,
+		Choices: []CompletionChoice{},
+	}
+	// Choices are ordered by prompt, then by sample
+	reqs := make([]executor.GenerateRequest, 0, len(req.Prompt)*n)
+	for _, prompt := range req.Prompt {
+		for i := 0; i < n; i++ {
+			reqs = append(reqs, executor.GenerateRequest{
+				Prompt:      prompt,
+				MaxTokens:   req.MaxTokens,
+				Temperature: req.Temperature,
+				TopP:        req.TopP,
+				Stop:        req.Stop,
+			})
+		}
+	}
+	generations, err := s.generateAll(r.Context(), model.Path, reqs)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	for i, generation := range generations {
+		resp.Choices = append(resp.Choices, CompletionChoice{
+			Index:        i,
+			Text:         generation.Text,
+			FinishReason: generation.FinishReason,
+		})
+		if i%n == 0 {
+			resp.Usage.PromptTokens += generation.PromptTokens
+		}
+		resp.Usage.CompletionTokens += generation.CompletionTokens
+	}
+	resp.Usage.TotalTokens = resp.Usage.PromptTokens + resp.Usage.CompletionTokens
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var req EmbeddingRequest
+	model, ok := s.decode(w, r, &req, &req.Model)
+	if !ok {
+		return
+	}
+	if len(req.Input) == 0 {
+		writeInvalid(w, "input", "'input' is required")
+		return
+	}
+	if req.EncodingFormat != "" && req.EncodingFormat != "float" {
+		writeInvalid(w, "encoding_format", "only the 'float' encoding format is supported")
+		return
+	}
+
+	embeddings, err := s.backend.Embed(r.Context(), model.Path, req.Input)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+
+	resp := EmbeddingResponse{
+		Object: "list",
+		Data:   make([]Embedding, 0, len(embeddings.Embeddings)),
+		Model:  model.ID,
+		Usage: Usage{
+			PromptTokens: embeddings.PromptTokens,
+			TotalTokens:  embeddings.PromptTokens,
+		},
+	}
+	for i, vector := range embeddings.Embeddings {
+		resp.Data = append(resp.Data, Embedding{Object: "embedding", Embedding: vector, Index: i})
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	list := ModelList{Object: "list", Data: []ModelObject{}}
+	for _, model := range s.registry.List() {
+		list.Data = append(list.Data, newModelObject(model))
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) handleModel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	model, err := s.registry.Resolve(strings.TrimPrefix(r.URL.Path, "/v1/models/"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "invalid_request_error", "model_not_found", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, newModelObject(*model))
+}
+
+// decode reads a POST body into req and resolves the model it names
+func (s *Server) decode(w http.ResponseWriter, r *http.Request, req interface{}, modelName *string) (*Model, bool) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, http.MethodPost)
+		return nil, false
+	}
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(req); err != nil {
+		writeInvalid(w, "", fmt.Sprintf("invalid request body: %v", err))
+		return nil, false
+	}
+	if *modelName == "" {
+		writeInvalid(w, "model", "'model' is required")
+		return nil, false
+	}
+
+	model, err := s.registry.Resolve(*modelName)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "invalid_request_error", "model_not_found", err.Error())
+		return nil, false
+	}
+	return model, true
+}
+
+// generateAll runs reqs concurrently, so the backend can batch them, and
+// returns their generations in order. The first error cancels the rest.
+func (s *Server) generateAll(ctx context.Context, modelPath string, reqs []executor.GenerateRequest) ([]*executor.Generation, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	generations := make([]*executor.Generation, len(reqs))
+	errs := make([]error, len(reqs))
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		wg.Add(1)
+		go func(i int, req executor.GenerateRequest) {
+			defer wg.Done()
+			generations[i], errs[i] = s.backend.Generate(ctx, modelPath, req)
+			if errs[i] != nil {
+				cancel()
+			}
+		}(i, req)
+	}
+	wg.Wait()
+
+	// Report the error that caused the others to be cancelled
+	var firstErr error
+	for _, err := range errs {
+		if err != nil && (firstErr == nil || errors.Is(firstErr, context.Canceled)) {
+			firstErr = err
+		}
+	}
+	return generations, firstErr
+}
+
+// choiceCount validates "n" and "stream"
+func choiceCount(w http.ResponseWriter, n int, stream bool) (int, bool) {
+	if n == 0 {
+		n = 1
+	}
+	if n < 0 || n > maxChoices {
+		writeInvalid(w, "n", fmt.Sprintf("'n' must be between 1 and %d", maxChoices))
+		return 0, false
+	}
+	if stream && n != 1 {
+		writeInvalid(w, "n", "streaming supports only 'n' of 1")
+		return 0, false
+	}
+	return n, true
+}
+
+func newModelObject(model Model) ModelObject {
+	return ModelObject{
+		ID:      model.ID,
+		Object:  "model",
+		Created: model.Created.Unix(),
+		OwnedBy: "atlas",
+		CID:     model.Path,
+	}
+}
+
+func newID(prefix string) string {
+	buf := make([]byte, 12)
+	rand.Read(buf)
+	return prefix + hex.EncodeToString(buf)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, errType string, code string, message string) {
+	apiErr := APIError{Message: message, Type: errType}
+	if code != "" {
+		apiErr.Code = &code
+	}
+	writeJSON(w, status, errorResponse{Error: apiErr})
+}
+
+func writeInvalid(w http.ResponseWriter, param string, message string) {
+	apiErr := APIError{Message: message, Type: "invalid_request_error"}
+	if param != "" {
+		apiErr.Param = &param
+	}
+	writeJSON(w, http.StatusBadRequest, errorResponse{Error: apiErr})
+}
+
+func writeBackendError(w http.ResponseWriter, err error) {
+	if errors.Is(err, context.Canceled) {
+		// The client went away; nobody is left to read a response
+		return
+	}
+	writeError(w, http.StatusInternalServerError, "server_error", "", err.Error())
+}
+
+func writeMethodNotAllowed(w http.ResponseWriter, method string) {
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "", "method not allowed")
+}
@@ -0,0 +1,264 @@
+package serving
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/atlas/node/executor"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBackend struct {
+	mu       sync.Mutex
+	requests []executor.GenerateRequest
+	paths    []string
+}
+
+func (b *fakeBackend) Generate(ctx context.Context, modelPath string, req executor.GenerateRequest) (*executor.Generation, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests = append(b.requests, req)
+	b.paths = append(b.paths, modelPath)
+
+	text := "reply to " + req.Prompt
+	if len(req.Messages) > 0 {
+		text = "reply to " + req.Messages[len(req.Messages)-1].Content
+	}
+	return &executor.Generation{Text: text, FinishReason: "stop", PromptTokens: 3, CompletionTokens: 2}, nil
+}
+
+// GenerateStream sends the reply to Generate a word at a time
+func (b *fakeBackend) GenerateStream(ctx context.Context, modelPath string, req executor.GenerateRequest) <-chan executor.GenerationChunk {
+	chunks := make(chan executor.GenerationChunk)
+	go func() {
+		defer close(chunks)
+		generation, _ := b.Generate(ctx, modelPath, req)
+		for i, word := range strings.SplitAfter(generation.Text, " ") {
+			if i == 0 && req.Prompt == "fail" {
+				chunks <- executor.GenerationChunk{Err: fmt.Errorf("worker exited")}
+				return
+			}
+			chunks <- executor.GenerationChunk{Text: word}
+		}
+		chunks <- executor.GenerationChunk{Generation: generation}
+	}()
+	return chunks
+}
+
+func (b *fakeBackend) Embed(ctx context.Context, modelPath string, inputs []string) (*executor.Embeddings, error) {
+	embeddings := &executor.Embeddings{PromptTokens: len(inputs)}
+	for i := range inputs {
+		embeddings.Embeddings = append(embeddings.Embeddings, []float64{float64(i), 1})
+	}
+	return embeddings, nil
+}
+
+func newTestServer(t *testing.T, apiKey string) (*Server, *fakeBackend) {
+	t.Helper()
+	registry := NewRegistry()
+	require.NoError(t, registry.Add("llama-3-8b", "QmLlama"))
+	require.NoError(t, registry.Add("minilm", "/models/minilm"))
+	backend := &fakeBackend{}
+	return NewServer(registry, backend, apiKey), backend
+}
+
+func post(t *testing.T, handler http.Handler, path string, body string, out interface{}) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if out != nil {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), out), rec.Body.String())
+	}
+	return rec.Code
+}
+
+func TestChatCompletions(t *testing.T) {
+	server, backend := newTestServer(t, "")
+
+	var resp ChatCompletionResponse
+	code := post(t, server, "/v1/chat/completions", `{
+		"model": "llama-3-8b",
+		"messages": [{"role": "system", "content": "Be brief."}, {"role": "user", "content": "hi"}],
+		"max_tokens": 16,
+		"temperature": 0,
+		"stop": "\n",
+		"n": 2
+	}`, &resp)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "chat.completion", resp.Object)
+	require.Equal(t, "llama-3-8b", resp.Model)
+	require.True(t, strings.HasPrefix(resp.ID, "chatcmpl-"))
+	require.Len(t, resp.Choices, 2)
+	require.Equal(t, "assistant", resp.Choices[1].Message.Role)
+	require.Equal(t, "reply to hi", resp.Choices[1].Message.Content)
+	require.Equal(t, Usage{PromptTokens: 3, CompletionTokens: 4, TotalTokens: 7}, resp.Usage)
+
+	require.Equal(t, "QmLlama", backend.paths[0])
+	require.Equal(t, 16, backend.requests[0].MaxTokens)
+	require.Equal(t, 0.0, *backend.requests[0].Temperature)
+	require.Equal(t, []string{"\n"}, backend.requests[0].Stop)
+	require.Len(t, backend.requests[0].Messages, 2)
+}
+
+func TestCompletions_RoutesByCID(t *testing.T) {
+	server, backend := newTestServer(t, "")
+
+	var resp CompletionResponse
+	code := post(t, server, "/v1/completions", `{"model": "QmLlama", "prompt": ["a", "b"]}`, &resp)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "text_completion", resp.Object)
+	require.Equal(t, "llama-3-8b", resp.Model)
+	require.Len(t, resp.Choices, 2)
+	require.Equal(t, "reply to b", resp.Choices[1].Text)
+	require.Equal(t, 1, resp.Choices[1].Index)
+	require.Equal(t, 6, resp.Usage.PromptTokens)
+	require.Equal(t, []string{"QmLlama", "QmLlama"}, backend.paths)
+}
+
+// readEvents returns the data of each Server-Sent Event in body
+func readEvents(t *testing.T, body string) []string {
+	t.Helper()
+	var events []string
+	for _, event := range strings.Split(strings.TrimSuffix(body, "\n\n"), "\n\n") {
+		data, found := strings.CutPrefix(event, "data: ")
+		require.True(t, found, event)
+		events = append(events, data)
+	}
+	return events
+}
+
+func TestChatCompletions_Stream(t *testing.T) {
+	server, _ := newTestServer(t, "")
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{
+		"model": "llama-3-8b",
+		"messages": [{"role": "user", "content": "hi"}],
+		"stream": true
+	}`))
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+
+	events := readEvents(t, rec.Body.String())
+	require.Len(t, events, 5)
+	require.Equal(t, "[DONE]", events[4])
+
+	var text string
+	for i, event := range events[:4] {
+		var chunk ChatCompletionChunk
+		require.NoError(t, json.Unmarshal([]byte(event), &chunk))
+		require.Equal(t, "chat.completion.chunk", chunk.Object)
+		require.Len(t, chunk.Choices, 1)
+		if i == 0 {
+			require.Equal(t, "assistant", chunk.Choices[0].Delta.Role)
+		}
+		text += chunk.Choices[0].Delta.Content
+		if i < 3 {
+			require.Nil(t, chunk.Choices[0].FinishReason)
+		} else {
+			require.Equal(t, "stop", *chunk.Choices[0].FinishReason)
+		}
+	}
+	require.Equal(t, "reply to hi", text)
+}
+
+func TestCompletions_Stream(t *testing.T) {
+	server, _ := newTestServer(t, "")
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/completions", strings.NewReader(
+		`{"model": "llama-3-8b", "prompt": "a b", "stream": true, "stream_options": {"include_usage": true}}`))
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	events := readEvents(t, rec.Body.String())
+	require.Len(t, events, 7)
+	var usage CompletionChunk
+	require.NoError(t, json.Unmarshal([]byte(events[5]), &usage))
+	require.Empty(t, usage.Choices)
+	require.Equal(t, &Usage{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5}, usage.Usage)
+
+	// An error before any text is an ordinary error response
+	var resp errorResponse
+	code := post(t, server, "/v1/completions", `{"model": "llama-3-8b", "prompt": "fail", "stream": true}`, &resp)
+	require.Equal(t, http.StatusInternalServerError, code)
+	require.Equal(t, "worker exited", resp.Error.Message)
+}
+
+func TestEmbeddings(t *testing.T) {
+	server, _ := newTestServer(t, "")
+
+	var resp EmbeddingResponse
+	code := post(t, server, "/v1/embeddings", `{"model": "minilm", "input": ["x", "y", "z"]}`, &resp)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "list", resp.Object)
+	require.Len(t, resp.Data, 3)
+	require.Equal(t, 2, resp.Data[2].Index)
+	require.Equal(t, []float64{2, 1}, resp.Data[2].Embedding)
+	require.Equal(t, 3, resp.Usage.TotalTokens)
+}
+
+func TestModels(t *testing.T) {
+	server, _ := newTestServer(t, "")
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var list ModelList
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Data, 2)
+	require.Equal(t, "llama-3-8b", list.Data[0].ID)
+	require.Equal(t, "model", list.Data[0].Object)
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/models/QmLlama", nil)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"id":"llama-3-8b"`)
+}
+
+func TestErrors(t *testing.T) {
+	server, _ := newTestServer(t, "sk-test")
+
+	tests := []struct {
+		path   string
+		body   string
+		key    string
+		status int
+		match  string
+	}{
+		{"/v1/completions", `{"model":"llama-3-8b","prompt":"hi"}`, "", http.StatusUnauthorized, "API key"},
+		{"/v1/completions", `{"model":"missing","prompt":"hi"}`, "sk-test", http.StatusNotFound, "model not found"},
+		{"/v1/completions", `{"prompt":"hi"}`, "sk-test", http.StatusBadRequest, "'model' is required"},
+		{"/v1/completions", `{"model":"llama-3-8b"}`, "sk-test", http.StatusBadRequest, "'prompt' is required"},
+		{"/v1/chat/completions", `{"model":"llama-3-8b","messages":[]}`, "sk-test", http.StatusBadRequest, "messages"},
+		{"/v1/chat/completions", `{"model":"llama-3-8b","messages":[{"role":"user","content":"hi"}],"n":100}`, "sk-test", http.StatusBadRequest, "'n'"},
+		{"/v1/chat/completions", `{"model":"llama-3-8b","messages":[{"role":"user","content":"hi"}],"n":2,"stream":true}`, "sk-test", http.StatusBadRequest, "streaming"},
+		{"/v1/completions", `{"model":"llama-3-8b","prompt":["a","b"],"stream":true}`, "sk-test", http.StatusBadRequest, "single prompt"},
+		{"/v1/embeddings", `{"model":"minilm","input":[1,2]}`, "sk-test", http.StatusBadRequest, "invalid request body"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+		if tt.key != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.key)
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		require.Equal(t, tt.status, rec.Code, fmt.Sprintf("%s %s", tt.path, tt.body))
+
+		var resp errorResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		require.Contains(t, resp.Error.Message, tt.match)
+	}
+}
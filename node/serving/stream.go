@@ -0,0 +1,157 @@
+package serving
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/atlas/node/executor"
+)
+
+// streamEvents builds the events of a streamed response
+type streamEvents struct {
+	text   func(text string) interface{}   // A piece of generated text
+	finish func(reason string) interface{} // The end of the generation
+	usage  func(usage Usage) interface{}   // Token counts; nil unless requested
+}
+
+func (s *Server) streamChatCompletion(w http.ResponseWriter, r *http.Request, model *Model, req ChatCompletionRequest) {
+	id := newID("chatcmpl-")
+	created := time.Now().Unix()
+	chunk := func(choices []ChatChunkChoice) *ChatCompletionChunk {
+		return &ChatCompletionChunk{ID: id, Object: "chat.completion.chunk", Created: created, Model: model.ID, Choices: choices}
+	}
+
+	role := "assistant"
+	events := streamEvents{
+		text: func(text string) interface{} {
+			// The first delta names the role
+			delta := ChatDelta{Role: role, Content: text}
+			role = ""
+			return chunk([]ChatChunkChoice{{Delta: delta}})
+		},
+		finish: func(reason string) interface{} {
+			return chunk([]ChatChunkChoice{{Delta: ChatDelta{Role: role}, FinishReason: &reason}})
+		},
+	}
+	if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+		events.usage = func(usage Usage) interface{} {
+			c := chunk([]ChatChunkChoice{})
+			c.Usage = &usage
+			return c
+		}
+	}
+
+	s.streamGeneration(w, r, model.Path, executor.GenerateRequest{
+		Messages:    req.Messages,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		Stop:        req.Stop,
+	}, events)
+}
+
+func (s *Server) streamCompletion(w http.ResponseWriter, r *http.Request, model *Model, req CompletionRequest) {
+	id := newID("cmpl-")
+	created := time.Now().Unix()
+	chunk := func(choices []CompletionChunkChoice) *CompletionChunk {
+		return &CompletionChunk{ID: id, Object: "text_completion", Created: created, Model: model.ID, Choices: choices}
+	}
+
+	events := streamEvents{
+		text: func(text string) interface{} {
+			return chunk([]CompletionChunkChoice{{Text: text}})
+		},
+		finish: func(reason string) interface{} {
+			return chunk([]CompletionChunkChoice{{FinishReason: &reason}})
+		},
+	}
+	if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+		events.usage = func(usage Usage) interface{} {
+			c := chunk([]CompletionChunkChoice{})
+			c.Usage = &usage
+			return c
+		}
+	}
+
+	s.streamGeneration(w, r, model.Path, executor.GenerateRequest{
+		Prompt:      req.Prompt[0],
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		Stop:        req.Stop,
+	}, events)
+}
+
+// streamGeneration runs req and writes it to the client as Server-Sent
+// Events, ending with "data: [DONE]". An error before any text is sent is
+// an ordinary error response; after that it is sent as an error event. The
+// generation stops when the client goes away.
+func (s *Server) streamGeneration(w http.ResponseWriter, r *http.Request, modelPath string, req executor.GenerateRequest, events streamEvents) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	started := false
+	for chunk := range s.backend.GenerateStream(ctx, modelPath, req) {
+		if chunk.Err != nil {
+			if !started {
+				writeBackendError(w, chunk.Err)
+				return
+			}
+			if errors.Is(chunk.Err, context.Canceled) {
+				return
+			}
+			writeEvent(w, errorResponse{Error: APIError{Message: chunk.Err.Error(), Type: "server_error"}})
+			return
+		}
+		if !started {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+
+		var err error
+		if chunk.Generation == nil {
+			err = writeEvent(w, events.text(chunk.Text))
+		} else {
+			err = writeEvent(w, events.finish(chunk.Generation.FinishReason))
+			if err == nil && events.usage != nil {
+				err = writeEvent(w, events.usage(Usage{
+					PromptTokens:     chunk.Generation.PromptTokens,
+					CompletionTokens: chunk.Generation.CompletionTokens,
+					TotalTokens:      chunk.Generation.PromptTokens + chunk.Generation.CompletionTokens,
+				}))
+			}
+			if err == nil {
+				err = writeData(w, "[DONE]")
+			}
+		}
+		if err != nil {
+			// The client went away
+			return
+		}
+	}
+}
+
+// writeEvent sends body as one Server-Sent Event
+func writeEvent(w http.ResponseWriter, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return writeData(w, string(data))
+}
+
+func writeData(w http.ResponseWriter, data string) error {
+	if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+		return err
+	}
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
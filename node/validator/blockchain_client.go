@@ -3,8 +3,15 @@ package validator
 import (
 	"context"
 	"fmt"
+
+	"github.com/atlas/node/health"
+	"github.com/atlas/storage/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+const tracerName = "github.com/atlas/node/validator"
+
 type BlockchainClient interface {
 	QueryShardAssignments(ctx context.Context, shardID string) ([]string, error)
 	QueryNodeCapacity(ctx context.Context, nodeID string) (int, error)
@@ -19,15 +26,64 @@ func NewHTTPBlockchainClient(rpcURL string) *HTTPBlockchainClient {
 	return &HTTPBlockchainClient{rpcURL: rpcURL}
 }
 
-func (c *HTTPBlockchainClient) QueryShardAssignments(ctx context.Context, shardID string) ([]string, error) {
+func (c *HTTPBlockchainClient) QueryShardAssignments(ctx context.Context, shardID string) (nodes []string, err error) {
+	_, span := c.startQuery(ctx, "chain.query_shard_assignments", attribute.String("shard.id", shardID))
+	defer func() { tracing.End(span, err) }()
 	return nil, fmt.Errorf("blockchain client not fully implemented: requires gRPC client with protobuf stubs")
 }
 
-func (c *HTTPBlockchainClient) QueryNodeCapacity(ctx context.Context, nodeID string) (int, error) {
+func (c *HTTPBlockchainClient) QueryNodeCapacity(ctx context.Context, nodeID string) (capacity int, err error) {
+	_, span := c.startQuery(ctx, "chain.query_node_capacity", attribute.String("node.id", nodeID))
+	defer func() { tracing.End(span, err) }()
 	return 0, fmt.Errorf("blockchain client not fully implemented: requires gRPC client with protobuf stubs")
 }
 
-func (c *HTTPBlockchainClient) QueryNodeReputation(ctx context.Context, nodeID string) (float64, error) {
+func (c *HTTPBlockchainClient) QueryNodeReputation(ctx context.Context, nodeID string) (reputation float64, err error) {
+	_, span := c.startQuery(ctx, "chain.query_node_reputation", attribute.String("node.id", nodeID))
+	defer func() { tracing.End(span, err) }()
 	return 0.0, fmt.Errorf("blockchain client not fully implemented: requires gRPC client with protobuf stubs")
 }
 
+// SubmitHeartbeat submits a signed heartbeat as the compute module's
+// MsgUpdateHeartbeat
+func (c *HTTPBlockchainClient) SubmitHeartbeat(ctx context.Context, beat health.ChainHeartbeat) (err error) {
+	_, span := c.startQuery(ctx, "chain.submit_heartbeat", attribute.String("node.id", beat.NodeID))
+	defer func() { tracing.End(span, err) }()
+	return fmt.Errorf("blockchain client not fully implemented: requires gRPC client with protobuf stubs")
+}
+
+// QueryReplicaAssignments returns the tasks x/validation assigned the node
+// to run as a replica that it has not submitted a result for
+func (c *HTTPBlockchainClient) QueryReplicaAssignments(ctx context.Context, nodeID string) (assignments []ReplicaAssignment, err error) {
+	_, span := c.startQuery(ctx, "chain.query_replica_assignments", attribute.String("node.id", nodeID))
+	defer func() { tracing.End(span, err) }()
+	return nil, fmt.Errorf("blockchain client not fully implemented: requires gRPC client with protobuf stubs")
+}
+
+// SubmitResult submits a replica's result as x/validation's MsgSubmitResult
+func (c *HTTPBlockchainClient) SubmitResult(ctx context.Context, result ResultSubmission) (err error) {
+	_, span := c.startQuery(ctx, "chain.submit_result", attribute.String("task.id", result.TaskID))
+	defer func() { tracing.End(span, err) }()
+	return fmt.Errorf("blockchain client not fully implemented: requires gRPC client with protobuf stubs")
+}
+
+// QueryRefereeAssignments returns the open challenges the node referees and
+// has not submitted a replay for
+func (c *HTTPBlockchainClient) QueryRefereeAssignments(ctx context.Context, nodeID string) (assignments []RefereeAssignment, err error) {
+	_, span := c.startQuery(ctx, "chain.query_referee_assignments", attribute.String("node.id", nodeID))
+	defer func() { tracing.End(span, err) }()
+	return nil, fmt.Errorf("blockchain client not fully implemented: requires gRPC client with protobuf stubs")
+}
+
+// SubmitSegmentResult submits a referee's replay as x/validation's
+// MsgSubmitSegmentResult
+func (c *HTTPBlockchainClient) SubmitSegmentResult(ctx context.Context, result SegmentResultSubmission) (err error) {
+	_, span := c.startQuery(ctx, "chain.submit_segment_result", attribute.String("challenge.id", result.ChallengeID))
+	defer func() { tracing.End(span, err) }()
+	return fmt.Errorf("blockchain client not fully implemented: requires gRPC client with protobuf stubs")
+}
+
+// startQuery starts the span of a chain query
+func (c *HTTPBlockchainClient) startQuery(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Start(ctx, tracerName, name, append(attributes, attribute.String("chain.rpc", c.rpcURL))...)
+}
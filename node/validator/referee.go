@@ -0,0 +1,150 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/atlas/node/proof"
+	"github.com/atlas/storage/identity"
+)
+
+// RefereeAssignment is an open x/validation challenge the node referees:
+// the disputed segment of a locked result and the one before it
+type RefereeAssignment struct {
+	ChallengeID string
+	TaskID      string
+	NodeID      string   // Node whose result is challenged
+	InputCIDs   []string // Inputs of the challenged proof
+	Segment     proof.Segment
+	Previous    *proof.Segment // Nil if the first segment is disputed
+}
+
+// SegmentResultSubmission is a referee's replay as MsgSubmitSegmentResult
+// carries it, signed with proof.SignSegmentResult
+type SegmentResultSubmission struct {
+	ChallengeID string
+	NodeID      string
+	StateHash   string
+	Signature   string
+}
+
+// RefereeChain is the part of the chain client the referee worker uses
+type RefereeChain interface {
+	QueryRefereeAssignments(ctx context.Context, nodeID string) ([]RefereeAssignment, error)
+	SubmitSegmentResult(ctx context.Context, result SegmentResultSubmission) error
+}
+
+// RefereeWorker replays the segments of the challenges the node referees,
+// usually with executor.Replay, and submits the state hashes it reaches
+type RefereeWorker struct {
+	nodeID   string
+	signer   *identity.Identity
+	chain    RefereeChain
+	verifier *proof.Verifier
+	replays  map[string]*refereeReplay // By challenge
+	queryErr string
+}
+
+// refereeReplay is a challenge the worker replayed
+type refereeReplay struct {
+	stateHash string
+	submitted bool
+}
+
+func NewRefereeWorker(nodeID string, signer *identity.Identity, chain RefereeChain, replay proof.ReplayFunc) *RefereeWorker {
+	return &RefereeWorker{
+		nodeID: nodeID,
+		signer: signer,
+		chain:  chain,
+		// Referees only replay; the chain checked the challenged proof's
+		// signature when the result was locked
+		verifier: proof.NewVerifier(nil, replay),
+		replays:  make(map[string]*refereeReplay),
+	}
+}
+
+func (w *RefereeWorker) Start(ctx context.Context) error {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			w.poll(ctx)
+		}
+	}
+}
+
+// poll replays the segments of the challenges assigned since the last poll,
+// one at a time, and submits the state hashes. A failed replay or
+// submission is retried on the next poll while the challenge is open; a
+// segment is not replayed again once its state hash is known.
+func (w *RefereeWorker) poll(ctx context.Context) {
+	assignments, err := w.chain.QueryRefereeAssignments(ctx, w.nodeID)
+	if err != nil {
+		// Printed when it differs from the last one, so an unreachable
+		// chain does not flood the log
+		if err.Error() != w.queryErr {
+			fmt.Printf("Warning: failed to query referee assignments: %v\n", err)
+			w.queryErr = err.Error()
+		}
+		return
+	}
+	w.queryErr = ""
+
+	assigned := make(map[string]bool, len(assignments))
+	for _, assignment := range assignments {
+		assigned[assignment.ChallengeID] = true
+	}
+	for challengeID := range w.replays {
+		if !assigned[challengeID] {
+			delete(w.replays, challengeID)
+		}
+	}
+
+	for _, assignment := range assignments {
+		replay, ok := w.replays[assignment.ChallengeID]
+		if !ok {
+			stateHash, err := w.replay(ctx, assignment)
+			if err != nil {
+				fmt.Printf("Warning: failed to replay challenge %s of task %s: %v\n", assignment.ChallengeID, assignment.TaskID, err)
+				continue
+			}
+			replay = &refereeReplay{stateHash: stateHash}
+			w.replays[assignment.ChallengeID] = replay
+		}
+		if replay.submitted {
+			continue
+		}
+
+		err := w.chain.SubmitSegmentResult(ctx, SegmentResultSubmission{
+			ChallengeID: assignment.ChallengeID,
+			NodeID:      w.nodeID,
+			StateHash:   replay.stateHash,
+			Signature:   proof.SignSegmentResult(assignment.ChallengeID, replay.stateHash, w.signer),
+		})
+		if err != nil {
+			fmt.Printf("Warning: failed to submit replay of challenge %s: %v\n", assignment.ChallengeID, err)
+			continue
+		}
+		replay.submitted = true
+	}
+}
+
+// replay re-executes the disputed segment from the checkpoint of the one
+// before it, or from the task's inputs, and returns the state hash
+func (w *RefereeWorker) replay(ctx context.Context, assignment RefereeAssignment) (string, error) {
+	computation := &proof.ProofOfComputation{
+		TaskID:    assignment.TaskID,
+		NodeID:    assignment.NodeID,
+		InputCIDs: assignment.InputCIDs,
+	}
+	if assignment.Previous != nil {
+		computation.Segments = append(computation.Segments, *assignment.Previous)
+	}
+	computation.Segments = append(computation.Segments, assignment.Segment)
+	return w.verifier.Replay(ctx, computation, len(computation.Segments)-1)
+}
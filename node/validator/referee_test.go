@@ -0,0 +1,105 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/atlas/node/proof"
+	"github.com/atlas/storage/identity"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRefereeChain struct {
+	assignments []RefereeAssignment
+	results     []SegmentResultSubmission
+	submitErr   error
+}
+
+func (c *fakeRefereeChain) QueryRefereeAssignments(ctx context.Context, nodeID string) ([]RefereeAssignment, error) {
+	return c.assignments, nil
+}
+
+func (c *fakeRefereeChain) SubmitSegmentResult(ctx context.Context, result SegmentResultSubmission) error {
+	if c.submitErr != nil {
+		return c.submitErr
+	}
+	c.results = append(c.results, result)
+	return nil
+}
+
+func TestRefereeWorker_SubmitsReplays(t *testing.T) {
+	signer, err := identity.Generate()
+	require.NoError(t, err)
+
+	type replayed struct {
+		from *proof.Segment
+		to   proof.Segment
+	}
+	var replays []replayed
+	replay := func(ctx context.Context, computation *proof.ProofOfComputation, from *proof.Segment, to proof.Segment) (string, error) {
+		require.Equal(t, []string{"QmModel", "QmDataset"}, computation.InputCIDs)
+		replays = append(replays, replayed{from: from, to: to})
+		return "hash-" + to.StateHash, nil
+	}
+
+	previous := proof.Segment{Epoch: 1, Checkpoint: "QmCheckpoint1", StateHash: "s1"}
+	chain := &fakeRefereeChain{
+		assignments: []RefereeAssignment{
+			{ChallengeID: "challenge-1", TaskID: "task-1", NodeID: "node-1", InputCIDs: []string{"QmModel", "QmDataset"}, Segment: proof.Segment{Epoch: 2, StateHash: "s2"}, Previous: &previous},
+			{ChallengeID: "challenge-2", TaskID: "task-2", NodeID: "node-1", InputCIDs: []string{"QmModel", "QmDataset"}, Segment: proof.Segment{Epoch: 1, StateHash: "s1"}},
+		},
+		submitErr: errors.New("chain unavailable"),
+	}
+	worker := NewRefereeWorker("node-2", signer, chain, replay)
+	ctx := context.Background()
+
+	worker.poll(ctx)
+	require.Len(t, replays, 2)
+	require.Equal(t, &previous, replays[0].from)
+	require.Equal(t, 2, replays[0].to.Epoch)
+	require.Nil(t, replays[1].from)
+	require.Empty(t, chain.results)
+
+	// Submissions are retried without replaying again
+	chain.submitErr = nil
+	worker.poll(ctx)
+	require.Len(t, replays, 2)
+	require.Len(t, chain.results, 2)
+
+	result := chain.results[0]
+	require.Equal(t, "challenge-1", result.ChallengeID)
+	require.Equal(t, "node-2", result.NodeID)
+	require.Equal(t, "hash-s2", result.StateHash)
+	require.NoError(t, identity.Verify(signer.PublicKey(), identity.DomainSegment, []byte("challenge-1\nhash-s2"), result.Signature))
+
+	// Submitted replays are not sent twice, and settled challenges are
+	// forgotten
+	worker.poll(ctx)
+	require.Len(t, chain.results, 2)
+	chain.assignments = nil
+	worker.poll(ctx)
+	require.Empty(t, worker.replays)
+}
+
+func TestRefereeWorker_RetriesFailedReplays(t *testing.T) {
+	signer, err := identity.Generate()
+	require.NoError(t, err)
+
+	attempts := 0
+	replay := func(ctx context.Context, computation *proof.ProofOfComputation, from *proof.Segment, to proof.Segment) (string, error) {
+		attempts++
+		if attempts == 1 {
+			return "", errors.New("ipfs unavailable")
+		}
+		return "hash", nil
+	}
+	chain := &fakeRefereeChain{assignments: []RefereeAssignment{{ChallengeID: "challenge-1", Segment: proof.Segment{Epoch: 1}}}}
+	worker := NewRefereeWorker("node-2", signer, chain, replay)
+
+	worker.poll(context.Background())
+	require.Empty(t, chain.results)
+	worker.poll(context.Background())
+	require.Len(t, chain.results, 1)
+	require.Equal(t, 2, attempts)
+}
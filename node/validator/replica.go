@@ -0,0 +1,202 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/atlas/node/executor"
+	"github.com/atlas/node/proof"
+)
+
+// ReplicaAssignment is a task x/validation assigned the node to run as a
+// replica, with the inputs of the task it re-runs
+type ReplicaAssignment struct {
+	TaskID        string
+	JobID         string
+	ModelCID      string
+	DatasetCID    string
+	CheckpointCID string // The task's resume checkpoint, if it had one
+}
+
+// ResultSubmission is a replica's result as MsgSubmitResult carries it. The
+// proof is signed by the node like the proof it completes its own tasks
+// with.
+type ResultSubmission struct {
+	TaskID  string
+	Proof   *proof.ProofOfComputation
+	Metrics map[string]float64
+}
+
+// ReplicaChain is the part of the chain client the replica worker uses
+type ReplicaChain interface {
+	QueryReplicaAssignments(ctx context.Context, nodeID string) ([]ReplicaAssignment, error)
+	SubmitResult(ctx context.Context, result ResultSubmission) error
+}
+
+// ReplicaWorker runs the replicas the node is assigned as training tasks of
+// its executor and submits their results. Replicas keep the ID of the task
+// they re-run, which the proof commits to. The executor must prove tasks
+// (executor.SetProver).
+type ReplicaWorker struct {
+	nodeID   string
+	chain    ReplicaChain
+	executor *executor.Executor
+	replicas map[string]bool // Tasks added to the executor as replicas
+	queryErr string
+}
+
+func NewReplicaWorker(nodeID string, chain ReplicaChain, exec *executor.Executor) *ReplicaWorker {
+	return &ReplicaWorker{
+		nodeID:   nodeID,
+		chain:    chain,
+		executor: exec,
+		replicas: make(map[string]bool),
+	}
+}
+
+func (w *ReplicaWorker) Start(ctx context.Context) error {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			w.poll(ctx)
+		}
+	}
+}
+
+// poll starts the replicas assigned since the last poll, submits the
+// results of completed ones and removes them once submitted. Replicas
+// that are no longer assigned, because the execution was settled without
+// them, are cancelled. Paused replicas, e.g. after a restart, are resumed.
+func (w *ReplicaWorker) poll(ctx context.Context) {
+	assignments, err := w.chain.QueryReplicaAssignments(ctx, w.nodeID)
+	if err != nil {
+		// Printed when it differs from the last one, so an unreachable
+		// chain does not flood the log
+		if err.Error() != w.queryErr {
+			fmt.Printf("Warning: failed to query replica assignments: %v\n", err)
+			w.queryErr = err.Error()
+		}
+		return
+	}
+	w.queryErr = ""
+
+	assigned := make(map[string]bool, len(assignments))
+	for _, assignment := range assignments {
+		assigned[assignment.TaskID] = true
+		if w.replicas[assignment.TaskID] {
+			continue
+		}
+		if _, err := w.executor.TaskSnapshot(assignment.TaskID); err == nil {
+			// Added before a restart
+			w.replicas[assignment.TaskID] = true
+			continue
+		}
+		err := w.executor.AddTask(&executor.Task{
+			ID:            assignment.TaskID,
+			JobID:         assignment.JobID,
+			TaskType:      "training",
+			ModelPath:     assignment.ModelCID,
+			DatasetPath:   assignment.DatasetCID,
+			CheckpointCID: assignment.CheckpointCID,
+		})
+		if err != nil {
+			fmt.Printf("Warning: failed to start replica of task %s: %v\n", assignment.TaskID, err)
+			continue
+		}
+		w.replicas[assignment.TaskID] = true
+	}
+
+	for taskID := range w.replicas {
+		task, err := w.executor.TaskSnapshot(taskID)
+		if err != nil {
+			delete(w.replicas, taskID)
+			continue
+		}
+
+		switch task.Status {
+		case "completed":
+			if assigned[taskID] {
+				if err := w.submit(ctx, task); err != nil {
+					fmt.Printf("Warning: %v\n", err)
+					continue
+				}
+			}
+			w.remove(taskID)
+		case "failed", "cancelled":
+			if task.Error != nil {
+				fmt.Printf("Warning: replica of task %s failed: %v\n", taskID, task.Error)
+			}
+			w.remove(taskID)
+		case "paused":
+			if !assigned[taskID] {
+				w.cancel(taskID)
+			} else if err := w.executor.ResumeTask(taskID); err != nil {
+				fmt.Printf("Warning: failed to resume replica of task %s: %v\n", taskID, err)
+			}
+		default:
+			if !assigned[taskID] {
+				w.cancel(taskID)
+			}
+		}
+	}
+}
+
+// submit submits the result of a completed replica
+func (w *ReplicaWorker) submit(ctx context.Context, task executor.Task) error {
+	if task.Proof == nil {
+		return fmt.Errorf("replica of task %s completed without a proof", task.ID)
+	}
+	events, err := w.executor.TaskMetrics(task.ID)
+	if err != nil {
+		return err
+	}
+	result := ResultSubmission{TaskID: task.ID, Proof: task.Proof, Metrics: finalMetrics(events)}
+	if err := w.chain.SubmitResult(ctx, result); err != nil {
+		return fmt.Errorf("failed to submit result of task %s: %w", task.ID, err)
+	}
+	return nil
+}
+
+// remove forgets a finished replica
+func (w *ReplicaWorker) remove(taskID string) {
+	if err := w.executor.RemoveTask(taskID); err != nil {
+		fmt.Printf("Warning: failed to remove replica of task %s: %v\n", taskID, err)
+		return
+	}
+	delete(w.replicas, taskID)
+}
+
+// cancel stops a replica that is no longer assigned. It is removed once it
+// has stopped.
+func (w *ReplicaWorker) cancel(taskID string) {
+	if err := w.executor.CancelTask(taskID); err != nil {
+		fmt.Printf("Warning: failed to cancel replica of task %s: %v\n", taskID, err)
+	}
+}
+
+// finalMetrics returns the last loss a task reported, as "loss", and the
+// last value of each evaluation metric. They are compared with the other
+// nodes' for non-deterministic tasks.
+func finalMetrics(events []executor.TaskEvent) map[string]float64 {
+	metrics := make(map[string]float64)
+	for _, event := range events {
+		switch event.Type {
+		case executor.EventLoss:
+			metrics["loss"] = event.Value
+		case executor.EventEval:
+			for name, value := range event.Metrics {
+				metrics[name] = value
+			}
+		}
+	}
+	if len(metrics) == 0 {
+		return nil
+	}
+	return metrics
+}
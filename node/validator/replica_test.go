@@ -0,0 +1,143 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/atlas/node/executor"
+	"github.com/atlas/storage/identity"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeReplicaChain struct {
+	mu          sync.Mutex
+	assignments []ReplicaAssignment
+	results     []ResultSubmission
+	submitErr   error
+}
+
+func (c *fakeReplicaChain) QueryReplicaAssignments(ctx context.Context, nodeID string) ([]ReplicaAssignment, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.assignments, nil
+}
+
+func (c *fakeReplicaChain) SubmitResult(ctx context.Context, result ResultSubmission) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.submitErr != nil {
+		return c.submitErr
+	}
+	c.results = append(c.results, result)
+	return nil
+}
+
+// trainingRuntime stands in for the python runtime. Tasks named "slow" run
+// until they are cancelled.
+type trainingRuntime struct{}
+
+func (trainingRuntime) Prepare(ctx context.Context, run *executor.TaskRun) error { return nil }
+
+func (trainingRuntime) Run(ctx context.Context, run *executor.TaskRun) error {
+	if run.Task.ID == "slow" {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (trainingRuntime) Collect(ctx context.Context, run *executor.TaskRun) ([]byte, error) {
+	return []byte(`{"loss":0.1}`), nil
+}
+
+func newReplicaExecutor(t *testing.T) *executor.Executor {
+	t.Helper()
+	signer, err := identity.Generate()
+	require.NoError(t, err)
+
+	e := executor.NewExecutor(nil)
+	e.SetWorkDir(t.TempDir())
+	e.SetProver("node-2", signer)
+	e.RegisterRuntime("python", trainingRuntime{})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go e.Start(ctx)
+	return e
+}
+
+func waitForReplica(t *testing.T, e *executor.Executor, taskID string, status string) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		task, err := e.TaskSnapshot(taskID)
+		return err == nil && task.Status == status
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestReplicaWorker_SubmitsResults(t *testing.T) {
+	e := newReplicaExecutor(t)
+	chain := &fakeReplicaChain{
+		assignments: []ReplicaAssignment{{TaskID: "task-1", JobID: "job-1", ModelCID: "QmModel", DatasetCID: "QmDataset"}},
+		submitErr:   errors.New("chain unavailable"),
+	}
+	worker := NewReplicaWorker("node-2", chain, e)
+	ctx := context.Background()
+
+	worker.poll(ctx)
+	task, err := e.TaskSnapshot("task-1")
+	require.NoError(t, err)
+	require.Equal(t, "training", task.TaskType)
+	require.Equal(t, "QmDataset", task.DatasetPath)
+	waitForReplica(t, e, "task-1", "completed")
+
+	// A failed submission is retried on the next poll
+	worker.poll(ctx)
+	require.Empty(t, chain.results)
+	_, err = e.TaskSnapshot("task-1")
+	require.NoError(t, err)
+
+	chain.submitErr = nil
+	worker.poll(ctx)
+	require.Len(t, chain.results, 1)
+	result := chain.results[0]
+	require.Equal(t, "task-1", result.TaskID)
+	require.Equal(t, "task-1", result.Proof.TaskID)
+	require.Equal(t, "node-2", result.Proof.NodeID)
+
+	// Submitted replicas are removed from the executor
+	_, err = e.TaskSnapshot("task-1")
+	require.Error(t, err)
+	require.Empty(t, worker.replicas)
+}
+
+func TestReplicaWorker_CancelsUnassignedReplicas(t *testing.T) {
+	e := newReplicaExecutor(t)
+	chain := &fakeReplicaChain{assignments: []ReplicaAssignment{{TaskID: "slow"}}}
+	worker := NewReplicaWorker("node-2", chain, e)
+	ctx := context.Background()
+
+	worker.poll(ctx)
+	waitForReplica(t, e, "slow", "in_progress")
+
+	// The execution was settled without this node's result
+	chain.assignments = nil
+	worker.poll(ctx)
+	waitForReplica(t, e, "slow", "cancelled")
+	worker.poll(ctx)
+	_, err := e.TaskSnapshot("slow")
+	require.Error(t, err)
+	require.Empty(t, chain.results)
+}
+
+func TestFinalMetrics(t *testing.T) {
+	require.Nil(t, finalMetrics(nil))
+	require.Equal(t, map[string]float64{"loss": 0.2, "accuracy": 0.9, "f1": 0.8}, finalMetrics([]executor.TaskEvent{
+		{Type: executor.EventLoss, Value: 0.5},
+		{Type: executor.EventEval, Metrics: map[string]float64{"accuracy": 0.7, "f1": 0.8}},
+		{Type: executor.EventLoss, Value: 0.2},
+		{Type: executor.EventEval, Metrics: map[string]float64{"accuracy": 0.9}},
+		{Type: executor.EventProgress, Progress: 1},
+	}))
+}
@@ -0,0 +1,522 @@
+// Package cache keeps artifacts fetched by CID on local disk so they are
+// downloaded once per node rather than once per task.
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/atlas/storage/tracing"
+	"github.com/atlas/storage/validation"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const tracerName = "github.com/atlas/storage/cache"
+
+// Fetcher downloads the content of a CID to a path. *manager.IPFSManager
+// implements it.
+type Fetcher interface {
+	GetFile(cid string, outputPath string) error
+}
+
+// ContextFetcher is a Fetcher whose downloads join the caller's trace
+type ContextFetcher interface {
+	GetFileContext(ctx context.Context, cid string, outputPath string) error
+}
+
+var (
+	// ErrTooLarge is returned for an artifact that does not fit in the quota
+	ErrTooLarge = errors.New("artifact is larger than the cache quota")
+
+	// ErrPinned is returned when removing an artifact that is in use
+	ErrPinned = errors.New("artifact is in use")
+)
+
+// Entry describes a cached artifact
+type Entry struct {
+	CID      string    `json:"cid"`
+	Size     int64     `json:"size"`
+	Digest   string    `json:"digest"` // SHA-256 of the content, recorded when it was fetched
+	Added    time.Time `json:"added"`
+	LastUsed time.Time `json:"last_used"`
+	Pins     int       `json:"pins"` // Handles currently using the artifact
+
+	verified bool          // Digest checked since the entry was last unpinned
+	checking chan struct{} // Closed once a running digest check ends
+}
+
+// Stats describes the cache's usage
+type Stats struct {
+	Entries   int   `json:"entries"`
+	Pinned    int   `json:"pinned"`
+	Size      int64 `json:"size"`
+	Quota     int64 `json:"quota"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	Corrupted int64 `json:"corrupted"` // Entries dropped because their digest no longer matched
+}
+
+// Cache is a content-addressed artifact cache with a disk quota. Artifacts
+// are fetched on first use, kept until the least recently used ones must
+// make room for others, and never evicted while pinned by a Handle. An
+// artifact's digest is checked whenever it is acquired while unpinned, and
+// one that no longer matches is fetched again.
+type Cache struct {
+	dir     string
+	quota   int64 // Bytes; 0 means unlimited
+	fetcher Fetcher
+
+	mu       sync.Mutex
+	entries  map[string]*Entry
+	size     int64
+	fetching map[string]*fetch
+	stats    Stats
+}
+
+// fetch is a download in progress, shared by everyone waiting for the CID
+type fetch struct {
+	done chan struct{}
+	err  error
+}
+
+// Handle pins an artifact until it is released
+type Handle struct {
+	Path string // Local path of the artifact: a file, or a directory
+
+	cache *Cache
+	cid   string
+	once  sync.Once
+}
+
+// Release unpins the artifact. It may be called more than once.
+func (h *Handle) Release() {
+	h.once.Do(func() {
+		h.cache.release(h.cid)
+	})
+}
+
+// IsCID reports whether s looks like an IPFS CID (v0 "Qm..." or base32 v1
+// "ba...") rather than a local path
+func IsCID(s string) bool {
+	if len(s) <= 2 || (s[:2] != "Qm" && s[:2] != "ba") {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Open opens the cache in dir, creating it if needed. quota bounds the
+// total size of the cached artifacts in bytes; 0 means unlimited. Entries
+// whose content is missing are dropped, as are downloads interrupted by a
+// restart.
+func Open(dir string, quota int64, fetcher Fetcher) (*Cache, error) {
+	c := &Cache{
+		dir:      dir,
+		quota:    quota,
+		fetcher:  fetcher,
+		entries:  make(map[string]*Entry),
+		fetching: make(map[string]*fetch),
+	}
+
+	if err := os.RemoveAll(c.tmpDir()); err != nil {
+		return nil, fmt.Errorf("failed to clear cache downloads: %w", err)
+	}
+	for _, d := range []string{c.objectsDir(), c.tmpDir()} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create cache directory: %w", err)
+		}
+	}
+
+	var entries []*Entry
+	data, err := os.ReadFile(c.indexPath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read cache index: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &entries); err != nil {
+			fmt.Printf("Warning: ignoring unreadable cache index %s: %v\n", c.indexPath(), err)
+			entries = nil
+		}
+	}
+	for _, e := range entries {
+		if !IsCID(e.CID) {
+			continue
+		}
+		if _, err := os.Lstat(c.objectPath(e.CID)); err != nil {
+			continue
+		}
+		e.Pins = 0
+		c.entries[e.CID] = e
+		c.size += e.Size
+	}
+
+	// Content without an index entry cannot be verified
+	objects, err := os.ReadDir(c.objectsDir())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+	for _, object := range objects {
+		if c.entries[object.Name()] == nil {
+			os.RemoveAll(filepath.Join(c.objectsDir(), object.Name()))
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.evictLocked("")
+	if err := c.saveLocked(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Acquire returns a handle on the artifact for cid, fetching it if it is
+// not cached. The artifact stays on disk until the handle is released.
+// Concurrent acquires of the same CID share one download; a download
+// outlives a caller whose ctx ends so that later callers can use it.
+func (c *Cache) Acquire(ctx context.Context, cid string) (handle *Handle, err error) {
+	if !IsCID(cid) {
+		return nil, fmt.Errorf("invalid CID: %q", cid)
+	}
+
+	missed := false
+	ctx, span := tracing.Start(ctx, tracerName, "cache.acquire", attribute.String("ipfs.cid", cid))
+	defer func() {
+		span.SetAttributes(attribute.Bool("cache.hit", !missed))
+		tracing.End(span, err)
+	}()
+
+	for {
+		c.mu.Lock()
+		if e := c.entries[cid]; e != nil {
+			if e.checking != nil {
+				checking := e.checking
+				c.mu.Unlock()
+				if err := wait(ctx, checking); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			e.Pins++
+			e.LastUsed = time.Now()
+			if e.verified {
+				c.countHitLocked(missed)
+				c.saveLocked()
+				c.mu.Unlock()
+				return c.handle(cid), nil
+			}
+
+			// First use since the entry was unpinned: check its content
+			e.checking = make(chan struct{})
+			c.mu.Unlock()
+			digest, _, err := digestPath(c.objectPath(cid))
+
+			c.mu.Lock()
+			close(e.checking)
+			e.checking = nil
+			if err == nil && digest == e.Digest {
+				e.verified = true
+				c.countHitLocked(missed)
+				c.saveLocked()
+				c.mu.Unlock()
+				return c.handle(cid), nil
+			}
+			fmt.Printf("Warning: cached artifact %s is corrupt, fetching it again\n", cid)
+			e.Pins--
+			c.stats.Corrupted++
+			c.removeLocked(e)
+			c.saveLocked()
+			c.mu.Unlock()
+			continue
+		}
+
+		f := c.fetching[cid]
+		if f == nil {
+			f = &fetch{done: make(chan struct{})}
+			c.fetching[cid] = f
+			go c.fetch(context.WithoutCancel(ctx), cid, f)
+		}
+		if !missed {
+			c.stats.Misses++
+			missed = true
+		}
+		c.mu.Unlock()
+
+		if err := wait(ctx, f.done); err != nil {
+			return nil, err
+		}
+		if f.err != nil {
+			return nil, f.err
+		}
+	}
+}
+
+// Remove deletes an artifact from the cache. Pinned artifacts cannot be
+// removed.
+func (c *Cache) Remove(cid string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := c.entries[cid]
+	if e == nil {
+		return nil
+	}
+	if e.Pins > 0 || e.checking != nil {
+		return ErrPinned
+	}
+	c.removeLocked(e)
+	return c.saveLocked()
+}
+
+// Entries describes the cached artifacts, most recently used first
+func (c *Cache) Entries() []Entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := make([]Entry, 0, len(c.entries))
+	for _, e := range c.entries {
+		entries = append(entries, Entry{
+			CID:      e.CID,
+			Size:     e.Size,
+			Digest:   e.Digest,
+			Added:    e.Added,
+			LastUsed: e.LastUsed,
+			Pins:     e.Pins,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastUsed.After(entries[j].LastUsed)
+	})
+	return entries
+}
+
+// Stats describes the cache's usage
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = len(c.entries)
+	stats.Size = c.size
+	stats.Quota = c.quota
+	for _, e := range c.entries {
+		if e.Pins > 0 {
+			stats.Pinned++
+		}
+	}
+	return stats
+}
+
+// countHitLocked counts an acquire served from the cache, unless it had to
+// wait for a fetch first
+func (c *Cache) countHitLocked(missed bool) {
+	if !missed {
+		c.stats.Hits++
+	}
+}
+
+func (c *Cache) handle(cid string) *Handle {
+	return &Handle{Path: c.objectPath(cid), cache: c, cid: cid}
+}
+
+func (c *Cache) release(cid string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := c.entries[cid]
+	if e == nil || e.Pins == 0 {
+		return
+	}
+	e.Pins--
+	if e.Pins == 0 {
+		e.verified = false
+		c.evictLocked("")
+		c.saveLocked()
+	}
+}
+
+// fetch downloads cid into the cache and records its digest
+func (c *Cache) fetch(ctx context.Context, cid string, f *fetch) {
+	entry, err := c.download(ctx, cid)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.fetching, cid)
+	if err == nil {
+		c.entries[cid] = entry
+		c.size += entry.Size
+		c.evictLocked(cid)
+		err = c.saveLocked()
+	}
+	f.err = err
+	close(f.done)
+}
+
+func (c *Cache) download(ctx context.Context, cid string) (*Entry, error) {
+	tmp, err := os.MkdirTemp(c.tmpDir(), cid+"-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create download directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	content := filepath.Join(tmp, "content")
+	get := c.fetcher.GetFile
+	if fetcher, ok := c.fetcher.(ContextFetcher); ok {
+		get = func(cid string, outputPath string) error {
+			return fetcher.GetFileContext(ctx, cid, outputPath)
+		}
+	}
+	if err := get(cid, content); err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", cid, err)
+	}
+	digest, size, err := digestPath(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash %s: %w", cid, err)
+	}
+	if c.quota > 0 && size > c.quota {
+		return nil, fmt.Errorf("failed to cache %s (%d bytes): %w", cid, size, ErrTooLarge)
+	}
+
+	os.RemoveAll(c.objectPath(cid))
+	if err := os.Rename(content, c.objectPath(cid)); err != nil {
+		return nil, fmt.Errorf("failed to store %s: %w", cid, err)
+	}
+
+	now := time.Now()
+	return &Entry{CID: cid, Size: size, Digest: digest, Added: now, LastUsed: now, verified: true}, nil
+}
+
+// evictLocked removes the least recently used unpinned entries, other than
+// keep, until the cache fits its quota. The caller must hold c.mu.
+func (c *Cache) evictLocked(keep string) {
+	if c.quota <= 0 || c.size <= c.quota {
+		return
+	}
+
+	var candidates []*Entry
+	for _, e := range c.entries {
+		if e.CID != keep && e.Pins == 0 && e.checking == nil {
+			candidates = append(candidates, e)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].LastUsed.Before(candidates[j].LastUsed)
+	})
+	for _, e := range candidates {
+		if c.size <= c.quota {
+			return
+		}
+		c.removeLocked(e)
+		c.stats.Evictions++
+	}
+	if c.size > c.quota {
+		fmt.Printf("Warning: artifact cache holds %d bytes over its %d byte quota in artifacts in use\n", c.size-c.quota, c.quota)
+	}
+}
+
+func (c *Cache) removeLocked(e *Entry) {
+	delete(c.entries, e.CID)
+	c.size -= e.Size
+	if err := os.RemoveAll(c.objectPath(e.CID)); err != nil {
+		fmt.Printf("Warning: failed to remove cached artifact %s: %v\n", e.CID, err)
+	}
+}
+
+// saveLocked writes the index. The caller must hold c.mu.
+func (c *Cache) saveLocked() error {
+	entries := make([]*Entry, 0, len(c.entries))
+	for _, e := range c.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].CID < entries[j].CID
+	})
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cache index: %w", err)
+	}
+
+	tmp := c.indexPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cache index: %w", err)
+	}
+	if err := os.Rename(tmp, c.indexPath()); err != nil {
+		return fmt.Errorf("failed to write cache index: %w", err)
+	}
+	return nil
+}
+
+func (c *Cache) indexPath() string {
+	return filepath.Join(c.dir, "index.json")
+}
+
+func (c *Cache) objectsDir() string {
+	return filepath.Join(c.dir, "objects")
+}
+
+func (c *Cache) tmpDir() string {
+	return filepath.Join(c.dir, "tmp")
+}
+
+func (c *Cache) objectPath(cid string) string {
+	return filepath.Join(c.objectsDir(), cid)
+}
+
+// digestPath returns the SHA-256 digest and size of a file, or of a
+// directory's files and their relative paths
+func digestPath(path string) (string, int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", 0, err
+	}
+	if !info.IsDir() {
+		hash, err := validation.CalculateHash(path)
+		return hash, info.Size(), err
+	}
+
+	h := sha256.New()
+	var size int64
+	err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		hash, err := validation.CalculateHash(file)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(path, file)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%s\n", filepath.ToSlash(rel), hash)
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), size, nil
+}
+
+func wait(ctx context.Context, done <-chan struct{}) error {
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
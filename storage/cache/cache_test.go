@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeFetcher serves artifacts from memory. A CID containing "Dir" is a
+// directory holding the content as model.bin.
+type fakeFetcher struct {
+	mu      sync.Mutex
+	content map[string]string
+	fetches map[string]int
+	gate    chan struct{} // If set, fetches wait for it to be closed
+}
+
+func newFakeFetcher() *fakeFetcher {
+	return &fakeFetcher{content: make(map[string]string), fetches: make(map[string]int)}
+}
+
+func (f *fakeFetcher) GetFile(cid string, outputPath string) error {
+	if f.gate != nil {
+		<-f.gate
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	content, ok := f.content[cid]
+	if !ok {
+		return fmt.Errorf("not found: %s", cid)
+	}
+	f.fetches[cid]++
+	if strings.Contains(cid, "Dir") {
+		if err := os.MkdirAll(outputPath, 0755); err != nil {
+			return err
+		}
+		outputPath = filepath.Join(outputPath, "model.bin")
+	}
+	return os.WriteFile(outputPath, []byte(content), 0644)
+}
+
+func (f *fakeFetcher) count(cid string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.fetches[cid]
+}
+
+func TestAcquire_FetchesOnce(t *testing.T) {
+	dir := t.TempDir()
+	fetcher := newFakeFetcher()
+	fetcher.content["QmFile"] = "weights"
+	fetcher.content["QmDir"] = "layers"
+
+	c, err := Open(dir, 0, fetcher)
+	require.NoError(t, err)
+
+	h, err := c.Acquire(context.Background(), "QmFile")
+	require.NoError(t, err)
+	data, err := os.ReadFile(h.Path)
+	require.NoError(t, err)
+	require.Equal(t, "weights", string(data))
+	h.Release()
+	h.Release()
+
+	h, err = c.Acquire(context.Background(), "QmDir")
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(h.Path, "model.bin"))
+	require.Equal(t, 1, c.Stats().Pinned)
+	h.Release()
+
+	// The index survives a restart
+	c, err = Open(dir, 0, fetcher)
+	require.NoError(t, err)
+	h, err = c.Acquire(context.Background(), "QmFile")
+	require.NoError(t, err)
+	h.Release()
+
+	require.Equal(t, 1, fetcher.count("QmFile"))
+	require.Equal(t, 1, fetcher.count("QmDir"))
+	stats := c.Stats()
+	require.Equal(t, 2, stats.Entries)
+	require.Equal(t, int64(len("weights")+len("layers")), stats.Size)
+	require.Equal(t, int64(1), stats.Hits)
+}
+
+func TestAcquire_SharesConcurrentFetch(t *testing.T) {
+	fetcher := newFakeFetcher()
+	fetcher.content["QmShared"] = "weights"
+	fetcher.gate = make(chan struct{})
+	c, err := Open(t.TempDir(), 0, fetcher)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			h, err := c.Acquire(context.Background(), "QmShared")
+			if err == nil {
+				h.Release()
+			}
+			errs[i] = err
+		}(i)
+	}
+	close(fetcher.gate)
+	wg.Wait()
+
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	require.Equal(t, 1, fetcher.count("QmShared"))
+	stats := c.Stats()
+	require.Equal(t, int64(4), stats.Hits+stats.Misses)
+}
+
+func TestEviction(t *testing.T) {
+	fetcher := newFakeFetcher()
+	for _, cid := range []string{"QmA", "QmB", "QmC", "QmD"} {
+		fetcher.content[cid] = "1234"
+	}
+	c, err := Open(t.TempDir(), 10, fetcher)
+	require.NoError(t, err)
+
+	use := func(cid string) {
+		h, err := c.Acquire(context.Background(), cid)
+		require.NoError(t, err)
+		h.Release()
+	}
+	use("QmA")
+	use("QmB")
+	use("QmA")
+
+	// B is the least recently used
+	use("QmC")
+	cids := func() []string {
+		var cids []string
+		for _, e := range c.Entries() {
+			cids = append(cids, e.CID)
+		}
+		return cids
+	}
+	require.Equal(t, []string{"QmC", "QmA"}, cids())
+
+	// Pinned artifacts stay even when they are the oldest
+	pinned, err := c.Acquire(context.Background(), "QmA")
+	require.NoError(t, err)
+	use("QmD")
+	require.ElementsMatch(t, []string{"QmA", "QmD"}, cids())
+	require.ErrorIs(t, c.Remove("QmA"), ErrPinned)
+	pinned.Release()
+	require.NoError(t, c.Remove("QmA"))
+	require.Equal(t, int64(2), c.Stats().Evictions)
+
+	fetcher.content["QmHuge"] = "0123456789a"
+	_, err = c.Acquire(context.Background(), "QmHuge")
+	require.ErrorIs(t, err, ErrTooLarge)
+}
+
+func TestAcquire_RefetchesCorruptArtifact(t *testing.T) {
+	fetcher := newFakeFetcher()
+	fetcher.content["QmDir"] = "layers"
+	c, err := Open(t.TempDir(), 0, fetcher)
+	require.NoError(t, err)
+
+	h, err := c.Acquire(context.Background(), "QmDir")
+	require.NoError(t, err)
+	h.Release()
+	require.NoError(t, os.WriteFile(filepath.Join(h.Path, "model.bin"), []byte("tampered"), 0644))
+
+	h, err = c.Acquire(context.Background(), "QmDir")
+	require.NoError(t, err)
+	defer h.Release()
+	data, err := os.ReadFile(filepath.Join(h.Path, "model.bin"))
+	require.NoError(t, err)
+	require.Equal(t, "layers", string(data))
+	require.Equal(t, 2, fetcher.count("QmDir"))
+	require.Equal(t, int64(1), c.Stats().Corrupted)
+}
+
+func TestIsCID(t *testing.T) {
+	require.True(t, IsCID("QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG"))
+	require.True(t, IsCID("bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi"))
+	require.False(t, IsCID("/models/llama"))
+	require.False(t, IsCID("base/model.pt"))
+	require.False(t, IsCID("Qm"))
+}
@@ -0,0 +1,153 @@
+// Package identity holds a node's ed25519 key pair, which it registers with
+// x/compute and signs its heartbeats, checkpoints, proofs and gradient
+// messages with.
+//
+// Every signature covers a domain string naming the kind of message, a NUL
+// byte and the message, so a signature over one kind of message is never
+// valid for another. Signatures and public keys are base64 encoded.
+package identity
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Domains of the messages nodes sign
+const (
+	DomainHeartbeat  = "atlas/heartbeat/v1"
+	DomainCheckpoint = "atlas/checkpoint/v1"
+	DomainProof      = "atlas/proof/v1"
+	DomainGradients  = "atlas/gradients/v1"
+	DomainSegment    = "atlas/segment/v1"
+)
+
+// ErrInvalidSignature is returned when a signature does not match the
+// message and key
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// KeyResolver returns the registered public key of a node, e.g. from
+// x/compute
+type KeyResolver func(nodeID string) (string, error)
+
+// Identity is a node's key pair
+type Identity struct {
+	privateKey ed25519.PrivateKey
+}
+
+// Generate creates a new random identity
+func Generate() (*Identity, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return &Identity{privateKey: privateKey}, nil
+}
+
+// DefaultKeyPath is where the node keeps its key unless configured
+// otherwise: ~/.atlas/node.key
+func DefaultKeyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".atlas", "node.key")
+	}
+	return filepath.Join(home, ".atlas", "node.key")
+}
+
+// LoadOrCreate reads the key at path, a PKCS #8 PEM file, or creates one
+// readable only by the current user if there is none
+func LoadOrCreate(path string) (*Identity, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return parse(data)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read node key: %w", err)
+	}
+
+	id, err := Generate()
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := x509.MarshalPKCS8PrivateKey(id.privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode node key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create key directory: %w", err)
+	}
+	// O_EXCL so two nodes starting at once cannot replace each other's key
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create node key: %w", err)
+	}
+	defer file.Close()
+	if err := pem.Encode(file, &pem.Block{Type: "PRIVATE KEY", Bytes: encoded}); err != nil {
+		return nil, fmt.Errorf("failed to write node key: %w", err)
+	}
+	return id, nil
+}
+
+func parse(data []byte) (*Identity, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("node key is not a PEM private key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse node key: %w", err)
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("node key is a %T, not an ed25519 key", key)
+	}
+	return &Identity{privateKey: privateKey}, nil
+}
+
+// PublicKey returns the base64-encoded public key registered on chain
+func (id *Identity) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(id.privateKey.Public().(ed25519.PublicKey))
+}
+
+// NodeID derives a node ID from the public key, for nodes not given one
+func (id *Identity) NodeID() string {
+	sum := sha256.Sum256(id.privateKey.Public().(ed25519.PublicKey))
+	return "node-" + hex.EncodeToString(sum[:8])
+}
+
+// Sign signs message in domain
+func (id *Identity) Sign(domain string, message []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(id.privateKey, signedBytes(domain, message)))
+}
+
+// Verify checks a signature made with Sign against a base64-encoded public
+// key
+func Verify(publicKey string, domain string, message []byte, signature string) error {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid public key %q", publicKey)
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return ErrInvalidSignature
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), signedBytes(domain, message), sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func signedBytes(domain string, message []byte) []byte {
+	signed := make([]byte, 0, len(domain)+1+len(message))
+	signed = append(signed, domain...)
+	signed = append(signed, 0)
+	return append(signed, message...)
+}
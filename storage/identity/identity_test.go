@@ -0,0 +1,48 @@
+package identity
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadOrCreate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "node.key")
+
+	id, err := LoadOrCreate(path)
+	require.NoError(t, err)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// The key persists across restarts
+	again, err := LoadOrCreate(path)
+	require.NoError(t, err)
+	require.Equal(t, id.PublicKey(), again.PublicKey())
+	require.Equal(t, id.NodeID(), again.NodeID())
+	require.True(t, strings.HasPrefix(id.NodeID(), "node-"))
+
+	require.NoError(t, os.WriteFile(path, []byte("not a key"), 0600))
+	_, err = LoadOrCreate(path)
+	require.Error(t, err)
+}
+
+func TestSignVerify(t *testing.T) {
+	id, err := Generate()
+	require.NoError(t, err)
+	other, err := Generate()
+	require.NoError(t, err)
+
+	message := []byte("task-1:3:42:QmCheckpoint")
+	signature := id.Sign(DomainCheckpoint, message)
+	require.NoError(t, Verify(id.PublicKey(), DomainCheckpoint, message, signature))
+
+	require.ErrorIs(t, Verify(id.PublicKey(), DomainCheckpoint, []byte("task-1:3:43:QmCheckpoint"), signature), ErrInvalidSignature)
+	require.ErrorIs(t, Verify(id.PublicKey(), DomainProof, message, signature), ErrInvalidSignature)
+	require.ErrorIs(t, Verify(other.PublicKey(), DomainCheckpoint, message, signature), ErrInvalidSignature)
+	require.ErrorIs(t, Verify(id.PublicKey(), DomainCheckpoint, message, "bm90IGEgc2lnbmF0dXJl"), ErrInvalidSignature)
+	require.Error(t, Verify("bad key", DomainCheckpoint, message, signature))
+}
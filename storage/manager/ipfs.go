@@ -5,9 +5,13 @@ import (
 	"fmt"
 	"os"
 	"time"
+	"github.com/atlas/storage/tracing"
 	"github.com/ipfs/go-ipfs-api"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+const tracerName = "github.com/atlas/storage/manager"
+
 type IPFSManager struct {
 	api          *api.Shell
 	fallbackAPIs []*api.Shell // Fallback IPFS nodes
@@ -49,13 +53,31 @@ func (m *IPFSManager) checkCID(ctx context.Context, apiShell *api.Shell, cid str
 }
 
 func (m *IPFSManager) AddFile(filePath string) (string, error) {
+	return m.AddFileContext(context.Background(), filePath)
+}
+
+// AddFileContext uploads a file like AddFile, recording the upload as a
+// span of the trace in ctx
+func (m *IPFSManager) AddFileContext(ctx context.Context, filePath string) (cid string, err error) {
+	_, span := tracing.Start(ctx, tracerName, "ipfs.add", attribute.String("ipfs.path", filePath))
+	defer func() {
+		span.SetAttributes(attribute.String("ipfs.cid", cid))
+		tracing.End(span, err)
+	}()
+
 	file, err := os.Open(filePath)
 	if err != nil {
 		return "", err
 	}
 	defer file.Close()
 
-	cid, err := m.api.Add(file)
+	var size int64
+	if info, err := file.Stat(); err == nil {
+		size = info.Size()
+	}
+	span.SetAttributes(attribute.Int64("ipfs.bytes", size))
+	cid, err = m.api.Add(file)
+	recordUpload(size, err)
 	if err != nil {
 		return "", err
 	}
@@ -67,7 +89,21 @@ func (m *IPFSManager) GetFile(cid string, outputPath string) error {
 	return m.GetFileWithFallback(cid, outputPath)
 }
 
+// GetFileContext downloads a file like GetFile, recording the download as
+// a span of the trace in ctx
+func (m *IPFSManager) GetFileContext(ctx context.Context, cid string, outputPath string) error {
+	_, span := tracing.Start(ctx, tracerName, "ipfs.get", attribute.String("ipfs.cid", cid))
+	err := m.getFile(cid, outputPath)
+	span.SetAttributes(attribute.Int64("ipfs.bytes", recordDownload(outputPath, err)))
+	tracing.End(span, err)
+	return err
+}
+
 func (m *IPFSManager) GetFileWithFallback(cid string, outputPath string) error {
+	return m.GetFileContext(context.Background(), cid, outputPath)
+}
+
+func (m *IPFSManager) getFile(cid string, outputPath string) error {
 	var lastErr error
 	
 	err := m.api.Get(cid, outputPath)
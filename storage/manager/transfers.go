@@ -0,0 +1,60 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// TransferStats counts data moved to and from IPFS by every IPFSManager in
+// the process
+type TransferStats struct {
+	BytesDownloaded int64 `json:"bytes_downloaded"`
+	BytesUploaded   int64 `json:"bytes_uploaded"`
+	DownloadErrors  int64 `json:"download_errors"`
+	UploadErrors    int64 `json:"upload_errors"`
+}
+
+var transfers struct {
+	bytesDownloaded atomic.Int64
+	bytesUploaded   atomic.Int64
+	downloadErrors  atomic.Int64
+	uploadErrors    atomic.Int64
+}
+
+// Transfers returns the transfer counters since the process started
+func Transfers() TransferStats {
+	return TransferStats{
+		BytesDownloaded: transfers.bytesDownloaded.Load(),
+		BytesUploaded:   transfers.bytesUploaded.Load(),
+		DownloadErrors:  transfers.downloadErrors.Load(),
+		UploadErrors:    transfers.uploadErrors.Load(),
+	}
+}
+
+// recordDownload counts a finished download of path, a file or directory,
+// and returns its size
+func recordDownload(path string, err error) int64 {
+	if err != nil {
+		transfers.downloadErrors.Add(1)
+		return 0
+	}
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	transfers.bytesDownloaded.Add(size)
+	return size
+}
+
+// recordUpload counts a finished upload of size bytes
+func recordUpload(size int64, err error) {
+	if err != nil {
+		transfers.uploadErrors.Add(1)
+		return
+	}
+	transfers.bytesUploaded.Add(size)
+}
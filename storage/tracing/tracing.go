@@ -0,0 +1,165 @@
+// Package tracing sets up OpenTelemetry tracing for Atlas components and
+// carries trace context across the boundaries spans cannot follow on their
+// own: task metadata, pubsub messages and child processes.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters supported by Setup
+const (
+	ExporterNone = "none"
+	ExporterOTLP = "otlp" // OTLP over HTTP, e.g. to an OpenTelemetry Collector or Jaeger
+	ExporterFile = "file" // One JSON span per line, for collecting traces without a backend
+)
+
+// Config selects where spans are exported
+type Config struct {
+	ServiceName string
+	Exporter    string  // ExporterNone, ExporterOTLP or ExporterFile
+	Endpoint    string  // OTLP host:port (default: OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4318)
+	Insecure    bool    // Send OTLP over plain HTTP
+	File        string  // Output of the file exporter
+	SampleRatio float64 // Fraction of new traces recorded; 0 records all
+}
+
+// propagator writes and reads W3C trace context (traceparent, tracestate)
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Setup installs the global tracer provider and propagator for config. The
+// returned function flushes buffered spans and stops the exporter. With
+// ExporterNone spans are not recorded, but trace context received from
+// others is still passed on.
+func Setup(ctx context.Context, config Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagator)
+
+	var exporter sdktrace.SpanExporter
+	switch config.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		var options []otlptracehttp.Option
+		if config.Endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(config.Endpoint))
+		}
+		if config.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		otlp, err := otlptracehttp.New(ctx, options...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+		exporter = otlp
+	case ExporterFile:
+		if config.File == "" {
+			return nil, fmt.Errorf("the file exporter needs an output file")
+		}
+		if err := os.MkdirAll(filepath.Dir(config.File), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create trace directory: %w", err)
+		}
+		file, err := os.OpenFile(config.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open trace file: %w", err)
+		}
+		stdout, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to create file exporter: %w", err)
+		}
+		exporter = &fileExporter{SpanExporter: stdout, file: file}
+	default:
+		return nil, fmt.Errorf("unknown trace exporter: %s", config.Exporter)
+	}
+
+	sampler := sdktrace.AlwaysSample()
+	if config.SampleRatio > 0 && config.SampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(config.SampleRatio)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", config.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// fileExporter closes its output when it is shut down
+type fileExporter struct {
+	sdktrace.SpanExporter
+	file *os.File
+}
+
+func (e *fileExporter) Shutdown(ctx context.Context) error {
+	err := e.SpanExporter.Shutdown(ctx)
+	if closeErr := e.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Start starts a span with the global tracer provider. Packages pass their
+// import path as the tracer name.
+func Start(ctx context.Context, tracer string, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracer).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// End marks span failed if err is set and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject returns the trace context of ctx as string pairs for task metadata
+// and messages, or nil if ctx carries none
+func Inject(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+// Extract returns ctx with the remote trace context found in carrier, so
+// spans started from it continue that trace
+func Extract(ctx context.Context, carrier map[string]string) context.Context {
+	if len(carrier) == 0 {
+		return ctx
+	}
+	return propagator.Extract(ctx, propagation.MapCarrier(carrier))
+}
+
+// ExtractHeader returns ctx with the trace context sent in HTTP headers
+func ExtractHeader(ctx context.Context, header http.Header) context.Context {
+	return propagator.Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// Environ returns the trace context of ctx as TRACEPARENT and TRACESTATE
+// environment variables for a child process
+func Environ(ctx context.Context) []string {
+	var env []string
+	for key, value := range Inject(ctx) {
+		env = append(env, strings.ToUpper(key)+"="+value)
+	}
+	return env
+}
@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestInjectExtract(t *testing.T) {
+	require.Nil(t, Inject(context.Background()))
+
+	provider := sdktrace.NewTracerProvider()
+	ctx, span := provider.Tracer("test").Start(context.Background(), "send")
+	defer span.End()
+
+	carrier := Inject(ctx)
+	require.Contains(t, carrier, "traceparent")
+
+	remote := trace.SpanContextFromContext(Extract(context.Background(), carrier))
+	require.True(t, remote.IsRemote())
+	require.Equal(t, span.SpanContext().TraceID(), remote.TraceID())
+	require.Equal(t, span.SpanContext().SpanID(), remote.SpanID())
+
+	require.Len(t, Environ(ctx), 1)
+	require.Contains(t, Environ(ctx)[0], "TRACEPARENT=00-"+span.SpanContext().TraceID().String())
+}
+
+func TestSetup_FileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces", "traces.jsonl")
+	stop, err := Setup(context.Background(), Config{ServiceName: "test", Exporter: ExporterFile, File: path})
+	require.NoError(t, err)
+
+	ctx, parent := Start(context.Background(), "test", "parent")
+	_, child := Start(ctx, "test", "child")
+	End(child, errors.New("failed"))
+	End(parent, nil)
+	require.NoError(t, stop(context.Background()))
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var spans []struct {
+		Name   string
+		Status struct{ Code string }
+	}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var span struct {
+			Name   string
+			Status struct{ Code string }
+		}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &span))
+		spans = append(spans, span)
+	}
+	require.Len(t, spans, 2)
+	require.Equal(t, "child", spans[0].Name)
+	require.Equal(t, "Error", spans[0].Status.Code)
+	require.Equal(t, "parent", spans[1].Name)
+
+	_, err = Setup(context.Background(), Config{Exporter: "jaeger"})
+	require.Error(t, err)
+}